@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/audit"
+)
+
+func AddAuditAPI(router gin.IRouter, audit_store audit.Store) {
+	// Provide an API for admins to see who changed what
+	auditAPI := router.Group("/api/audit", RequireAdmin())
+	{
+		// GET /api/audit - get audit log entries, newest first, possibly with filters
+		// e.g. /api/audit?recipe_id=ID
+		// e.g. /api/audit?actor=alice&action=delete&since=2023-01-01&limit=20
+		auditAPI.GET("/", func(c *gin.Context) {
+			// Read the filters from the query string
+			filter := audit.Filter{
+				Actor:    c.Query("actor"),
+				Action:   c.Query("action"),
+				RecipeID: c.Query("recipe_id"),
+			}
+			var err error
+			if value := c.Query("since"); value != "" {
+				if filter.Since, err = parseDate(value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'since': " + err.Error()})
+					return
+				}
+			}
+			if value := c.Query("until"); value != "" {
+				if filter.Until, err = parseDate(value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'until': " + err.Error()})
+					return
+				}
+			}
+			if value := c.Query("limit"); value != "" {
+				if filter.Limit, err = strconv.Atoi(value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit': " + err.Error()})
+					return
+				}
+			}
+			if err := filter.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Get the matching entries
+			entries, err := audit_store.Query(c.Request.Context(), filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, entries)
+		})
+	}
+}
+
+// RecordClientIP records the IP address of the client making each request, so that
+// the audit log can note where changes came from
+func RecordClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithClientIP(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// sessionCookie is the name of the cookie holding the session token in browsers
+const sessionCookie = "recipes_session"
+
+// userKey is the key under which Authenticate stores the logged-in user in the context
+const userKey = "user"
+
+// scopeKey is the key under which Authenticate stores the scope of the API token used
+// for the request, if any. Requests made with a session have no scope limits.
+const scopeKey = "scope"
+
+// tokenIDKey is the key under which Authenticate stores the ID of the API token used for
+// the request, if any
+const tokenIDKey = "token_id"
+
+// Credentials is the body of register and login requests
+type Credentials struct {
+	Username string
+	Password string
+}
+
+func AddAuthAPI(router gin.IRouter, user_store users.UserStore) {
+	// Provide an API for creating accounts and logging in and out
+	authAPI := router.Group("/api/auth")
+	{
+		// POST /api/auth/register - create a new account
+		authAPI.POST("/register", func(c *gin.Context) {
+			// Get the credentials from the request
+			var credentials Credentials
+			if err := c.BindJSON(&credentials); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Make sure the credentials are acceptable
+			if err := users.ValidateUsername(credentials.Username); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := users.ValidatePassword(credentials.Password); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Create the user
+			user, err := users.Register(c.Request.Context(), user_store, credentials.Username, credentials.Password)
+			if errors.Is(err, users.ErrUsernameTaken) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "User registered successfully", "user": user})
+		})
+
+		// POST /api/auth/login - start a session. The session token is set as a cookie
+		// for browsers and returned in the body for other clients, which should send it
+		// as "Authorization: Bearer TOKEN".
+		authAPI.POST("/login", func(c *gin.Context) {
+			// Get the credentials from the request
+			var credentials Credentials
+			if err := c.BindJSON(&credentials); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Check them and start a session
+			token, user, err := users.Login(c.Request.Context(), user_store, credentials.Username, credentials.Password)
+			if errors.Is(err, users.ErrInvalidCredentials) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(sessionCookie, token, int(users.SessionDuration.Seconds()), "/", "",
+				c.Request.TLS != nil, true)
+			c.JSON(http.StatusOK, gin.H{"message": "Logged in successfully", "token": token, "user": user})
+		})
+
+		// POST /api/auth/logout - end the current session
+		authAPI.POST("/logout", func(c *gin.Context) {
+			if token, _ := sessionToken(c); token != "" {
+				if err := users.Logout(c.Request.Context(), user_store, token); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+			c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		})
+
+		// GET /api/auth/me - get the logged-in user
+		authAPI.GET("/me", RequireUser(), func(c *gin.Context) {
+			user, _ := currentUser(c)
+			c.JSON(http.StatusOK, user)
+		})
+	}
+}
+
+// Authenticate looks up the user for the session token sent with each request (if
+// any) and makes it available to later handlers, including as the actor and viewer for
+// the recipe manager. It does not reject anonymous requests; see RequireUser for that.
+func Authenticate(user_store users.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Until we know better, the request is from an anonymous visitor, who can only
+		// see public recipes
+		c.Request = c.Request.WithContext(recipes.WithViewer(c.Request.Context(), recipes.Viewer{}))
+
+		token, fromHeader := sessionToken(c)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		// Scripts can use a personal API token instead of logging in
+		if fromHeader && users.IsAPIToken(token) {
+			user, apiToken, err := users.AuthenticateAPIToken(c.Request.Context(), user_store, token)
+			if errors.Is(err, users.ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or revoked API token"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.Set(scopeKey, apiToken.Scope)
+			c.Set(tokenIDKey, apiToken.ID.Hex())
+			setUser(c, user, apiToken.Scope)
+			c.Next()
+			return
+		}
+
+		// Look up the user for the token. Scripts that send a bad token should find
+		// out right away, but browsers with a stale cookie just carry on anonymously.
+		user, err := users.Authenticate(c.Request.Context(), user_store, token)
+		if errors.Is(err, users.ErrNotFound) && !fromHeader {
+			c.Next()
+			return
+		}
+		if errors.Is(err, users.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired session"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Remember the user for the rest of the request
+		setUser(c, user, users.ScopeAdmin)
+		c.Next()
+	}
+}
+
+// setUser records the logged-in user for the rest of the request, for handlers and the
+// recipe manager
+func setUser(c *gin.Context, user users.User, scope string) {
+	c.Set(userKey, user)
+	ctx := recipes.WithActor(c.Request.Context(), user.Username)
+	ctx = recipes.WithViewer(ctx, viewerFor(user, scope))
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// viewerFor describes what the user is allowed to see and change in the recipe
+// manager. Admins only get their extra powers with a session or an admin-scoped token.
+func viewerFor(user users.User, scope string) recipes.Viewer {
+	viewer := recipes.Viewer{
+		UserID: user.ID.Hex(),
+		Admin:  user.Admin && users.ScopeAllows(scope, users.ScopeAdmin),
+	}
+	if user.InHousehold() {
+		viewer.HouseholdID = user.HouseholdID.Hex()
+	}
+	return viewer
+}
+
+// RequireUser rejects requests that are not from a logged-in user
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := currentUser(c); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserForWrites lets anyone read, but rejects requests that change anything
+// unless they are from a logged-in user
+func RequireUserForWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+		default:
+			RequireUser()(c)
+		}
+	}
+}
+
+// RequireAdmin rejects requests that are not from an admin, using either a session or
+// an admin-scoped API token
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+		if !user.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		RequireScope(users.ScopeAdmin)(c)
+	}
+}
+
+// RequireScope rejects requests made with an API token whose scope doesn't include the
+// given scope. Requests made with a session are let through.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenScope, ok := c.Get(scopeKey); ok && !users.ScopeAllows(tokenScope.(string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API token scope does not allow this request"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScopeForWrites lets API tokens with any scope read, but rejects requests that
+// change anything unless the token has read-write scope or more
+func RequireScopeForWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			RequireScope(users.ScopeRead)(c)
+		default:
+			RequireScope(users.ScopeReadWrite)(c)
+		}
+	}
+}
+
+// currentUser returns the user that Authenticate found for this request, if any
+func currentUser(c *gin.Context) (users.User, bool) {
+	value, ok := c.Get(userKey)
+	if !ok {
+		return users.User{}, false
+	}
+	user, ok := value.(users.User)
+	return user, ok
+}
+
+// sessionToken returns the session token sent with the request, either as a bearer
+// token (in which case fromHeader is true) or as a cookie
+func sessionToken(c *gin.Context) (token string, fromHeader bool) {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer "), true
+	}
+	token, _ = c.Cookie(sessionCookie)
+	return token, false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/backup"
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+func AddBackupAPI(router gin.IRouter, recipe_manager recipes.RecipeManager, user_store users.UserStore, audit_store audit.Store) {
+	// Provide an API for admins to back up the whole library and restore it, including
+	// every user's password hash. The same archives are made and read by the backup and
+	// restore commands.
+	backupAPI := router.Group("/api/admin", RequireAdmin())
+	{
+		// GET /api/admin/backup - download a backup of every recipe (including the
+		// trash), household, and user as a gzipped tar archive
+		backupAPI.GET("/backup", func(c *gin.Context) {
+			// A large library can take longer to send than the server's write timeout, so
+			// lift it for this response
+			http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+			// Take the backup before sending anything, so that failures can still be
+			// reported, then stream it from disk
+			snapshot, err := backup.TakeSnapshot(c.Request.Context(), recipe_manager, user_store)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+			defer snapshot.Close()
+
+			filename := backupFilename(snapshot.Manifest.CreatedAt)
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+			c.Header("Content-Type", "application/gzip")
+			c.Status(http.StatusOK)
+			if err := snapshot.Write(c.Writer); err != nil {
+				// It's too late to send an error, but the archive is cut short
+				c.Error(err)
+			}
+		})
+
+		// POST /api/admin/restore - check a backup archive sent as the request body and
+		// load it into the library, merging it with what is there or replacing the
+		// recipes altogether. Nothing is changed unless the whole archive is valid.
+		// e.g. /api/admin/restore?mode=replace
+		backupAPI.POST("/restore", func(c *gin.Context) {
+			mode, err := backup.ParseMode(c.DefaultQuery("mode", string(backup.ModeMerge)))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'mode': " + err.Error()})
+				return
+			}
+
+			// Archives can take longer to upload than the server's read timeout
+			http.NewResponseController(c.Writer).SetReadDeadline(time.Time{})
+			archive, err := backup.Read(c.Request.Body)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			result, err := backup.Restore(c.Request.Context(), archive, recipe_manager, user_store, audit_store, mode)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": fmt.Sprintf("Restored %d recipes, %d households, and %d users",
+					result.Recipes.Restored, result.Households.Restored, result.Users.Restored),
+				"result": result,
+			})
+		})
+	}
+}
+
+// backupFilename names a backup made at the given time
+func backupFilename(created time.Time) string {
+	return "recipes-backup-" + created.UTC().Format("20060102T150405Z") + ".tar.gz"
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/dawsonc/recipes/src/backup"
+	"github.com/dawsonc/recipes/src/config"
+	"github.com/dawsonc/recipes/src/logging"
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// backupActor is who the audit log says made the changes in a restore
+const backupActor = "restore"
+
+// runBackupCommand backs up or restores the library from the command line instead of
+// starting the server, and returns the exit code:
+//
+//	recipes backup FILE [settings]
+//	recipes restore [-mode merge|replace] FILE [settings]
+//
+// FILE can be - for standard output or input. The settings are the same as the server's,
+// and say which database to use.
+func runBackupCommand(command string, args []string) int {
+	flags := flag.NewFlagSet("recipes "+command, flag.ContinueOnError)
+	mode := flags.String("mode", string(backup.ModeMerge), "merge with the library, or replace its recipes (restore only)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: recipes %s [-mode merge|replace] FILE [settings]\n", command)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	restoreMode, err := backup.ParseMode(*mode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Load the settings the same way the server does
+	path := flags.Arg(0)
+	cfg, err := config.Load(flags.Args()[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	logger := logging.New(os.Stderr, level)
+
+	// Stop on Ctrl-C, and connect to the database
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	data, err := connectBackend(cfg.Backend, logging.SlowQueryObserver(logger, cfg.SlowQueryThreshold))
+	if err != nil {
+		logger.Error("failed to connect to the backend", "error", err)
+		return 1
+	}
+	defer data.Close()
+
+	switch command {
+	case "backup":
+		err = writeBackup(ctx, path, data)
+	case "restore":
+		err = readBackup(recipes.WithActor(ctx, backupActor), path, data, restoreMode)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		logger.Error("failed to "+command, "error", err)
+		return 1
+	}
+	return 0
+}
+
+// writeBackup backs up the library to the file at the given path. The archive is only
+// moved into place once it is complete, so a failed backup never looks like a good one.
+func writeBackup(ctx context.Context, path string, data *backend) error {
+	if path == "-" {
+		_, err := backup.Write(ctx, os.Stdout, data.recipe_manager, data.user_store)
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".recipes-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	manifest, err := backup.Write(ctx, file, data.recipe_manager, data.user_store)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Backed up %d recipes, %d households, and %d users to %s\n",
+		manifest.Recipes, manifest.Households, manifest.Users, path)
+	return nil
+}
+
+// readBackup checks the backup in the file at the given path, and then restores it
+func readBackup(ctx context.Context, path string, data *backend, mode backup.Mode) error {
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	archive, err := backup.Read(input)
+	if err != nil {
+		return err
+	}
+	result, err := backup.Restore(ctx, archive, data.recipe_manager, data.user_store, data.audit_store, mode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Restored %d recipes (%d skipped, %d removed), %d households (%d skipped), and %d users (%d skipped)\n",
+		result.Recipes.Restored, result.Recipes.Skipped, result.Removed,
+		result.Households.Restored, result.Households.Skipped,
+		result.Users.Restored, result.Users.Skipped)
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// backupRecipeManager is a fake recipe manager that exports and imports recipes in a slice
+type backupRecipeManager struct {
+	recipes.RecipeManager
+	recipes []recipes.Recipe
+}
+
+func (m *backupRecipeManager) ExportRecipes(ctx context.Context, fn func(recipes.Recipe) error) error {
+	for _, recipe := range m.recipes {
+		if err := fn(recipe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *backupRecipeManager) GetTrash(ctx context.Context) ([]recipes.Recipe, error) {
+	return nil, nil
+}
+
+func (m *backupRecipeManager) ImportRecipes(ctx context.Context, batch []recipes.Recipe) error {
+	m.recipes = append(m.recipes, batch...)
+	return nil
+}
+
+// backupUserStore is a fake user store that lists and imports users in a slice
+type backupUserStore struct {
+	users.UserStore
+	users []users.User
+}
+
+func (s *backupUserStore) ListUsers(ctx context.Context) ([]users.User, error) {
+	return s.users, nil
+}
+
+func (s *backupUserStore) ImportUsers(ctx context.Context, batch []users.User) error {
+	s.users = append(s.users, batch...)
+	return nil
+}
+
+func (s *backupUserStore) ListHouseholds(ctx context.Context) ([]users.Household, error) {
+	return nil, nil
+}
+
+func (s *backupUserStore) ImportHouseholds(ctx context.Context, batch []users.Household) error {
+	return nil
+}
+
+// backupAuditStore is a fake audit store that keeps entries in a slice
+type backupAuditStore struct {
+	audit.Store
+	entries []audit.Entry
+}
+
+func (s *backupAuditStore) Record(ctx context.Context, entry audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// TestBackupAPI tests that admins can download a backup and restore it into another
+// library, even though it is larger than other requests may be
+func TestBackupAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	alice := users.User{ID: primitive.NewObjectID(), Username: "alice", PasswordHash: "hash", Admin: true}
+	bob := users.User{ID: primitive.NewObjectID(), Username: "bob"}
+	manager := &backupRecipeManager{recipes: []recipes.Recipe{{ID: primitive.NewObjectID(), Name: "Soup"}}}
+	store := &backupUserStore{users: []users.User{alice, bob}}
+	audit_store := &backupAuditStore{}
+	newBackupRouter := func(manager recipes.RecipeManager, store users.UserStore) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if c.GetHeader("X-User") == "bob" {
+				c.Set(userKey, bob)
+			} else {
+				c.Set(userKey, alice)
+			}
+		})
+		router.Use(LimitBodySize(10, map[string]int{"/api/admin/restore": 1 << 20}))
+		AddBackupAPI(router, manager, store, audit_store)
+		return router
+	}
+	request := func(router *gin.Engine, method, path string, body []byte, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/gzip")
+		req.Header.Set("X-User", user)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	router := newBackupRouter(manager, store)
+
+	// Only admins can back up the library
+	if response := request(router, http.MethodGet, "/api/admin/backup", nil, "bob"); response.Code != http.StatusForbidden {
+		t.Errorf("Expected bob to be forbidden, got %d", response.Code)
+	}
+	response := request(router, http.MethodGet, "/api/admin/backup", nil, "alice")
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != "application/gzip" ||
+		!strings.Contains(response.Header().Get("Content-Disposition"), "recipes-backup-") {
+		t.Fatalf("Expected a backup archive, got %d %q", response.Code, response.Header())
+	}
+	archive := response.Body.Bytes()
+
+	// Bad modes and archives are rejected
+	if response := request(router, http.MethodPost, "/api/admin/restore?mode=overwrite", archive, "alice"); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown mode to be rejected, got %d", response.Code)
+	}
+	if response := request(router, http.MethodPost, "/api/admin/restore", []byte("not an archive"), "alice"); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected a bad archive to be rejected, got %d", response.Code)
+	}
+
+	// And the backup can be restored into an empty library
+	restored, restoredUsers := &backupRecipeManager{}, &backupUserStore{}
+	router = newBackupRouter(restored, restoredUsers)
+	response = request(router, http.MethodPost, "/api/admin/restore?mode=merge", archive, "alice")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "Restored 1 recipes, 0 households, and 2 users") {
+		t.Fatalf("Expected the backup to be restored, got %d: %s", response.Code, response.Body)
+	}
+	if len(restored.recipes) != 1 || restored.recipes[0].ID != manager.recipes[0].ID || len(restoredUsers.users) != 2 || restoredUsers.users[0].PasswordHash != "hash" {
+		t.Errorf("Expected the library as it was, got %+v and %+v", restored.recipes, restoredUsers.users)
+	}
+	if len(audit_store.entries) != 3 || audit_store.entries[2].Action != audit.ActionRestore {
+		t.Errorf("Expected the restored users and the restore to be recorded, got %+v", audit_store.entries)
+	}
+}
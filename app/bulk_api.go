@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// BulkResult is the outcome of importing one recipe with POST /api/recipes/bulk: its
+// ID if it was added, or why it wasn't
+type BulkResult struct {
+	// Index is the position of the recipe in the request, counting from zero
+	Index int
+	ID    string
+	Error string
+}
+
+// ndjsonTypes are the content types that mean a body has one JSON value on each line
+var ndjsonTypes = []string{"application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines"}
+
+func AddBulkAPI(router gin.IRouter, recipe_manager recipes.RecipeManager) {
+	// POST /api/recipes/bulk - add many recipes at once, sent either as a JSON array or
+	// as NDJSON (one recipe on each line, with a Content-Type of application/x-ndjson).
+	// Each recipe is added or rejected on its own, and the response has a result for
+	// each of them in the order they were sent.
+	router.POST("/api/recipes/bulk", func(c *gin.Context) {
+		// Read the recipes from the request, without parsing them yet
+		var items []json.RawMessage
+		var err error
+		if slices.Contains(ndjsonTypes, c.ContentType()) {
+			items, err = readNDJSON(c.Request.Body)
+		} else {
+			items, err = readJSONArray(c.Request.Body)
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no recipes to add"})
+			return
+		}
+		if len(items) > recipes.MaxBulkRecipes {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("too many recipes: at most %d can be added at once, got %d", recipes.MaxBulkRecipes, len(items)),
+			})
+			return
+		}
+
+		// Parse each recipe, setting aside the ones that aren't valid
+		results := make([]BulkResult, len(items))
+		var valid []recipes.Recipe
+		var positions []int
+		for i, item := range items {
+			results[i].Index = i
+			var recipe recipes.Recipe
+			if err := json.Unmarshal(item, &recipe); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			if recipe.Visibility != "" {
+				if err := recipes.ValidateVisibility(recipe.Visibility); err != nil {
+					results[i].Error = err.Error()
+					continue
+				}
+			}
+			valid = append(valid, recipe)
+			positions = append(positions, i)
+		}
+
+		// Add the rest together
+		if len(valid) > 0 {
+			ids, err := recipe_manager.AddRecipes(c.Request.Context(), valid)
+			var bulkErr *recipes.BulkError
+			if err != nil && !errors.As(err, &bulkErr) {
+				respondWithError(c, err)
+				return
+			}
+			for i, id := range ids {
+				results[positions[i]].ID = id
+			}
+			if bulkErr != nil {
+				for i, err := range bulkErr.Errors {
+					results[positions[i]].Error = err.Error()
+				}
+			}
+		}
+
+		added := 0
+		for _, result := range results {
+			if result.Error == "" {
+				added++
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("Added %d of %d recipes", added, len(results)),
+			"added":   added,
+			"failed":  len(results) - added,
+			"results": results,
+		})
+	})
+
+	// GET /api/recipes/export - get every recipe the user can see as NDJSON, one recipe
+	// on each line, streamed as they are read from the database
+	router.GET("/api/recipes/export", func(c *gin.Context) {
+		// A large library can take longer to send than the server's write timeout, so
+		// lift it for this response
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+		// Only say the response is NDJSON once the export has started, so that errors
+		// before then are sent as JSON like any other
+		started := false
+		start := func() {
+			if !started {
+				c.Header("Content-Type", "application/x-ndjson")
+				c.Header("Content-Disposition", `attachment; filename="recipes.ndjson"`)
+				c.Status(http.StatusOK)
+				started = true
+			}
+		}
+		encoder := json.NewEncoder(c.Writer)
+		err := recipe_manager.ExportRecipes(c.Request.Context(), func(recipe recipes.Recipe) error {
+			start()
+			return encoder.Encode(recipe)
+		})
+		if err != nil && !started {
+			respondWithError(c, err)
+			return
+		}
+		if err != nil {
+			// The response has started, so the status can't change. Cut it off instead,
+			// so the client doesn't mistake part of the library for all of it.
+			slog.ErrorContext(c.Request.Context(), "failed to finish export", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		start()
+	})
+}
+
+// readJSONArray splits a JSON array into its items
+func readJSONArray(body io.Reader) ([]json.RawMessage, error) {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		if err == nil || err == io.EOF {
+			err = errors.New("expected a JSON array of recipes, or NDJSON with a Content-Type of application/x-ndjson")
+		}
+		return nil, err
+	}
+	var items []json.RawMessage
+	for decoder.More() {
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// readNDJSON splits NDJSON into its lines, skipping blank ones
+func readNDJSON(body io.Reader) ([]json.RawMessage, error) {
+	reader := bufio.NewReader(body)
+	var items []json.RawMessage
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, json.RawMessage(line))
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
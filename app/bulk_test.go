@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// bulkRecipeManager is a fake recipe manager that adds recipes to a slice, failing the
+// ones called "Fail", and exports them until it reaches one called "Broken"
+type bulkRecipeManager struct {
+	recipes.RecipeManager
+	recipes []recipes.Recipe
+}
+
+func (m *bulkRecipeManager) AddRecipes(ctx context.Context, batch []recipes.Recipe) ([]string, error) {
+	ids := make([]string, len(batch))
+	failed := make(map[int]error)
+	for i, recipe := range batch {
+		if recipe.Name == "Fail" {
+			failed[i] = errors.New("failed to insert")
+			continue
+		}
+		recipe.ID = primitive.NewObjectID()
+		m.recipes = append(m.recipes, recipe)
+		ids[i] = recipe.ID.Hex()
+	}
+	if len(failed) > 0 {
+		return ids, &recipes.BulkError{Errors: failed}
+	}
+	return ids, nil
+}
+
+func (m *bulkRecipeManager) ExportRecipes(ctx context.Context, fn func(recipes.Recipe) error) error {
+	for _, recipe := range m.recipes {
+		if recipe.Name == "Broken" {
+			return errors.New("lost the database")
+		}
+		if err := fn(recipe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkResponse is the response to a bulk import
+type bulkResponse struct {
+	Added   int `json:"added"`
+	Failed  int `json:"failed"`
+	Results []BulkResult
+}
+
+// TestBulkImport tests that recipes can be imported as a JSON array or NDJSON, and
+// that each one is added or rejected on its own
+func TestBulkImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := &bulkRecipeManager{}
+	router := gin.New()
+	AddBulkAPI(router, manager)
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/recipes/bulk", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		errors      []bool
+	}{
+		{
+			"JSON array",
+			"application/json",
+			`[{"Name": "Soup"}, {"Name": "Stew", "Visibility": "secret"}, {"Name": 5}, {"Name": "Fail"}, {"Name": "Pie"}]`,
+			[]bool{false, true, true, true, false},
+		},
+		{
+			"NDJSON",
+			"application/x-ndjson; charset=utf-8",
+			"{\"Name\": \"Soup\"}\n\n{\"Name\": \"Fail\"}\r\n{\"Name\": \"Pie\"}",
+			[]bool{false, true, false},
+		},
+	}
+	for _, test := range tests {
+		manager.recipes = nil
+		response := post(test.contentType, test.body)
+		if response.Code != http.StatusOK {
+			t.Errorf("%s: expected OK, got %d: %s", test.name, response.Code, response.Body)
+			continue
+		}
+		var result bulkResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", test.name, err)
+		}
+		if len(result.Results) != len(test.errors) {
+			t.Errorf("%s: expected %d results, got %+v", test.name, len(test.errors), result.Results)
+			continue
+		}
+		added := 0
+		for i, item := range result.Results {
+			if item.Index != i || (item.Error != "") != test.errors[i] || (item.ID == "") != test.errors[i] {
+				t.Errorf("%s: unexpected result %d: %+v", test.name, i, item)
+			}
+			if !test.errors[i] {
+				added++
+			}
+		}
+		if result.Added != added || result.Failed != len(test.errors)-added || len(manager.recipes) != added {
+			t.Errorf("%s: expected %d recipes to be added, got %+v", test.name, added, result)
+		}
+	}
+
+	// Bodies that can't be split into recipes are rejected outright
+	for _, body := range []string{`{"Name": "Soup"}`, `[{"Name": "Soup"}`, `[]`, ``} {
+		if response := post("application/json", body); response.Code != http.StatusBadRequest {
+			t.Errorf("Expected %q to be rejected, got %d", body, response.Code)
+		}
+	}
+}
+
+// TestExport tests that recipes are exported as NDJSON, and that a failed export is cut
+// off rather than looking complete
+func TestExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := &bulkRecipeManager{}
+	manager.AddRecipes(context.Background(), []recipes.Recipe{{Name: "Soup"}, {Name: "Pie"}})
+	router := gin.New()
+	router.Use(RecoverAndLog(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	AddBulkAPI(router, manager)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Each recipe is on its own line
+	response, err := http.Get(server.URL + "/api/recipes/export")
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil || response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected NDJSON, got %d %q (%v)", response.StatusCode, response.Header.Get("Content-Type"), err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"Name":"Soup"`) || !strings.Contains(lines[1], `"Name":"Pie"`) {
+		t.Errorf("Expected both recipes, got %q", body)
+	}
+
+	// Failing part way through cuts the response off, before or after the client has
+	// seen the headers depending on how much was sent
+	manager.recipes = append(manager.recipes, recipes.Recipe{Name: "Broken"})
+	response, err = http.Get(server.URL + "/api/recipes/export")
+	if err == nil {
+		_, err = io.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	if err == nil {
+		t.Error("Expected the failed export to be cut off")
+	}
+
+	// Failing before anything is sent is an ordinary error
+	manager.recipes = []recipes.Recipe{{Name: "Broken"}}
+	response, err = http.Get(server.URL + "/api/recipes/export")
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusInternalServerError || !strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		t.Errorf("Expected a JSON error, got %d %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+}
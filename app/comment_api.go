@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+func AddCommentsAPI(router gin.IRouter, recipe_manager recipes.RecipeManager) {
+	// Provide an API for commenting on a recipe
+	commentsAPI := router.Group("/api/recipes/id/:id/comments")
+	{
+		// GET /api/recipes/id/:id/comments - get all comments on a recipe
+		commentsAPI.GET("/", func(c *gin.Context) {
+			recipe, err := recipe_manager.GetRecipeByID(c.Request.Context(), c.Param("id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			// Always return a list, even if the recipe has never had comments
+			comments := recipe.Comments
+			if comments == nil {
+				comments = []recipes.Comments{}
+			}
+
+			c.JSON(http.StatusOK, comments)
+		})
+
+		// POST /api/recipes/id/:id/comments - add a comment to a recipe. The date and
+		// author are filled in by the server.
+		commentsAPI.POST("/", func(c *gin.Context) {
+			// Get the comment from the request
+			var comment recipes.Comments
+			if err := c.BindJSON(&comment); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Add it to the recipe
+			id, err := recipe_manager.AddComment(c.Request.Context(), c.Param("id"), comment)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Comment added successfully", "id": id})
+		})
+
+		// PUT /api/recipes/id/:id/comments/:comment_id - change the text of a comment
+		commentsAPI.PUT("/:comment_id", func(c *gin.Context) {
+			// Get the comment from the request
+			var comment recipes.Comments
+			if err := c.BindJSON(&comment); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Make sure the ID in the URL matches the ID in the comment
+			commentID, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			comment.ID = commentID
+
+			// Update the comment
+			err = recipe_manager.UpdateComment(c.Request.Context(), c.Param("id"), comment)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully"})
+		})
+
+		// DELETE /api/recipes/id/:id/comments/:comment_id - delete a comment
+		commentsAPI.DELETE("/:comment_id", func(c *gin.Context) {
+			err := recipe_manager.DeleteComment(c.Request.Context(), c.Param("id"), c.Param("comment_id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+func AddCookLogAPI(router gin.IRouter, cook_log recipes.CookLog) {
+	// Provide an API for logging each time a recipe is cooked
+	cookLogAPI := router.Group("/api/recipes/id/:id/cooklog")
+	{
+		// GET /api/recipes/id/:id/cooklog - get every time a recipe was cooked
+		cookLogAPI.GET("/", func(c *gin.Context) {
+			entries, err := cook_log.GetCookLog(c.Request.Context(), c.Param("id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, entries)
+		})
+
+		// POST /api/recipes/id/:id/cooklog - record that a recipe was cooked. The date
+		// and cook default to now and the current user.
+		cookLogAPI.POST("/", func(c *gin.Context) {
+			// Get the entry from the request
+			var entry recipes.CookLogEntry
+			if err := c.BindJSON(&entry); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Make sure the entry is for the recipe in the URL
+			recipeID, err := primitive.ObjectIDFromHex(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			entry.RecipeID = recipeID
+			if err := entry.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Add it to the log
+			id, err := cook_log.LogCook(c.Request.Context(), entry)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Cook logged successfully", "id": id})
+		})
+
+		// DELETE /api/recipes/id/:id/cooklog/:entry_id - remove an entry from the log
+		cookLogAPI.DELETE("/:entry_id", func(c *gin.Context) {
+			err := cook_log.DeleteCookLogEntry(c.Request.Context(), c.Param("id"), c.Param("entry_id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Cook log entry deleted successfully"})
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec describes every route the server provides. openapi_test.go checks that
+// it stays in step with the router.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiDocsTemplate renders the interactive API docs page
+var apiDocsTemplate = template.Must(template.ParseFS(templates, "templates/api_docs.html"))
+
+// apiDocsScript starts Swagger UI on the docs page. It is inlined into the page, and
+// allowed to run by its hash in the content security policy.
+const apiDocsScript = `SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#docs", deepLinking: true });`
+
+// apiDocsCSP lets the docs page load Swagger UI from its CDN and run apiDocsScript
+var apiDocsCSP = "default-src 'self'; " +
+	"script-src https://cdn.jsdelivr.net 'sha256-" + scriptHash(apiDocsScript) + "'; " +
+	"style-src https://cdn.jsdelivr.net 'unsafe-inline'; " +
+	"img-src 'self' data: https:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+func AddAPIDocs(router gin.IRouter) {
+	// GET /api/openapi.json - get the OpenAPI document describing this API
+	router.GET("/api/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openAPISpec)
+	})
+
+	// GET /api/docs - browse the API interactively
+	docsHeaders := OverrideHeaders(map[string]string{"Content-Security-Policy": apiDocsCSP})
+	router.GET("/api/docs", docsHeaders, func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := apiDocsTemplate.Execute(c.Writer, template.JS(apiDocsScript)); err != nil {
+			c.Error(err)
+		}
+	})
+}
+
+// scriptHash returns the base64 encoded SHA-256 hash of an inline script, for allowing
+// it in a content security policy
+func scriptHash(script string) string {
+	hash := sha256.Sum256([]byte(script))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
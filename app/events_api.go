@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// eventsHeartbeat is how often an idle event stream sends a comment, so that proxies
+// don't close it and clients notice if the server has gone away
+const eventsHeartbeat = 30 * time.Second
+
+// AddEventsAPI streams changes to recipes. Streams end once the shutdown context is
+// done, so that they don't hold up the server's graceful shutdown.
+func AddEventsAPI(router gin.IRouter, events recipes.EventSource, shutdown context.Context) {
+	// GET /api/recipes/events - follow changes to the recipes the user can see, as
+	// server-sent events named "created", "updated", or "deleted". Each event's data is
+	// a JSON object with the Type, RecipeID, Revision, Actor, and Time of the change.
+	router.GET("/api/recipes/events", func(c *gin.Context) {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		stop := context.AfterFunc(shutdown, cancel)
+		defer stop()
+		viewer, _ := recipes.ViewerFromContext(ctx)
+		subscription, err := events.Subscribe(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The stream stays open for much longer than the server's write timeout, so
+		// lift it for this response. Send the headers straight away, so the client
+		// knows it is subscribed.
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		// Send each change the user can see until they go away or the server shuts down.
+		// If they fell too far behind, end the stream; browsers reconnect by themselves.
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-subscription:
+				if !ok {
+					return false
+				}
+				if event.VisibleTo(viewer) {
+					c.SSEvent(event.Type, event)
+				}
+				return true
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// TestRecipeEvents tests that the event stream sends the changes the viewer can see,
+// and nothing else
+func TestRecipeEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bus := recipes.NewBus()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(recipes.WithViewer(c.Request.Context(), recipes.Viewer{}))
+	})
+	AddEventsAPI(router, bus, context.Background())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Subscribe, which is done once the headers arrive
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/recipes/events", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Fatalf("Expected an event stream, got %q", contentType)
+	}
+
+	// Anonymous visitors can't see the private recipe, so only hear about the public one
+	private := recipes.Recipe{ID: primitive.NewObjectID(), Revision: 2, Visibility: recipes.VisibilityPrivate, Owner: "alice-id"}
+	public := recipes.Recipe{ID: primitive.NewObjectID(), Revision: 1, Visibility: recipes.VisibilityPublic, Owner: "alice-id"}
+	bus.Publish(recipes.NewEvent(recipes.EventUpdated, private, "alice", time.Now()))
+	bus.Publish(recipes.NewEvent(recipes.EventCreated, public, "alice", time.Now()))
+
+	// Read the first event
+	reader := bufio.NewReader(response.Body)
+	var name, data string
+	for name == "" || data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read an event: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "event:"); ok {
+			name = value
+		} else if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = value
+		}
+	}
+	if name != recipes.EventCreated {
+		t.Errorf("Expected a created event, got %q", name)
+	}
+	var event recipes.Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("Failed to parse event %q: %v", data, err)
+	}
+	if event.RecipeID != public.ID.Hex() || event.Revision != 1 || event.Actor != "alice" {
+		t.Errorf("Expected the public recipe's event, got %+v", event)
+	}
+}
+
+// TestRecipeEventsShutdown tests that event streams are closed when the server shuts
+// down, rather than holding it up until they time out
+func TestRecipeEventsShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	shutdown, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+	AddEventsAPI(router, recipes.NewBus(), shutdown)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: router}
+	server.RegisterOnShutdown(closeStreams)
+	go server.Serve(listener)
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/api/recipes/events")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer response.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected the server to shut down straight away, got %v", err)
+	}
+	if _, err := io.ReadAll(response.Body); err != nil {
+		t.Errorf("Expected the stream to end, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"github.com/dawsonc/recipes/src/config"
+	"github.com/dawsonc/recipes/src/gql"
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// graphQLPath is where GraphQL queries are sent
+const graphQLPath = "/graphql"
+
+func AddGraphQLAPI(router gin.IRouter, recipe_manager recipes.RecipeManager, cook_log recipes.CookLog, limits config.Limits) {
+	// The schema never changes, so it can only fail to build because of a bug
+	executor, err := gql.NewExecutor(recipe_manager, cook_log, gql.Limits{
+		MaxDepth:      limits.GraphQLDepth,
+		MaxComplexity: limits.GraphQLComplexity,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("building the GraphQL schema: %v", err))
+	}
+
+	// GET /graphql - run a GraphQL query given in the query string
+	// e.g. /graphql?query={tags}
+	// e.g. /graphql?query=query($id:ID!){recipe(id:$id){name}}&variables={"id":"ID"}
+	router.GET(graphQLPath, func(c *gin.Context) {
+		request := gql.Request{Query: c.Query("query"), OperationName: c.Query("operationName")}
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				respondWithGraphQL(c, graphQLError(fmt.Errorf("invalid variables: %w", err)))
+				return
+			}
+		}
+
+		respondWithGraphQL(c, executor.Execute(c.Request.Context(), request))
+	})
+
+	// POST /graphql - run a GraphQL query given as JSON, with a query and optionally an
+	// operationName and variables
+	router.POST(graphQLPath, func(c *gin.Context) {
+		var request gql.Request
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondWithGraphQL(c, graphQLError(err))
+			return
+		}
+
+		respondWithGraphQL(c, executor.Execute(c.Request.Context(), request))
+	})
+}
+
+// graphQLError makes a result for a request that couldn't be run at all
+func graphQLError(err error) *graphql.Result {
+	return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+}
+
+// respondWithGraphQL sends the result of a query. Queries that couldn't be run at all
+// have no data, and are reported as bad requests; errors in single fields aren't.
+func respondWithGraphQL(c *gin.Context, result *graphql.Result) {
+	status := http.StatusOK
+	if result.Data == nil {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, result)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/recipespb"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// recipeService serves the gRPC recipe service from the same recipe manager and users
+// as the HTTP API. Calls are authenticated by the server's interceptors before they get
+// here, so the recipe manager sees the same viewer it would for an HTTP request.
+type recipeService struct {
+	recipespb.UnimplementedRecipeServiceServer
+	recipe_manager recipes.RecipeManager
+	user_store     users.UserStore
+}
+
+// AddRecipe adds a recipe owned by the caller
+func (s *recipeService) AddRecipe(ctx context.Context, request *recipespb.AddRecipeRequest) (*recipespb.AddRecipeResponse, error) {
+	recipe, err := recipespb.ToRecipe(request.GetRecipe())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Recipes default to being visible to the user's household, but they can choose
+	// otherwise up front
+	if recipe.Visibility != "" {
+		if err := recipes.ValidateVisibility(recipe.Visibility); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	id, err := s.recipe_manager.AddRecipe(ctx, recipe)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &recipespb.AddRecipeResponse{Id: id}, nil
+}
+
+// DeleteRecipe moves a recipe to the trash
+func (s *recipeService) DeleteRecipe(ctx context.Context, request *recipespb.DeleteRecipeRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	return empty(s.recipe_manager.DeleteRecipe(ctx, request.GetId()))
+}
+
+// UpdateRecipe replaces the recipe with the ID in the request
+func (s *recipeService) UpdateRecipe(ctx context.Context, request *recipespb.UpdateRecipeRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetRecipe().GetId()); err != nil {
+		return nil, err
+	}
+	recipe, err := recipespb.ToRecipe(request.GetRecipe())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return empty(s.recipe_manager.UpdateRecipe(ctx, recipe))
+}
+
+// GetAllRecipes streams every recipe the caller can see
+func (s *recipeService) GetAllRecipes(request *recipespb.GetAllRecipesRequest, stream recipespb.RecipeService_GetAllRecipesServer) error {
+	found, err := s.recipe_manager.GetAllRecipes(stream.Context())
+	return sendRecipes(found, err, stream.Send)
+}
+
+// GetRecipeByID returns a single recipe
+func (s *recipeService) GetRecipeByID(ctx context.Context, request *recipespb.GetRecipeByIDRequest) (*recipespb.Recipe, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	recipe, err := s.recipe_manager.GetRecipeByID(ctx, request.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return recipespb.FromRecipe(recipe), nil
+}
+
+// GetRecipesByIDs streams the recipes with the given IDs that the caller can see
+func (s *recipeService) GetRecipesByIDs(request *recipespb.GetRecipesByIDsRequest, stream recipespb.RecipeService_GetRecipesByIDsServer) error {
+	found, err := s.recipe_manager.GetRecipesByIDs(stream.Context(), request.GetIds())
+	return sendRecipes(found, err, stream.Send)
+}
+
+// GetRecipesByTags streams the recipes that have all of the given tags
+func (s *recipeService) GetRecipesByTags(request *recipespb.GetRecipesByTagsRequest, stream recipespb.RecipeService_GetRecipesByTagsServer) error {
+	found, err := s.recipe_manager.GetRecipesByTags(stream.Context(), request.GetTags())
+	return sendRecipes(found, err, stream.Send)
+}
+
+// GetTags returns every tag on a recipe the caller can see
+func (s *recipeService) GetTags(ctx context.Context, request *recipespb.GetTagsRequest) (*recipespb.GetTagsResponse, error) {
+	tags, err := s.recipe_manager.GetTags(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &recipespb.GetTagsResponse{Tags: tags}, nil
+}
+
+// SearchRecipes streams the recipes that match the query and tags
+func (s *recipeService) SearchRecipes(request *recipespb.SearchRecipesRequest, stream recipespb.RecipeService_SearchRecipesServer) error {
+	found, err := s.recipe_manager.SearchRecipes(stream.Context(), request.GetQuery(), request.GetTags())
+	return sendRecipes(found, err, stream.Send)
+}
+
+// ListRecipes streams the recipes that match the filters, in the requested order
+func (s *recipeService) ListRecipes(request *recipespb.ListRecipesRequest, stream recipespb.RecipeService_ListRecipesServer) error {
+	opts := recipes.ListOptions{
+		Query:         request.GetQuery(),
+		Tags:          request.GetTags(),
+		CreatedAfter:  recipespb.ToTime(request.GetCreatedAfter()),
+		CreatedBefore: recipespb.ToTime(request.GetCreatedBefore()),
+		UpdatedAfter:  recipespb.ToTime(request.GetUpdatedAfter()),
+		UpdatedBefore: recipespb.ToTime(request.GetUpdatedBefore()),
+		SortBy:        request.GetSortBy(),
+		Descending:    request.GetDescending(),
+	}
+	if err := opts.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	found, err := s.recipe_manager.ListRecipes(stream.Context(), opts)
+	return sendRecipes(found, err, stream.Send)
+}
+
+// GetRevisions streams the revision history of a recipe, oldest first
+func (s *recipeService) GetRevisions(request *recipespb.GetRevisionsRequest, stream recipespb.RecipeService_GetRevisionsServer) error {
+	if err := checkIDs(request.GetId()); err != nil {
+		return err
+	}
+	revisions, err := s.recipe_manager.GetRevisions(stream.Context(), request.GetId())
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, revision := range revisions {
+		if err := stream.Send(recipespb.FromRevision(revision)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevision returns a single revision of a recipe
+func (s *recipeService) GetRevision(ctx context.Context, request *recipespb.GetRevisionRequest) (*recipespb.Revision, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	revision, err := s.recipe_manager.GetRevision(ctx, request.GetId(), int(request.GetNumber()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return recipespb.FromRevision(revision), nil
+}
+
+// RestoreRevision makes an old revision of a recipe current again
+func (s *recipeService) RestoreRevision(ctx context.Context, request *recipespb.RestoreRevisionRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	return empty(s.recipe_manager.RestoreRevision(ctx, request.GetId(), int(request.GetNumber())))
+}
+
+// GetTrash streams the recipes in the trash
+func (s *recipeService) GetTrash(request *recipespb.GetTrashRequest, stream recipespb.RecipeService_GetTrashServer) error {
+	found, err := s.recipe_manager.GetTrash(stream.Context())
+	return sendRecipes(found, err, stream.Send)
+}
+
+// RestoreRecipe moves a recipe out of the trash
+func (s *recipeService) RestoreRecipe(ctx context.Context, request *recipespb.RestoreRecipeRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	return empty(s.recipe_manager.RestoreRecipe(ctx, request.GetId()))
+}
+
+// PurgeTrash permanently removes recipes deleted before the given time
+func (s *recipeService) PurgeTrash(ctx context.Context, request *recipespb.PurgeTrashRequest) (*recipespb.PurgeTrashResponse, error) {
+	if request.GetBefore() == nil {
+		return nil, status.Error(codes.InvalidArgument, "before is required")
+	}
+	purged, err := s.recipe_manager.PurgeTrash(ctx, recipespb.ToTime(request.GetBefore()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &recipespb.PurgeTrashResponse{Purged: int32(purged)}, nil
+}
+
+// AddComment adds a comment to a recipe
+func (s *recipeService) AddComment(ctx context.Context, request *recipespb.AddCommentRequest) (*recipespb.AddCommentResponse, error) {
+	if err := checkIDs(request.GetRecipeId()); err != nil {
+		return nil, err
+	}
+	comment, err := recipespb.ToComment(request.GetComment())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	id, err := s.recipe_manager.AddComment(ctx, request.GetRecipeId(), comment)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &recipespb.AddCommentResponse{Id: id}, nil
+}
+
+// UpdateComment changes the text of the comment with the ID in the request
+func (s *recipeService) UpdateComment(ctx context.Context, request *recipespb.UpdateCommentRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetRecipeId(), request.GetComment().GetId()); err != nil {
+		return nil, err
+	}
+	comment, err := recipespb.ToComment(request.GetComment())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return empty(s.recipe_manager.UpdateComment(ctx, request.GetRecipeId(), comment))
+}
+
+// DeleteComment removes a comment from a recipe
+func (s *recipeService) DeleteComment(ctx context.Context, request *recipespb.DeleteCommentRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetRecipeId(), request.GetCommentId()); err != nil {
+		return nil, err
+	}
+	return empty(s.recipe_manager.DeleteComment(ctx, request.GetRecipeId(), request.GetCommentId()))
+}
+
+// SetVisibility changes who can see a recipe
+func (s *recipeService) SetVisibility(ctx context.Context, request *recipespb.SetVisibilityRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	if err := recipes.ValidateVisibility(request.GetVisibility()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return empty(s.recipe_manager.SetVisibility(ctx, request.GetId(), request.GetVisibility()))
+}
+
+// ShareRecipe lets another user view or edit a recipe
+func (s *recipeService) ShareRecipe(ctx context.Context, request *recipespb.ShareRecipeRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	if err := recipes.ValidatePermission(request.GetPermission()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.lookUpUser(ctx, request.GetUsername())
+	if err != nil {
+		return nil, err
+	}
+	grant := recipes.ShareGrant{UserID: user.ID.Hex(), Permission: request.GetPermission()}
+	return empty(s.recipe_manager.ShareRecipe(ctx, request.GetId(), grant))
+}
+
+// UnshareRecipe takes away any permission a user was given on a recipe
+func (s *recipeService) UnshareRecipe(ctx context.Context, request *recipespb.UnshareRecipeRequest) (*emptypb.Empty, error) {
+	if err := checkIDs(request.GetId()); err != nil {
+		return nil, err
+	}
+	user, err := s.lookUpUser(ctx, request.GetUsername())
+	if err != nil {
+		return nil, err
+	}
+	return empty(s.recipe_manager.UnshareRecipe(ctx, request.GetId(), user.ID.Hex()))
+}
+
+// lookUpUser finds the user with the given username
+func (s *recipeService) lookUpUser(ctx context.Context, username string) (users.User, error) {
+	user, err := s.user_store.GetUserByUsername(ctx, username)
+	if errors.Is(err, users.ErrNotFound) {
+		return user, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		return user, status.Error(codes.Internal, err.Error())
+	}
+	return user, nil
+}
+
+// sendRecipes streams the recipes that were found one at a time, or the error from
+// looking them up
+func sendRecipes(found []recipes.Recipe, err error, send func(*recipespb.Recipe) error) error {
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, recipe := range found {
+		if err := send(recipespb.FromRecipe(recipe)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIDs returns an InvalidArgument error if any of the IDs isn't a valid ID
+func checkIDs(ids ...string) error {
+	for _, id := range ids {
+		if !primitive.IsValidObjectID(id) {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("invalid ID %q", id))
+		}
+	}
+	return nil
+}
+
+// empty is the response for calls that only change something
+func empty(err error) (*emptypb.Empty, error) {
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// grpcError converts an error from the recipe manager to a status with the matching
+// code, the same way respondWithError picks an HTTP status
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, recipes.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, recipes.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/logging"
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/recipespb"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// grpcRequestIDKey is the metadata key that carries the ID of each call, the same as
+// the X-Request-ID header does for HTTP requests
+const grpcRequestIDKey = "x-request-id"
+
+// grpcAccess is what a caller needs in order to make a call
+type grpcAccess int
+
+const (
+	// grpcRead calls can be made by anyone, including anonymous callers
+	grpcRead grpcAccess = iota
+	// grpcWrite calls need a logged-in user, and API tokens need read-write scope
+	grpcWrite
+	// grpcAdmin calls need an admin, using a session or an admin-scoped API token
+	grpcAdmin
+)
+
+// grpcMethodAccess lists what every method of every service needs. Methods that aren't
+// listed need a logged-in user, so that a new method isn't open to everyone by mistake.
+var grpcMethodAccess = map[string]grpcAccess{
+	recipespb.RecipeService_AddRecipe_FullMethodName:        grpcWrite,
+	recipespb.RecipeService_DeleteRecipe_FullMethodName:     grpcWrite,
+	recipespb.RecipeService_UpdateRecipe_FullMethodName:     grpcWrite,
+	recipespb.RecipeService_GetAllRecipes_FullMethodName:    grpcRead,
+	recipespb.RecipeService_GetRecipeByID_FullMethodName:    grpcRead,
+	recipespb.RecipeService_GetRecipesByIDs_FullMethodName:  grpcRead,
+	recipespb.RecipeService_GetRecipesByTags_FullMethodName: grpcRead,
+	recipespb.RecipeService_GetTags_FullMethodName:          grpcRead,
+	recipespb.RecipeService_SearchRecipes_FullMethodName:    grpcRead,
+	recipespb.RecipeService_ListRecipes_FullMethodName:      grpcRead,
+	recipespb.RecipeService_GetRevisions_FullMethodName:     grpcRead,
+	recipespb.RecipeService_GetRevision_FullMethodName:      grpcRead,
+	recipespb.RecipeService_RestoreRevision_FullMethodName:  grpcWrite,
+	recipespb.RecipeService_GetTrash_FullMethodName:         grpcRead,
+	recipespb.RecipeService_RestoreRecipe_FullMethodName:    grpcWrite,
+	recipespb.RecipeService_PurgeTrash_FullMethodName:       grpcAdmin,
+	recipespb.RecipeService_AddComment_FullMethodName:       grpcWrite,
+	recipespb.RecipeService_UpdateComment_FullMethodName:    grpcWrite,
+	recipespb.RecipeService_DeleteComment_FullMethodName:    grpcWrite,
+	recipespb.RecipeService_SetVisibility_FullMethodName:    grpcWrite,
+	recipespb.RecipeService_ShareRecipe_FullMethodName:      grpcWrite,
+	recipespb.RecipeService_UnshareRecipe_FullMethodName:    grpcWrite,
+
+	healthpb.Health_Check_FullMethodName:                                     grpcRead,
+	healthpb.Health_Watch_FullMethodName:                                     grpcRead,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:        grpcRead,
+	reflectionv1alphapb.ServerReflection_ServerReflectionInfo_FullMethodName: grpcRead,
+}
+
+// newGRPCServer makes a gRPC server for the recipe service, backed by the same recipe
+// manager and users as the HTTP API. Every call is traced, logged, and authenticated
+// like an HTTP request. Credentials are used for TLS if they are given. The server also
+// answers standard health checks, and describes its services for tools like grpcurl.
+func newGRPCServer(logger *slog.Logger, data *backend, creds credentials.TransportCredentials) *grpc.Server {
+	interceptor := &grpcInterceptor{logger: logger, user_store: data.user_store}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(interceptor.unary),
+		grpc.StreamInterceptor(interceptor.stream),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	recipespb.RegisterRecipeServiceServer(server, &recipeService{
+		recipe_manager: data.recipe_manager,
+		user_store:     data.user_store,
+	})
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	return server
+}
+
+// grpcCredentials loads the certificate and key for the gRPC server, using the same
+// TLS settings as the HTTP server
+func grpcCredentials(tlsConfig *tls.Config, certFile, keyFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// stopGRPCServer stops taking new calls and waits for the ones in progress to finish,
+// cutting them off if the context is done first
+func stopGRPCServer(ctx context.Context, server *grpc.Server) error {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return ctx.Err()
+	}
+}
+
+// grpcInterceptor does for gRPC calls what the HTTP middleware does for requests
+type grpcInterceptor struct {
+	logger     *slog.Logger
+	user_store users.UserStore
+}
+
+// unary handles calls that return a single response
+func (i *grpcInterceptor) unary(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var response any
+	err := i.handle(ctx, info.FullMethod, func(ctx context.Context) error {
+		var err error
+		response, err = handler(ctx, request)
+		return err
+	})
+	return response, err
+}
+
+// stream handles calls that stream their responses
+func (i *grpcInterceptor) stream(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return i.handle(stream.Context(), info.FullMethod, func(ctx context.Context) error {
+		return handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+	})
+}
+
+// handle gives a call an ID, works out who is making it, and makes the call if they
+// may. Panics are turned into Internal errors, and every call is logged once it is done.
+func (i *grpcInterceptor) handle(ctx context.Context, method string, call func(context.Context) error) (err error) {
+	start := time.Now()
+
+	// Take the ID from the caller if they sent a usable one, and send it back
+	id := ""
+	if values := metadata.ValueFromIncomingContext(ctx, grpcRequestIDKey); len(values) > 0 {
+		id = values[0]
+	}
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	ctx = logging.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+
+	// Record where the call came from for the audit log
+	clientIP := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+	ctx = audit.WithClientIP(ctx, clientIP)
+
+	var user users.User
+	var ok bool
+	defer func() {
+		if r := recover(); r != nil {
+			i.logger.ErrorContext(ctx, "panic while handling call", "method", method, "panic", r)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+		i.log(ctx, method, start, clientIP, user, ok, err)
+	}()
+
+	ctx, user, ok, err = i.authenticate(ctx, method)
+	if err != nil {
+		return err
+	}
+	return call(ctx)
+}
+
+// authenticate looks up the user for the bearer token in the call's metadata (if any),
+// the same way Authenticate does for HTTP requests, and checks that they may call the
+// method. It returns a context with the user as the actor and viewer for the recipe
+// manager, or only able to see public recipes if there is no token.
+func (i *grpcInterceptor) authenticate(ctx context.Context, method string) (context.Context, users.User, bool, error) {
+	ctx = recipes.WithViewer(ctx, recipes.Viewer{})
+	access, ok := grpcMethodAccess[method]
+	if !ok {
+		access = grpcWrite
+	}
+
+	token := ""
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if token == "" {
+		if access != grpcRead {
+			return ctx, users.User{}, false, status.Error(codes.Unauthenticated, "login required")
+		}
+		return ctx, users.User{}, false, nil
+	}
+
+	// Scripts can use a personal API token instead of logging in. Sessions have no
+	// scope limits.
+	var user users.User
+	scope := users.ScopeAdmin
+	var err error
+	if users.IsAPIToken(token) {
+		var apiToken users.APIToken
+		user, apiToken, err = users.AuthenticateAPIToken(ctx, i.user_store, token)
+		if errors.Is(err, users.ErrNotFound) {
+			return ctx, user, false, status.Error(codes.Unauthenticated, "invalid or revoked API token")
+		}
+		scope = apiToken.Scope
+	} else {
+		user, err = users.Authenticate(ctx, i.user_store, token)
+		if errors.Is(err, users.ErrNotFound) {
+			return ctx, user, false, status.Error(codes.Unauthenticated, "invalid or expired session")
+		}
+	}
+	if err != nil {
+		return ctx, user, false, status.Error(codes.Internal, err.Error())
+	}
+	ctx = recipes.WithActor(ctx, user.Username)
+	ctx = recipes.WithViewer(ctx, viewerFor(user, scope))
+
+	// Check the user may make the call
+	switch {
+	case access == grpcAdmin && !user.Admin:
+		return ctx, user, true, status.Error(codes.PermissionDenied, "admin access required")
+	case access == grpcAdmin && !users.ScopeAllows(scope, users.ScopeAdmin),
+		access == grpcWrite && !users.ScopeAllows(scope, users.ScopeReadWrite),
+		!users.ScopeAllows(scope, users.ScopeRead):
+		return ctx, user, true, status.Error(codes.PermissionDenied, "API token scope does not allow this request")
+	}
+	return ctx, user, true, nil
+}
+
+// log logs a structured line for a call once it has been handled, the same way
+// LogRequests does for HTTP requests
+func (i *grpcInterceptor) log(ctx context.Context, method string, start time.Time, clientIP string, user users.User, ok bool, err error) {
+	// Server errors are errors, client errors are warnings, and the rest is info
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		logging.DurationMS(time.Since(start)),
+		slog.String("client_ip", clientIP),
+	}
+	if ok {
+		attrs = append(attrs, slog.String("user", user.Username))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	i.logger.Log(ctx, level, "grpc call", attrs...)
+}
+
+// contextStream is a server stream with a different context, so that streaming calls
+// see the same request ID, client IP, and viewer as unary ones
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
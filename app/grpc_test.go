@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/recipespb"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// grpcRecipeManager is a recipe manager with a few recipes, which remembers the viewer
+// and actor of the last call. Methods the tests don't use panic.
+type grpcRecipeManager struct {
+	recipes.RecipeManager
+	recipes []recipes.Recipe
+	viewer  recipes.Viewer
+	actor   string
+}
+
+func (m *grpcRecipeManager) remember(ctx context.Context) {
+	m.viewer, _ = recipes.ViewerFromContext(ctx)
+	m.actor = recipes.ActorFromContext(ctx)
+}
+
+func (m *grpcRecipeManager) AddRecipe(ctx context.Context, recipe recipes.Recipe) (string, error) {
+	m.remember(ctx)
+	recipe.ID = primitive.NewObjectID()
+	m.recipes = append(m.recipes, recipe)
+	return recipe.ID.Hex(), nil
+}
+
+func (m *grpcRecipeManager) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	m.remember(ctx)
+	for _, recipe := range m.recipes {
+		if recipe.ID.Hex() == id {
+			return recipe, nil
+		}
+	}
+	return recipes.Recipe{}, recipes.ErrNotFound
+}
+
+func (m *grpcRecipeManager) ListRecipes(ctx context.Context, opts recipes.ListOptions) ([]recipes.Recipe, error) {
+	m.remember(ctx)
+	return m.recipes, nil
+}
+
+// grpcUserStore knows a single user, logged in with a single session
+type grpcUserStore struct {
+	users.UserStore
+	user users.User
+}
+
+// grpcSession is the session token of the user in grpcUserStore
+const grpcSession = "session-token"
+
+func (s *grpcUserStore) GetSession(ctx context.Context, tokenHash string) (users.Session, error) {
+	if tokenHash != users.HashToken(grpcSession) {
+		return users.Session{}, users.ErrNotFound
+	}
+	return users.Session{TokenHash: tokenHash, UserID: s.user.ID}, nil
+}
+
+func (s *grpcUserStore) GetUserByID(ctx context.Context, id string) (users.User, error) {
+	if id != s.user.ID.Hex() {
+		return users.User{}, users.ErrNotFound
+	}
+	return s.user, nil
+}
+
+// startGRPC serves the recipe service over an in-memory connection and returns a client
+// for it
+func startGRPC(t *testing.T, recipe_manager recipes.RecipeManager, user_store users.UserStore) recipespb.RecipeServiceClient {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := newGRPCServer(logger, &backend{recipe_manager: recipe_manager, user_store: user_store}, nil)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return recipespb.NewRecipeServiceClient(conn)
+}
+
+// withSession adds a session token to the context of a call
+func withSession(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// TestGRPCListRecipes tests that anyone can list recipes, which are streamed one at a time
+func TestGRPCListRecipes(t *testing.T) {
+	recipe_manager := &grpcRecipeManager{recipes: []recipes.Recipe{
+		{ID: primitive.NewObjectID(), Name: "Pancakes", Ingredients: []recipes.Ingredient{{Name: "flour", Quantity: "1 cup"}}},
+		{ID: primitive.NewObjectID(), Name: "Waffles"},
+	}}
+	client := startGRPC(t, recipe_manager, &grpcUserStore{})
+
+	stream, err := client.ListRecipes(context.Background(), &recipespb.ListRecipesRequest{SortBy: recipes.SortByName})
+	if err != nil {
+		t.Fatalf("Failed to list recipes: %v", err)
+	}
+	var names []string
+	for {
+		recipe, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive a recipe: %v", err)
+		}
+		names = append(names, recipe.GetName())
+		if recipe.GetName() == "Pancakes" && recipe.GetIngredients()[0].GetQuantity() != "1 cup" {
+			t.Errorf("Expected the ingredients to be sent, got %v", recipe.GetIngredients())
+		}
+	}
+	if len(names) != 2 || names[0] != "Pancakes" || names[1] != "Waffles" {
+		t.Errorf("Expected both recipes in order, got %v", names)
+	}
+	if recipe_manager.viewer != (recipes.Viewer{}) {
+		t.Errorf("Expected an anonymous viewer, got %+v", recipe_manager.viewer)
+	}
+
+	// Bad options are the caller's fault
+	stream, err = client.ListRecipes(context.Background(), &recipespb.ListRecipesRequest{SortBy: "colour"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad sort key, got %v", err)
+	}
+}
+
+// TestGRPCAuth tests that calls are made as the user whose session they carry, and that
+// changes need a user
+func TestGRPCAuth(t *testing.T) {
+	user := users.User{ID: primitive.NewObjectID(), Username: "alice"}
+	recipe_manager := &grpcRecipeManager{}
+	client := startGRPC(t, recipe_manager, &grpcUserStore{user: user})
+	request := &recipespb.AddRecipeRequest{Recipe: &recipespb.Recipe{Name: "Soup"}}
+
+	if _, err := client.AddRecipe(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a session, got %v", err)
+	}
+	if _, err := client.AddRecipe(withSession("stale"), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with a bad session, got %v", err)
+	}
+
+	response, err := client.AddRecipe(withSession(grpcSession), request)
+	if err != nil {
+		t.Fatalf("Failed to add a recipe: %v", err)
+	}
+	if response.GetId() == "" {
+		t.Error("Expected the ID of the new recipe")
+	}
+	if recipe_manager.actor != "alice" || recipe_manager.viewer.UserID != user.ID.Hex() {
+		t.Errorf("Expected the recipe to be added by alice, got actor %q and viewer %+v",
+			recipe_manager.actor, recipe_manager.viewer)
+	}
+
+	// Only admins can purge the trash
+	_, err = client.PurgeTrash(withSession(grpcSession), &recipespb.PurgeTrashRequest{Before: timestamppb.Now()})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a non-admin, got %v", err)
+	}
+}
+
+// TestGRPCErrors tests that errors from the recipe manager and bad requests get the
+// matching codes
+func TestGRPCErrors(t *testing.T) {
+	client := startGRPC(t, &grpcRecipeManager{}, &grpcUserStore{})
+
+	_, err := client.GetRecipeByID(context.Background(), &recipespb.GetRecipeByIDRequest{Id: primitive.NewObjectID().Hex()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for a missing recipe, got %v", err)
+	}
+	_, err = client.GetRecipeByID(context.Background(), &recipespb.GetRecipeByIDRequest{Id: "nope"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad ID, got %v", err)
+	}
+}
+
+// TestGRPCMethodAccess tests that every method the server offers says what access it
+// needs, and that methods that aren't listed need a logged-in user
+func TestGRPCMethodAccess(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := newGRPCServer(logger, &backend{recipe_manager: &grpcRecipeManager{}, user_store: &grpcUserStore{}}, nil)
+	defer server.Stop()
+
+	methods := make([]string, 0)
+	for _, method := range recipespb.RecipeService_ServiceDesc.Methods {
+		methods = append(methods, "/"+recipespb.RecipeService_ServiceDesc.ServiceName+"/"+method.MethodName)
+	}
+	for _, stream := range recipespb.RecipeService_ServiceDesc.Streams {
+		methods = append(methods, "/"+recipespb.RecipeService_ServiceDesc.ServiceName+"/"+stream.StreamName)
+	}
+	for service, info := range server.GetServiceInfo() {
+		for _, method := range info.Methods {
+			methods = append(methods, "/"+service+"/"+method.Name)
+		}
+	}
+	for _, method := range methods {
+		if _, ok := grpcMethodAccess[method]; !ok {
+			t.Errorf("Expected %s to be listed in grpcMethodAccess", method)
+		}
+	}
+
+	interceptor := &grpcInterceptor{logger: logger, user_store: &grpcUserStore{}}
+	_, _, _, err := interceptor.authenticate(context.Background(), "/recipes.v1.RecipeService/Unknown")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated for an unlisted method, got %v", err)
+	}
+}
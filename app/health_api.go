@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/metrics"
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// serverMetrics are the metrics the server keeps about itself
+type serverMetrics struct {
+	registry         *metrics.Registry
+	requests         *metrics.CounterVec
+	requestDurations *metrics.HistogramVec
+	backendDurations *metrics.HistogramVec
+	library          libraryCounts
+}
+
+// libraryCounts are the number of recipes and tags in the library, as last counted by
+// WatchLibrary
+type libraryCounts struct {
+	mu      sync.Mutex
+	counted bool
+	recipes int
+	tags    int
+}
+
+// libraryCountInterval is how often WatchLibrary counts the recipes and tags
+const libraryCountInterval = time.Minute
+
+// newServerMetrics registers the server's metrics in a new registry
+func newServerMetrics() *serverMetrics {
+	registry := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: registry,
+		requests: registry.NewCounterVec("recipes_http_requests_total",
+			"Number of HTTP requests handled, by route and status.", "method", "route", "status"),
+		requestDurations: registry.NewHistogramVec("recipes_http_request_duration_seconds",
+			"Time taken to handle HTTP requests, by route and status.", metrics.DefaultBuckets,
+			"method", "route", "status"),
+		backendDurations: registry.NewHistogramVec("recipes_backend_operation_duration_seconds",
+			"Time taken by recipe manager operations, by operation and outcome.", metrics.DefaultBuckets,
+			"operation", "outcome"),
+	}
+	registry.NewGaugeFunc("recipes_library_recipes", "Number of recipes, not counting the trash.",
+		m.library.value(func(counts *libraryCounts) int { return counts.recipes }))
+	registry.NewGaugeFunc("recipes_library_tags", "Number of distinct tags on recipes.",
+		m.library.value(func(counts *libraryCounts) int { return counts.tags }))
+	return m
+}
+
+// value returns a gauge function for one of the counts, which leaves the gauge out
+// until the library has been counted
+func (l *libraryCounts) value(count func(*libraryCounts) int) func() (float64, error) {
+	return func() (float64, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.counted {
+			return 0, errors.New("the library hasn't been counted yet")
+		}
+		return float64(count(l)), nil
+	}
+}
+
+// CountDroppedEvents adds a gauge for how many event subscribers the bus has dropped for
+// falling behind
+func (m *serverMetrics) CountDroppedEvents(bus *recipes.Bus) {
+	m.registry.NewGaugeFunc("recipes_event_subscribers_dropped",
+		"Number of event subscribers dropped for falling behind.",
+		func() (float64, error) { return float64(bus.Dropped()), nil })
+}
+
+// Middleware counts and times every request
+func (m *serverMetrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Label requests by their route pattern rather than their path, so that IDs
+		// don't make a new series each
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.Inc(c.Request.Method, route, status)
+		m.requestDurations.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// ObserveBackend times recipe manager operations (see recipes.Instrument)
+func (m *serverMetrics) ObserveBackend(ctx context.Context, operation string, duration time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.backendDurations.Observe(duration.Seconds(), operation, outcome)
+}
+
+// WatchLibrary counts the recipes and tags in the library for their gauges right away
+// and then once per interval, so that scraping the metrics doesn't load every recipe. If
+// counting fails, the gauges keep their last values. It only returns once the context is
+// cancelled.
+func (m *serverMetrics) WatchLibrary(ctx context.Context, recipe_manager recipes.RecipeManager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Count everything, then update both gauges at once
+		count, err := recipe_manager.CountRecipes(ctx)
+		var tags []string
+		if err == nil {
+			tags, err = recipe_manager.GetTags(ctx)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to count the library", "error", err)
+		} else {
+			m.library.mu.Lock()
+			m.library.counted, m.library.recipes, m.library.tags = true, count, len(tags)
+			m.library.mu.Unlock()
+		}
+
+		// Wait for the next count
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func AddHealthAPI(router gin.IRouter, recipe_manager recipes.RecipeManager, server_metrics *serverMetrics) {
+	// GET /healthz - check that the server is running
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// GET /readyz - check that the server can reach its backend and take requests
+	router.GET("/readyz", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		if err := recipe_manager.Ping(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// GET /metrics - get the server's metrics in the Prometheus text format
+	router.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := server_metrics.registry.Write(c.Writer); err != nil {
+			c.Error(err)
+		}
+	})
+}
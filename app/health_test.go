@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// countingRecipeManager has two recipes and one tag, and counts how often the recipes
+// are counted
+type countingRecipeManager struct {
+	recipes.RecipeManager
+	counts atomic.Int32
+}
+
+func (m *countingRecipeManager) CountRecipes(ctx context.Context) (int, error) {
+	m.counts.Add(1)
+	return 2, nil
+}
+
+func (m *countingRecipeManager) GetTags(ctx context.Context) ([]string, error) {
+	return []string{"dinner"}, nil
+}
+
+// TestWatchLibrary tests that the library gauges are counted in the background rather
+// than each time the metrics are scraped
+func TestWatchLibrary(t *testing.T) {
+	server_metrics := newServerMetrics()
+	scrape := func() string {
+		var out strings.Builder
+		if err := server_metrics.registry.Write(&out); err != nil {
+			t.Fatalf("Failed to write metrics: %v", err)
+		}
+		return out.String()
+	}
+
+	// The gauges are left out until the library has been counted
+	if strings.Contains(scrape(), "recipes_library_recipes") {
+		t.Errorf("Expected no library gauges before counting")
+	}
+
+	manager := &countingRecipeManager{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server_metrics.WatchLibrary(ctx, manager, time.Hour)
+		close(done)
+	}()
+	for deadline := time.Now().Add(time.Second); manager.counts.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// Scraping reports the counts without counting the recipes again
+	for i := 0; i < 3; i++ {
+		out := scrape()
+		if !strings.Contains(out, "recipes_library_recipes 2\n") || !strings.Contains(out, "recipes_library_tags 1\n") {
+			t.Fatalf("Expected two recipes and one tag, got:\n%s", out)
+		}
+	}
+	if counts := manager.counts.Load(); counts != 1 {
+		t.Errorf("Expected the recipes to be counted once, got %d", counts)
+	}
+}
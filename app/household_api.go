@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// NewHousehold is the body of requests that create a household
+type NewHousehold struct {
+	Name string
+}
+
+// NewInvitation is the body of requests that invite a user to join a household
+type NewInvitation struct {
+	Username string
+}
+
+func AddHouseholdsAPI(router gin.IRouter, user_store users.UserStore) {
+	// Provide an API for managing the logged-in user's household. Members of a
+	// household can see and edit each other's recipes unless they are private.
+	householdsAPI := router.Group("/api/households", RequireUser(), RequireScopeForWrites())
+	{
+		// POST /api/households - create a household with the logged-in user in it
+		householdsAPI.POST("/", func(c *gin.Context) {
+			// Get the household from the request
+			var newHousehold NewHousehold
+			if err := c.BindJSON(&newHousehold); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if newHousehold.Name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "household name is required"})
+				return
+			}
+
+			// Create it
+			user, _ := currentUser(c)
+			household, err := users.CreateHousehold(c.Request.Context(), user_store, user, newHousehold.Name)
+			if errors.Is(err, users.ErrInHousehold) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Household created successfully", "household": household})
+		})
+
+		// GET /api/households/mine - get the logged-in user's household and its members
+		householdsAPI.GET("/mine", RequireHousehold(), func(c *gin.Context) {
+			user, _ := currentUser(c)
+			household, err := user_store.GetHousehold(c.Request.Context(), user.HouseholdID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			members, err := user_store.GetHouseholdMembers(c.Request.Context(), user.HouseholdID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"household": household, "members": members})
+		})
+
+		// POST /api/households/mine/invitations - invite a user to join the logged-in
+		// user's household. They only join once they accept the invitation.
+		householdsAPI.POST("/mine/invitations", RequireHousehold(), func(c *gin.Context) {
+			// Get the user to invite from the request
+			var newInvitation NewInvitation
+			if err := c.BindJSON(&newInvitation); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Invite them
+			user, _ := currentUser(c)
+			invitation, err := users.InviteHouseholdMember(c.Request.Context(), user_store, user, newInvitation.Username)
+			if errors.Is(err, users.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+				return
+			}
+			if errors.Is(err, users.ErrInHousehold) || errors.Is(err, users.ErrAlreadyInvited) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully", "invitation": invitation})
+		})
+
+		// GET /api/households/invitations - get the invitations the logged-in user has
+		// been sent to join households
+		householdsAPI.GET("/invitations", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			invitations, err := user_store.ListInvitations(c.Request.Context(), user.ID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, invitations)
+		})
+
+		// POST /api/households/invitations/:id/accept - join the household that sent the
+		// logged-in user an invitation
+		householdsAPI.POST("/invitations/:id/accept", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			household, err := users.AcceptInvitation(c.Request.Context(), user_store, user, c.Param("id"))
+			if errors.Is(err, users.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+				return
+			}
+			if errors.Is(err, users.ErrInHousehold) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Joined household successfully", "household": household})
+		})
+
+		// DELETE /api/households/invitations/:id - decline an invitation sent to the
+		// logged-in user
+		householdsAPI.DELETE("/invitations/:id", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			err := users.DeclineInvitation(c.Request.Context(), user_store, user, c.Param("id"))
+			if errors.Is(err, users.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+		})
+
+		// DELETE /api/households/mine/members/:user_id - remove a user from the logged-in
+		// user's household (including the logged-in user themselves)
+		householdsAPI.DELETE("/mine/members/:user_id", RequireHousehold(), func(c *gin.Context) {
+			user, _ := currentUser(c)
+			err := users.RemoveHouseholdMember(c.Request.Context(), user_store,
+				user.HouseholdID.Hex(), c.Param("user_id"))
+			if errors.Is(err, users.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "user is not a member of this household"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+		})
+	}
+}
+
+// RequireHousehold rejects requests from users who are not in a household. It must come
+// after RequireUser.
+func RequireHousehold() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, _ := currentUser(c); !user.InHousehold() {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not in a household"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/logging"
+)
+
+// requestIDHeader carries the ID of each request, so that it can be traced through the
+// logs of this server and any in front of it
+const requestIDHeader = "X-Request-ID"
+
+// RequestID gives each request an ID, taking it from the X-Request-ID header if the
+// client (or a proxy) sent a usable one. The ID is sent back in the response and
+// carried in the request context, so that everything logged for the request has it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// LogRequests logs a structured line for every request once it has been handled
+func LogRequests(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Server errors are errors, client errors are warnings, and the rest is info
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			slog.String("method", c.Request.Method),
+			slog.String("path", loggedPath(c)),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			logging.DurationMS(time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if user, ok := currentUser(c); ok {
+			attrs = append(attrs, slog.String("user", user.Username))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// secretParams are the route parameters that hold secrets, such as share link tokens,
+// which must never be logged
+var secretParams = map[string]bool{"token": true}
+
+// loggedPath returns the path of the request with the values of any secret route
+// parameters redacted
+// e.g. /share/REDACTED
+func loggedPath(c *gin.Context) string {
+	path := c.Request.URL.Path
+	for _, param := range c.Params {
+		if secretParams[param.Key] && param.Value != "" {
+			path = strings.Replace(path, param.Value, "REDACTED", 1)
+		}
+	}
+	return path
+}
+
+// RecoverAndLog turns panics in handlers into 500 responses, logging what happened
+func RecoverAndLog(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		// Handlers that can't finish a response they have started, such as a stream that
+		// fails part way through, abort it so the client can tell it was cut short
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		logger.ErrorContext(c.Request.Context(), "panic while handling request",
+			"path", loggedPath(c), "panic", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	})
+}
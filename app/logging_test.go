@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestLogRequestsRedactsTokens tests that share link tokens in request paths never
+// reach the logs
+func TestLogRequestsRedactsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(LogRequests(slog.New(slog.NewJSONHandler(&logs, nil))))
+	router.GET("/share/:token", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/api/recipes/id/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/share/s3cr3t-t0ken", "/api/recipes/id/abc123"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := logs.String()
+	if strings.Contains(out, "s3cr3t-t0ken") || !strings.Contains(out, `"path":"/share/REDACTED"`) {
+		t.Errorf("Expected the share token to be redacted, got %s", out)
+	}
+	if !strings.Contains(out, `"path":"/api/recipes/id/abc123"`) {
+		t.Errorf("Expected other paths to be logged as they are, got %s", out)
+	}
+}
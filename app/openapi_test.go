@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/config"
+)
+
+// openAPIDocument is the part of the OpenAPI document that the tests look at
+type openAPIDocument struct {
+	OpenAPI string                                 `json:"openapi"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string                    `json:"operationId"`
+	Parameters  []map[string]interface{}  `json:"parameters"`
+	Responses   map[string]map[string]any `json:"responses"`
+}
+
+// loadSpec parses the embedded OpenAPI document
+func loadSpec(t *testing.T) openAPIDocument {
+	var spec openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("Failed to parse openapi.json: %v", err)
+	}
+	return spec
+}
+
+// testRouter builds the server's router without a backend, which is enough to see its
+// routes
+func testRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return newRouter(config.Default(), logger, &backend{}, newServerMetrics(), context.Background())
+}
+
+// ginParam matches the path parameters in a Gin route, e.g. ":id"
+var ginParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// TestOpenAPIMatchesRoutes tests that the OpenAPI document describes exactly the routes
+// the server provides
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	// Gather the routes from the router, written the way OpenAPI writes them. The
+	// frontend's static files aren't part of the API.
+	routes := make(map[string]bool)
+	for _, route := range testRouter().Routes() {
+		if strings.HasPrefix(route.Path, "/app/") {
+			continue
+		}
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		routes[strings.ToLower(route.Method)+" "+path] = true
+	}
+
+	// And the operations from the document
+	operations := make(map[string]bool)
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			operations[method+" "+path] = true
+		}
+	}
+
+	var missing, extra []string
+	for route := range routes {
+		if !operations[route] {
+			missing = append(missing, route)
+		}
+	}
+	for operation := range operations {
+		if !routes[operation] {
+			extra = append(extra, operation)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		t.Errorf("Routes missing from openapi.json: %v", missing)
+	}
+	if len(extra) > 0 {
+		t.Errorf("Operations in openapi.json with no route: %v", extra)
+	}
+}
+
+// TestOpenAPIOperations tests that every operation is complete and consistent
+func TestOpenAPIOperations(t *testing.T) {
+	spec := loadSpec(t)
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version %q", spec.OpenAPI)
+	}
+
+	// Parameters shared between operations are referenced from the components
+	var components struct {
+		Components struct {
+			Parameters map[string]map[string]interface{} `json:"parameters"`
+		} `json:"components"`
+	}
+	json.Unmarshal(openAPISpec, &components)
+
+	operationIDs := make(map[string]string)
+	for path, methods := range spec.Paths {
+		for method, operation := range methods {
+			name := method + " " + path
+
+			// Operation IDs must be unique, for generated clients
+			if operation.OperationID == "" {
+				t.Errorf("%s: no operationId", name)
+			} else if other, ok := operationIDs[operation.OperationID]; ok {
+				t.Errorf("%s: operationId %q is also used by %s", name, operation.OperationID, other)
+			}
+			operationIDs[operation.OperationID] = name
+
+			if _, ok := operation.Responses["200"]; !ok {
+				t.Errorf("%s: no successful response", name)
+			}
+
+			// Every parameter in the path must be described
+			described := make(map[string]bool)
+			for _, parameter := range operation.Parameters {
+				if ref, ok := parameter["$ref"].(string); ok {
+					parameter = components.Components.Parameters[strings.TrimPrefix(ref, "#/components/parameters/")]
+				}
+				if parameter["in"] == "path" {
+					described[parameter["name"].(string)] = true
+				}
+			}
+			for _, match := range regexp.MustCompile(`\{([A-Za-z_]+)\}`).FindAllStringSubmatch(path, -1) {
+				if !described[match[1]] {
+					t.Errorf("%s: path parameter %q is not described", name, match[1])
+				}
+			}
+		}
+	}
+}
+
+// TestOpenAPIReferences tests that every reference in the document points to something
+func TestOpenAPIReferences(t *testing.T) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		t.Fatalf("Failed to parse openapi.json: %v", err)
+	}
+
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if ref, ok := value["$ref"].(string); ok {
+				// Follow the reference from the root of the document
+				var target interface{} = document
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					object, _ := target.(map[string]interface{})
+					target = object[part]
+				}
+				if target == nil {
+					t.Errorf("Reference %q points to nothing", ref)
+				}
+			}
+			for _, child := range value {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range value {
+				walk(child)
+			}
+		}
+	}
+	walk(document)
+}
+
+// TestServeOpenAPI tests that the document and the docs page are served
+func TestServeOpenAPI(t *testing.T) {
+	router := testRouter()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if response.Code != http.StatusOK || response.Body.String() != string(openAPISpec) {
+		t.Errorf("Expected the OpenAPI document, got %d", response.Code)
+	}
+
+	// The docs page's script must be allowed to run by the content security policy
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "<script>"+apiDocsScript+"</script>") {
+		t.Errorf("Expected the docs page, got %d: %s", response.Code, response.Body.String())
+	}
+	csp := response.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "'sha256-"+scriptHash(apiDocsScript)+"'") {
+		t.Errorf("Expected the docs script to be allowed, got %q", csp)
+	}
+}
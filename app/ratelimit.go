@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/config"
+	"github.com/dawsonc/recipes/src/ratelimit"
+)
+
+// RateLimit stops each client from making requests faster than the configured limits,
+// with separate budgets for reading and for changing things. Clients are told apart by
+// the API token they use, then by the user they are logged in as, and otherwise by
+// their IP address, so it must come after Authenticate.
+func RateLimit(cfg config.Limits) gin.HandlerFunc {
+	reads := ratelimit.NewLimiter(cfg.Reads.PerMinute, cfg.Reads.Burst)
+	writes := ratelimit.NewLimiter(cfg.Writes.PerMinute, cfg.Writes.Burst)
+
+	return func(c *gin.Context) {
+		limiter := writes
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			limiter = reads
+		}
+
+		// GraphQL queries are POSTed, but they can only read
+		if c.Request.URL.Path == graphQLPath {
+			limiter = reads
+		}
+
+		allowed, wait := limiter.Allow(clientKey(c), time.Now())
+		if !allowed {
+			// Round up, so that clients that wait as long as they are told succeed
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, please slow down"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies who made a request, for rate limiting
+func clientKey(c *gin.Context) string {
+	if tokenID := c.GetString(tokenIDKey); tokenID != "" {
+		return "token:" + tokenID
+	}
+	if user, ok := currentUser(c); ok {
+		return "user:" + user.ID.Hex()
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// LimitBodySize rejects POST and PUT requests with a body larger than the given number
+// of bytes, or the limit given for their route (e.g. "/api/admin/restore"). Bodies that
+// don't say how big they are up front are cut off at the limit, so reading them fails.
+func LimitBodySize(defaultMaxBytes int, routeLimits map[string]int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		maxBytes, ok := routeLimits[c.FullPath()]
+		if !ok {
+			maxBytes = defaultMaxBytes
+		}
+
+		if c.Request.ContentLength > int64(maxBytes) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+				gin.H{"error": "request body is larger than " + strconv.Itoa(maxBytes) + " bytes"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes))
+		c.Next()
+	}
+}
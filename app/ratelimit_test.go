@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/config"
+)
+
+// TestRateLimitIgnoresForwardedFor tests that clients can't get around the rate limit by
+// claiming to be someone else with X-Forwarded-For, unless they are a trusted proxy
+func TestRateLimitIgnoresForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	request := func(router *gin.Engine, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/nothing", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// By default, a client is limited however many addresses it claims to forward for
+	cfg := config.Default()
+	cfg.Limits.Reads = config.RateLimit{PerMinute: 1, Burst: 1}
+	router := newRouter(cfg, logger, &backend{}, newServerMetrics(), context.Background())
+	if code := request(router, "203.0.113.7:1234", "198.51.100.1"); code == http.StatusTooManyRequests {
+		t.Fatalf("Expected the first request to be allowed")
+	}
+	if code := request(router, "203.0.113.7:1234", "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a spoofed X-Forwarded-For to still be limited, got %d", code)
+	}
+
+	// But a trusted proxy can say which client each request is for
+	cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	router = newRouter(cfg, logger, &backend{}, newServerMetrics(), context.Background())
+	if code := request(router, "10.0.0.2:1234", "198.51.100.1"); code == http.StatusTooManyRequests {
+		t.Fatalf("Expected the first client to be allowed")
+	}
+	if code := request(router, "10.0.0.2:1234", "198.51.100.2"); code == http.StatusTooManyRequests {
+		t.Errorf("Expected a second client behind the proxy to be allowed")
+	}
+	if code := request(router, "10.0.0.2:1234", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the first client to be limited, got %d", code)
+	}
+}
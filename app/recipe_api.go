@@ -1,15 +1,18 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/dawsonc/recipes/src/recipes"
 )
 
-func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
+func AddRecipesAPI(router gin.IRouter, recipe_manager recipes.RecipeManager) {
 	// Provide a RESTful API for recipes
 	recipesAPI := router.Group("/api/recipes")
 	{
@@ -17,6 +20,8 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 		// e.g. /api/recipes?id=ID
 		// e.g. /api/recipes?tags=tag1,tag2
 		// e.g. /api/recipes?q=search_term&tags=tag1,tag2
+		// e.g. /api/recipes?created_after=2023-01-01&sort=created&order=desc
+		// e.g. /api/recipes?sort=rating&order=desc
 		recipesAPI.GET("/", func(c *gin.Context) {
 			// Allow optional query strings
 			id := c.Query("id")
@@ -32,26 +37,24 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 			// Get recipes based on the provided queries
 			var queried_recipes []recipes.Recipe
 			var err error
-			switch {
-			case id != "":
+			if id != "" {
 				// Get the recipe from the database with the given ID
 				var recipe recipes.Recipe
-				recipe, err = recipe_manager.GetRecipeByID(id)
+				recipe, err = recipe_manager.GetRecipeByID(c.Request.Context(), id)
 				// Wrap the recipe in a single element slice
 				queried_recipes = []recipes.Recipe{recipe}
-			case search_term != "":
-				// Get all recipes that match the given tags and search term
-				queried_recipes, err = recipe_manager.SearchRecipes(search_term, tags)
-			case len(tags) > 0:
-				// Get all recipes that match the given tags
-				queried_recipes, err = recipe_manager.GetRecipesByTags(tags)
-			default:
-				// Get all recipes from the database
-				queried_recipes, err = recipe_manager.GetAllRecipes()
+			} else {
+				// Get all recipes that match the given filters, in the requested order
+				opts := recipes.ListOptions{Query: search_term, Tags: tags}
+				if err := parseListOptions(c, &opts); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				queried_recipes, err = recipe_manager.ListRecipes(c.Request.Context(), opts)
 			}
 
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
@@ -61,9 +64,9 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 		// GET /api/recipes/tags - get all tags
 		recipesAPI.GET("/tags", func(c *gin.Context) {
 			// Get all tags from the database
-			tags, err := recipe_manager.GetTags()
+			tags, err := recipe_manager.GetTags(c.Request.Context())
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
@@ -79,10 +82,19 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 				return
 			}
 
+			// Recipes default to being visible to the user's household, but they can
+			// choose otherwise up front
+			if recipe.Visibility != "" {
+				if err := recipes.ValidateVisibility(recipe.Visibility); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
 			// Insert the recipe into the database
-			id, err := recipe_manager.AddRecipe(recipe)
+			id, err := recipe_manager.AddRecipe(c.Request.Context(), recipe)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
@@ -95,9 +107,9 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 			id := c.Param("id")
 
 			// Get the recipe from the database
-			recipe, err := recipe_manager.GetRecipeByID(id)
+			recipe, err := recipe_manager.GetRecipeByID(c.Request.Context(), id)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
@@ -120,28 +132,87 @@ func AddRecipesAPI(router *gin.Engine, recipe_manager recipes.RecipeManager) {
 			recipe.SetID(id)
 
 			// Update the recipe
-			err := recipe_manager.UpdateRecipe(recipe)
+			err := recipe_manager.UpdateRecipe(c.Request.Context(), recipe)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
 			c.JSON(http.StatusOK, gin.H{"message": "Recipe updated successfully"})
 		})
 
-		// DELETE /api/recipes/:id - delete a recipe by ID
+		// DELETE /api/recipes/:id - move a recipe to the trash by ID
 		recipesAPI.DELETE("/id/:id", func(c *gin.Context) {
 			// Get the ID from the URL
 			id := c.Param("id")
 
-			// Delete the recipe from the database
-			err := recipe_manager.DeleteRecipe(id)
+			// Move the recipe to the trash
+			err := recipe_manager.DeleteRecipe(c.Request.Context(), id)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondWithError(c, err)
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{"message": "Recipe deleted successfully"})
+			c.JSON(http.StatusOK, gin.H{"message": "Recipe moved to trash"})
 		})
 	}
 }
+
+// respondWithError reports an error from the recipe manager as JSON, using the status
+// code that matches the kind of error
+func respondWithError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, recipes.ErrNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, recipes.ErrForbidden) {
+		status = http.StatusForbidden
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// parseListOptions reads the optional date range and sorting query strings into the
+// list options
+// e.g. ?created_after=2023-01-01&created_before=2023-02-01T12:00:00Z
+// e.g. ?updated_after=2023-01-01&sort=updated&order=desc
+func parseListOptions(c *gin.Context, opts *recipes.ListOptions) error {
+	// Parse each of the date bounds that was given
+	dates := map[string]*time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+		"updated_after":  &opts.UpdatedAfter,
+		"updated_before": &opts.UpdatedBefore,
+	}
+	for param, date := range dates {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseDate(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", param, err)
+		}
+		*date = parsed
+	}
+
+	// Sort in ascending order unless told otherwise
+	opts.SortBy = c.Query("sort")
+	switch order := c.DefaultQuery("order", "asc"); order {
+	case "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		return fmt.Errorf("invalid order %q: expected asc or desc", order)
+	}
+
+	return opts.Validate()
+}
+
+// parseDate parses a date given either as an RFC 3339 timestamp or a plain date
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+func AddRevisionsAPI(router gin.IRouter, recipe_manager recipes.RecipeManager) {
+	// Provide an API for browsing and restoring the revision history of a recipe
+	revisionsAPI := router.Group("/api/recipes/id/:id/revisions")
+	{
+		// GET /api/recipes/id/:id/revisions - list all revisions of a recipe
+		revisionsAPI.GET("/", func(c *gin.Context) {
+			revisions, err := recipe_manager.GetRevisions(c.Request.Context(), c.Param("id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, revisions)
+		})
+
+		// GET /api/recipes/id/:id/revisions/diff - compare two revisions
+		// e.g. /api/recipes/id/ID/revisions/diff?from=1&to=3
+		revisionsAPI.GET("/diff", func(c *gin.Context) {
+			// Both revision numbers are required
+			from, err := strconv.Atoi(c.Query("from"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' revision: " + err.Error()})
+				return
+			}
+			to, err := strconv.Atoi(c.Query("to"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' revision: " + err.Error()})
+				return
+			}
+
+			// Load both snapshots and compare them
+			fromRevision, err := recipe_manager.GetRevision(c.Request.Context(), c.Param("id"), from)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+			toRevision, err := recipe_manager.GetRevision(c.Request.Context(), c.Param("id"), to)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"from":    from,
+				"to":      to,
+				"changes": recipes.DiffRecipes(fromRevision.Recipe, toRevision.Recipe),
+			})
+		})
+
+		// GET /api/recipes/id/:id/revisions/:number - get a single revision
+		revisionsAPI.GET("/:number", func(c *gin.Context) {
+			number, err := strconv.Atoi(c.Param("number"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision number: " + err.Error()})
+				return
+			}
+
+			revision, err := recipe_manager.GetRevision(c.Request.Context(), c.Param("id"), number)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, revision)
+		})
+
+		// POST /api/recipes/id/:id/revisions/:number/restore - restore an old revision,
+		// recording it as a new revision
+		revisionsAPI.POST("/:number/restore", func(c *gin.Context) {
+			number, err := strconv.Atoi(c.Param("number"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision number: " + err.Error()})
+				return
+			}
+
+			err = recipe_manager.RestoreRevision(c.Request.Context(), c.Param("id"), number)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Revision restored successfully"})
+		})
+	}
+}
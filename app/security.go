@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/config"
+)
+
+// Headers allowed and exposed on cross-origin requests to the API
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE"
+	corsAllowedHeaders = "Authorization, Content-Type, X-Request-ID"
+	corsExposedHeaders = "X-Request-ID, Retry-After"
+)
+
+// frontendCSP only lets pages load scripts and styles from this server and the CDNs
+// used by frontend/index.html. The frontend compiles its JSX in the browser with Babel,
+// which needs inline scripts and eval.
+const frontendCSP = "default-src 'self'; " +
+	"script-src 'self' https://cdnjs.cloudflare.com 'unsafe-inline' 'unsafe-eval'; " +
+	"style-src 'self' https://cdn.jsdelivr.net 'unsafe-inline'; " +
+	"img-src 'self' data: https:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// sharedRecipeCSP lets a shared recipe page use its inline styles, but nothing else
+const sharedRecipeCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:; " +
+	"base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// securityHeaders are sent with every response unless a route overrides them
+var securityHeaders = map[string]string{
+	"Content-Security-Policy": frontendCSP,
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Referrer-Policy":         "strict-origin-when-cross-origin",
+}
+
+// hstsHeader tells browsers to only use HTTPS for this site for the next year
+const hstsHeader = "max-age=31536000; includeSubDomains"
+
+// SecurityHeaders sets headers that limit what browsers let pages from this server do:
+// where they can load content from, whether they can be framed, and (over HTTPS) that
+// the site should only ever be visited over HTTPS
+func SecurityHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		for name, value := range securityHeaders {
+			header.Set(name, value)
+		}
+		if c.Request.TLS != nil {
+			header.Set("Strict-Transport-Security", hstsHeader)
+		}
+
+		c.Next()
+	}
+}
+
+// OverrideHeaders replaces the security headers for a route, e.g. to loosen the content
+// security policy for a page that needs it. An empty value removes the header.
+func OverrideHeaders(overrides map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		for name, value := range overrides {
+			if value == "" {
+				header.Del(name)
+			} else {
+				header.Set(name, value)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CORS lets the configured origins call the API from a browser. It only applies to
+// paths under /api and to /graphql, and answers preflight requests before they reach
+// any route (so they don't need to be logged in).
+func CORS(cfg config.CORS) gin.HandlerFunc {
+	// Browsers never send a trailing slash in the origin
+	allowed := make(map[string]bool)
+	anyOrigin := false
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, "/api/") && path != graphQLPath || origin == "" {
+			c.Next()
+			return
+		}
+
+		// The answer depends on the origin, so caches must keep them apart
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// Other origins get no CORS headers, so the browser won't let them read the response
+		if !anyOrigin && !allowed[origin] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			header.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+}
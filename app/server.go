@@ -1,27 +1,361 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/config"
+	"github.com/dawsonc/recipes/src/logging"
 	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+	"github.com/dawsonc/recipes/src/webhooks"
 )
 
-func main() {
-	// Make a router
-	router := gin.Default()
+// backend holds the stores the server keeps its data in
+type backend struct {
+	recipe_manager recipes.RecipeManager
+	cook_log       recipes.CookLog
+	share_links    recipes.ShareLinks
+	user_store     users.UserStore
+	audit_store    audit.Store
+	webhook_store  webhooks.Store
+	events         recipes.EventSource
+	// bus carries the events when they don't come from the database
+	bus *recipes.Bus
+}
+
+// connectBackend connects to the configured database. Every operation on recipes, their
+// cook logs, and their share links is reported to the observer and any changes are
+// recorded in the audit log. Changes to recipes are also published as events.
+func connectBackend(cfg config.Backend, observe recipes.Observer) (*backend, error) {
+	switch cfg.Type {
+	case config.BackendMongo:
+		mongo_recipe_manager, err := recipes.CreateMongoRecipeManager(cfg.DSN, cfg.Database, cfg.Collection)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to MongoDB for recipes: %w", err)
+		}
+		user_store, err := users.CreateMongoUserStore(cfg.DSN, cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to MongoDB for users: %w", err)
+		}
+		audit_store, err := audit.CreateMongoStore(cfg.DSN, cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to MongoDB for the audit log: %w", err)
+		}
+		webhook_store, err := webhooks.CreateMongoStore(cfg.DSN, cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to MongoDB for webhooks: %w", err)
+		}
 
-	// Create the recipes manager and connect to MongoDB
-	recipe_manager, err := recipes.CreateMongoRecipeManager("mongodb://localhost:27017", "recipes", "recipes")
+		// Events come from the database itself if it can tell us about changes made by
+		// every server, and otherwise from the changes made through this one
+		var recipe_manager recipes.RecipeManager = recipes.Instrument(mongo_recipe_manager, observe)
+		var events recipes.EventSource = mongo_recipe_manager
+		var bus *recipes.Bus
+		if !cfg.ChangeStreams {
+			bus = recipes.NewBus()
+			recipe_manager = recipes.PublishChanges(recipe_manager, bus)
+			events = bus
+		}
+
+		return &backend{
+			recipe_manager: audit.NewRecipeManager(recipe_manager, audit_store),
+			cook_log:       audit.NewCookLog(recipes.InstrumentCookLog(mongo_recipe_manager, observe), audit_store),
+			share_links:    audit.NewShareLinks(recipes.InstrumentShareLinks(mongo_recipe_manager, observe), audit_store),
+			user_store:     user_store,
+			audit_store:    audit_store,
+			webhook_store:  webhook_store,
+			events:         events,
+			bus:            bus,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported backend %q", cfg.Type)
+}
+
+// Close disconnects from the database, reporting every store that fails to close
+func (b *backend) Close() error {
+	return errors.Join(b.recipe_manager.Close(), b.user_store.Close(), b.audit_store.Close(), b.webhook_store.Close())
+}
+
+// webhookViewer returns the viewer that a user's webhooks see recipes as: the user
+// themselves, as if they were using a read-only API token
+func (b *backend) webhookViewer(ctx context.Context, userID string) (recipes.Viewer, error) {
+	user, err := b.user_store.GetUserByID(ctx, userID)
 	if err != nil {
-		panic(err)
+		return recipes.Viewer{}, err
 	}
+	return viewerFor(user, users.ScopeRead), nil
+}
+
+// newRouter makes a router that serves the API and the frontend from the backend. It
+// traces, logs, and keeps metrics on every request, and tells browsers which sites may
+// use it. Long-lived event streams end once the shutdown context is done.
+func newRouter(cfg config.Config, logger *slog.Logger, data *backend, server_metrics *serverMetrics, shutdown context.Context) *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		// The settings have been validated, but trust no one rather than everyone if
+		// they are wrong
+		logger.Error("invalid trusted proxies", "error", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(RequestID(), LogRequests(logger), RecoverAndLog(logger), server_metrics.Middleware())
+	router.Use(SecurityHeaders(), CORS(cfg.CORS))
+	recipe_manager, user_store := data.recipe_manager, data.user_store
+
+	// Let the orchestrator check on the server, and describe the API for its clients
+	AddHealthAPI(router, recipe_manager, server_metrics)
+	AddAPIDocs(router)
 
-	// Provide a RESTful API for recipes
-	AddRecipesAPI(router, recipe_manager)
+	// Work out who is making each request and where from, stop any one client from
+	// making too many requests, and provide an API for logging in
+	routeLimits := map[string]int{"/api/admin/restore": cfg.Limits.MaxRestoreBytes}
+	router.Use(LimitBodySize(cfg.Limits.MaxBodyBytes, routeLimits), RecordClientIP(), Authenticate(user_store))
+	router.Use(RateLimit(cfg.Limits))
+	AddAuthAPI(router, user_store)
+	AddHouseholdsAPI(router, user_store)
+	AddTokensAPI(router, user_store)
+	AddAuditAPI(router, data.audit_store)
+	AddBackupAPI(router, recipe_manager, user_store, data.audit_store)
+	AddWebhooksAPI(router, data.webhook_store)
+
+	// Provide a RESTful API for recipes. Anyone can read public recipes, but only
+	// logged-in users can change them, and only the ones they own or have been shared.
+	// API tokens need read-write scope to change anything.
+	recipesRouter := router.Group("/", RequireUserForWrites(), RequireScopeForWrites())
+	AddRecipesAPI(recipesRouter, recipe_manager)
+	AddBulkAPI(recipesRouter, recipe_manager)
+	AddEventsAPI(recipesRouter, data.events, shutdown)
+	AddRevisionsAPI(recipesRouter, recipe_manager)
+	AddTrashAPI(recipesRouter, recipe_manager)
+	AddCommentsAPI(recipesRouter, recipe_manager)
+	AddCookLogAPI(recipesRouter, data.cook_log)
+	AddSharingAPI(recipesRouter, recipe_manager, user_store)
+	AddShareLinksAPI(recipesRouter, data.share_links)
+
+	// Let clients ask for just the recipe data they need in a single request. GraphQL
+	// queries can only read, so anyone can make them.
+	AddGraphQLAPI(router, recipe_manager, data.cook_log, cfg.Limits)
+
+	// Let anyone with a share link read that one recipe
+	AddSharedRecipeRoutes(router, data.share_links)
 
 	// Serve frontend files
-	router.Static("/app", "./frontend")
+	router.Static("/app", cfg.FrontendDir)
+
+	return router
+}
+
+func main() {
+	// Back up or restore the library instead of serving it if asked to
+	if len(os.Args) > 1 && (os.Args[1] == "backup" || os.Args[1] == "restore") {
+		os.Exit(runBackupCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Load the settings from the config file, environment, and command line
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Log everything as JSON, including anything logged with the standard log package
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := logging.New(os.Stderr, level)
+	slog.SetDefault(logger)
+
+	// Gin only logs its routes and warnings when debugging
+	if cfg.LogLevel != config.LogLevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Load the certificate for HTTPS (or make one for development) before anything else,
+	// so that a problem with it stops the server straight away
+	var tlsConfig *tls.Config
+	var certFile, keyFile string
+	var grpcCreds credentials.TransportCredentials
+	if cfg.TLSEnabled() {
+		tlsConfig, certFile, keyFile, err = setUpTLS(cfg.TLS, logger)
+		if err == nil && cfg.GRPCListen != "" {
+			grpcCreds, err = grpcCredentials(tlsConfig, certFile, keyFile)
+		}
+		if err != nil {
+			logger.Error("failed to set up TLS", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Work out which private networks webhooks may send to
+	allowedNetworks, err := webhooks.ParseNetworks(cfg.Webhooks.AllowedNetworks)
+	if err != nil {
+		logger.Error("invalid webhook networks", "error", err)
+		os.Exit(2)
+	}
 
-	// Run the server
-	router.Run(":8080")
+	// Stop cleanly on Ctrl-C or when asked to by the system
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Connect to the database, keeping metrics on every operation
+	server_metrics := newServerMetrics()
+	slowQueries := logging.SlowQueryObserver(logger, cfg.SlowQueryThreshold)
+	observe := func(ctx context.Context, operation string, duration time.Duration, err error) {
+		server_metrics.ObserveBackend(ctx, operation, duration, err)
+		slowQueries(ctx, operation, duration, err)
+	}
+	data, err := connectBackend(cfg.Backend, observe)
+	if err != nil {
+		logger.Error("failed to connect to the backend", "error", err)
+		os.Exit(1)
+	}
+	recipe_manager := data.recipe_manager
+
+	// Empty the trash of old recipes in the background until the server stops
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		recipes.RunTrashPurger(jobsCtx, recipe_manager, cfg.TrashRetention, time.Hour)
+	}()
+
+	// And send changes to recipes to webhooks. Events on the bus are dropped for clients
+	// that fall behind, but the dispatcher gets every one.
+	webhook_events := data.events
+	if data.bus != nil {
+		webhook_events = data.bus.Reliable()
+		server_metrics.CountDroppedEvents(data.bus)
+	}
+	dispatcher := webhooks.NewDispatcher(data.webhook_store, data.webhookViewer, webhooks.Options{
+		Timeout:         cfg.Webhooks.Timeout,
+		MaxAttempts:     cfg.Webhooks.MaxAttempts,
+		AllowedNetworks: allowedNetworks,
+	})
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		dispatcher.Run(jobsCtx, webhook_events)
+	}()
+
+	// And count the library for the metrics
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		server_metrics.WatchLibrary(jobsCtx, recipe_manager, libraryCountInterval)
+	}()
+
+	// Let the orchestrator check on the server, and serve the API and frontend. Event
+	// streams only end when the client goes away, which shutting down would wait for, so
+	// they are closed as soon as it starts.
+	streamsCtx, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+	router := newRouter(cfg, logger, data, server_metrics, streamsCtx)
+
+	// Run the server until it fails or is asked to stop
+	server := &http.Server{
+		Addr:         cfg.Listen,
+		Handler:      router,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+	}
+	server.RegisterOnShutdown(closeStreams)
+	servers := []*http.Server{server}
+	serverErr := make(chan error, 3)
+	if cfg.TLSEnabled() {
+		server.TLSConfig = tlsConfig
+		go func() {
+			logger.Info("listening", "address", cfg.Listen, "tls", true, "min_version", cfg.TLS.MinVersion,
+				"client_auth", cfg.TLS.ClientAuth)
+			serverErr <- server.ListenAndServeTLS(certFile, keyFile)
+		}()
+
+		// Send anyone who connects over plain HTTP to HTTPS instead
+		if cfg.TLS.RedirectHTTP != "" {
+			redirectServer := &http.Server{
+				Addr:         cfg.TLS.RedirectHTTP,
+				Handler:      redirectToHTTPS(cfg.Listen),
+				ReadTimeout:  cfg.Timeouts.Read,
+				WriteTimeout: cfg.Timeouts.Write,
+				IdleTimeout:  cfg.Timeouts.Idle,
+			}
+			servers = append(servers, redirectServer)
+			go func() {
+				logger.Info("redirecting HTTP to HTTPS", "address", cfg.TLS.RedirectHTTP)
+				serverErr <- redirectServer.ListenAndServe()
+			}()
+		}
+	} else {
+		go func() {
+			logger.Info("listening", "address", cfg.Listen, "tls", false)
+			serverErr <- server.ListenAndServe()
+		}()
+	}
+
+	// Serve the same recipes over gRPC on a separate port
+	grpcServer := newGRPCServer(logger, data, grpcCreds)
+	if cfg.GRPCListen != "" {
+		go func() {
+			listener, err := net.Listen("tcp", cfg.GRPCListen)
+			if err != nil {
+				serverErr <- err
+				return
+			}
+			logger.Info("serving gRPC", "address", cfg.GRPCListen, "tls", cfg.TLSEnabled())
+			serverErr <- grpcServer.Serve(listener)
+		}()
+	}
+	exitCode := 0
+	select {
+	case err = <-serverErr:
+		logger.Error("server failed", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		logger.Info("shutting down", "timeout", cfg.Timeouts.Shutdown.String())
+	}
+	stop()
+
+	// Stop taking new requests and let the ones in progress finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to finish all requests", "address", server.Addr, "error", err)
+		}
+	}
+	if err := stopGRPCServer(shutdownCtx, grpcServer); err != nil {
+		logger.Warn("failed to finish all gRPC calls", "address", cfg.GRPCListen, "error", err)
+	}
+
+	// Then stop the background jobs, and only disconnect from the database once
+	// nothing is using it
+	stopJobs()
+	jobs.Wait()
+	if err := data.Close(); err != nil {
+		logger.Error("failed to disconnect from the database", "error", err)
+	}
+	logger.Info("stopped")
+	os.Exit(exitCode)
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+//go:embed templates/shared_recipe.html templates/api_docs.html
+var templates embed.FS
+
+// sharedRecipeTemplate renders a recipe as a standalone, printable page
+var sharedRecipeTemplate = template.Must(template.ParseFS(templates, "templates/shared_recipe.html"))
+
+// NewShareLink is the body of requests that create a share link
+type NewShareLink struct {
+	// ExpiresAt is when the link stops working (never if it is not given)
+	ExpiresAt *time.Time
+}
+
+func AddShareLinksAPI(router gin.IRouter, share_links recipes.ShareLinks) {
+	// Provide an API for managing the public share links of a recipe
+	shareLinksAPI := router.Group("/api/recipes/id/:id/links")
+	{
+		// GET /api/recipes/id/:id/links - get the share links of a recipe (without their
+		// tokens, which are only shown when they are created)
+		shareLinksAPI.GET("/", func(c *gin.Context) {
+			links, err := share_links.GetShareLinks(c.Request.Context(), c.Param("id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, links)
+		})
+
+		// POST /api/recipes/id/:id/links - create a share link that anyone can use to
+		// read the recipe at /share/TOKEN (as a page) or /api/share/TOKEN (as JSON)
+		shareLinksAPI.POST("/", func(c *gin.Context) {
+			// Get the expiry time from the request
+			var newLink NewShareLink
+			if err := c.BindJSON(&newLink); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if newLink.ExpiresAt != nil && !newLink.ExpiresAt.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expiry time must be in the future"})
+				return
+			}
+
+			// Create the link
+			token, link, err := share_links.CreateShareLink(c.Request.Context(), c.Param("id"), newLink.ExpiresAt)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Share link created successfully",
+				"token":   token,
+				"url":     "/share/" + token,
+				"link":    link,
+			})
+		})
+
+		// DELETE /api/recipes/id/:id/links/:link_id - revoke a share link
+		shareLinksAPI.DELETE("/:link_id", func(c *gin.Context) {
+			err := share_links.RevokeShareLink(c.Request.Context(), c.Param("id"), c.Param("link_id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+		})
+	}
+}
+
+func AddSharedRecipeRoutes(router gin.IRouter, share_links recipes.ShareLinks) {
+	// Provide read-only access to recipes through their share links, for people
+	// without an account. Nothing else in the library is reachable from here.
+
+	// GET /api/share/:token - get a shared recipe as JSON
+	router.GET("/api/share/:token", func(c *gin.Context) {
+		recipe, err := share_links.GetSharedRecipe(c.Request.Context(), c.Param("token"))
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, recipe)
+	})
+
+	// GET /share/:token - get a shared recipe as a printable page. The token is in the
+	// URL, so it isn't passed on to any links the recipe has.
+	sharedPageHeaders := OverrideHeaders(map[string]string{
+		"Content-Security-Policy": sharedRecipeCSP,
+		"Referrer-Policy":         "no-referrer",
+	})
+	router.GET("/share/:token", sharedPageHeaders, func(c *gin.Context) {
+		recipe, err := share_links.GetSharedRecipe(c.Request.Context(), c.Param("token"))
+		if errors.Is(err, recipes.ErrNotFound) {
+			c.String(http.StatusNotFound, "This link has expired or does not exist.")
+			return
+		}
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Something went wrong loading this recipe.")
+			return
+		}
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := sharedRecipeTemplate.Execute(c.Writer, recipe); err != nil {
+			c.Error(err)
+		}
+	})
+}
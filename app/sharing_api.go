@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// Visibility is the body of requests that change who can see a recipe
+type Visibility struct {
+	Visibility string
+}
+
+// Share is the body of requests that share a recipe with another user
+type Share struct {
+	Permission string
+}
+
+func AddSharingAPI(router gin.IRouter, recipe_manager recipes.RecipeManager, user_store users.UserStore) {
+	// Provide an API for choosing who can see and change a recipe
+	sharingAPI := router.Group("/api/recipes/id/:id")
+	{
+		// PUT /api/recipes/id/:id/visibility - make a recipe private, visible to the
+		// owner's household, or public
+		sharingAPI.PUT("/visibility", func(c *gin.Context) {
+			// Get the visibility from the request
+			var visibility Visibility
+			if err := c.BindJSON(&visibility); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := recipes.ValidateVisibility(visibility.Visibility); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Change it
+			err := recipe_manager.SetVisibility(c.Request.Context(), c.Param("id"), visibility.Visibility)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Visibility updated successfully"})
+		})
+
+		// PUT /api/recipes/id/:id/shares/:username - let another user view or edit a
+		// recipe
+		sharingAPI.PUT("/shares/:username", func(c *gin.Context) {
+			// Get the permission from the request
+			var share Share
+			if err := c.BindJSON(&share); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := recipes.ValidatePermission(share.Permission); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Find the user to share with
+			user, ok := lookUpUser(c, user_store, c.Param("username"))
+			if !ok {
+				return
+			}
+
+			// Share the recipe
+			grant := recipes.ShareGrant{UserID: user.ID.Hex(), Permission: share.Permission}
+			err := recipe_manager.ShareRecipe(c.Request.Context(), c.Param("id"), grant)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Recipe shared successfully"})
+		})
+
+		// DELETE /api/recipes/id/:id/shares/:username - stop sharing a recipe with a user
+		sharingAPI.DELETE("/shares/:username", func(c *gin.Context) {
+			// Find the user to stop sharing with
+			user, ok := lookUpUser(c, user_store, c.Param("username"))
+			if !ok {
+				return
+			}
+
+			// Stop sharing the recipe
+			err := recipe_manager.UnshareRecipe(c.Request.Context(), c.Param("id"), user.ID.Hex())
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Recipe unshared successfully"})
+		})
+	}
+}
+
+// lookUpUser finds the user with the given username, responding with an error if there
+// isn't one
+func lookUpUser(c *gin.Context, user_store users.UserStore, username string) (users.User, bool) {
+	user, err := user_store.GetUserByUsername(c.Request.Context(), username)
+	if errors.Is(err, users.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return user, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return user, false
+	}
+	return user, true
+}
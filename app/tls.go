@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dawsonc/recipes/src/certs"
+	"github.com/dawsonc/recipes/src/config"
+)
+
+// tlsVersions maps the TLS versions that can be configured to their crypto/tls values
+var tlsVersions = map[string]uint16{
+	config.TLSVersion12: tls.VersionTLS12,
+	config.TLSVersion13: tls.VersionTLS13,
+}
+
+// clientAuthTypes maps the ways of asking for client certificates to their crypto/tls
+// values. Certificates are always checked against the configured authorities.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	config.ClientAuthNone:     tls.NoClientCert,
+	config.ClientAuthOptional: tls.VerifyClientCertIfGiven,
+	config.ClientAuthRequire:  tls.RequireAndVerifyClientCert,
+}
+
+// setUpTLS returns the TLS settings for the server, along with the certificate and key
+// files to serve. A self-signed certificate is generated first if one is wanted and
+// doesn't exist yet. HTTP/2 is offered automatically alongside HTTP/1.1.
+func setUpTLS(cfg config.TLS, logger *slog.Logger) (*tls.Config, string, string, error) {
+	certFile, keyFile := cfg.Files()
+	if cfg.SelfSigned {
+		hosts := append(certs.LocalHosts(), cfg.Hosts...)
+		created, err := certs.EnsureSelfSigned(certFile, keyFile, hosts)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("generating a self-signed certificate: %w", err)
+		}
+		if created {
+			logger.Warn("generated a self-signed certificate for development; browsers will warn that it isn't trusted",
+				"cert_file", certFile, "hosts", hosts)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tlsVersions[cfg.MinVersion],
+		ClientAuth: clientAuthTypes[cfg.ClientAuth],
+	}
+
+	// Only trust client certificates signed by the given authorities
+	if cfg.ClientAuth != config.ClientAuthNone {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("reading client certificate authorities: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, "", "", errors.New("no certificates found in " + cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, certFile, keyFile, nil
+}
+
+// redirectToHTTPS sends every request to the same path over HTTPS, on the port the
+// server listens on for HTTPS
+func redirectToHTTPS(listen string) http.Handler {
+	_, port, _ := net.SplitHostPort(listen)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Keep the host the client asked for, but swap in the HTTPS port
+		host := strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
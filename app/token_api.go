@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// NewAPIToken is the body of requests that create an API token
+type NewAPIToken struct {
+	Name  string
+	Scope string
+}
+
+func AddTokensAPI(router gin.IRouter, user_store users.UserStore) {
+	// Provide an API for managing the logged-in user's personal API tokens. Tokens
+	// can't be used to make more tokens unless they have admin scope.
+	tokensAPI := router.Group("/api/tokens", RequireUser(), RequireScope(users.ScopeAdmin))
+	{
+		// GET /api/tokens - get the logged-in user's API tokens (without the tokens
+		// themselves, which are only shown when they are created)
+		tokensAPI.GET("/", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			tokens, err := user_store.ListAPITokens(c.Request.Context(), user.ID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, tokens)
+		})
+
+		// POST /api/tokens - create an API token with a scope of read, read-write, or
+		// admin. The token is returned once and should be sent as
+		// "Authorization: Bearer TOKEN".
+		tokensAPI.POST("/", func(c *gin.Context) {
+			// Get the token details from the request
+			var newToken NewAPIToken
+			if err := c.BindJSON(&newToken); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := users.ValidateScope(newToken.Scope); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Create the token
+			user, _ := currentUser(c)
+			token, apiToken, err := users.CreateAPIToken(c.Request.Context(), user_store, user,
+				newToken.Name, newToken.Scope)
+			if errors.Is(err, users.ErrScopeNotAllowed) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "API token created successfully", "token": token, "api_token": apiToken})
+		})
+
+		// DELETE /api/tokens/:id - revoke an API token
+		tokensAPI.DELETE("/:id", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			err := user_store.DeleteAPIToken(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+			if errors.Is(err, users.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "API token revoked successfully"})
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+func AddTrashAPI(router gin.IRouter, recipe_manager recipes.RecipeManager) {
+	// Provide an API for recovering deleted recipes
+	trashAPI := router.Group("/api/recipes/trash")
+	{
+		// GET /api/recipes/trash - list all recipes in the trash
+		trashAPI.GET("/", func(c *gin.Context) {
+			trash, err := recipe_manager.GetTrash(c.Request.Context())
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, trash)
+		})
+
+		// POST /api/recipes/trash/:id/restore - move a recipe out of the trash
+		trashAPI.POST("/:id/restore", func(c *gin.Context) {
+			err := recipe_manager.RestoreRecipe(c.Request.Context(), c.Param("id"))
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Recipe restored successfully"})
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dawsonc/recipes/src/webhooks"
+)
+
+// NewWebhook is the body of requests that create a webhook. The secret is generated if
+// it isn't given.
+type NewWebhook struct {
+	URL    string
+	Events []string
+	Secret string
+}
+
+func AddWebhooksAPI(router gin.IRouter, webhook_store webhooks.Store) {
+	// Provide an API for managing the logged-in user's webhooks, which are told about
+	// changes to the recipes the user can see. API tokens need read-write scope to
+	// change them.
+	webhooksAPI := router.Group("/api/webhooks", RequireUser(), RequireScopeForWrites())
+	{
+		// GET /api/webhooks - get the logged-in user's webhooks (without their secrets,
+		// which are only shown when they are created)
+		webhooksAPI.GET("/", func(c *gin.Context) {
+			user, _ := currentUser(c)
+			hooks, err := webhook_store.ListWebhooks(c.Request.Context(), user.ID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, hooks)
+		})
+
+		// POST /api/webhooks - create a webhook that is sent the given events. The secret
+		// is returned once, and signs every delivery (see the X-Recipes-Signature
+		// header).
+		webhooksAPI.POST("/", func(c *gin.Context) {
+			// Get the webhook details from the request
+			var newWebhook NewWebhook
+			if err := c.BindJSON(&newWebhook); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			user, _ := currentUser(c)
+			webhook := webhooks.Webhook{
+				UserID:    user.ID,
+				URL:       newWebhook.URL,
+				Events:    newWebhook.Events,
+				Secret:    newWebhook.Secret,
+				CreatedAt: time.Now().UTC(),
+			}
+			if err := webhook.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if webhook.Secret == "" {
+				secret, err := webhooks.NewSecret()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				webhook.Secret = secret
+			}
+
+			// Create the webhook
+			id, err := webhook_store.CreateWebhook(c.Request.Context(), webhook)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Webhook created successfully", "id": id, "secret": webhook.Secret})
+		})
+
+		// GET /api/webhooks/:id - get one of the logged-in user's webhooks
+		webhooksAPI.GET("/:id", func(c *gin.Context) {
+			webhook, ok := ownWebhook(c, webhook_store)
+			if !ok {
+				return
+			}
+
+			c.JSON(http.StatusOK, webhook)
+		})
+
+		// DELETE /api/webhooks/:id - delete a webhook, along with its deliveries
+		webhooksAPI.DELETE("/:id", func(c *gin.Context) {
+			if _, ok := ownWebhook(c, webhook_store); !ok {
+				return
+			}
+			err := webhook_store.DeleteWebhook(c.Request.Context(), c.Param("id"))
+			if errors.Is(err, webhooks.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+		})
+
+		// GET /api/webhooks/:id/deliveries - get the newest deliveries to a webhook,
+		// along with every attempt to send them
+		// e.g. /api/webhooks/ID/deliveries?limit=20
+		webhooksAPI.GET("/:id/deliveries", func(c *gin.Context) {
+			limit := webhooks.DefaultDeliveryLimit
+			if value := c.Query("limit"); value != "" {
+				var err error
+				if limit, err = strconv.Atoi(value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit': " + err.Error()})
+					return
+				}
+			}
+			if limit < 1 || limit > webhooks.MaxDeliveryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("invalid 'limit': must be between 1 and %d", webhooks.MaxDeliveryLimit),
+				})
+				return
+			}
+			if _, ok := ownWebhook(c, webhook_store); !ok {
+				return
+			}
+
+			deliveries, err := webhook_store.ListDeliveries(c.Request.Context(), c.Param("id"), limit)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, deliveries)
+		})
+	}
+}
+
+// ownWebhook gets the webhook named in the request if it belongs to the logged-in user.
+// Otherwise it responds as if the webhook doesn't exist, and returns false.
+func ownWebhook(c *gin.Context, webhook_store webhooks.Store) (webhooks.Webhook, bool) {
+	user, _ := currentUser(c)
+	webhook, err := webhook_store.GetWebhook(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, webhooks.ErrNotFound) || (err == nil && webhook.UserID != user.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return webhooks.Webhook{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return webhooks.Webhook{}, false
+	}
+	return webhook, true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/users"
+	"github.com/dawsonc/recipes/src/webhooks"
+)
+
+// webhookStore is a fake webhook store that keeps webhooks in memory
+type webhookStore struct {
+	webhooks.Store
+	webhooks []webhooks.Webhook
+}
+
+func (s *webhookStore) CreateWebhook(ctx context.Context, webhook webhooks.Webhook) (string, error) {
+	webhook.ID = primitive.NewObjectID()
+	s.webhooks = append(s.webhooks, webhook)
+	return webhook.ID.Hex(), nil
+}
+
+func (s *webhookStore) GetWebhook(ctx context.Context, id string) (webhooks.Webhook, error) {
+	for _, webhook := range s.webhooks {
+		if webhook.ID.Hex() == id {
+			return webhook, nil
+		}
+	}
+	return webhooks.Webhook{}, webhooks.ErrNotFound
+}
+
+func (s *webhookStore) ListWebhooks(ctx context.Context, userID string) ([]webhooks.Webhook, error) {
+	var found []webhooks.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.UserID.Hex() == userID {
+			found = append(found, webhook)
+		}
+	}
+	return found, nil
+}
+
+func (s *webhookStore) DeleteWebhook(ctx context.Context, id string) error {
+	for i, webhook := range s.webhooks {
+		if webhook.ID.Hex() == id {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return webhooks.ErrNotFound
+}
+
+// TestWebhooksAPI tests that secrets are only shown once, and that users can only see
+// and delete their own webhooks
+func TestWebhooksAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &webhookStore{}
+	alice := users.User{ID: primitive.NewObjectID(), Username: "alice"}
+	bob := users.User{ID: primitive.NewObjectID(), Username: "bob"}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-User") == "bob" {
+			c.Set(userKey, bob)
+		} else {
+			c.Set(userKey, alice)
+		}
+	})
+	AddWebhooksAPI(router, store)
+	request := func(method, path, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", user)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Bad webhooks are rejected
+	response := request(http.MethodPost, "/api/webhooks/", `{"URL": "https://example.com/hook", "Events": ["cooked"]}`, "alice")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown event to be rejected, got %d", response.Code)
+	}
+
+	// The secret is generated and shown when the webhook is created, and never again
+	response = request(http.MethodPost, "/api/webhooks/", `{"URL": "https://example.com/hook", "Events": ["created"]}`, "alice")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"secret":"whsec_`) {
+		t.Fatalf("Expected the webhook to be created with a secret, got %d: %s", response.Code, response.Body)
+	}
+	if len(store.webhooks) != 1 || store.webhooks[0].UserID != alice.ID {
+		t.Fatalf("Expected alice's webhook to be stored, got %+v", store.webhooks)
+	}
+	id := store.webhooks[0].ID.Hex()
+	response = request(http.MethodGet, "/api/webhooks/", "", "alice")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), id) || strings.Contains(response.Body.String(), "whsec_") {
+		t.Errorf("Expected alice's webhook without its secret, got %d: %s", response.Code, response.Body)
+	}
+
+	// Other users can't see or delete it
+	if response := request(http.MethodGet, "/api/webhooks/"+id, "", "bob"); response.Code != http.StatusNotFound {
+		t.Errorf("Expected bob not to find alice's webhook, got %d", response.Code)
+	}
+	if response := request(http.MethodDelete, "/api/webhooks/"+id, "", "bob"); response.Code != http.StatusNotFound {
+		t.Errorf("Expected bob not to delete alice's webhook, got %d", response.Code)
+	}
+	if response := request(http.MethodDelete, "/api/webhooks/"+id, "", "alice"); response.Code != http.StatusOK {
+		t.Errorf("Expected alice to delete her webhook, got %d", response.Code)
+	}
+	if len(store.webhooks) != 0 {
+		t.Errorf("Expected the webhook to be deleted, got %+v", store.webhooks)
+	}
+}
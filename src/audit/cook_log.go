@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define a cook log that records every change made through it in the audit log
+
+// CookLog wraps another cook log, passing every call through to it and recording the
+// ones that change something
+type CookLog struct {
+	log   recipes.CookLog
+	store Store
+}
+
+// NewCookLog wraps the cook log so that its changes are recorded in the audit store
+func NewCookLog(log recipes.CookLog, store Store) *CookLog {
+	return &CookLog{log: log, store: store}
+}
+
+// LogCook records that a recipe was cooked, and records that in the audit log too
+func (l *CookLog) LogCook(ctx context.Context, entry recipes.CookLogEntry) (string, error) {
+	id, err := l.log.LogCook(ctx, entry)
+	if err != nil {
+		return id, err
+	}
+	Record(ctx, l.store, ActionCook, entry.RecipeID.Hex(), fmt.Sprintf("logged cook %s (rated %d)", id, entry.Rating))
+	return id, nil
+}
+
+// GetCookLog changes nothing, so goes straight to the wrapped cook log
+func (l *CookLog) GetCookLog(ctx context.Context, recipeID string) ([]recipes.CookLogEntry, error) {
+	return l.log.GetCookLog(ctx, recipeID)
+}
+
+// DeleteCookLogEntry removes an entry from the cook log of a recipe and records it
+func (l *CookLog) DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) error {
+	if err := l.log.DeleteCookLogEntry(ctx, recipeID, entryID); err != nil {
+		return err
+	}
+	Record(ctx, l.store, ActionCook, recipeID, "deleted cook "+entryID)
+	return nil
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Define structs for the audit log of changes to recipes
+
+// Entry records a single change to the library, made through the recipe manager or by
+// restoring a backup
+type Entry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Time     time.Time          `bson:"time"`
+	Actor    string             `bson:"actor"`
+	ClientIP string             `bson:"client_ip"`
+	Action   string             `bson:"action"`
+	RecipeID string             `bson:"recipe_id"`
+	Summary  string             `bson:"summary"`
+}
+
+// Kinds of change recorded in the audit log
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionTag     = "tag"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+	ActionComment = "comment"
+	ActionShare   = "share"
+	ActionCook    = "cook"
+)
+
+// actions lists all of the valid values for Entry.Action
+var actions = []string{
+	ActionCreate, ActionUpdate, ActionTag, ActionDelete,
+	ActionRestore, ActionPurge, ActionComment, ActionShare, ActionCook,
+}
+
+// Limits on the number of entries returned by a query
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Filter narrows down the entries returned by Store.Query. Zero values leave the
+// corresponding filter unset.
+type Filter struct {
+	Actor    string
+	Action   string
+	RecipeID string
+	Since    time.Time
+	Until    time.Time
+	// Limit is the most entries to return, newest first (DefaultLimit if zero)
+	Limit int
+}
+
+// Validate checks that the filter makes sense, and fills in the default limit
+func (filter *Filter) Validate() error {
+	if filter.Action != "" && !contains(actions, filter.Action) {
+		return fmt.Errorf("invalid action %q: expected one of %v", filter.Action, actions)
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return fmt.Errorf("invalid date range: %v is before %v", filter.Until, filter.Since)
+	}
+	if filter.Limit < 0 || filter.Limit > MaxLimit {
+		return fmt.Errorf("invalid limit %d: must be between 1 and %d", filter.Limit, MaxLimit)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLimit
+	}
+
+	return nil
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Define an interface for a generic audit log store
+type Store interface {
+	// Record adds an entry to the audit log
+	Record(ctx context.Context, entry Entry) error
+	// Query returns the entries matching the filter, newest first
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+	// Close releases the store's connections once it is no longer needed
+	Close() error
+}
+
+// Define helpers for recording where a change came from
+
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of the context that records the IP address of the client
+// making the change
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the IP address of the client, or "" if none was recorded
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
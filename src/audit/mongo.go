@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Define a MongoDB audit store that implements the Store interface
+type MongoStore struct {
+	client *mongo.Client
+	dbName string
+}
+
+// CreateMongoStore creates a new MongoDB audit store, keeping entries in the "audit"
+// collection of the given database
+func CreateMongoStore(uri, dbName string) (*MongoStore, error) {
+	// Set client options
+	clientOptions := options.Client().ApplyURI(uri)
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the connection, letting go of the client if it doesn't work
+	err = client.Ping(context.Background(), nil)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	// Create a new audit store
+	store := &MongoStore{
+		client: client,
+		dbName: dbName,
+	}
+
+	// Queries are always newest first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = store.entries().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"time": -1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Close disconnects from MongoDB, waiting for operations in progress to finish. The
+// audit store can't be used afterwards.
+func (s *MongoStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.client.Disconnect(ctx)
+}
+
+// entries returns the collection holding audit log entries
+func (s *MongoStore) entries() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("audit")
+}
+
+// Record adds an entry to the audit log
+func (s *MongoStore) Record(ctx context.Context, entry Entry) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := s.entries().InsertOne(ctx, entry)
+	return err
+}
+
+// Query returns the entries matching the filter, newest first
+func (s *MongoStore) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Build the query from whichever filters were given
+	query := bson.M{}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.RecipeID != "" {
+		query["recipe_id"] = filter.RecipeID
+	}
+	timeRange := bson.M{}
+	if !filter.Since.IsZero() {
+		timeRange["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		timeRange["$lt"] = filter.Until
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+
+	// Find the newest matching entries
+	opts := options.Find().SetSort(bson.M{"time": -1}).SetLimit(int64(filter.Limit))
+	cursor, err := s.entries().Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := make([]Entry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
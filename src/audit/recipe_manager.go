@@ -0,0 +1,298 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define a recipe manager that records every change made through it in the audit log
+
+// RecipeManager wraps another recipe manager, passing every call through to it and
+// recording the ones that change something. Every method is wrapped explicitly, so that
+// new methods can't be missed.
+type RecipeManager struct {
+	manager recipes.RecipeManager
+	store   Store
+}
+
+// NewRecipeManager wraps the recipe manager so that its changes are recorded in the
+// audit store
+func NewRecipeManager(manager recipes.RecipeManager, store Store) *RecipeManager {
+	return &RecipeManager{manager: manager, store: store}
+}
+
+// record adds an entry for a successful change to the audit log. The change has already
+// happened by the time it is recorded, so failures are logged rather than returned.
+func (m *RecipeManager) record(ctx context.Context, action, recipeID, summary string) {
+	Record(ctx, m.store, action, recipeID, summary)
+}
+
+// Record adds an entry for a successful change to the audit store, logging any failure.
+// The recipe ID is empty for changes that aren't to a single recipe.
+func Record(ctx context.Context, store Store, action, recipeID, summary string) {
+	entry := Entry{
+		Time:     time.Now().UTC().Truncate(time.Millisecond),
+		Actor:    recipes.ActorFromContext(ctx),
+		ClientIP: ClientIPFromContext(ctx),
+		Action:   action,
+		RecipeID: recipeID,
+		Summary:  summary,
+	}
+	if err := store.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "failed to record change in the audit log",
+			"action", action, "recipe_id", recipeID, "error", err)
+	}
+}
+
+// AddRecipe adds a recipe and records its creation
+func (m *RecipeManager) AddRecipe(ctx context.Context, recipe recipes.Recipe) (string, error) {
+	id, err := m.manager.AddRecipe(ctx, recipe)
+	if err != nil {
+		return id, err
+	}
+	m.record(ctx, ActionCreate, id, fmt.Sprintf("created recipe %q", recipe.Name))
+	return id, nil
+}
+
+// AddRecipes adds several recipes and records the creation of each one that was added
+func (m *RecipeManager) AddRecipes(ctx context.Context, batch []recipes.Recipe) ([]string, error) {
+	ids, err := m.manager.AddRecipes(ctx, batch)
+	for i, id := range ids {
+		if id != "" {
+			m.record(ctx, ActionCreate, id, fmt.Sprintf("created recipe %q in a bulk import", batch[i].Name))
+		}
+	}
+	return ids, err
+}
+
+// ImportRecipes stores recipes from a backup and records the restoration of each one
+func (m *RecipeManager) ImportRecipes(ctx context.Context, batch []recipes.Recipe) error {
+	if err := m.manager.ImportRecipes(ctx, batch); err != nil {
+		return err
+	}
+	for _, recipe := range batch {
+		m.record(ctx, ActionCreate, recipe.ID.Hex(), fmt.Sprintf("restored recipe %q from a backup", recipe.Name))
+	}
+	return nil
+}
+
+// DeleteRecipe moves a recipe to the trash and records its deletion
+func (m *RecipeManager) DeleteRecipe(ctx context.Context, id string) error {
+	if err := m.manager.DeleteRecipe(ctx, id); err != nil {
+		return err
+	}
+	m.record(ctx, ActionDelete, id, "moved recipe to trash")
+	return nil
+}
+
+// UpdateRecipe updates a recipe and records what changed. Updates that only change the
+// tags are recorded as tag changes.
+func (m *RecipeManager) UpdateRecipe(ctx context.Context, recipe recipes.Recipe) error {
+	// Remember the recipe as it was, to work out what changed
+	id := recipe.ID.Hex()
+	old, err := m.manager.GetRecipeByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.manager.UpdateRecipe(ctx, recipe); err != nil {
+		return err
+	}
+
+	action, summary := SummarizeUpdate(old, recipe)
+	m.record(ctx, action, id, summary)
+	return nil
+}
+
+// RestoreRevision restores an old revision of a recipe and records the restore
+func (m *RecipeManager) RestoreRevision(ctx context.Context, id string, number int) error {
+	if err := m.manager.RestoreRevision(ctx, id, number); err != nil {
+		return err
+	}
+	m.record(ctx, ActionRestore, id, fmt.Sprintf("restored revision %d", number))
+	return nil
+}
+
+// RestoreRecipe takes a recipe out of the trash and records the restore
+func (m *RecipeManager) RestoreRecipe(ctx context.Context, id string) error {
+	if err := m.manager.RestoreRecipe(ctx, id); err != nil {
+		return err
+	}
+	m.record(ctx, ActionRestore, id, "restored recipe from trash")
+	return nil
+}
+
+// PurgeTrash permanently removes old recipes from the trash and records how many
+func (m *RecipeManager) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	count, err := m.manager.PurgeTrash(ctx, before)
+	if err != nil || count == 0 {
+		return count, err
+	}
+	m.record(ctx, ActionPurge, "", fmt.Sprintf("purged %d recipes deleted before %s",
+		count, before.UTC().Format(time.RFC3339)))
+	return count, nil
+}
+
+// PurgeRecipes permanently removes recipes from the trash and records each one that was
+// removed
+func (m *RecipeManager) PurgeRecipes(ctx context.Context, ids []string) ([]string, error) {
+	purged, err := m.manager.PurgeRecipes(ctx, ids)
+	if err != nil {
+		return purged, err
+	}
+	for _, id := range purged {
+		m.record(ctx, ActionPurge, id, "purged recipe from trash")
+	}
+	return purged, nil
+}
+
+// AddComment adds a comment to a recipe and records it
+func (m *RecipeManager) AddComment(ctx context.Context, recipeID string, comment recipes.Comments) (string, error) {
+	id, err := m.manager.AddComment(ctx, recipeID, comment)
+	if err != nil {
+		return id, err
+	}
+	m.record(ctx, ActionComment, recipeID, "added comment "+id)
+	return id, nil
+}
+
+// UpdateComment changes the text of a comment and records it
+func (m *RecipeManager) UpdateComment(ctx context.Context, recipeID string, comment recipes.Comments) error {
+	if err := m.manager.UpdateComment(ctx, recipeID, comment); err != nil {
+		return err
+	}
+	m.record(ctx, ActionComment, recipeID, "edited comment "+comment.ID.Hex())
+	return nil
+}
+
+// DeleteComment removes a comment from a recipe and records it
+func (m *RecipeManager) DeleteComment(ctx context.Context, recipeID, commentID string) error {
+	if err := m.manager.DeleteComment(ctx, recipeID, commentID); err != nil {
+		return err
+	}
+	m.record(ctx, ActionComment, recipeID, "deleted comment "+commentID)
+	return nil
+}
+
+// SetVisibility changes who can see a recipe and records it
+func (m *RecipeManager) SetVisibility(ctx context.Context, id, visibility string) error {
+	if err := m.manager.SetVisibility(ctx, id, visibility); err != nil {
+		return err
+	}
+	m.record(ctx, ActionShare, id, "set visibility to "+visibility)
+	return nil
+}
+
+// ShareRecipe shares a recipe with a user and records it
+func (m *RecipeManager) ShareRecipe(ctx context.Context, id string, grant recipes.ShareGrant) error {
+	if err := m.manager.ShareRecipe(ctx, id, grant); err != nil {
+		return err
+	}
+	m.record(ctx, ActionShare, id, fmt.Sprintf("shared with user %s (%s)", grant.UserID, grant.Permission))
+	return nil
+}
+
+// UnshareRecipe stops sharing a recipe with a user and records it
+func (m *RecipeManager) UnshareRecipe(ctx context.Context, id, userID string) error {
+	if err := m.manager.UnshareRecipe(ctx, id, userID); err != nil {
+		return err
+	}
+	m.record(ctx, ActionShare, id, "unshared with user "+userID)
+	return nil
+}
+
+// Reads change nothing, so they go straight to the wrapped manager
+
+func (m *RecipeManager) GetAllRecipes(ctx context.Context) ([]recipes.Recipe, error) {
+	return m.manager.GetAllRecipes(ctx)
+}
+
+func (m *RecipeManager) CountRecipes(ctx context.Context) (int, error) {
+	return m.manager.CountRecipes(ctx)
+}
+
+func (m *RecipeManager) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	return m.manager.GetRecipeByID(ctx, id)
+}
+
+func (m *RecipeManager) GetRecipesByIDs(ctx context.Context, ids []string) ([]recipes.Recipe, error) {
+	return m.manager.GetRecipesByIDs(ctx, ids)
+}
+
+func (m *RecipeManager) GetRecipesByTags(ctx context.Context, tags []string) ([]recipes.Recipe, error) {
+	return m.manager.GetRecipesByTags(ctx, tags)
+}
+
+func (m *RecipeManager) GetTags(ctx context.Context) ([]string, error) {
+	return m.manager.GetTags(ctx)
+}
+
+func (m *RecipeManager) SearchRecipes(ctx context.Context, query string, tags []string) ([]recipes.Recipe, error) {
+	return m.manager.SearchRecipes(ctx, query, tags)
+}
+
+func (m *RecipeManager) ListRecipes(ctx context.Context, opts recipes.ListOptions) ([]recipes.Recipe, error) {
+	return m.manager.ListRecipes(ctx, opts)
+}
+
+func (m *RecipeManager) ExportRecipes(ctx context.Context, fn func(recipes.Recipe) error) error {
+	return m.manager.ExportRecipes(ctx, fn)
+}
+
+func (m *RecipeManager) GetRevisions(ctx context.Context, id string) ([]recipes.Revision, error) {
+	return m.manager.GetRevisions(ctx, id)
+}
+
+func (m *RecipeManager) GetRevision(ctx context.Context, id string, number int) (recipes.Revision, error) {
+	return m.manager.GetRevision(ctx, id, number)
+}
+
+func (m *RecipeManager) GetTrash(ctx context.Context) ([]recipes.Recipe, error) {
+	return m.manager.GetTrash(ctx)
+}
+
+func (m *RecipeManager) Ping(ctx context.Context) error {
+	return m.manager.Ping(ctx)
+}
+
+func (m *RecipeManager) Close() error {
+	return m.manager.Close()
+}
+
+// SummarizeUpdate describes the change from the old recipe to the new one for the audit
+// log, returning ActionTag if only the tags changed and ActionUpdate otherwise
+// e.g. "changed Name, Steps"
+// e.g. "added tags dinner; removed tags lunch"
+func SummarizeUpdate(old, new recipes.Recipe) (string, string) {
+	changes := recipes.DiffRecipes(old, new)
+	if len(changes) == 0 {
+		return ActionUpdate, "saved without changes"
+	}
+
+	// Tag-only changes list the tags themselves
+	if len(changes) == 1 && changes[0].Field == "Tags" {
+		parts := make([]string, 0, 2)
+		if len(changes[0].Added) > 0 {
+			parts = append(parts, "added tags "+strings.Join(changes[0].Added, ", "))
+		}
+		if len(changes[0].Removed) > 0 {
+			parts = append(parts, "removed tags "+strings.Join(changes[0].Removed, ", "))
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "reordered tags")
+		}
+		return ActionTag, strings.Join(parts, "; ")
+	}
+
+	// Other changes just list the fields
+	fields := make([]string, len(changes))
+	for i, change := range changes {
+		fields[i] = change.Field
+	}
+	return ActionUpdate, "changed " + strings.Join(fields, ", ")
+}
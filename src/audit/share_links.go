@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define share links that record every change made through them in the audit log
+
+// ShareLinks wraps other share links, passing every call through to them and recording
+// the ones that change something. Tokens are never recorded.
+type ShareLinks struct {
+	links recipes.ShareLinks
+	store Store
+}
+
+// NewShareLinks wraps the share links so that their changes are recorded in the audit
+// store
+func NewShareLinks(links recipes.ShareLinks, store Store) *ShareLinks {
+	return &ShareLinks{links: links, store: store}
+}
+
+// CreateShareLink creates a link to a recipe and records it
+func (l *ShareLinks) CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (string, recipes.ShareLink, error) {
+	token, link, err := l.links.CreateShareLink(ctx, recipeID, expiresAt)
+	if err != nil {
+		return token, link, err
+	}
+	summary := "created share link " + link.ID.Hex()
+	if expiresAt != nil {
+		summary += " expiring " + expiresAt.UTC().Format(time.RFC3339)
+	}
+	Record(ctx, l.store, ActionShare, recipeID, summary)
+	return token, link, nil
+}
+
+// GetShareLinks changes nothing, so goes straight to the wrapped share links
+func (l *ShareLinks) GetShareLinks(ctx context.Context, recipeID string) ([]recipes.ShareLink, error) {
+	return l.links.GetShareLinks(ctx, recipeID)
+}
+
+// RevokeShareLink deletes a link to a recipe and records it
+func (l *ShareLinks) RevokeShareLink(ctx context.Context, recipeID, linkID string) error {
+	if err := l.links.RevokeShareLink(ctx, recipeID, linkID); err != nil {
+		return err
+	}
+	Record(ctx, l.store, ActionShare, recipeID, "revoked share link "+linkID)
+	return nil
+}
+
+// GetSharedRecipe changes nothing, so goes straight to the wrapped share links
+func (l *ShareLinks) GetSharedRecipe(ctx context.Context, token string) (recipes.Recipe, error) {
+	return l.links.GetSharedRecipe(ctx, token)
+}
@@ -0,0 +1,306 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/recipes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeRecipeManager keeps recipes in a map, implementing just the methods the tests use
+type fakeRecipeManager struct {
+	recipes.RecipeManager
+	recipes map[string]recipes.Recipe
+}
+
+func (m *fakeRecipeManager) AddRecipe(ctx context.Context, recipe recipes.Recipe) (string, error) {
+	recipe.ID = primitive.NewObjectID()
+	m.recipes[recipe.ID.Hex()] = recipe
+	return recipe.ID.Hex(), nil
+}
+
+// AddRecipes adds each recipe in turn, failing the ones without a name
+func (m *fakeRecipeManager) AddRecipes(ctx context.Context, batch []recipes.Recipe) ([]string, error) {
+	ids := make([]string, len(batch))
+	failed := make(map[int]error)
+	for i, recipe := range batch {
+		if recipe.Name == "" {
+			failed[i] = errors.New("no name")
+			continue
+		}
+		ids[i], _ = m.AddRecipe(ctx, recipe)
+	}
+	if len(failed) > 0 {
+		return ids, &recipes.BulkError{Errors: failed}
+	}
+	return ids, nil
+}
+
+func (m *fakeRecipeManager) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	recipe, ok := m.recipes[id]
+	if !ok {
+		return recipe, recipes.ErrNotFound
+	}
+	return recipe, nil
+}
+
+func (m *fakeRecipeManager) UpdateRecipe(ctx context.Context, recipe recipes.Recipe) error {
+	if _, ok := m.recipes[recipe.ID.Hex()]; !ok {
+		return recipes.ErrNotFound
+	}
+	m.recipes[recipe.ID.Hex()] = recipe
+	return nil
+}
+
+func (m *fakeRecipeManager) DeleteRecipe(ctx context.Context, id string) error {
+	if _, ok := m.recipes[id]; !ok {
+		return recipes.ErrNotFound
+	}
+	delete(m.recipes, id)
+	return nil
+}
+
+// PurgeRecipes removes the requested recipes that are in the trash
+func (m *fakeRecipeManager) PurgeRecipes(ctx context.Context, ids []string) ([]string, error) {
+	var purged []string
+	for _, id := range ids {
+		if recipe, ok := m.recipes[id]; ok && recipe.DeletedAt != nil {
+			delete(m.recipes, id)
+			purged = append(purged, id)
+		}
+	}
+	return purged, nil
+}
+
+// fakeStore keeps audit log entries in a slice
+type fakeStore struct {
+	entries []audit.Entry
+}
+
+func (s *fakeStore) Record(ctx context.Context, entry audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *fakeStore) Query(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return s.entries, nil
+}
+
+func (s *fakeStore) Close() error {
+	return nil
+}
+
+// TestRecipeManager tests that the audit recipe manager records successful changes
+func TestRecipeManager(t *testing.T) {
+	store := &fakeStore{}
+	manager := audit.NewRecipeManager(&fakeRecipeManager{recipes: map[string]recipes.Recipe{}}, store)
+	ctx := audit.WithClientIP(recipes.WithActor(context.Background(), "alice"), "192.0.2.1")
+
+	// Create, retag, rename, and delete a recipe
+	id, err := manager.AddRecipe(ctx, recipes.Recipe{Name: "Soup", Tags: []string{"lunch"}})
+	if err != nil {
+		t.Fatalf("Failed to add recipe: %v", err)
+	}
+	recipe, _ := manager.GetRecipeByID(ctx, id)
+	recipe.Tags = []string{"dinner"}
+	if err := manager.UpdateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("Failed to update recipe: %v", err)
+	}
+	recipe.Name = "Stew"
+	if err := manager.UpdateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("Failed to update recipe: %v", err)
+	}
+	if err := manager.DeleteRecipe(ctx, id); err != nil {
+		t.Fatalf("Failed to delete recipe: %v", err)
+	}
+
+	// Failed changes aren't recorded
+	if err := manager.DeleteRecipe(ctx, id); !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	// Each change should have been recorded with who made it and from where
+	expected := []struct{ action, summary string }{
+		{audit.ActionCreate, `created recipe "Soup"`},
+		{audit.ActionTag, "added tags dinner; removed tags lunch"},
+		{audit.ActionUpdate, "changed Name"},
+		{audit.ActionDelete, "moved recipe to trash"},
+	}
+	if len(store.entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), store.entries)
+	}
+	for i, entry := range store.entries {
+		if entry.Action != expected[i].action || entry.Summary != expected[i].summary {
+			t.Errorf("Expected entry %d to be %v, got %q: %q", i, expected[i], entry.Action, entry.Summary)
+		}
+		if entry.Actor != "alice" || entry.ClientIP != "192.0.2.1" || entry.RecipeID != id {
+			t.Errorf("Expected entry %d to be by alice from 192.0.2.1 on %s, got %v", i, id, entry)
+		}
+	}
+}
+
+// TestRecipeManagerBulk tests that each recipe added in bulk is recorded, and the ones
+// that failed aren't
+func TestRecipeManagerBulk(t *testing.T) {
+	store := &fakeStore{}
+	manager := audit.NewRecipeManager(&fakeRecipeManager{recipes: map[string]recipes.Recipe{}}, store)
+	ctx := recipes.WithActor(context.Background(), "alice")
+
+	ids, err := manager.AddRecipes(ctx, []recipes.Recipe{{Name: "Soup"}, {}, {Name: "Stew"}})
+	var bulkErr *recipes.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("Expected the second recipe to fail, got %v", err)
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %v", store.entries)
+	}
+	for i, index := range []int{0, 2} {
+		entry := store.entries[i]
+		if entry.Action != audit.ActionCreate || entry.RecipeID != ids[index] || entry.Actor != "alice" {
+			t.Errorf("Expected the creation of %s by alice, got %v", ids[index], entry)
+		}
+	}
+	if store.entries[1].Summary != `created recipe "Stew" in a bulk import` {
+		t.Errorf("Expected the summary to name the recipe, got %q", store.entries[1].Summary)
+	}
+}
+
+// TestRecipeManagerPurgeRecipes tests that only the recipes that were really purged are
+// recorded
+func TestRecipeManagerPurgeRecipes(t *testing.T) {
+	store := &fakeStore{}
+	deleted := time.Now()
+	trashed := recipes.Recipe{ID: primitive.NewObjectID(), Name: "Soup", DeletedAt: &deleted}
+	live := recipes.Recipe{ID: primitive.NewObjectID(), Name: "Stew"}
+	fake := &fakeRecipeManager{recipes: map[string]recipes.Recipe{trashed.ID.Hex(): trashed, live.ID.Hex(): live}}
+	manager := audit.NewRecipeManager(fake, store)
+
+	ids := []string{trashed.ID.Hex(), live.ID.Hex(), primitive.NewObjectID().Hex()}
+	purged, err := manager.PurgeRecipes(context.Background(), ids)
+	if err != nil || len(purged) != 1 {
+		t.Fatalf("Expected 1 recipe to be purged, got %v and %v", purged, err)
+	}
+	if len(store.entries) != 1 || store.entries[0].Action != audit.ActionPurge || store.entries[0].RecipeID != trashed.ID.Hex() {
+		t.Errorf("Expected only the purge of %s to be recorded, got %v", trashed.ID.Hex(), store.entries)
+	}
+}
+
+// fakeLinks implements the cook log and share links, failing for unknown recipes
+type fakeLinks struct {
+	recipes.CookLog
+	recipes.ShareLinks
+}
+
+func (l *fakeLinks) LogCook(ctx context.Context, entry recipes.CookLogEntry) (string, error) {
+	if entry.RecipeID.IsZero() {
+		return "", recipes.ErrNotFound
+	}
+	return "cook1", nil
+}
+
+func (l *fakeLinks) DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) error {
+	return nil
+}
+
+func (l *fakeLinks) CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (string, recipes.ShareLink, error) {
+	return "secret-token", recipes.ShareLink{ID: primitive.NewObjectID(), ExpiresAt: expiresAt}, nil
+}
+
+func (l *fakeLinks) RevokeShareLink(ctx context.Context, recipeID, linkID string) error {
+	return nil
+}
+
+// TestCookLogAndShareLinks tests that changes to the cook log and share links are
+// recorded, without the share link tokens
+func TestCookLogAndShareLinks(t *testing.T) {
+	store := &fakeStore{}
+	cookLog := audit.NewCookLog(&fakeLinks{}, store)
+	shareLinks := audit.NewShareLinks(&fakeLinks{}, store)
+	ctx := recipes.WithActor(context.Background(), "alice")
+	recipeID := primitive.NewObjectID()
+
+	// Log and delete a cook, and fail to log one for a missing recipe
+	if _, err := cookLog.LogCook(ctx, recipes.CookLogEntry{RecipeID: recipeID, Rating: 4}); err != nil {
+		t.Fatalf("Failed to log cook: %v", err)
+	}
+	if _, err := cookLog.LogCook(ctx, recipes.CookLogEntry{Rating: 4}); !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if err := cookLog.DeleteCookLogEntry(ctx, recipeID.Hex(), "cook1"); err != nil {
+		t.Fatalf("Failed to delete cook: %v", err)
+	}
+
+	// Create and revoke a share link
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, link, err := shareLinks.CreateShareLink(ctx, recipeID.Hex(), &expires)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	if err := shareLinks.RevokeShareLink(ctx, recipeID.Hex(), link.ID.Hex()); err != nil {
+		t.Fatalf("Failed to revoke share link: %v", err)
+	}
+
+	expected := []struct{ action, summary string }{
+		{audit.ActionCook, "logged cook cook1 (rated 4)"},
+		{audit.ActionCook, "deleted cook cook1"},
+		{audit.ActionShare, "created share link " + link.ID.Hex() + " expiring 2030-01-02T03:04:05Z"},
+		{audit.ActionShare, "revoked share link " + link.ID.Hex()},
+	}
+	if len(store.entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), store.entries)
+	}
+	for i, entry := range store.entries {
+		if entry.Action != expected[i].action || entry.Summary != expected[i].summary ||
+			entry.Actor != "alice" || entry.RecipeID != recipeID.Hex() {
+			t.Errorf("Expected entry %d to be %v by alice, got %v", i, expected[i], entry)
+		}
+	}
+}
+
+// TestSummarizeUpdate tests the SummarizeUpdate function
+func TestSummarizeUpdate(t *testing.T) {
+	old := recipes.Recipe{Name: "Soup", Steps: []string{"Boil"}, Tags: []string{"lunch"}}
+
+	new := old
+	action, summary := audit.SummarizeUpdate(old, new)
+	if action != audit.ActionUpdate || summary != "saved without changes" {
+		t.Errorf("Expected an unchanged update, got %q: %q", action, summary)
+	}
+
+	new.Tags = []string{"lunch", "quick"}
+	action, summary = audit.SummarizeUpdate(old, new)
+	if action != audit.ActionTag || summary != "added tags quick" {
+		t.Errorf("Expected a tag change, got %q: %q", action, summary)
+	}
+
+	new.Steps = []string{"Boil", "Serve"}
+	action, summary = audit.SummarizeUpdate(old, new)
+	if action != audit.ActionUpdate || summary != "changed Steps, Tags" {
+		t.Errorf("Expected an update of steps and tags, got %q: %q", action, summary)
+	}
+}
+
+// TestFilterValidate tests the Filter.Validate function
+func TestFilterValidate(t *testing.T) {
+	filter := audit.Filter{Action: audit.ActionDelete}
+	if err := filter.Validate(); err != nil {
+		t.Errorf("Expected filter to be valid, got %v", err)
+	}
+	if filter.Limit != audit.DefaultLimit {
+		t.Errorf("Expected the default limit, got %d", filter.Limit)
+	}
+
+	for _, filter := range []audit.Filter{
+		{Action: "eat"},
+		{Limit: -1},
+		{Limit: audit.MaxLimit + 1},
+	} {
+		if err := filter.Validate(); err == nil {
+			t.Errorf("Expected filter %v to be invalid", filter)
+		}
+	}
+}
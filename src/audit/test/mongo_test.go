@@ -0,0 +1,121 @@
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	testDBName, testURI string
+)
+
+func init() {
+	// Get the database name and uri from environment variables using os
+	testDBName = os.Getenv("TEST_DB_NAME")
+	testURI = os.Getenv("TEST_DB_URI")
+
+	// If the environment variables are not set, use the default values
+	if testDBName == "" {
+		testDBName = "recipes_test"
+	}
+	if testURI == "" {
+		testURI = "mongodb://localhost:27017"
+	}
+}
+
+// Define functions to set up and tear down the test database before and after each
+// test
+func setupTestDB() error {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		return fmt.Errorf("failed to connect to test database: %w", err)
+	}
+
+	// Drop the existing test database (if it exists)
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil && err.Error() != "mongo: database not found" {
+		return fmt.Errorf("failed to drop test database: %w", err)
+	}
+
+	return nil
+}
+
+func teardownTestDB() {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	// Drop the test database
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to drop test database: %v", err)
+	}
+}
+
+// TestMongoStore tests recording and querying entries with a MongoDB audit store
+func TestMongoStore(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new audit store
+	store, err := audit.CreateMongoStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create audit store: %v", err)
+	}
+
+	// Record a few entries, an hour apart
+	start := time.Now().UTC().Truncate(time.Hour)
+	entries := []audit.Entry{
+		{Time: start, Actor: "alice", Action: audit.ActionCreate, RecipeID: "1"},
+		{Time: start.Add(time.Hour), Actor: "bob", Action: audit.ActionUpdate, RecipeID: "1"},
+		{Time: start.Add(2 * time.Hour), Actor: "alice", Action: audit.ActionDelete, RecipeID: "2"},
+	}
+	for _, entry := range entries {
+		if err := store.Record(context.Background(), entry); err != nil {
+			t.Fatalf("Failed to record entry: %v", err)
+		}
+	}
+
+	// Each filter should find the right entries, newest first
+	tests := []struct {
+		filter   audit.Filter
+		expected []string
+	}{
+		{audit.Filter{}, []string{audit.ActionDelete, audit.ActionUpdate, audit.ActionCreate}},
+		{audit.Filter{Actor: "alice"}, []string{audit.ActionDelete, audit.ActionCreate}},
+		{audit.Filter{RecipeID: "1"}, []string{audit.ActionUpdate, audit.ActionCreate}},
+		{audit.Filter{Action: audit.ActionUpdate}, []string{audit.ActionUpdate}},
+		{audit.Filter{Since: start.Add(time.Hour)}, []string{audit.ActionDelete, audit.ActionUpdate}},
+		{audit.Filter{Until: start.Add(time.Hour)}, []string{audit.ActionCreate}},
+		{audit.Filter{Limit: 1}, []string{audit.ActionDelete}},
+	}
+	for _, test := range tests {
+		found, err := store.Query(context.Background(), test.filter)
+		if err != nil {
+			t.Fatalf("Failed to query entries: %v", err)
+		}
+		actions := make([]string, len(found))
+		for i, entry := range found {
+			actions[i] = entry.Action
+		}
+		if !reflect.DeepEqual(actions, test.expected) {
+			t.Errorf("Expected %v for filter %v, got %v", test.expected, test.filter, actions)
+		}
+	}
+}
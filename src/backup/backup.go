@@ -0,0 +1,419 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// Define backups, which hold a whole library in a portable archive that doesn't depend
+// on the database it came from
+
+// Format names the kind of archive in its manifest
+const Format = "recipes-backup"
+
+// Version is the newest archive version this package writes and reads
+const Version = 1
+
+// The files in an archive, in the order they are written. Each one holds a JSON value
+// on each line.
+const (
+	ManifestFile   = "manifest.json"
+	RecipesFile    = "recipes.ndjson"
+	HouseholdsFile = "households.ndjson"
+	UsersFile      = "users.ndjson"
+)
+
+// maxManifestSize is the largest manifest that Read accepts
+const maxManifestSize = 1 << 20
+
+// ErrInvalid is returned when an archive is damaged, incomplete, or not a backup at all
+var ErrInvalid = errors.New("invalid backup")
+
+// Manifest describes an archive. It is the first file in the archive, so that the rest
+// can be checked as they are read.
+type Manifest struct {
+	Format    string
+	Version   int
+	CreatedAt time.Time
+	// Recipes, Households, and Users count what the archive holds. Recipes includes
+	// the ones in the trash.
+	Recipes    int
+	Households int
+	Users      int
+	Files      []File
+}
+
+// File is a file in an archive, along with its size in bytes and its SHA-256 checksum
+// in hex
+type File struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// Archive is the contents of a backup that has been read and checked
+type Archive struct {
+	Manifest   Manifest
+	Recipes    []recipes.Recipe
+	Households []users.Household
+	Users      []users.User
+}
+
+// user is a user as it is kept in an archive. Unlike users.User it includes the password
+// hash, so that users can still log in after a restore.
+type user struct {
+	ID           primitive.ObjectID
+	Username     string
+	PasswordHash string
+	Admin        bool
+	CreatedAt    time.Time
+	HouseholdID  primitive.ObjectID
+}
+
+// Snapshot is a backup that has been taken but not yet written out. Its files are kept
+// in temporary files rather than in memory, so Close must be called once it is no
+// longer needed.
+type Snapshot struct {
+	Manifest Manifest
+	files    []*os.File
+}
+
+// Write backs up every recipe (including the ones in the trash, along with their
+// comments), household, and user to w as a gzipped tar archive, and returns its
+// manifest. The context should have no Viewer, or an admin one, so that every recipe is
+// included. Revision histories, the cook log, share links, API tokens, sessions,
+// webhooks, and the audit log are left out, so restored recipes start new histories
+// and cook stats (see recipes.RecipeManager.ImportRecipes).
+func Write(ctx context.Context, w io.Writer, recipe_manager recipes.RecipeManager, user_store users.UserStore) (Manifest, error) {
+	snapshot, err := TakeSnapshot(ctx, recipe_manager, user_store)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer snapshot.Close()
+	if err := snapshot.Write(w); err != nil {
+		return Manifest{}, err
+	}
+	return snapshot.Manifest, nil
+}
+
+// TakeSnapshot backs up the library as Write does, keeping the archive's files on disk
+// until the snapshot is written, so that its manifest is known before anything is sent
+func TakeSnapshot(ctx context.Context, recipe_manager recipes.RecipeManager, user_store users.UserStore) (*Snapshot, error) {
+	snapshot := &Snapshot{Manifest: Manifest{Format: Format, Version: Version, CreatedAt: time.Now().UTC().Truncate(time.Second)}}
+	manifest := &snapshot.Manifest
+
+	// Each file's size and checksum go in the manifest at the start of the archive, so
+	// the files are written out before the archive is
+	err := snapshot.stage(RecipesFile, func(encoder *json.Encoder) error {
+		err := recipe_manager.ExportRecipes(ctx, func(recipe recipes.Recipe) error {
+			manifest.Recipes++
+			return encoder.Encode(recipe)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export recipes: %w", err)
+		}
+		trash, err := recipe_manager.GetTrash(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to export the trash: %w", err)
+		}
+		for _, recipe := range trash {
+			manifest.Recipes++
+			if err := encoder.Encode(recipe); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+
+	err = snapshot.stage(HouseholdsFile, func(encoder *json.Encoder) error {
+		households, err := user_store.ListHouseholds(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to export households: %w", err)
+		}
+		manifest.Households = len(households)
+		for _, household := range households {
+			if err := encoder.Encode(household); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+
+	err = snapshot.stage(UsersFile, func(encoder *json.Encoder) error {
+		stored, err := user_store.ListUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to export users: %w", err)
+		}
+		manifest.Users = len(stored)
+		for _, u := range stored {
+			if err := encoder.Encode(user(u)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// stage writes a file of the archive to a temporary file, one JSON value per line, and
+// adds it to the manifest
+func (s *Snapshot) stage(name string, write func(encoder *json.Encoder) error) error {
+	temp, err := os.CreateTemp("", "recipes-backup-*")
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, temp)
+
+	sum := sha256.New()
+	buffered := bufio.NewWriter(io.MultiWriter(temp, sum))
+	if err := write(json.NewEncoder(buffered)); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	size, err := temp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	s.Manifest.Files = append(s.Manifest.Files, File{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(sum.Sum(nil)),
+	})
+	return nil
+}
+
+// Write writes the snapshot to w as a gzipped tar archive, manifest first. It can only
+// be written once.
+func (s *Snapshot) Write(w io.Writer) error {
+	manifestData, err := json.MarshalIndent(s.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	writeHeader := func(name string, size int64) error {
+		return archive.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    size,
+			ModTime: s.Manifest.CreatedAt,
+		})
+	}
+	if err := writeHeader(ManifestFile, int64(len(manifestData))); err != nil {
+		return err
+	}
+	if _, err := archive.Write(manifestData); err != nil {
+		return err
+	}
+	for i, file := range s.Manifest.Files {
+		if err := writeHeader(file.Name, file.Size); err != nil {
+			return err
+		}
+		if _, err := s.files[i].Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(archive, s.files[i], file.Size); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Close removes the snapshot's temporary files
+func (s *Snapshot) Close() error {
+	var errs []error
+	for _, file := range s.files {
+		file.Close()
+		if err := os.Remove(file.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.files = nil
+	return errors.Join(errs...)
+}
+
+// Read reads an archive written by Write and checks it: the manifest must be for a
+// version this package understands, every file in it must be present with the right
+// size and checksum, and the recipes, households, and users must match its counts and
+// have unique IDs. Problems with the archive are returned wrapping ErrInvalid.
+func Read(r io.Reader) (*Archive, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	defer compressed.Close()
+	archive := tar.NewReader(compressed)
+
+	// The manifest comes first
+	header, err := archive.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if header.Name != ManifestFile || header.Size > maxManifestSize {
+		return nil, fmt.Errorf("%w: expected %s at the start of the archive, got %s", ErrInvalid, ManifestFile, header.Name)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to read the manifest: %v", ErrInvalid, err)
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("%w: not a recipes backup", ErrInvalid)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d (this server reads versions 1 to %d)", ErrInvalid, manifest.Version, Version)
+	}
+	expected := make(map[string]File)
+	for _, file := range manifest.Files {
+		expected[file.Name] = file
+	}
+
+	// Then every file it lists, each checked against it
+	contents := make(map[string][]byte)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		file, ok := expected[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrInvalid, header.Name)
+		}
+		if _, seen := contents[file.Name]; seen {
+			return nil, fmt.Errorf("%w: %s appears more than once", ErrInvalid, file.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(archive, file.Size+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalid, file.Name, err)
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("%w: %s does not match its checksum", ErrInvalid, file.Name)
+		}
+		contents[file.Name] = data
+	}
+	for _, name := range []string{RecipesFile, HouseholdsFile, UsersFile} {
+		if _, ok := contents[name]; !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalid, name)
+		}
+	}
+
+	// Finally parse the files and check what they hold
+	result := &Archive{Manifest: manifest}
+	if result.Recipes, err = decodeLines[recipes.Recipe](contents[RecipesFile]); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, RecipesFile, err)
+	}
+	if result.Households, err = decodeLines[users.Household](contents[HouseholdsFile]); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, HouseholdsFile, err)
+	}
+	records, err := decodeLines[user](contents[UsersFile])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, UsersFile, err)
+	}
+	for _, record := range records {
+		result.Users = append(result.Users, users.User(record))
+	}
+	if err := result.check(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return result, nil
+}
+
+// decodeLines decodes a JSON value from each line
+func decodeLines[T any](data []byte) ([]T, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var values []T
+	for line := 1; decoder.More(); line++ {
+		var value T
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// check makes sure the contents of an archive match its manifest and can be restored
+func (a *Archive) check() error {
+	if len(a.Recipes) != a.Manifest.Recipes || len(a.Households) != a.Manifest.Households || len(a.Users) != a.Manifest.Users {
+		return fmt.Errorf("expected %d recipes, %d households, and %d users, found %d, %d, and %d",
+			a.Manifest.Recipes, a.Manifest.Households, a.Manifest.Users,
+			len(a.Recipes), len(a.Households), len(a.Users))
+	}
+
+	recipeIDs := make(map[primitive.ObjectID]bool)
+	for _, recipe := range a.Recipes {
+		if recipe.ID.IsZero() || recipeIDs[recipe.ID] {
+			return fmt.Errorf("recipe %q has a missing or repeated ID", recipe.Name)
+		}
+		recipeIDs[recipe.ID] = true
+		if recipe.Visibility != "" {
+			if err := recipes.ValidateVisibility(recipe.Visibility); err != nil {
+				return fmt.Errorf("recipe %s: %v", recipe.ID.Hex(), err)
+			}
+		}
+	}
+
+	householdIDs := make(map[primitive.ObjectID]bool)
+	for _, household := range a.Households {
+		if household.ID.IsZero() || householdIDs[household.ID] {
+			return fmt.Errorf("household %q has a missing or repeated ID", household.Name)
+		}
+		householdIDs[household.ID] = true
+	}
+
+	userIDs := make(map[primitive.ObjectID]bool)
+	usernames := make(map[string]bool)
+	for _, u := range a.Users {
+		if u.ID.IsZero() || userIDs[u.ID] {
+			return fmt.Errorf("user %q has a missing or repeated ID", u.Username)
+		}
+		if u.Username == "" || usernames[u.Username] {
+			return fmt.Errorf("user %s has a missing or repeated username", u.ID.Hex())
+		}
+		if u.InHousehold() && !householdIDs[u.HouseholdID] {
+			return fmt.Errorf("user %q is in a household that isn't in the backup", u.Username)
+		}
+		userIDs[u.ID], usernames[u.Username] = true, true
+	}
+
+	return nil
+}
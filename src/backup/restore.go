@@ -0,0 +1,198 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// Mode is how a restore treats what is already in the library
+type Mode string
+
+const (
+	// ModeMerge adds what is in the archive but not in the library, and leaves
+	// everything already in the library as it is
+	ModeMerge Mode = "merge"
+	// ModeReplace makes the library's recipes (including the trash) the ones in the
+	// archive. Recipes in both are overwritten, keeping their revision histories, cook
+	// logs, and share links; recipes that aren't in the archive are purged along with
+	// theirs. Households and users in the archive replace the ones with the same IDs;
+	// others are kept, so that whoever is restoring isn't locked out.
+	ModeReplace Mode = "replace"
+)
+
+// ParseMode returns the mode with the given name
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeMerge, ModeReplace:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown restore mode %q: must be %q or %q", name, ModeMerge, ModeReplace)
+}
+
+// Counts is how many of one kind of thing a restore restored, and how many it skipped
+// because they were already in the library (or, for users, their username was taken by
+// someone else)
+type Counts struct {
+	Restored int
+	Skipped  int
+}
+
+// Result is what a restore did
+type Result struct {
+	Recipes    Counts
+	Households Counts
+	Users      Counts
+	// Removed is how many recipes were purged from the library because they weren't in
+	// the archive
+	Removed int
+}
+
+// Restore loads an archive into a recipe manager and user store, recording each restored
+// household and user, and the restore as a whole, in the audit store. The context should
+// have no Viewer, or an admin one, since the restore sees and changes every recipe.
+// Everything is kept as it was in the archive, including IDs, owners, and password
+// hashes. In replace mode the archive's recipes are restored before the others are
+// removed, so an error part way through can leave some behind; restoring the same
+// archive again finishes the job.
+func Restore(ctx context.Context, archive *Archive, recipe_manager recipes.RecipeManager, user_store users.UserStore, audit_store audit.Store, mode Mode) (Result, error) {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return Result{}, err
+	}
+	var result Result
+
+	// Restore the households first, since users belong to them
+	existingHouseholds, err := user_store.ListHouseholds(ctx)
+	if err != nil {
+		return result, err
+	}
+	householdIDs := make(map[primitive.ObjectID]bool)
+	for _, household := range existingHouseholds {
+		householdIDs[household.ID] = true
+	}
+	var households []users.Household
+	for _, household := range archive.Households {
+		if mode == ModeMerge && householdIDs[household.ID] {
+			result.Households.Skipped++
+			continue
+		}
+		households = append(households, household)
+	}
+	if err := user_store.ImportHouseholds(ctx, households); err != nil {
+		return result, fmt.Errorf("failed to restore households: %w", err)
+	}
+	result.Households.Restored = len(households)
+	for _, household := range households {
+		audit.Record(ctx, audit_store, audit.ActionCreate, "", fmt.Sprintf("restored household %q from a backup", household.Name))
+	}
+
+	// Then the users, leaving out any whose username now belongs to someone else
+	existingUsers, err := user_store.ListUsers(ctx)
+	if err != nil {
+		return result, err
+	}
+	userIDs := make(map[primitive.ObjectID]bool)
+	usernames := make(map[string]primitive.ObjectID)
+	for _, u := range existingUsers {
+		userIDs[u.ID] = true
+		usernames[u.Username] = u.ID
+	}
+	var restoredUsers []users.User
+	for _, u := range archive.Users {
+		owner, taken := usernames[u.Username]
+		if (mode == ModeMerge && userIDs[u.ID]) || (taken && owner != u.ID) {
+			result.Users.Skipped++
+			continue
+		}
+		restoredUsers = append(restoredUsers, u)
+	}
+	if err := user_store.ImportUsers(ctx, restoredUsers); err != nil {
+		return result, fmt.Errorf("failed to restore users: %w", err)
+	}
+	result.Users.Restored = len(restoredUsers)
+	for _, u := range restoredUsers {
+		audit.Record(ctx, audit_store, audit.ActionCreate, "", fmt.Sprintf("restored user %q from a backup", u.Username))
+	}
+
+	// Then the recipes, after finding the ones already in the library
+	var existing []string
+	err = recipe_manager.ExportRecipes(ctx, func(recipe recipes.Recipe) error {
+		existing = append(existing, recipe.ID.Hex())
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	trash, err := recipe_manager.GetTrash(ctx)
+	if err != nil {
+		return result, err
+	}
+	recipeIDs := make(map[string]bool)
+	for _, id := range existing {
+		recipeIDs[id] = true
+	}
+	for _, recipe := range trash {
+		recipeIDs[recipe.ID.Hex()] = true
+	}
+
+	// Replacing overwrites the recipes in the library with the ones in the archive, so
+	// they keep their histories, cook logs, and share links
+	var batch []recipes.Recipe
+	archived := make(map[string]bool)
+	for _, recipe := range archive.Recipes {
+		archived[recipe.ID.Hex()] = true
+		if mode == ModeMerge && recipeIDs[recipe.ID.Hex()] {
+			result.Recipes.Skipped++
+			continue
+		}
+		batch = append(batch, recipe)
+		if len(batch) == recipes.MaxBulkRecipes {
+			if err := recipe_manager.ImportRecipes(ctx, batch); err != nil {
+				return result, fmt.Errorf("failed to restore recipes: %w", err)
+			}
+			result.Recipes.Restored += len(batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err := recipe_manager.ImportRecipes(ctx, batch); err != nil {
+			return result, fmt.Errorf("failed to restore recipes: %w", err)
+		}
+		result.Recipes.Restored += len(batch)
+	}
+
+	if mode == ModeReplace {
+		// Then everything that isn't in the archive is moved to the trash and purged
+		var removed []string
+		for _, id := range existing {
+			if archived[id] {
+				continue
+			}
+			if err := recipe_manager.DeleteRecipe(ctx, id); err != nil {
+				return result, fmt.Errorf("failed to remove recipe %s: %w", id, err)
+			}
+			removed = append(removed, id)
+		}
+		for _, recipe := range trash {
+			if !archived[recipe.ID.Hex()] {
+				removed = append(removed, recipe.ID.Hex())
+			}
+		}
+		purged, err := recipe_manager.PurgeRecipes(ctx, removed)
+		if err != nil {
+			return result, fmt.Errorf("failed to purge removed recipes: %w", err)
+		}
+		result.Removed = len(purged)
+	}
+
+	audit.Record(ctx, audit_store, audit.ActionRestore, "", fmt.Sprintf(
+		"restored a backup in %s mode: %d recipes (%d skipped, %d removed), %d households (%d skipped), and %d users (%d skipped)",
+		mode, result.Recipes.Restored, result.Recipes.Skipped, result.Removed,
+		result.Households.Restored, result.Households.Skipped, result.Users.Restored, result.Users.Skipped))
+	return result, nil
+}
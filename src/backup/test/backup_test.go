@@ -0,0 +1,408 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/audit"
+	"github.com/dawsonc/recipes/src/backup"
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// Define a fake recipe manager that keeps recipes in memory, by ID
+type fakeRecipeManager struct {
+	recipes.RecipeManager
+	recipes []recipes.Recipe
+	// purged lists the IDs of the recipes purged from the trash, whose histories would
+	// be gone
+	purged []string
+}
+
+func (m *fakeRecipeManager) ExportRecipes(ctx context.Context, fn func(recipes.Recipe) error) error {
+	for _, recipe := range m.recipes {
+		if recipe.DeletedAt != nil {
+			continue
+		}
+		if err := fn(recipe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *fakeRecipeManager) GetTrash(ctx context.Context) ([]recipes.Recipe, error) {
+	var trash []recipes.Recipe
+	for _, recipe := range m.recipes {
+		if recipe.DeletedAt != nil {
+			trash = append(trash, recipe)
+		}
+	}
+	return trash, nil
+}
+
+func (m *fakeRecipeManager) DeleteRecipe(ctx context.Context, id string) error {
+	for i := range m.recipes {
+		if m.recipes[i].ID.Hex() == id && m.recipes[i].DeletedAt == nil {
+			now := time.Now().UTC()
+			m.recipes[i].DeletedAt = &now
+			return nil
+		}
+	}
+	return recipes.ErrNotFound
+}
+
+func (m *fakeRecipeManager) PurgeRecipes(ctx context.Context, ids []string) ([]string, error) {
+	var kept []recipes.Recipe
+	var purged []string
+	for _, recipe := range m.recipes {
+		if recipe.DeletedAt != nil && contains(ids, recipe.ID.Hex()) {
+			purged = append(purged, recipe.ID.Hex())
+			continue
+		}
+		kept = append(kept, recipe)
+	}
+	m.purged = append(m.purged, purged...)
+	m.recipes = kept
+	return purged, nil
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *fakeRecipeManager) ImportRecipes(ctx context.Context, batch []recipes.Recipe) error {
+	for _, recipe := range batch {
+		replaced := false
+		for i := range m.recipes {
+			if m.recipes[i].ID == recipe.ID {
+				m.recipes[i], replaced = recipe, true
+			}
+		}
+		if !replaced {
+			m.recipes = append(m.recipes, recipe)
+		}
+	}
+	return nil
+}
+
+// Define a fake user store that keeps users and households in memory
+type fakeUserStore struct {
+	users.UserStore
+	users      []users.User
+	households []users.Household
+}
+
+func (s *fakeUserStore) ListUsers(ctx context.Context) ([]users.User, error) {
+	return s.users, nil
+}
+
+func (s *fakeUserStore) ImportUsers(ctx context.Context, batch []users.User) error {
+	for _, user := range batch {
+		replaced := false
+		for i := range s.users {
+			if s.users[i].ID == user.ID {
+				s.users[i], replaced = user, true
+			} else if s.users[i].Username == user.Username {
+				return users.ErrUsernameTaken
+			}
+		}
+		if !replaced {
+			s.users = append(s.users, user)
+		}
+	}
+	return nil
+}
+
+func (s *fakeUserStore) ListHouseholds(ctx context.Context) ([]users.Household, error) {
+	return s.households, nil
+}
+
+func (s *fakeUserStore) ImportHouseholds(ctx context.Context, batch []users.Household) error {
+	for _, household := range batch {
+		replaced := false
+		for i := range s.households {
+			if s.households[i].ID == household.ID {
+				s.households[i], replaced = household, true
+			}
+		}
+		if !replaced {
+			s.households = append(s.households, household)
+		}
+	}
+	return nil
+}
+
+// newLibrary returns a recipe manager and user store with a little of everything in them
+// fakeAuditStore keeps audit log entries in a slice
+type fakeAuditStore struct {
+	audit.Store
+	entries []audit.Entry
+}
+
+func (s *fakeAuditStore) Record(ctx context.Context, entry audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func newLibrary() (*fakeRecipeManager, *fakeUserStore) {
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	household := users.Household{ID: primitive.NewObjectID(), Name: "Home", CreatedAt: created}
+	alice := users.User{ID: primitive.NewObjectID(), Username: "alice", PasswordHash: "hash", Admin: true, CreatedAt: created, HouseholdID: household.ID}
+	manager := &fakeRecipeManager{recipes: []recipes.Recipe{
+		{
+			ID:         primitive.NewObjectID(),
+			Name:       "Soup",
+			Revision:   3,
+			CreatedAt:  created,
+			Owner:      alice.ID.Hex(),
+			Visibility: recipes.VisibilityPrivate,
+			Comments:   []recipes.Comments{{ID: primitive.NewObjectID(), Comment: "Lovely", Author: "alice", Date: created}},
+		},
+		{ID: primitive.NewObjectID(), Name: "Stew", Revision: 1, CreatedAt: created, DeletedAt: &deleted},
+	}}
+	store := &fakeUserStore{users: []users.User{alice}, households: []users.Household{household}}
+	return manager, store
+}
+
+// TestBackupRoundTrip tests that everything written to a backup, including the trash and
+// password hashes, is read back exactly
+func TestBackupRoundTrip(t *testing.T) {
+	manager, store := newLibrary()
+	var data bytes.Buffer
+	manifest, err := backup.Write(context.Background(), &data, manager, store)
+	if err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	if manifest.Version != backup.Version || manifest.Recipes != 2 || manifest.Households != 1 || manifest.Users != 1 || len(manifest.Files) != 3 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	archive, err := backup.Read(&data)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if len(archive.Recipes) != 2 || archive.Recipes[0].ID != manager.recipes[0].ID || archive.Recipes[0].Revision != 3 ||
+		len(archive.Recipes[0].Comments) != 1 || archive.Recipes[1].DeletedAt == nil {
+		t.Errorf("Expected the recipes as they were, got %+v", archive.Recipes)
+	}
+	if len(archive.Users) != 1 || archive.Users[0] != store.users[0] {
+		t.Errorf("Expected the user with their password hash, got %+v", archive.Users)
+	}
+	if len(archive.Households) != 1 || archive.Households[0] != store.households[0] {
+		t.Errorf("Expected the household, got %+v", archive.Households)
+	}
+}
+
+// TestSnapshot tests that a snapshot knows its manifest before it is written, and
+// leaves no temporary files behind
+func TestSnapshot(t *testing.T) {
+	temp := t.TempDir()
+	t.Setenv("TMPDIR", temp)
+	manager, store := newLibrary()
+	snapshot, err := backup.TakeSnapshot(context.Background(), manager, store)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if snapshot.Manifest.Recipes != 2 || snapshot.Manifest.Users != 1 || len(snapshot.Manifest.Files) != 3 {
+		t.Errorf("Unexpected manifest: %+v", snapshot.Manifest)
+	}
+
+	var data bytes.Buffer
+	if err := snapshot.Write(&data); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if err := snapshot.Close(); err != nil {
+		t.Fatalf("Failed to close snapshot: %v", err)
+	}
+	if left, _ := os.ReadDir(temp); len(left) != 0 {
+		t.Errorf("Expected the temporary files to be removed, got %v", left)
+	}
+	if archive, err := backup.Read(&data); err != nil || len(archive.Recipes) != 2 {
+		t.Errorf("Expected the snapshot to read back, got %v", err)
+	}
+}
+
+// rewrite reads a backup and writes it out again, letting change alter each file
+func rewrite(t *testing.T, data []byte, change func(header *tar.Header, contents []byte) []byte) []byte {
+	t.Helper()
+	compressed, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(compressed)
+	var out bytes.Buffer
+	outCompressed := gzip.NewWriter(&out)
+	writer := tar.NewWriter(outCompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, _ := io.ReadAll(reader)
+		if contents = change(header, contents); contents == nil {
+			continue
+		}
+		header.Size = int64(len(contents))
+		writer.WriteHeader(header)
+		writer.Write(contents)
+	}
+	writer.Close()
+	outCompressed.Close()
+	return out.Bytes()
+}
+
+// TestReadInvalidBackups tests that damaged and unknown archives are rejected
+func TestReadInvalidBackups(t *testing.T) {
+	manager, store := newLibrary()
+	var data bytes.Buffer
+	if _, err := backup.Write(context.Background(), &data, manager, store); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	good := data.Bytes()
+
+	tests := []struct {
+		name    string
+		archive []byte
+		message string
+	}{
+		{"not gzipped", []byte("recipes"), "invalid backup"},
+		{"truncated", good[:len(good)/2], "invalid backup"},
+		{"changed recipes", rewrite(t, good, func(header *tar.Header, contents []byte) []byte {
+			if header.Name == backup.RecipesFile {
+				return bytes.Replace(contents, []byte("Soup"), []byte("Stew"), 1)
+			}
+			return contents
+		}), "checksum"},
+		{"missing users", rewrite(t, good, func(header *tar.Header, contents []byte) []byte {
+			if header.Name == backup.UsersFile {
+				return nil
+			}
+			return contents
+		}), "missing"},
+		{"newer version", rewrite(t, good, func(header *tar.Header, contents []byte) []byte {
+			if header.Name == backup.ManifestFile {
+				return bytes.Replace(contents, []byte(`"Version": 1`), []byte(`"Version": 99`), 1)
+			}
+			return contents
+		}), "unsupported version"},
+	}
+	for _, test := range tests {
+		_, err := backup.Read(bytes.NewReader(test.archive))
+		if !errors.Is(err, backup.ErrInvalid) || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an invalid backup error mentioning %q, got %v", test.name, test.message, err)
+		}
+	}
+}
+
+// TestRestoreModes tests that merging keeps what is already in the library, and that
+// replacing overwrites recipes in place and purges the ones that aren't in the backup
+func TestRestoreModes(t *testing.T) {
+	manager, store := newLibrary()
+	var data bytes.Buffer
+	if _, err := backup.Write(context.Background(), &data, manager, store); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	archive, err := backup.Read(&data)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+
+	// Change the library after the backup: rename a recipe, add another, and give a new
+	// user alice's old username
+	manager.recipes[0].Name = "Better soup"
+	manager.recipes = append(manager.recipes, recipes.Recipe{ID: primitive.NewObjectID(), Name: "Pie"})
+	store.users[0].Username = "alicia"
+	store.users = append(store.users, users.User{ID: primitive.NewObjectID(), Username: "alice"})
+
+	// Merging leaves everything already there alone
+	audit_store := &fakeAuditStore{}
+	result, err := backup.Restore(context.Background(), archive, manager, store, audit_store, backup.ModeMerge)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if result.Recipes != (backup.Counts{Skipped: 2}) || result.Users != (backup.Counts{Skipped: 1}) || result.Households != (backup.Counts{Skipped: 1}) {
+		t.Errorf("Expected everything to be skipped, got %+v", result)
+	}
+	if len(manager.recipes) != 3 || manager.recipes[0].Name != "Better soup" {
+		t.Errorf("Expected the library to be unchanged, got %+v", manager.recipes)
+	}
+	if len(audit_store.entries) != 1 || audit_store.entries[0].Action != audit.ActionRestore {
+		t.Errorf("Expected only the restore itself to be recorded, got %+v", audit_store.entries)
+	}
+
+	// Replacing brings back the recipes as they were and removes the new one, but
+	// can't give alice's username back while someone else has it. Only the new recipe
+	// is purged, so the others keep their histories.
+	pie := manager.recipes[2].ID.Hex()
+	audit_store = &fakeAuditStore{}
+	result, err = backup.Restore(context.Background(), archive, manager, store, audit_store, backup.ModeReplace)
+	if err != nil {
+		t.Fatalf("Failed to replace: %v", err)
+	}
+	if result.Recipes != (backup.Counts{Restored: 2}) || result.Removed != 1 || result.Users != (backup.Counts{Skipped: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(manager.recipes) != 2 || manager.recipes[0].Name != "Soup" || manager.recipes[1].DeletedAt == nil {
+		t.Errorf("Expected the recipes from the backup, got %+v", manager.recipes)
+	}
+	if len(manager.purged) != 1 || manager.purged[0] != pie {
+		t.Errorf("Expected only the new recipe to be purged, got %v", manager.purged)
+	}
+	if store.users[0].Username != "alicia" {
+		t.Errorf("Expected alice to keep her new username, got %+v", store.users)
+	}
+
+	// Merging into an empty library restores everything
+	manager, store = &fakeRecipeManager{}, &fakeUserStore{}
+	audit_store = &fakeAuditStore{}
+	result, err = backup.Restore(context.Background(), archive, manager, store, audit_store, backup.ModeMerge)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if result.Recipes.Restored != 2 || result.Users.Restored != 1 || result.Households.Restored != 1 {
+		t.Errorf("Expected everything to be restored, got %+v", result)
+	}
+	if len(store.users) != 1 || store.users[0].PasswordHash != "hash" {
+		t.Errorf("Expected alice to be able to log in, got %+v", store.users)
+	}
+
+	// The restored household and user are recorded, then the restore as a whole
+	expected := []struct{ action, summary string }{
+		{audit.ActionCreate, `restored household "Home" from a backup`},
+		{audit.ActionCreate, `restored user "alice" from a backup`},
+		{audit.ActionRestore, "restored a backup in merge mode: 2 recipes (0 skipped, 0 removed), 1 households (0 skipped), and 1 users (0 skipped)"},
+	}
+	if len(audit_store.entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), audit_store.entries)
+	}
+	for i, entry := range audit_store.entries {
+		if entry.Action != expected[i].action || entry.Summary != expected[i].summary || entry.RecipeID != "" {
+			t.Errorf("Expected entry %d to be %v, got %+v", i, expected[i], entry)
+		}
+	}
+
+	if _, err := backup.ParseMode("overwrite"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
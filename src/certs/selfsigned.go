@@ -0,0 +1,143 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Define how self-signed certificates are made for serving HTTPS in development
+
+// ValidFor is how long a generated certificate lasts
+const ValidFor = 365 * 24 * time.Hour
+
+// renewBefore is how long before it expires that a certificate is replaced
+const renewBefore = 7 * 24 * time.Hour
+
+// GenerateSelfSigned makes a self-signed certificate for the given host names and IP
+// addresses, returning the certificate and its private key PEM encoded
+func GenerateSelfSigned(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("at least one host is required")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Recipes development"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(ValidFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding key: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// EnsureSelfSigned makes sure there is a usable certificate and key in the given files,
+// generating a new self-signed certificate for the given hosts if either file is
+// missing or the certificate is about to expire. It returns true if a new certificate
+// was made.
+func EnsureSelfSigned(certFile, keyFile string, hosts []string) (bool, error) {
+	now := time.Now()
+
+	// Keep the existing certificate while it is still good
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err == nil && now.Add(renewBefore).Before(cert.NotAfter) {
+			return false, nil
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts, now)
+	if err != nil {
+		return false, err
+	}
+
+	// Only the server should be able to read the key
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			return false, fmt.Errorf("creating certificate directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return false, fmt.Errorf("writing certificate: %w", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return false, fmt.Errorf("writing key: %w", err)
+	}
+	return true, nil
+}
+
+// LocalHosts returns the names and addresses this machine can be reached at: localhost,
+// its host name, and the IP address of each network interface that is up, so that other
+// devices on the LAN can connect to it
+func LocalHosts() []string {
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil && name != "" && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return append(hosts, "127.0.0.1", "::1")
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
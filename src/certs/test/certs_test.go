@@ -0,0 +1,116 @@
+package certs_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/certs"
+)
+
+// parseCertificate decodes a PEM encoded certificate
+func parseCertificate(t *testing.T, certPEM []byte) *x509.Certificate {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("Expected a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+// TestGenerateSelfSigned tests that generated certificates are valid for their hosts
+func TestGenerateSelfSigned(t *testing.T) {
+	now := time.Now()
+	certPEM, keyPEM, err := certs.GenerateSelfSigned([]string{"localhost", "kitchen.lan", "192.168.1.20"}, now)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatalf("Expected the certificate and key to match: %v", err)
+	}
+
+	// The certificate should be trusted for each host once it is trusted itself
+	cert := parseCertificate(t, certPEM)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	for _, host := range []string{"localhost", "kitchen.lan", "192.168.1.20"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now}); err != nil {
+			t.Errorf("Expected the certificate to be valid for %s: %v", host, err)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, CurrentTime: now}); err == nil {
+		t.Errorf("Expected the certificate to be invalid for other hosts")
+	}
+	if cert.NotAfter.Before(now.Add(certs.ValidFor - time.Minute)) {
+		t.Errorf("Expected the certificate to last %v, expires %v", certs.ValidFor, cert.NotAfter)
+	}
+
+	if _, _, err := certs.GenerateSelfSigned(nil, now); err == nil {
+		t.Errorf("Expected an error without any hosts")
+	}
+}
+
+// TestEnsureSelfSigned tests that a certificate is only generated when needed
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "certs", "cert.pem")
+	keyFile := filepath.Join(dir, "certs", "key.pem")
+
+	// The first call makes a certificate
+	created, err := certs.EnsureSelfSigned(certFile, keyFile, []string{"localhost"})
+	if err != nil || !created {
+		t.Fatalf("Expected a certificate to be created, got %v, %v", created, err)
+	}
+	first, _ := os.ReadFile(certFile)
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("Failed to stat key: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the key to only be readable by its owner, got %v", info.Mode().Perm())
+	}
+
+	// The second call keeps it
+	created, err = certs.EnsureSelfSigned(certFile, keyFile, []string{"localhost"})
+	if err != nil || created {
+		t.Fatalf("Expected the certificate to be kept, got %v, %v", created, err)
+	}
+	second, _ := os.ReadFile(certFile)
+	if !bytes.Equal(first, second) {
+		t.Errorf("Expected the certificate to be unchanged")
+	}
+
+	// A certificate about to expire is replaced
+	certPEM, keyPEM, err := certs.GenerateSelfSigned([]string{"localhost"}, time.Now().Add(-certs.ValidFor+time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	os.WriteFile(certFile, certPEM, 0o644)
+	os.WriteFile(keyFile, keyPEM, 0o600)
+	created, err = certs.EnsureSelfSigned(certFile, keyFile, []string{"localhost"})
+	if err != nil || !created {
+		t.Errorf("Expected an expiring certificate to be replaced, got %v, %v", created, err)
+	}
+
+	// A broken certificate is reported rather than overwritten
+	os.WriteFile(certFile, []byte("not a certificate"), 0o644)
+	if _, err := certs.EnsureSelfSigned(certFile, keyFile, []string{"localhost"}); err == nil {
+		t.Errorf("Expected an error for a broken certificate")
+	}
+}
+
+// TestLocalHosts tests that the local host names include localhost
+func TestLocalHosts(t *testing.T) {
+	hosts := certs.LocalHosts()
+	if len(hosts) == 0 || hosts[0] != "localhost" {
+		t.Errorf("Expected localhost first, got %v", hosts)
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dawsonc/recipes/src/logging"
+)
+
+// Define a client for the recipes server's HTTP API
+
+// Client talks to a recipes server over HTTP. It implements recipes.RecipeManager,
+// recipes.CookLog, and recipes.ShareLinks, so it can be used anywhere a local recipe
+// manager can. Requests are made as the user the token belongs to; the actor and viewer
+// in the context are ignored. It is safe to use from several goroutines.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// Options configure a Client. The zero value makes anonymous requests with
+// http.DefaultClient and DefaultRetryPolicy.
+type Options struct {
+	// Token is a personal API token (see POST /api/tokens) or a session token, sent as
+	// "Authorization: Bearer TOKEN". Without one, only public recipes can be read.
+	Token string
+	// HTTPClient makes the requests (http.DefaultClient if nil)
+	HTTPClient *http.Client
+	// Retry decides when failed requests are tried again (DefaultRetryPolicy if zero)
+	Retry RetryPolicy
+}
+
+// RetryPolicy decides how often and how long to wait before trying a failed request
+// again. Requests are retried if the server can't be reached, is overloaded (429), or
+// is unavailable (502, 503, or 504), but requests that create something (POST) are only
+// retried if the server turned them away without handling them (429).
+type RetryPolicy struct {
+	// MaxRetries is the most times to retry a request (never if less than zero)
+	MaxRetries int
+	// BaseDelay is how long to wait before the first retry. Each retry waits twice as
+	// long as the one before, with some jitter, up to MaxDelay. A Retry-After header from
+	// the server takes precedence.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy retries a few times over a couple of seconds
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// New makes a client for the server at the given base URL, e.g. "https://recipes.example.com"
+func New(baseURL string, opts Options) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: expected e.g. \"https://recipes.example.com\"", baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	retry := opts.Retry
+	if retry == (RetryPolicy{}) {
+		retry = DefaultRetryPolicy
+	}
+
+	return &Client{baseURL: parsed, token: opts.Token, httpClient: httpClient, retry: retry}, nil
+}
+
+// do sends a request to the API, retrying it as the retry policy allows, and decodes
+// the JSON response into result (unless it is nil). If result is a func(io.Reader) error,
+// it reads the response itself instead. The body, if not nil, is sent as JSON. Error
+// responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	// Encode the body once, so it can be sent again on each retry
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	// The path's parts are already escaped, so that IDs can't add parts of their own
+	target := *c.baseURL
+	target.RawPath = c.baseURL.EscapedPath() + path
+	target.Path, _ = url.PathUnescape(target.RawPath)
+	target.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, method, target.String(), payload)
+		if err == nil && response.StatusCode < 300 {
+			defer response.Body.Close()
+			if result == nil {
+				return nil
+			}
+			if read, ok := result.(func(io.Reader) error); ok {
+				return read(response.Body)
+			}
+			if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+				return fmt.Errorf("decoding response from %s %s: %w", method, path, err)
+			}
+			return nil
+		}
+
+		// Work out what went wrong, and whether it is worth trying again
+		var wait time.Duration
+		retryable := false
+		if err != nil {
+			// Give up straight away if the caller has
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			err = fmt.Errorf("%s %s: %w", method, path, err)
+			retryable = method != http.MethodPost
+		} else {
+			apiErr := readError(response)
+			err = apiErr
+			wait = apiErr.RetryAfter
+			switch response.StatusCode {
+			case http.StatusTooManyRequests:
+				retryable = true
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retryable = method != http.MethodPost
+			}
+		}
+		if !retryable || attempt >= c.retry.MaxRetries {
+			return err
+		}
+
+		// Wait before trying again, unless the caller gives up first
+		if backoff := c.retry.delay(attempt); backoff > wait {
+			wait = backoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes a single attempt at a request
+func (c *Client) send(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	// Carry the ID of the request being handled, so it can be traced across services
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		request.Header.Set("X-Request-ID", id)
+	}
+
+	return c.httpClient.Do(request)
+}
+
+// delay returns how long to wait before the given retry (counting from zero), with up to
+// half of it added at random so that clients don't all retry at once
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	}
+	return delay
+}
+
+// readError turns an error response from the server into an *Error
+func readError(response *http.Response) *Error {
+	defer response.Body.Close()
+
+	apiErr := &Error{
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get("X-Request-ID"),
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	// The API reports errors as {"error": "..."}, but a proxy in front of it may not
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(response.StatusCode)
+	}
+	return apiErr
+}
+
+// Close releases the client's idle connections. The client can still be used afterwards.
+func (c *Client) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Ping checks that the server is up and can reach its database
+func (c *Client) Ping(ctx context.Context) error {
+	err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		return fmt.Errorf("server is not ready: %w", err)
+	}
+	return err
+}
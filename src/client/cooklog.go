@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// LogCook records that a recipe was cooked and returns the ID of the new entry
+func (c *Client) LogCook(ctx context.Context, entry recipes.CookLogEntry) (string, error) {
+	var response created
+	err := c.do(ctx, http.MethodPost, recipePath(entry.RecipeID.Hex(), "cooklog")+"/", nil, entry, &response)
+	return response.ID, err
+}
+
+// GetCookLog returns every time a recipe was cooked, newest first
+func (c *Client) GetCookLog(ctx context.Context, recipeID string) ([]recipes.CookLogEntry, error) {
+	entries := make([]recipes.CookLogEntry, 0)
+	err := c.do(ctx, http.MethodGet, recipePath(recipeID, "cooklog")+"/", nil, nil, &entries)
+	return entries, err
+}
+
+// DeleteCookLogEntry removes an entry from the cook log of a recipe
+func (c *Client) DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) error {
+	return c.do(ctx, http.MethodDelete, recipePath(recipeID, "cooklog", entryID), nil, nil, nil)
+}
+
+// CreateShareLink creates a public link to a recipe, which expires at the given time (or
+// never if it is nil), and returns its token along with its details
+func (c *Client) CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (string, recipes.ShareLink, error) {
+	var response struct {
+		Token string            `json:"token"`
+		Link  recipes.ShareLink `json:"link"`
+	}
+	body := map[string]*time.Time{"ExpiresAt": expiresAt}
+	err := c.do(ctx, http.MethodPost, recipePath(recipeID, "links")+"/", nil, body, &response)
+	return response.Token, response.Link, err
+}
+
+// GetShareLinks returns the share links of a recipe, oldest first
+func (c *Client) GetShareLinks(ctx context.Context, recipeID string) ([]recipes.ShareLink, error) {
+	links := make([]recipes.ShareLink, 0)
+	err := c.do(ctx, http.MethodGet, recipePath(recipeID, "links")+"/", nil, nil, &links)
+	return links, err
+}
+
+// RevokeShareLink deletes a share link so that its token stops working
+func (c *Client) RevokeShareLink(ctx context.Context, recipeID, linkID string) error {
+	return c.do(ctx, http.MethodDelete, recipePath(recipeID, "links", linkID), nil, nil, nil)
+}
+
+// GetSharedRecipe returns the recipe that a share link token points to
+func (c *Client) GetSharedRecipe(ctx context.Context, token string) (recipes.Recipe, error) {
+	var recipe recipes.Recipe
+	err := c.do(ctx, http.MethodGet, "/api/share/"+url.PathEscape(token), nil, nil, &recipe)
+	return recipe, err
+}
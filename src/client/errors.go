@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define errors returned by the client
+
+// Errors that an *Error can be matched against with errors.Is, by its status code. Not
+// found and forbidden responses match recipes.ErrNotFound and recipes.ErrForbidden, the
+// same as a local recipe manager.
+var (
+	// ErrBadRequest is returned when the server rejects a request as invalid (400 or 413)
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is returned when a request needs a token, or the token is invalid (401)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrConflict is returned when a request conflicts with existing data (409)
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited is returned when the client has made too many requests (429)
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer is returned when the server fails to handle a request (5xx)
+	ErrServer = errors.New("server error")
+)
+
+// ErrNotSupported is returned by methods of recipes.RecipeManager that the API doesn't
+// provide
+var ErrNotSupported = errors.New("not supported by the recipes API")
+
+// Error is an error response from the server
+type Error struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Message is the error reported by the server
+	Message string
+	// RequestID identifies the request in the server's logs
+	RequestID string
+	// RetryAfter is how long the server asked the client to wait before trying again
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("recipes API: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("recipes API: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the kind of error matching the status code, so that errors.Is works
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return recipes.ErrNotFound
+	case e.StatusCode == http.StatusForbidden:
+		return recipes.ErrForbidden
+	case e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Make sure the client can stand in for a local recipe manager
+var (
+	_ recipes.RecipeManager = (*Client)(nil)
+	_ recipes.CookLog       = (*Client)(nil)
+	_ recipes.ShareLinks    = (*Client)(nil)
+)
+
+// recipePath returns the escaped API path for the recipe with the given ID, followed by
+// any further parts
+func recipePath(id string, parts ...string) string {
+	path := "/api/recipes/id/" + url.PathEscape(id)
+	for _, part := range parts {
+		path += "/" + url.PathEscape(part)
+	}
+	return path
+}
+
+// created is the response to requests that create something
+type created struct {
+	ID string `json:"id"`
+}
+
+// AddRecipe adds a recipe and returns its ID
+func (c *Client) AddRecipe(ctx context.Context, recipe recipes.Recipe) (string, error) {
+	var response created
+	err := c.do(ctx, http.MethodPost, "/api/recipes/", nil, recipe, &response)
+	return response.ID, err
+}
+
+// bulkCreated is the response to a bulk import, with a result for each recipe in the
+// order they were sent
+type bulkCreated struct {
+	Results []struct {
+		Index int
+		ID    string
+		Error string
+	} `json:"results"`
+}
+
+// AddRecipes adds several recipes in a single request and returns their IDs in the same
+// order. Recipes the server couldn't add have an empty ID, and are described by the
+// *recipes.BulkError returned along with the IDs.
+func (c *Client) AddRecipes(ctx context.Context, batch []recipes.Recipe) ([]string, error) {
+	if len(batch) > recipes.MaxBulkRecipes {
+		return nil, fmt.Errorf("too many recipes: at most %d can be added at once, got %d", recipes.MaxBulkRecipes, len(batch))
+	}
+	var response bulkCreated
+	if err := c.do(ctx, http.MethodPost, "/api/recipes/bulk", nil, batch, &response); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(batch))
+	failed := make(map[int]error)
+	for _, result := range response.Results {
+		if result.Index < 0 || result.Index >= len(batch) {
+			continue
+		}
+		if result.Error != "" {
+			failed[result.Index] = errors.New(result.Error)
+		}
+		ids[result.Index] = result.ID
+	}
+	if len(failed) > 0 {
+		return ids, &recipes.BulkError{Errors: failed}
+	}
+	return ids, nil
+}
+
+// DeleteRecipe moves a recipe to the trash
+func (c *Client) DeleteRecipe(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, recipePath(id), nil, nil, nil)
+}
+
+// UpdateRecipe replaces the recipe with the same ID
+func (c *Client) UpdateRecipe(ctx context.Context, recipe recipes.Recipe) error {
+	return c.do(ctx, http.MethodPut, recipePath(recipe.ID.Hex()), nil, recipe, nil)
+}
+
+// GetAllRecipes returns all recipes the user can see
+func (c *Client) GetAllRecipes(ctx context.Context) ([]recipes.Recipe, error) {
+	return c.ListRecipes(ctx, recipes.ListOptions{})
+}
+
+// CountRecipes returns how many recipes the user can see. The API has no count, so the
+// recipes are listed and counted.
+func (c *Client) CountRecipes(ctx context.Context) (int, error) {
+	all, err := c.GetAllRecipes(ctx)
+	return len(all), err
+}
+
+// GetRecipeByID returns the recipe with the given ID
+func (c *Client) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	var recipe recipes.Recipe
+	err := c.do(ctx, http.MethodGet, recipePath(id), nil, nil, &recipe)
+	return recipe, err
+}
+
+// GetRecipesByIDs returns the recipes with the given IDs, leaving out any the user can't
+// see. The API has no batch lookup, so each recipe is fetched in turn.
+func (c *Client) GetRecipesByIDs(ctx context.Context, ids []string) ([]recipes.Recipe, error) {
+	found := make([]recipes.Recipe, 0, len(ids))
+	for _, id := range ids {
+		recipe, err := c.GetRecipeByID(ctx, id)
+		if errors.Is(err, recipes.ErrNotFound) || errors.Is(err, recipes.ErrForbidden) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, recipe)
+	}
+	return found, nil
+}
+
+// GetRecipesByTags returns all recipes with the given tags
+func (c *Client) GetRecipesByTags(ctx context.Context, tags []string) ([]recipes.Recipe, error) {
+	return c.ListRecipes(ctx, recipes.ListOptions{Tags: tags})
+}
+
+// GetTags returns all tags on recipes
+func (c *Client) GetTags(ctx context.Context) ([]string, error) {
+	tags := make([]string, 0)
+	err := c.do(ctx, http.MethodGet, "/api/recipes/tags", nil, nil, &tags)
+	return tags, err
+}
+
+// SearchRecipes returns all recipes that match the given query string and tags
+func (c *Client) SearchRecipes(ctx context.Context, query string, tags []string) ([]recipes.Recipe, error) {
+	return c.ListRecipes(ctx, recipes.ListOptions{Query: query, Tags: tags})
+}
+
+// ExportRecipes calls fn with every recipe the user can see as the server streams them,
+// one at a time
+func (c *Client) ExportRecipes(ctx context.Context, fn func(recipes.Recipe) error) error {
+	read := func(body io.Reader) error {
+		decoder := json.NewDecoder(body)
+		for {
+			var recipe recipes.Recipe
+			err := decoder.Decode(&recipe)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("decoding exported recipes: %w", err)
+			}
+			if err := fn(recipe); err != nil {
+				return err
+			}
+		}
+	}
+	return c.do(ctx, http.MethodGet, "/api/recipes/export", nil, nil, read)
+}
+
+// ListRecipes returns all recipes that match the given options, in the requested order
+func (c *Client) ListRecipes(ctx context.Context, opts recipes.ListOptions) ([]recipes.Recipe, error) {
+	// Catch bad options before they are sent
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if opts.Query != "" {
+		query.Set("q", opts.Query)
+	}
+	if len(opts.Tags) > 0 {
+		query.Set("tags", strings.Join(opts.Tags, ","))
+	}
+	dates := map[string]time.Time{
+		"created_after":  opts.CreatedAfter,
+		"created_before": opts.CreatedBefore,
+		"updated_after":  opts.UpdatedAfter,
+		"updated_before": opts.UpdatedBefore,
+	}
+	for param, date := range dates {
+		if !date.IsZero() {
+			query.Set(param, date.Format(time.RFC3339Nano))
+		}
+	}
+	if opts.SortBy != "" {
+		query.Set("sort", opts.SortBy)
+	}
+	if opts.Descending {
+		query.Set("order", "desc")
+	}
+
+	list := make([]recipes.Recipe, 0)
+	err := c.do(ctx, http.MethodGet, "/api/recipes/", query, nil, &list)
+	return list, err
+}
+
+// GetRevisions returns the revision history of a recipe, oldest first
+func (c *Client) GetRevisions(ctx context.Context, id string) ([]recipes.Revision, error) {
+	revisions := make([]recipes.Revision, 0)
+	err := c.do(ctx, http.MethodGet, recipePath(id, "revisions")+"/", nil, nil, &revisions)
+	return revisions, err
+}
+
+// GetRevision returns a single revision of a recipe
+func (c *Client) GetRevision(ctx context.Context, id string, number int) (recipes.Revision, error) {
+	var revision recipes.Revision
+	err := c.do(ctx, http.MethodGet, recipePath(id, "revisions", strconv.Itoa(number)), nil, nil, &revision)
+	return revision, err
+}
+
+// RestoreRevision makes an old revision of a recipe current again
+func (c *Client) RestoreRevision(ctx context.Context, id string, number int) error {
+	return c.do(ctx, http.MethodPost, recipePath(id, "revisions", strconv.Itoa(number), "restore"), nil, nil, nil)
+}
+
+// GetTrash returns all recipes in the trash
+func (c *Client) GetTrash(ctx context.Context) ([]recipes.Recipe, error) {
+	trash := make([]recipes.Recipe, 0)
+	err := c.do(ctx, http.MethodGet, "/api/recipes/trash/", nil, nil, &trash)
+	return trash, err
+}
+
+// RestoreRecipe moves a recipe out of the trash
+func (c *Client) RestoreRecipe(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/recipes/trash/"+url.PathEscape(id)+"/restore", nil, nil, nil)
+}
+
+// ImportRecipes is not supported, since the API only restores whole backups (see POST
+// /api/admin/restore)
+func (c *Client) ImportRecipes(ctx context.Context, batch []recipes.Recipe) error {
+	return ErrNotSupported
+}
+
+// PurgeTrash is not supported, since only the server purges the trash (see
+// trash_retention in its configuration)
+func (c *Client) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// PurgeRecipes is not supported, since the API only restores whole backups (see POST
+// /api/admin/restore)
+func (c *Client) PurgeRecipes(ctx context.Context, ids []string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// AddComment adds a comment to a recipe and returns its ID
+func (c *Client) AddComment(ctx context.Context, recipeID string, comment recipes.Comments) (string, error) {
+	var response created
+	err := c.do(ctx, http.MethodPost, recipePath(recipeID, "comments")+"/", nil, comment, &response)
+	return response.ID, err
+}
+
+// UpdateComment changes the text of a comment on a recipe
+func (c *Client) UpdateComment(ctx context.Context, recipeID string, comment recipes.Comments) error {
+	return c.do(ctx, http.MethodPut, recipePath(recipeID, "comments", comment.ID.Hex()), nil, comment, nil)
+}
+
+// DeleteComment removes a comment from a recipe
+func (c *Client) DeleteComment(ctx context.Context, recipeID, commentID string) error {
+	return c.do(ctx, http.MethodDelete, recipePath(recipeID, "comments", commentID), nil, nil, nil)
+}
+
+// SetVisibility changes who can see a recipe
+func (c *Client) SetVisibility(ctx context.Context, id, visibility string) error {
+	body := map[string]string{"Visibility": visibility}
+	return c.do(ctx, http.MethodPut, recipePath(id, "visibility"), nil, body, nil)
+}
+
+// ShareRecipe is not supported, since the API shares recipes by username rather than
+// user ID. Use ShareRecipeWith instead.
+func (c *Client) ShareRecipe(ctx context.Context, id string, grant recipes.ShareGrant) error {
+	return ErrNotSupported
+}
+
+// UnshareRecipe is not supported, since the API shares recipes by username rather than
+// user ID. Use UnshareRecipeWith instead.
+func (c *Client) UnshareRecipe(ctx context.Context, id, userID string) error {
+	return ErrNotSupported
+}
+
+// ShareRecipeWith gives the user with the given username permission to view or edit a
+// recipe, replacing any permission they had before
+func (c *Client) ShareRecipeWith(ctx context.Context, id, username, permission string) error {
+	body := map[string]string{"Permission": permission}
+	return c.do(ctx, http.MethodPut, recipePath(id, "shares", username), nil, body, nil)
+}
+
+// UnshareRecipeWith takes away any permission the user with the given username was
+// given on a recipe
+func (c *Client) UnshareRecipeWith(ctx context.Context, id, username string) error {
+	return c.do(ctx, http.MethodDelete, recipePath(id, "shares", username), nil, nil, nil)
+}
@@ -0,0 +1,374 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/client"
+	"github.com/dawsonc/recipes/src/logging"
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// fastRetries retries quickly, so the tests don't wait around
+var fastRetries = client.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// newClient starts a server with the given handler and returns a client for it
+func newClient(t *testing.T, handler http.HandlerFunc, opts client.Options) *client.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	if opts.Retry == (client.RetryPolicy{}) {
+		opts.Retry = fastRetries
+	}
+	c, err := client.New(server.URL, opts)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+// respond writes a JSON response
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// TestNew tests that only usable base URLs are accepted
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"https://recipes.example.com", "http://localhost:8080/", "http://example.com/recipes"} {
+		if _, err := client.New(baseURL, client.Options{}); err != nil {
+			t.Errorf("Expected %q to be accepted: %v", baseURL, err)
+		}
+	}
+	for _, baseURL := range []string{"", "recipes.example.com", "ftp://example.com", "http://"} {
+		if _, err := client.New(baseURL, client.Options{}); err == nil {
+			t.Errorf("Expected %q to be rejected", baseURL)
+		}
+	}
+}
+
+// TestRecipes tests that recipes are sent and received in the API's format
+func TestRecipes(t *testing.T) {
+	id := primitive.NewObjectID()
+	var requests []string
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+
+		// Every request carries the token and the request ID
+		if r.Header.Get("Authorization") != "Bearer rcp_secret" || r.Header.Get("X-Request-ID") != "req-1" {
+			respond(w, http.StatusUnauthorized, map[string]string{"error": "missing headers"})
+			return
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/recipes/":
+			var recipe recipes.Recipe
+			if err := json.NewDecoder(r.Body).Decode(&recipe); err != nil || recipe.Name != "Soup" {
+				respond(w, http.StatusBadRequest, map[string]string{"error": "bad recipe"})
+				return
+			}
+			respond(w, http.StatusOK, map[string]string{"message": "Recipe created successfully", "id": id.Hex()})
+		case "GET /api/recipes/id/" + id.Hex():
+			respond(w, http.StatusOK, recipes.Recipe{ID: id, Name: "Soup", Tags: []string{"dinner"}})
+		case "GET /api/recipes/":
+			respond(w, http.StatusOK, []recipes.Recipe{{ID: id, Name: "Soup"}})
+		case "PUT /api/recipes/id/" + id.Hex() + "/shares/alice":
+			respond(w, http.StatusOK, map[string]string{"message": "Recipe shared successfully"})
+		default:
+			respond(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	}, client.Options{Token: "rcp_secret"})
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+
+	newID, err := c.AddRecipe(ctx, recipes.Recipe{Name: "Soup"})
+	if err != nil || newID != id.Hex() {
+		t.Fatalf("Expected ID %s, got %q, %v", id.Hex(), newID, err)
+	}
+
+	recipe, err := c.GetRecipeByID(ctx, id.Hex())
+	if err != nil || recipe.ID != id || recipe.Name != "Soup" || len(recipe.Tags) != 1 {
+		t.Errorf("Expected the recipe, got %+v, %v", recipe, err)
+	}
+
+	opts := recipes.ListOptions{
+		Query:        "tomato soup",
+		Tags:         []string{"dinner", "quick"},
+		CreatedAfter: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		SortBy:       recipes.SortByRating,
+		Descending:   true,
+	}
+	list, err := c.ListRecipes(ctx, opts)
+	if err != nil || len(list) != 1 {
+		t.Errorf("Expected one recipe, got %v, %v", list, err)
+	}
+	expected := "GET /api/recipes/?created_after=2023-01-02T03%3A04%3A05Z&order=desc&q=tomato+soup&sort=rating&tags=dinner%2Cquick"
+	if requests[len(requests)-1] != expected {
+		t.Errorf("Expected request %q, got %q", expected, requests[len(requests)-1])
+	}
+
+	if err := c.ShareRecipeWith(ctx, id.Hex(), "alice", recipes.PermissionView); err != nil {
+		t.Errorf("Failed to share recipe: %v", err)
+	}
+
+	// IDs can't reach other routes
+	if _, err := c.GetRecipeByID(ctx, "../../tags"); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	if requests[len(requests)-1] != "GET /api/recipes/id/..%2F..%2Ftags" {
+		t.Errorf("Expected the ID to be escaped, got %q", requests[len(requests)-1])
+	}
+
+	// Bad options are caught before they are sent
+	if _, err := c.ListRecipes(ctx, recipes.ListOptions{SortBy: "colour"}); err == nil {
+		t.Errorf("Expected an error for a bad sort key")
+	}
+
+	// Some methods have no equivalent in the API
+	if _, err := c.PurgeTrash(ctx, time.Now()); !errors.Is(err, client.ErrNotSupported) {
+		t.Errorf("Expected purging to be unsupported, got %v", err)
+	}
+}
+
+// TestBulkRecipes tests importing recipes in bulk and streaming an export
+func TestBulkRecipes(t *testing.T) {
+	ids := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+	truncate := false
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/recipes/bulk":
+			var batch []recipes.Recipe
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || len(batch) != 3 {
+				respond(w, http.StatusBadRequest, map[string]string{"error": "bad recipes"})
+				return
+			}
+			respond(w, http.StatusOK, map[string]interface{}{
+				"message": "Added 2 of 3 recipes",
+				"results": []map[string]interface{}{
+					{"Index": 0, "ID": ids[0], "Error": ""},
+					{"Index": 1, "ID": "", "Error": "invalid visibility"},
+					{"Index": 2, "ID": ids[1], "Error": ""},
+				},
+			})
+		case "GET /api/recipes/export":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for _, id := range ids {
+				json.NewEncoder(w).Encode(map[string]string{"ID": id, "Name": "Soup"})
+				if truncate {
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+			}
+		default:
+			respond(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	}, client.Options{})
+	ctx := context.Background()
+
+	// Each recipe gets its ID or the reason it failed, in the order they were sent
+	added, err := c.AddRecipes(ctx, []recipes.Recipe{{Name: "Soup"}, {Name: "Stew", Visibility: "secret"}, {Name: "Pie"}})
+	var bulkErr *recipes.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Errors) != 1 || bulkErr.Errors[1] == nil {
+		t.Fatalf("Expected the second recipe to fail, got %v", err)
+	}
+	if !reflect.DeepEqual(added, []string{ids[0], "", ids[1]}) {
+		t.Errorf("Expected IDs %v, got %v", ids, added)
+	}
+
+	// Exported recipes are handed over one at a time
+	var exported []string
+	err = c.ExportRecipes(ctx, func(recipe recipes.Recipe) error {
+		exported = append(exported, recipe.ID.Hex())
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(exported, ids) {
+		t.Errorf("Expected to export %v, got %v (%v)", ids, exported, err)
+	}
+
+	// An export that is cut off part way through fails, rather than looking complete
+	truncate = true
+	exported = nil
+	err = c.ExportRecipes(ctx, func(recipe recipes.Recipe) error {
+		exported = append(exported, recipe.ID.Hex())
+		return nil
+	})
+	if err == nil || len(exported) != 1 {
+		t.Errorf("Expected the export to fail after one recipe, got %v (%v)", exported, err)
+	}
+}
+
+// TestErrors tests that error responses can be matched by kind
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   error
+	}{
+		{http.StatusBadRequest, client.ErrBadRequest},
+		{http.StatusUnauthorized, client.ErrUnauthorized},
+		{http.StatusForbidden, recipes.ErrForbidden},
+		{http.StatusNotFound, recipes.ErrNotFound},
+		{http.StatusConflict, client.ErrConflict},
+		{http.StatusRequestEntityTooLarge, client.ErrBadRequest},
+		{http.StatusInternalServerError, client.ErrServer},
+	}
+	for _, test := range tests {
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-2")
+			respond(w, test.status, map[string]string{"error": "something happened"})
+		}, client.Options{})
+
+		err := c.DeleteRecipe(context.Background(), "abc")
+		if !errors.Is(err, test.kind) {
+			t.Errorf("%d: expected %v, got %v", test.status, test.kind, err)
+		}
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status || apiErr.Message != "something happened" ||
+			apiErr.RequestID != "req-2" {
+			t.Errorf("%d: expected the details of the error, got %#v", test.status, err)
+		}
+	}
+
+	// Errors that aren't from the API still have a message
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream exploded", http.StatusBadGateway)
+	}, client.Options{Retry: client.RetryPolicy{MaxRetries: -1}})
+	var apiErr *client.Error
+	if err := c.DeleteRecipe(context.Background(), "abc"); !errors.As(err, &apiErr) || apiErr.Message != "upstream exploded" {
+		t.Errorf("Expected the plain text error, got %v", err)
+	}
+}
+
+// TestRetries tests which requests are retried
+func TestRetries(t *testing.T) {
+	// Failures that might be temporary are retried
+	var attempts int32
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			respond(w, http.StatusServiceUnavailable, map[string]string{"error": "try again"})
+			return
+		}
+		respond(w, http.StatusOK, []string{"dinner"})
+	}, client.Options{})
+	tags, err := c.GetTags(context.Background())
+	if err != nil || len(tags) != 1 || attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %v, %v after %d", tags, err, attempts)
+	}
+
+	// But only so many times
+	attempts = 0
+	c = newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		respond(w, http.StatusServiceUnavailable, map[string]string{"error": "down"})
+	}, client.Options{})
+	if _, err := c.GetTags(context.Background()); !errors.Is(err, client.ErrServer) || attempts != 4 {
+		t.Errorf("Expected to give up after 4 attempts, got %v after %d", err, attempts)
+	}
+
+	// Creating something is only retried if the server didn't handle it
+	attempts = 0
+	if _, err := c.AddRecipe(context.Background(), recipes.Recipe{}); err == nil || attempts != 1 {
+		t.Errorf("Expected a POST not to be retried after a 503, got %d attempts", attempts)
+	}
+
+	// Mistakes aren't retried at all
+	attempts = 0
+	c = newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		respond(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}, client.Options{})
+	if _, err := c.GetTags(context.Background()); err == nil || attempts != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d attempts", attempts)
+	}
+
+	// The server can't be reached
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	c, _ = client.New(server.URL, client.Options{Retry: fastRetries})
+	if _, err := c.GetTags(context.Background()); err == nil {
+		t.Errorf("Expected an error when the server is down")
+	}
+}
+
+// TestRetryAfter tests that the client waits as long as the server asks it to
+func TestRetryAfter(t *testing.T) {
+	var attempts int32
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			respond(w, http.StatusTooManyRequests, map[string]string{"error": "slow down"})
+			return
+		}
+		respond(w, http.StatusOK, map[string]string{"message": "Recipe created successfully", "id": "abc"})
+	}, client.Options{})
+
+	// Even creating something is retried, since the server didn't handle it
+	start := time.Now()
+	id, err := c.AddRecipe(context.Background(), recipes.Recipe{Name: "Soup"})
+	if err != nil || id != "abc" {
+		t.Fatalf("Expected success after waiting, got %q, %v", id, err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("Expected to wait at least a second, waited %v", waited)
+	}
+
+	// Without retries the wait is reported
+	attempts = 0
+	c = newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		respond(w, http.StatusTooManyRequests, map[string]string{"error": "slow down"})
+	}, client.Options{Retry: client.RetryPolicy{MaxRetries: -1}})
+	var apiErr *client.Error
+	_, err = c.GetTags(context.Background())
+	if !errors.Is(err, client.ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("Expected a rate limit error asking to wait 30s, got %#v", err)
+	}
+}
+
+// TestCancel tests that cancelling the context stops the client waiting to retry
+func TestCancel(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		respond(w, http.StatusTooManyRequests, map[string]string{"error": "slow down"})
+	}, client.Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.GetTags(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("Expected to stop waiting when the context was done, waited %v", waited)
+	}
+}
+
+// TestPingReadiness tests that Ping reports whether the server is ready
+func TestPingReadiness(t *testing.T) {
+	ready := true
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			respond(w, http.StatusNotFound, nil)
+		} else if ready {
+			respond(w, http.StatusOK, map[string]string{"status": "ok"})
+		} else {
+			respond(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": "no database"})
+		}
+	}, client.Options{Retry: client.RetryPolicy{MaxRetries: -1}})
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the server to be ready, got %v", err)
+	}
+	ready = false
+	if err := c.Ping(context.Background()); err == nil {
+		t.Errorf("Expected the server not to be ready")
+	}
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Define the settings for the server
+
+type Config struct {
+	// Listen is the address the server listens on
+	Listen string `yaml:"listen"`
+	// GRPCListen is the address the gRPC recipe service listens on, or empty to not
+	// serve it. It uses the same certificate as HTTPS when that is turned on.
+	GRPCListen string `yaml:"grpc_listen"`
+	// FrontendDir is the directory the frontend files are served from
+	FrontendDir string `yaml:"frontend_dir"`
+	// TLS turns on HTTPS if both files are given, or a self-signed certificate is wanted
+	TLS TLS `yaml:"tls"`
+	// Backend is where recipes, users, and the audit log are stored
+	Backend Backend `yaml:"backend"`
+	// Timeouts limit how long the server waits on each connection
+	Timeouts Timeouts `yaml:"timeouts"`
+	// CORS controls which other sites may call the API from a browser
+	CORS CORS `yaml:"cors"`
+	// TrustedProxies lists the addresses (e.g. "10.0.0.1") or networks (e.g.
+	// "10.0.0.0/8") of the reverse proxies in front of the server. Only requests from
+	// them may say which client they came from with X-Forwarded-For; by default no one
+	// is trusted, and each client is known by the address it connected from.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Limits stop any one client from overloading the server
+	Limits Limits `yaml:"limits"`
+	// Webhooks control how changes to recipes are sent to other services
+	Webhooks Webhooks `yaml:"webhooks"`
+	// LogLevel is one of debug, info, warn, or error
+	LogLevel string `yaml:"log_level"`
+	// SlowQueryThreshold is how long a database operation can take before it is logged
+	// as slow (never if zero)
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+	// TrashRetention is how long deleted recipes stay in the trash before they are purged
+	TrashRetention time.Duration `yaml:"trash_retention"`
+}
+
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinVersion is the oldest version of TLS to accept ("1.2" or "1.3")
+	MinVersion string `yaml:"min_version"`
+	// ClientAuth asks clients for a certificate signed by one of the authorities in
+	// ClientCAFile: "none", "optional" (checked if given), or "require"
+	ClientAuth   string `yaml:"client_auth"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// RedirectHTTP is an address (e.g. ":80") to listen on for plain HTTP requests and
+	// redirect them to HTTPS, or empty to not listen for HTTP at all
+	RedirectHTTP string `yaml:"redirect_http"`
+	// SelfSigned generates a self-signed certificate for development if the certificate
+	// files don't exist yet (by default, DevCertFile and DevKeyFile). It is valid for
+	// this machine's names and addresses, along with any extra Hosts.
+	SelfSigned bool     `yaml:"self_signed"`
+	Hosts      []string `yaml:"hosts"`
+}
+
+type Backend struct {
+	// Type is the kind of database to use (only "mongo" for now)
+	Type string `yaml:"type"`
+	// DSN is the connection string for the database
+	DSN        string `yaml:"dsn"`
+	Database   string `yaml:"database"`
+	Collection string `yaml:"collection"`
+	// ChangeStreams follows changes to recipes with MongoDB change streams, so that
+	// clients of every server see them, rather than only changes made through this one.
+	// MongoDB must be running as a replica set.
+	ChangeStreams bool `yaml:"change_streams"`
+}
+
+type Timeouts struct {
+	Read  time.Duration `yaml:"read"`
+	Write time.Duration `yaml:"write"`
+	Idle  time.Duration `yaml:"idle"`
+	// Shutdown is how long to wait for requests in progress to finish when stopping
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+type CORS struct {
+	// AllowedOrigins lists the origins (e.g. "https://example.com" or
+	// "chrome-extension://ID") allowed to call the API, or "*" for any origin
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowCredentials lets those origins send the session cookie. API tokens work
+	// without it, so leave it off unless a client needs to log in with a password.
+	AllowCredentials bool `yaml:"allow_credentials"`
+	// MaxAge is how long browsers may cache the answer to a preflight request
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+type Limits struct {
+	// MaxBodyBytes is the largest request body accepted by POST and PUT requests
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// MaxRestoreBytes is the largest backup archive accepted by POST /api/admin/restore,
+	// which is usually much larger than any other request
+	MaxRestoreBytes int `yaml:"max_restore_bytes"`
+	// Reads limits how often each client can make GET requests, and Writes everything
+	// else. Clients are told apart by API token, then user, then IP address.
+	Reads  RateLimit `yaml:"reads"`
+	Writes RateLimit `yaml:"writes"`
+	// GraphQLDepth is how deeply fields can be nested in a GraphQL query, and
+	// GraphQLComplexity roughly how many fields it can ask for, counting the fields of
+	// each item in a list several times over
+	GraphQLDepth      int `yaml:"graphql_depth"`
+	GraphQLComplexity int `yaml:"graphql_complexity"`
+}
+
+type Webhooks struct {
+	// Timeout is how long a receiver has to answer each delivery
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how many times to try each delivery, backing off exponentially
+	// between them, before giving up
+	MaxAttempts int `yaml:"max_attempts"`
+	// AllowedNetworks lists the addresses (e.g. "10.0.0.5") or networks (e.g.
+	// "10.0.0.0/8") of private services that webhooks may send to. Otherwise webhooks
+	// can't reach loopback, private, or link-local addresses, so that users can't use
+	// them to reach the server's own network.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+type RateLimit struct {
+	// PerMinute is the average number of requests allowed a minute (zero for no limit)
+	PerMinute int `yaml:"per_minute"`
+	// Burst is the number of requests that can be made at once, above the average
+	Burst int `yaml:"burst"`
+}
+
+// Backend types that the server knows how to connect to
+const (
+	BackendMongo = "mongo"
+)
+
+// Log levels, from most to least verbose
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// logLevels lists all of the valid values for Config.LogLevel
+var logLevels = []string{LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError}
+
+// TLS versions that can be required
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// tlsVersions lists all of the valid values for TLS.MinVersion
+var tlsVersions = []string{TLSVersion12, TLSVersion13}
+
+// Ways of asking clients for a certificate
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// clientAuths lists all of the valid values for TLS.ClientAuth
+var clientAuths = []string{ClientAuthNone, ClientAuthOptional, ClientAuthRequire}
+
+// Where a self-signed certificate is kept if no other files are given
+const (
+	DevCertFile = "certs/dev-cert.pem"
+	DevKeyFile  = "certs/dev-key.pem"
+)
+
+// Default returns the settings used when nothing else is given
+func Default() Config {
+	return Config{
+		Listen:      ":8080",
+		GRPCListen:  ":9090",
+		FrontendDir: "./frontend",
+		TLS: TLS{
+			MinVersion: TLSVersion12,
+			ClientAuth: ClientAuthNone,
+		},
+		Backend: Backend{
+			Type:       BackendMongo,
+			DSN:        "mongodb://localhost:27017",
+			Database:   "recipes",
+			Collection: "recipes",
+		},
+		Timeouts: Timeouts{
+			Read:     30 * time.Second,
+			Write:    30 * time.Second,
+			Idle:     2 * time.Minute,
+			Shutdown: 30 * time.Second,
+		},
+		CORS: CORS{
+			MaxAge: 10 * time.Minute,
+		},
+		Limits: Limits{
+			MaxBodyBytes:      1 << 20,
+			MaxRestoreBytes:   256 << 20,
+			Reads:             RateLimit{PerMinute: 600, Burst: 100},
+			Writes:            RateLimit{PerMinute: 60, Burst: 20},
+			GraphQLDepth:      8,
+			GraphQLComplexity: 1000,
+		},
+		Webhooks: Webhooks{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+		},
+		LogLevel:           LogLevelInfo,
+		SlowQueryThreshold: 200 * time.Millisecond,
+		TrashRetention:     30 * 24 * time.Hour,
+	}
+}
+
+// TLSEnabled returns true if the server should serve HTTPS
+func (cfg Config) TLSEnabled() bool {
+	return cfg.TLS.SelfSigned || (cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "")
+}
+
+// Files returns the certificate and key files to serve HTTPS with, which default to
+// DevCertFile and DevKeyFile for a self-signed certificate
+func (tls TLS) Files() (certFile, keyFile string) {
+	if tls.SelfSigned && tls.CertFile == "" && tls.KeyFile == "" {
+		return DevCertFile, DevKeyFile
+	}
+	return tls.CertFile, tls.KeyFile
+}
+
+// Validate checks that the settings make sense, reporting every problem at once
+func (cfg Config) Validate() error {
+	var errs []error
+	problem := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Listen == "" {
+		problem("listen: an address is required (e.g. \":8080\")")
+	}
+	if cfg.FrontendDir == "" {
+		problem("frontend_dir: a directory is required")
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		problem("tls: both cert_file and key_file are required to use TLS")
+	}
+	if !contains(tlsVersions, cfg.TLS.MinVersion) {
+		problem("tls.min_version: invalid version %q (expected one of %v)", cfg.TLS.MinVersion, tlsVersions)
+	}
+	if !contains(clientAuths, cfg.TLS.ClientAuth) {
+		problem("tls.client_auth: invalid value %q (expected one of %v)", cfg.TLS.ClientAuth, clientAuths)
+	} else if cfg.TLS.ClientAuth != ClientAuthNone && cfg.TLS.ClientCAFile == "" {
+		problem("tls.client_ca_file: a certificate authority is required to check client certificates")
+	}
+
+	// The other TLS settings mean nothing without HTTPS
+	if !cfg.TLSEnabled() {
+		if cfg.TLS.ClientAuth != ClientAuthNone {
+			problem("tls.client_auth: client certificates can only be checked over HTTPS")
+		}
+		if cfg.TLS.RedirectHTTP != "" {
+			problem("tls.redirect_http: can only redirect to HTTPS if it is turned on")
+		}
+	}
+	if cfg.TLS.RedirectHTTP != "" && cfg.TLS.RedirectHTTP == cfg.Listen {
+		problem("tls.redirect_http: must be a different address from listen")
+	}
+	if cfg.GRPCListen != "" && (cfg.GRPCListen == cfg.Listen || cfg.GRPCListen == cfg.TLS.RedirectHTTP) {
+		problem("grpc_listen: must be a different address from listen and tls.redirect_http")
+	}
+
+	// Only MongoDB is supported so far
+	if cfg.Backend.Type != BackendMongo {
+		problem("backend.type: unsupported backend %q (expected %q)", cfg.Backend.Type, BackendMongo)
+	}
+	if cfg.Backend.DSN == "" {
+		problem("backend.dsn: a connection string is required")
+	}
+	if cfg.Backend.Database == "" {
+		problem("backend.database: a database name is required")
+	}
+	if cfg.Backend.Collection == "" {
+		problem("backend.collection: a collection name is required")
+	}
+
+	// Zero means no timeout, but negative timeouts are a mistake
+	timeouts := map[string]time.Duration{
+		"timeouts.read": cfg.Timeouts.Read, "timeouts.write": cfg.Timeouts.Write, "timeouts.idle": cfg.Timeouts.Idle,
+	}
+	for _, name := range []string{"timeouts.read", "timeouts.write", "timeouts.idle"} {
+		if timeouts[name] < 0 {
+			problem("%s: must not be negative, got %v", name, timeouts[name])
+		}
+	}
+
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			problem("cors.allowed_origins: %v", err)
+		}
+	}
+	if cfg.CORS.AllowCredentials && contains(cfg.CORS.AllowedOrigins, "*") {
+		problem("cors.allow_credentials: can't be used when any origin (\"*\") is allowed")
+	}
+	if cfg.CORS.MaxAge < 0 {
+		problem("cors.max_age: must not be negative, got %v", cfg.CORS.MaxAge)
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if err := validateNetwork(proxy); err != nil {
+			problem("trusted_proxies: %v", err)
+		}
+	}
+	if cfg.Limits.MaxBodyBytes <= 0 {
+		problem("limits.max_body_bytes: must be positive, got %d", cfg.Limits.MaxBodyBytes)
+	}
+	if cfg.Limits.MaxRestoreBytes <= 0 {
+		problem("limits.max_restore_bytes: must be positive, got %d", cfg.Limits.MaxRestoreBytes)
+	}
+	rateLimits := map[string]RateLimit{"limits.reads": cfg.Limits.Reads, "limits.writes": cfg.Limits.Writes}
+	for _, name := range []string{"limits.reads", "limits.writes"} {
+		limit := rateLimits[name]
+		if limit.PerMinute < 0 {
+			problem("%s.per_minute: must not be negative, got %d", name, limit.PerMinute)
+		}
+		if limit.PerMinute > 0 && limit.Burst < 1 {
+			problem("%s.burst: must be at least 1, got %d", name, limit.Burst)
+		}
+	}
+	if cfg.Limits.GraphQLDepth <= 0 {
+		problem("limits.graphql_depth: must be positive, got %d", cfg.Limits.GraphQLDepth)
+	}
+	if cfg.Limits.GraphQLComplexity <= 0 {
+		problem("limits.graphql_complexity: must be positive, got %d", cfg.Limits.GraphQLComplexity)
+	}
+	if cfg.Webhooks.Timeout <= 0 {
+		problem("webhooks.timeout: must be positive, got %v", cfg.Webhooks.Timeout)
+	}
+	if cfg.Webhooks.MaxAttempts <= 0 {
+		problem("webhooks.max_attempts: must be positive, got %d", cfg.Webhooks.MaxAttempts)
+	}
+	for _, network := range cfg.Webhooks.AllowedNetworks {
+		if err := validateNetwork(network); err != nil {
+			problem("webhooks.allowed_networks: %v", err)
+		}
+	}
+	if !contains(logLevels, cfg.LogLevel) {
+		problem("log_level: invalid level %q (expected one of %v)", cfg.LogLevel, logLevels)
+	}
+	if cfg.SlowQueryThreshold < 0 {
+		problem("slow_query_threshold: must not be negative, got %v", cfg.SlowQueryThreshold)
+	}
+	if cfg.Timeouts.Shutdown <= 0 {
+		problem("timeouts.shutdown: must be positive, got %v", cfg.Timeouts.Shutdown)
+	}
+	if cfg.TrashRetention <= 0 {
+		problem("trash_retention: must be positive, got %v", cfg.TrashRetention)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateOrigin checks that an origin is "*" or a bare scheme and host. Browser
+// extensions and mobile apps have their own schemes, e.g. "moz-extension://ID".
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+		return fmt.Errorf("invalid origin %q (expected e.g. \"https://example.com\")", origin)
+	}
+	return nil
+}
+
+// validateNetwork checks that a network is an IP address or a network in CIDR notation
+func validateNetwork(network string) error {
+	if net.ParseIP(network) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return fmt.Errorf("invalid address %q (expected an IP address or a network like \"10.0.0.0/8\")", network)
+	}
+	return nil
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Define how settings are loaded from a file, the environment, and the command line
+
+// EnvPrefix starts the name of every environment variable read by Load
+const EnvPrefix = "RECIPES_"
+
+// setting describes one value that can be set from the environment or the command line.
+// The environment variable name is the flag name in upper case with EnvPrefix, e.g.
+// -trash-retention and RECIPES_TRASH_RETENTION.
+type setting struct {
+	name  string
+	usage string
+	set   func(cfg *Config, value string) error
+}
+
+// settings lists everything that can be set outside of the config file
+var settings = []setting{
+	{"listen", "address to listen on", setString(func(cfg *Config) *string { return &cfg.Listen })},
+	{"grpc-listen", "address for the gRPC recipe service to listen on (empty to turn it off)", setString(func(cfg *Config) *string { return &cfg.GRPCListen })},
+	{"frontend-dir", "directory to serve the frontend from", setString(func(cfg *Config) *string { return &cfg.FrontendDir })},
+	{"tls-cert", "TLS certificate file (enables HTTPS along with -tls-key)", setString(func(cfg *Config) *string { return &cfg.TLS.CertFile })},
+	{"tls-key", "TLS private key file", setString(func(cfg *Config) *string { return &cfg.TLS.KeyFile })},
+	{"tls-min-version", "oldest TLS version to accept: 1.2 or 1.3", setString(func(cfg *Config) *string { return &cfg.TLS.MinVersion })},
+	{"tls-client-auth", "ask clients for a certificate: none, optional, or require", setString(func(cfg *Config) *string { return &cfg.TLS.ClientAuth })},
+	{"tls-client-ca", "file of certificate authorities that sign client certificates", setString(func(cfg *Config) *string { return &cfg.TLS.ClientCAFile })},
+	{"tls-redirect-http", "address to redirect plain HTTP requests to HTTPS from (e.g. :80)", setString(func(cfg *Config) *string { return &cfg.TLS.RedirectHTTP })},
+	{"tls-self-signed", "generate a self-signed certificate for development: true or false", setBool(func(cfg *Config) *bool { return &cfg.TLS.SelfSigned })},
+	{"tls-hosts", "comma-separated extra host names and IPs for the self-signed certificate", setList(func(cfg *Config) *[]string { return &cfg.TLS.Hosts })},
+	{"backend", "kind of database to use (mongo)", setString(func(cfg *Config) *string { return &cfg.Backend.Type })},
+	{"dsn", "database connection string", setString(func(cfg *Config) *string { return &cfg.Backend.DSN })},
+	{"database", "database name", setString(func(cfg *Config) *string { return &cfg.Backend.Database })},
+	{"collection", "collection name for recipes", setString(func(cfg *Config) *string { return &cfg.Backend.Collection })},
+	{"change-streams", "follow changes to recipes with MongoDB change streams (needs a replica set): true or false", setBool(func(cfg *Config) *bool { return &cfg.Backend.ChangeStreams })},
+	{"read-timeout", "longest time to spend reading a request", setDuration(func(cfg *Config) *time.Duration { return &cfg.Timeouts.Read })},
+	{"write-timeout", "longest time to spend writing a response", setDuration(func(cfg *Config) *time.Duration { return &cfg.Timeouts.Write })},
+	{"idle-timeout", "longest time to keep an idle connection open", setDuration(func(cfg *Config) *time.Duration { return &cfg.Timeouts.Idle })},
+	{"shutdown-timeout", "longest time to wait for requests to finish when stopping", setDuration(func(cfg *Config) *time.Duration { return &cfg.Timeouts.Shutdown })},
+	{"cors-origins", "comma-separated origins allowed to call the API from a browser", setList(func(cfg *Config) *[]string { return &cfg.CORS.AllowedOrigins })},
+	{"cors-credentials", "let allowed origins send the session cookie: true or false", setBool(func(cfg *Config) *bool { return &cfg.CORS.AllowCredentials })},
+	{"cors-max-age", "how long browsers may cache preflight requests", setDuration(func(cfg *Config) *time.Duration { return &cfg.CORS.MaxAge })},
+	{"trusted-proxies", "comma-separated addresses or networks of reverse proxies whose X-Forwarded-For header is believed", setList(func(cfg *Config) *[]string { return &cfg.TrustedProxies })},
+	{"max-body-bytes", "largest request body to accept, in bytes", setInt(func(cfg *Config) *int { return &cfg.Limits.MaxBodyBytes })},
+	{"max-restore-bytes", "largest backup archive to accept when restoring over the API, in bytes", setInt(func(cfg *Config) *int { return &cfg.Limits.MaxRestoreBytes })},
+	{"read-rate-limit", "GET requests each client can make a minute (0 for no limit)", setInt(func(cfg *Config) *int { return &cfg.Limits.Reads.PerMinute })},
+	{"read-burst", "GET requests each client can make at once", setInt(func(cfg *Config) *int { return &cfg.Limits.Reads.Burst })},
+	{"write-rate-limit", "other requests each client can make a minute (0 for no limit)", setInt(func(cfg *Config) *int { return &cfg.Limits.Writes.PerMinute })},
+	{"write-burst", "other requests each client can make at once", setInt(func(cfg *Config) *int { return &cfg.Limits.Writes.Burst })},
+	{"graphql-depth", "how deeply fields can be nested in a GraphQL query", setInt(func(cfg *Config) *int { return &cfg.Limits.GraphQLDepth })},
+	{"graphql-complexity", "roughly how many fields a GraphQL query can ask for", setInt(func(cfg *Config) *int { return &cfg.Limits.GraphQLComplexity })},
+	{"webhook-timeout", "how long a webhook receiver has to answer each delivery", setDuration(func(cfg *Config) *time.Duration { return &cfg.Webhooks.Timeout })},
+	{"webhook-max-attempts", "how many times to try each webhook delivery before giving up", setInt(func(cfg *Config) *int { return &cfg.Webhooks.MaxAttempts })},
+	{"webhook-allowed-networks", "comma-separated private addresses or networks that webhooks may send to", setList(func(cfg *Config) *[]string { return &cfg.Webhooks.AllowedNetworks })},
+	{"log-level", "how much to log: debug, info, warn, or error", setString(func(cfg *Config) *string { return &cfg.LogLevel })},
+	{"slow-query-threshold", "log database operations slower than this (0 to turn off)", setDuration(func(cfg *Config) *time.Duration { return &cfg.SlowQueryThreshold })},
+	{"trash-retention", "how long deleted recipes stay in the trash before they are purged", setDuration(func(cfg *Config) *time.Duration { return &cfg.TrashRetention })},
+}
+
+// Load builds the server settings from (in increasing order of precedence) the
+// defaults, a YAML config file, environment variables, and command-line flags. The
+// config file is given with -config or RECIPES_CONFIG. The result is validated.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	// Read the command line first, to find the config file
+	flags := flag.NewFlagSet("recipes", flag.ContinueOnError)
+	configFile := flags.String("config", getenv(EnvPrefix+"CONFIG"), "YAML config file")
+	for _, s := range settings {
+		flags.String(s.name, "", s.usage+" (env "+envName(s.name)+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// Start from the defaults and apply the config file
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// Then the environment
+	for _, s := range settings {
+		if value := getenv(envName(s.name)); value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", envName(s.name), err)
+			}
+		}
+	}
+
+	// And finally any flags that were given
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.name == f.Name && err == nil {
+				if setErr := s.set(&cfg, f.Value.String()); setErr != nil {
+					err = fmt.Errorf("-%s: %w", s.name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile applies the settings in a YAML config file. Unknown settings are an error,
+// so that typos don't go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// envName returns the environment variable for the setting with the given flag name
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setString makes a setter for a string setting
+func setString(field func(cfg *Config) *string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+// setDuration makes a setter for a duration setting, e.g. "30s" or "720h"
+func setDuration(field func(cfg *Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = duration
+		return nil
+	}
+}
+
+// setInt makes a setter for a whole number setting
+func setInt(field func(cfg *Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+// setBool makes a setter for a true/false setting
+func setBool(field func(cfg *Config) *bool) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+// setList makes a setter for a comma-separated list setting
+func setList(field func(cfg *Config) *[]string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(cfg) = items
+		return nil
+	}
+}
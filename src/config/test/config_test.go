@@ -0,0 +1,207 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/config"
+)
+
+// env returns a getenv function that reads from the given map
+func env(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+// writeConfig writes a config file into a temporary directory and returns its path
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// TestLoadDefaults tests that Load uses the defaults when nothing is given
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, config.Default()) {
+		t.Errorf("Expected the defaults, got %+v", cfg)
+	}
+}
+
+// TestLoadPrecedence tests that flags beat the environment, which beats the file
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+listen: ":1000"
+log_level: warn
+backend:
+  database: from_file
+timeouts:
+  read: 5s
+cors:
+  allowed_origins: ["https://file.example.com"]
+`)
+	getenv := env(map[string]string{
+		"RECIPES_CONFIG":       path,
+		"RECIPES_LISTEN":       ":2000",
+		"RECIPES_LOG_LEVEL":    "error",
+		"RECIPES_CORS_ORIGINS": "https://a.example.com, https://b.example.com",
+	})
+	cfg, err := config.Load([]string{"-listen", ":3000"}, getenv)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Listen != ":3000" {
+		t.Errorf("Expected the flag to win, got %q", cfg.Listen)
+	}
+	if cfg.LogLevel != config.LogLevelError {
+		t.Errorf("Expected the environment to beat the file, got %q", cfg.LogLevel)
+	}
+	if cfg.Backend.Database != "from_file" || cfg.Timeouts.Read != 5*time.Second {
+		t.Errorf("Expected settings from the file, got %q and %v", cfg.Backend.Database, cfg.Timeouts.Read)
+	}
+	if cfg.Backend.Collection != "recipes" {
+		t.Errorf("Expected settings missing from the file to keep their defaults, got %q", cfg.Backend.Collection)
+	}
+	expected := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(cfg.CORS.AllowedOrigins, expected) {
+		t.Errorf("Expected origins %v, got %v", expected, cfg.CORS.AllowedOrigins)
+	}
+}
+
+// TestLoadErrors tests that Load reports bad settings clearly
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		file   string
+		errors []string
+	}{
+		{"unknown flag", []string{"-colour", "blue"}, "", []string{"colour"}},
+		{"bad duration", []string{"-read-timeout", "soon"}, "", []string{"-read-timeout"}},
+		{"unknown file setting", nil, "listne: \":80\"\n", []string{"listne"}},
+		{"missing file", []string{"-config", "/does/not/exist.yaml"}, "", []string{"reading config file"}},
+		{
+			"several problems",
+			[]string{"-backend", "sqlite", "-tls-cert", "cert.pem", "-log-level", "loud"},
+			"",
+			[]string{"backend.type", "tls", "log_level"},
+		},
+		{"bad origin", []string{"-cors-origins", "example.com"}, "", []string{"cors.allowed_origins"}},
+		{"origin with a path", []string{"-cors-origins", "https://example.com/app"}, "", []string{"cors.allowed_origins"}},
+		{"credentials for anyone", []string{"-cors-origins", "*", "-cors-credentials", "true"}, "", []string{"cors.allow_credentials"}},
+		{"bad proxy", []string{"-trusted-proxies", "10.0.0.1,proxy.local"}, "", []string{"trusted_proxies", "proxy.local"}},
+		{"bad webhook network", []string{"-webhook-allowed-networks", "10.0.0.0/33"}, "", []string{"webhooks.allowed_networks"}},
+		{"bad number", []string{"-read-burst", "lots"}, "", []string{"-read-burst"}},
+		{"no body size", []string{"-max-body-bytes", "0"}, "", []string{"limits.max_body_bytes"}},
+		{"no restore size", []string{"-max-restore-bytes", "-1"}, "", []string{"limits.max_restore_bytes"}},
+		{"no burst", []string{"-write-burst", "0"}, "", []string{"limits.writes.burst"}},
+		{"no GraphQL depth", []string{"-graphql-depth", "0"}, "", []string{"limits.graphql_depth"}},
+		{"negative rate", nil, "limits:\n  reads:\n    per_minute: -5\n", []string{"limits.reads.per_minute"}},
+		{"negative max age", []string{"-cors-max-age", "-1m"}, "", []string{"cors.max_age"}},
+		{"negative timeout", []string{"-idle-timeout", "-1s"}, "", []string{"timeouts.idle"}},
+		{"no shutdown timeout", []string{"-shutdown-timeout", "0s"}, "", []string{"timeouts.shutdown"}},
+		{"bad TLS version", []string{"-tls-self-signed", "true", "-tls-min-version", "1.0"}, "", []string{"tls.min_version"}},
+		{"bad boolean", []string{"-tls-self-signed", "maybe"}, "", []string{"-tls-self-signed"}},
+		{"client auth without a CA", []string{"-tls-self-signed", "true", "-tls-client-auth", "require"}, "", []string{"tls.client_ca_file"}},
+		{
+			"TLS settings without TLS",
+			[]string{"-tls-client-auth", "optional", "-tls-client-ca", "ca.pem", "-tls-redirect-http", ":80"},
+			"",
+			[]string{"tls.client_auth", "tls.redirect_http"},
+		},
+		{"redirect to itself", []string{"-tls-self-signed", "true", "-tls-redirect-http", ":8080"}, "", []string{"tls.redirect_http"}},
+		{"gRPC on the HTTP port", []string{"-grpc-listen", ":8080"}, "", []string{"grpc_listen"}},
+		{"no webhook attempts", nil, "webhooks:\n  timeout: 0s\n  max_attempts: 0\n", []string{"webhooks.timeout", "webhooks.max_attempts"}},
+	}
+	for _, test := range tests {
+		args := test.args
+		if test.file != "" {
+			args = append(args, "-config", writeConfig(t, test.file))
+		}
+		_, err := config.Load(args, env(nil))
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		for _, expected := range test.errors {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected the error to mention %q, got %v", test.name, expected, err)
+			}
+		}
+	}
+}
+
+// TestTLSEnabled tests the Config.TLSEnabled function
+func TestTLSEnabled(t *testing.T) {
+	cfg := config.Default()
+	if cfg.TLSEnabled() {
+		t.Errorf("Expected TLS to be off by default")
+	}
+	cfg.TLS = config.TLS{CertFile: "cert.pem", KeyFile: "key.pem"}
+	if !cfg.TLSEnabled() {
+		t.Errorf("Expected TLS to be on with a certificate and key")
+	}
+}
+
+// TestTLSFiles tests that self-signed certificates are kept in the default files unless
+// others are given
+func TestTLSFiles(t *testing.T) {
+	cfg, err := config.Load([]string{"-tls-self-signed", "true", "-tls-hosts", "kitchen.lan,10.0.0.5"}, env(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.TLSEnabled() {
+		t.Errorf("Expected TLS to be on with a self-signed certificate")
+	}
+	if certFile, keyFile := cfg.TLS.Files(); certFile != config.DevCertFile || keyFile != config.DevKeyFile {
+		t.Errorf("Expected the default files, got %q and %q", certFile, keyFile)
+	}
+	if !reflect.DeepEqual(cfg.TLS.Hosts, []string{"kitchen.lan", "10.0.0.5"}) {
+		t.Errorf("Expected the extra hosts, got %v", cfg.TLS.Hosts)
+	}
+
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = "cert.pem", "key.pem"
+	if certFile, keyFile := cfg.TLS.Files(); certFile != "cert.pem" || keyFile != "key.pem" {
+		t.Errorf("Expected the given files, got %q and %q", certFile, keyFile)
+	}
+}
+
+// TestCORSOrigins tests that origins for browser extensions and apps are allowed
+func TestCORSOrigins(t *testing.T) {
+	origins := "https://example.com,chrome-extension://abcdefghijklmnop,capacitor://localhost"
+	cfg, err := config.Load([]string{"-cors-origins", origins, "-cors-credentials", "true"}, env(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	expected := []string{"https://example.com", "chrome-extension://abcdefghijklmnop", "capacitor://localhost"}
+	if !reflect.DeepEqual(cfg.CORS.AllowedOrigins, expected) || !cfg.CORS.AllowCredentials {
+		t.Errorf("Expected origins %v with credentials, got %+v", expected, cfg.CORS)
+	}
+}
+
+// TestTrustedProxies tests that no proxies are trusted by default, and that addresses
+// and networks can be given
+func TestTrustedProxies(t *testing.T) {
+	if proxies := config.Default().TrustedProxies; len(proxies) != 0 {
+		t.Errorf("Expected no trusted proxies by default, got %v", proxies)
+	}
+	cfg, err := config.Load([]string{"-trusted-proxies", "10.0.0.1,192.168.0.0/16,::1"}, env(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	expected := []string{"10.0.0.1", "192.168.0.0/16", "::1"}
+	if !reflect.DeepEqual(cfg.TrustedProxies, expected) {
+		t.Errorf("Expected proxies %v, got %v", expected, cfg.TrustedProxies)
+	}
+}
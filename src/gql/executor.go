@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define an executor that runs GraphQL queries against a recipe manager
+
+// Request is a GraphQL query, as sent by a client
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Executor runs GraphQL queries for recipes, ingredients, tags, comments, and the cook
+// log. Queries see what the viewer in their context may see, the same as the recipe
+// manager. It is safe to use from several goroutines.
+type Executor struct {
+	schema         graphql.Schema
+	limits         Limits
+	recipe_manager recipes.RecipeManager
+}
+
+// NewExecutor makes an executor that resolves queries from the recipe manager and cook
+// log, and rejects queries that go over the limits
+func NewExecutor(recipe_manager recipes.RecipeManager, cook_log recipes.CookLog, limits Limits) (*Executor, error) {
+	schema, err := newSchema(recipe_manager, cook_log)
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{schema: schema, limits: limits, recipe_manager: recipe_manager}, nil
+}
+
+// Execute runs a query. Queries that can't be run at all (because they don't parse,
+// don't fit the schema, or go over the limits) return a result with errors but no data.
+// Otherwise the result has data, along with errors for any fields that failed.
+func (e *Executor) Execute(ctx context.Context, request Request) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&e.schema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	// Only check the limits once the query is known to be valid, so that they don't
+	// have to cope with fields that don't exist or fragments that loop
+	if err := checkLimits(e.schema, document, request.Variables, e.limits); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	// Each query gets its own loader, so recipes are never shared between viewers
+	ctx = context.WithValue(ctx, loaderKey{}, newRecipeLoader(ctx, e.recipe_manager))
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+}
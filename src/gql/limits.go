@@ -0,0 +1,184 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Define limits on how much work a single query can ask for
+
+// defaultListSize is how many items a list is assumed to have when working out the
+// complexity of a query, unless the query says how many it wants
+const defaultListSize = 10
+
+// Limits stop a single query from asking for more than the server is willing to do
+type Limits struct {
+	// MaxDepth is how deeply fields can be nested, e.g. { recipe { cookLog { date } } }
+	// has a depth of 3
+	MaxDepth int
+	// MaxComplexity is how many fields a query can ask for. The fields under a list are
+	// counted once for each item it is expected to have: as many as its "limit" or "ids"
+	// argument says, or defaultListSize otherwise.
+	MaxComplexity int
+}
+
+// checkLimits returns an error if any operation in the document is too deep or too
+// complex. Introspection fields (those starting with "__") count once each, however many
+// items they return, since that only depends on the size of the schema. The document
+// must already have been validated, so that its fields exist and its fragments don't
+// loop.
+func checkLimits(schema graphql.Schema, document *ast.Document, variables map[string]interface{}, limits Limits) error {
+	c := &costs{schema: schema, variables: variables, fragments: make(map[string]*ast.FragmentDefinition)}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			c.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity := c.selections(schema.QueryType(), operation.SelectionSet)
+		if depth > limits.MaxDepth {
+			return fmt.Errorf("query is nested %d levels deep, but at most %d are allowed", depth, limits.MaxDepth)
+		}
+		if complexity > limits.MaxComplexity {
+			return fmt.Errorf("query has a complexity of %d, but at most %d is allowed", complexity, limits.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+// costs works out the depth and complexity of the parts of a query
+type costs struct {
+	schema    graphql.Schema
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// selections returns the depth and complexity of a selection set on the given type,
+// including any fragments it uses
+func (c *costs) selections(parent *graphql.Object, set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, n int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, n = c.field(parent, selection)
+		case *ast.InlineFragment:
+			d, n = c.selections(c.fragmentType(parent, selection.TypeCondition), selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[selection.Name.Value]; ok {
+				d, n = c.selections(c.fragmentType(parent, fragment.TypeCondition), fragment.SelectionSet)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += n
+	}
+	return depth, complexity
+}
+
+// field returns the depth and complexity of a single field and everything under it
+func (c *costs) field(parent *graphql.Object, field *ast.Field) (depth, complexity int) {
+	// Introspection fields aren't in the schema's own types, so everything under them
+	// is counted once
+	if strings.HasPrefix(field.Name.Value, "__") || parent == nil {
+		depth, complexity = c.selections(nil, field.SelectionSet)
+		return depth + 1, complexity + 1
+	}
+	definition, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		return 1, 1
+	}
+
+	// Work out what type the field's own fields are on, and whether it is a list
+	fieldType := definition.Type
+	isList := false
+	for {
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+		} else if list, ok := fieldType.(*graphql.List); ok {
+			fieldType = list.OfType
+			isList = true
+		} else {
+			break
+		}
+	}
+	object, _ := fieldType.(*graphql.Object)
+
+	depth, complexity = c.selections(object, field.SelectionSet)
+	if isList {
+		complexity *= c.listSize(field)
+	}
+	return depth + 1, complexity + 1
+}
+
+// listSize guesses how many items a list field will return, from its arguments
+func (c *costs) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		value := c.resolve(argument.Value)
+		switch argument.Name.Value {
+		case "limit":
+			if n, ok := toInt(value); ok && n >= 0 {
+				return n
+			}
+		case "ids":
+			if items, ok := value.([]interface{}); ok {
+				return len(items)
+			}
+		}
+	}
+	return defaultListSize
+}
+
+// resolve returns the value of an argument, looking up variables
+func (c *costs) resolve(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.Variable:
+		return c.variables[value.Name.Value]
+	case *ast.IntValue:
+		return value.Value
+	case *ast.ListValue:
+		items := make([]interface{}, len(value.Values))
+		for i, item := range value.Values {
+			items[i] = c.resolve(item)
+		}
+		return items
+	}
+	return nil
+}
+
+// fragmentType returns the type a fragment applies to, or the parent type if it doesn't
+// say. Fragments on types that aren't objects aren't counted.
+func (c *costs) fragmentType(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	object, _ := c.schema.Type(condition.Name.Value).(*graphql.Object)
+	return object
+}
+
+// toInt converts an integer from a query (a string) or its variables (a JSON number)
+func toInt(value interface{}) (int, bool) {
+	switch value := value.(type) {
+	case string:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	case float64:
+		return int(value), value == float64(int(value))
+	case int:
+		return value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,88 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define a loader that batches lookups of recipes by ID within a query
+
+// recipeLoader collects the IDs of the recipes that a query needs and looks them all up
+// at once, the first time any of them is needed. The executor resolves every field at
+// one level of the query before the next, so a query for many recipes (e.g. each entry
+// in a cook log) makes one lookup per level instead of one per recipe. Each recipe is
+// only looked up once per query. A loader must only be used for a single query.
+type recipeLoader struct {
+	ctx            context.Context
+	recipe_manager recipes.RecipeManager
+
+	mu      sync.Mutex
+	pending []string
+	loaded  map[string]*loadedRecipe
+}
+
+// loadedRecipe is the result of looking up a recipe. Recipes that don't exist or can't
+// be seen are nil.
+type loadedRecipe struct {
+	recipe *recipes.Recipe
+	err    error
+}
+
+func newRecipeLoader(ctx context.Context, recipe_manager recipes.RecipeManager) *recipeLoader {
+	return &recipeLoader{ctx: ctx, recipe_manager: recipe_manager, loaded: make(map[string]*loadedRecipe)}
+}
+
+// Load queues up the recipe with the given ID to be looked up, and returns a thunk that
+// the executor calls once it needs the recipe
+func (l *recipeLoader) Load(id string) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.loaded[id]; !ok && !contains(l.pending, id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.loaded[id]; !ok {
+			l.dispatch()
+		}
+
+		// Recipes that weren't found come out as null
+		result := l.loaded[id]
+		if result.err != nil || result.recipe == nil {
+			return nil, result.err
+		}
+		return *result.recipe, nil
+	}
+}
+
+// dispatch looks up every pending recipe at once. It must be called with the lock held.
+func (l *recipeLoader) dispatch() {
+	ids := l.pending
+	l.pending = nil
+
+	// Every recipe that was asked for fails if the lookup does
+	found, err := l.recipe_manager.GetRecipesByIDs(l.ctx, ids)
+	for _, id := range ids {
+		l.loaded[id] = &loadedRecipe{err: err}
+	}
+	if err != nil {
+		return
+	}
+	for i := range found {
+		l.loaded[found[i].ID.Hex()] = &loadedRecipe{recipe: &found[i]}
+	}
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
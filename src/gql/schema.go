@@ -0,0 +1,263 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define the GraphQL schema for reading recipes, resolved by a recipe manager
+
+// resolvers holds what the schema's fields are resolved from
+type resolvers struct {
+	recipe_manager recipes.RecipeManager
+	cook_log       recipes.CookLog
+}
+
+// newSchema builds the schema. Every field is read-only; changes still go through the
+// REST API.
+func newSchema(recipe_manager recipes.RecipeManager, cook_log recipes.CookLog) (graphql.Schema, error) {
+	r := resolvers{recipe_manager: recipe_manager, cook_log: cook_log}
+
+	ingredientType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Ingredient",
+		Description: "An ingredient of a recipe, and how much of it to use",
+		Fields: graphql.Fields{
+			"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"quantity": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Comment",
+		Description: "A comment left on a recipe",
+		Fields: graphql.Fields{
+			"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveID(func(source interface{}) primitive.ObjectID { return source.(recipes.Comments).ID })},
+			"comment": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"author":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"date":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "CookStats",
+		Description: "A summary of the times a recipe was cooked",
+		Fields: graphql.Fields{
+			"timesCooked":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"averageRating": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"lastCooked":    &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	// Recipes and cook log entries refer to each other, so their fields are filled in
+	// once both exist
+	recipeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Recipe",
+		Description: "A recipe, with its ingredients, steps, tags, and comments",
+		Fields:      graphql.Fields{},
+	})
+	cookLogEntryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "CookLogEntry",
+		Description: "A time that a recipe was cooked",
+		Fields: graphql.Fields{
+			"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveID(func(source interface{}) primitive.ObjectID { return source.(recipes.CookLogEntry).ID })},
+			"date":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"rating": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"cook":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"notes":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"recipe": &graphql.Field{Type: recipeType, Description: "The recipe that was cooked", Resolve: r.entryRecipe},
+		},
+	})
+
+	recipeFields := graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveID(func(source interface{}) primitive.ObjectID { return source.(recipes.Recipe).ID })},
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"ingredients": &graphql.Field{Type: nonNullList(ingredientType)},
+		"steps":       &graphql.Field{Type: nonNullList(graphql.String)},
+		"tags":        &graphql.Field{Type: nonNullList(graphql.String)},
+		"comments":    &graphql.Field{Type: nonNullList(commentType)},
+		"revision":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"createdBy":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"updatedAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"updatedBy":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"owner":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "The ID of the user that owns the recipe"},
+		"visibility":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "Who can see the recipe: private, household, or public"},
+		"stats":       &graphql.Field{Type: graphql.NewNonNull(statsType)},
+		"cookLog": &graphql.Field{
+			Type:        nonNullList(cookLogEntryType),
+			Description: "The times the recipe was cooked, newest first",
+			Args: graphql.FieldConfigArgument{
+				"limit": &graphql.ArgumentConfig{Type: graphql.Int, Description: "The most entries to return"},
+			},
+			Resolve: r.cookLog,
+		},
+	}
+	for name, field := range recipeFields {
+		recipeType.AddFieldConfig(name, field)
+	}
+
+	sortKeyType := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "SortKey",
+		Description: "What to order recipes by",
+		Values: graphql.EnumValueConfigMap{
+			"NAME":         &graphql.EnumValueConfig{Value: recipes.SortByName},
+			"CREATED":      &graphql.EnumValueConfig{Value: recipes.SortByCreated},
+			"UPDATED":      &graphql.EnumValueConfig{Value: recipes.SortByUpdated},
+			"RATING":       &graphql.EnumValueConfig{Value: recipes.SortByRating},
+			"TIMES_COOKED": &graphql.EnumValueConfig{Value: recipes.SortByTimesCooked},
+			"LAST_COOKED":  &graphql.EnumValueConfig{Value: recipes.SortByLastCooked},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"recipe": &graphql.Field{
+				Type:        recipeType,
+				Description: "The recipe with the given ID, or null if there is no such recipe you can see",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.recipe,
+			},
+			"recipes": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(recipeType)),
+				Description: "The recipes with the given IDs, in the same order, with null for any you can't see",
+				Args: graphql.FieldConfigArgument{
+					"ids": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))},
+				},
+				Resolve: r.recipes,
+			},
+			"search": &graphql.Field{
+				Type:        nonNullList(recipeType),
+				Description: "The recipes that match all of the given filters, in the requested order",
+				Args: graphql.FieldConfigArgument{
+					"query":         &graphql.ArgumentConfig{Type: graphql.String, Description: "Text to find in the name, description, or comments"},
+					"tags":          &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Tags the recipes must all have"},
+					"createdAfter":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"createdBefore": &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"updatedAfter":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"updatedBefore": &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"sortBy":        &graphql.ArgumentConfig{Type: sortKeyType},
+					"descending":    &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"limit":         &graphql.ArgumentConfig{Type: graphql.Int, Description: "The most recipes to return"},
+				},
+				Resolve: r.search,
+			},
+			"tags": &graphql.Field{
+				Type:        nonNullList(graphql.String),
+				Description: "Every tag on a recipe you can see",
+				Resolve:     r.tags,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// nonNullList is the type of a list that is never null and never contains null
+func nonNullList(of graphql.Type) graphql.Type {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(of)))
+}
+
+// resolveID resolves an ID field as a hex string, rather than the ObjectID's Go syntax
+func resolveID(get func(source interface{}) primitive.ObjectID) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source).Hex(), nil
+	}
+}
+
+// loaderKey is the context key for the recipe loader of the query being executed
+type loaderKey struct{}
+
+// loader returns the recipe loader for the query being executed
+func loader(ctx context.Context) *recipeLoader {
+	return ctx.Value(loaderKey{}).(*recipeLoader)
+}
+
+func (r resolvers) recipe(p graphql.ResolveParams) (interface{}, error) {
+	return loader(p.Context).Load(p.Args["id"].(string)), nil
+}
+
+func (r resolvers) recipes(p graphql.ResolveParams) (interface{}, error) {
+	// Each recipe is a thunk, so they are all looked up together
+	ids := p.Args["ids"].([]interface{})
+	thunks := make([]interface{}, len(ids))
+	for i, id := range ids {
+		thunks[i] = loader(p.Context).Load(id.(string))
+	}
+	return thunks, nil
+}
+
+func (r resolvers) search(p graphql.ResolveParams) (interface{}, error) {
+	opts := recipes.ListOptions{Descending: p.Args["descending"].(bool)}
+	if query, ok := p.Args["query"].(string); ok {
+		opts.Query = query
+	}
+	if tags, ok := p.Args["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			opts.Tags = append(opts.Tags, tag.(string))
+		}
+	}
+	dates := map[string]*time.Time{
+		"createdAfter":  &opts.CreatedAfter,
+		"createdBefore": &opts.CreatedBefore,
+		"updatedAfter":  &opts.UpdatedAfter,
+		"updatedBefore": &opts.UpdatedBefore,
+	}
+	for arg, date := range dates {
+		if value, ok := p.Args[arg].(time.Time); ok {
+			*date = value
+		}
+	}
+	if sortBy, ok := p.Args["sortBy"].(string); ok {
+		opts.SortBy = sortBy
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	found, err := r.recipe_manager.ListRecipes(p.Context, opts)
+	if err != nil {
+		return nil, err
+	}
+	return limit(found, p.Args)
+}
+
+func (r resolvers) tags(p graphql.ResolveParams) (interface{}, error) {
+	return r.recipe_manager.GetTags(p.Context)
+}
+
+func (r resolvers) cookLog(p graphql.ResolveParams) (interface{}, error) {
+	entries, err := r.cook_log.GetCookLog(p.Context, p.Source.(recipes.Recipe).ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	return limit(entries, p.Args)
+}
+
+func (r resolvers) entryRecipe(p graphql.ResolveParams) (interface{}, error) {
+	return loader(p.Context).Load(p.Source.(recipes.CookLogEntry).RecipeID.Hex()), nil
+}
+
+// limit cuts a list down to the number of items given by the "limit" argument, if any
+func limit[T any](items []T, args map[string]interface{}) ([]T, error) {
+	n, ok := args["limit"].(int)
+	if !ok {
+		return items, nil
+	}
+	if n < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if n < len(items) {
+		items = items[:n]
+	}
+	return items, nil
+}
@@ -0,0 +1,292 @@
+package gql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/gql"
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// fakeRecipeManager keeps recipes in a slice, implementing just the methods the schema
+// uses, and records how it was called
+type fakeRecipeManager struct {
+	recipes.RecipeManager
+	recipes.CookLog
+	recipes  []recipes.Recipe
+	cookLog  map[string][]recipes.CookLogEntry
+	lookups  [][]string
+	listOpts recipes.ListOptions
+	viewer   recipes.Viewer
+	err      error
+}
+
+func (m *fakeRecipeManager) GetRecipesByIDs(ctx context.Context, ids []string) ([]recipes.Recipe, error) {
+	m.lookups = append(m.lookups, ids)
+	found := make([]recipes.Recipe, 0)
+	for _, recipe := range m.recipes {
+		for _, id := range ids {
+			if recipe.ID.Hex() == id {
+				found = append(found, recipe)
+			}
+		}
+	}
+	return found, m.err
+}
+
+func (m *fakeRecipeManager) ListRecipes(ctx context.Context, opts recipes.ListOptions) ([]recipes.Recipe, error) {
+	m.listOpts = opts
+	m.viewer, _ = recipes.ViewerFromContext(ctx)
+	return m.recipes, m.err
+}
+
+func (m *fakeRecipeManager) GetTags(ctx context.Context) ([]string, error) {
+	return []string{"dinner", "soup"}, m.err
+}
+
+func (m *fakeRecipeManager) GetCookLog(ctx context.Context, recipeID string) ([]recipes.CookLogEntry, error) {
+	return m.cookLog[recipeID], m.err
+}
+
+var (
+	soupID  = primitive.NewObjectID()
+	bread   = primitive.NewObjectID()
+	missing = primitive.NewObjectID()
+	cooked  = time.Date(2023, 5, 6, 18, 30, 0, 0, time.UTC)
+)
+
+// newExecutor returns an executor for two recipes, the soup having been cooked twice
+// and the bread once
+func newExecutor(t *testing.T, limits gql.Limits) (*gql.Executor, *fakeRecipeManager) {
+	manager := &fakeRecipeManager{
+		recipes: []recipes.Recipe{
+			{
+				ID:          soupID,
+				Name:        "Soup",
+				Ingredients: []recipes.Ingredient{{Name: "Tomato", Quantity: "4"}},
+				Tags:        []string{"dinner", "soup"},
+				Comments:    []recipes.Comments{{ID: primitive.NewObjectID(), Comment: "Lovely", Author: "alice", Date: cooked}},
+				Stats:       recipes.CookStats{TimesCooked: 2, AverageRating: 4.5, LastCooked: &cooked},
+			},
+			{ID: bread, Name: "Bread"},
+		},
+		cookLog: map[string][]recipes.CookLogEntry{
+			soupID.Hex(): {
+				{ID: primitive.NewObjectID(), RecipeID: soupID, Rating: 5, Date: cooked},
+				{ID: primitive.NewObjectID(), RecipeID: soupID, Rating: 4, Date: cooked.Add(-time.Hour)},
+			},
+			bread.Hex(): {{ID: primitive.NewObjectID(), RecipeID: bread, Rating: 3, Date: cooked}},
+		},
+	}
+	if limits == (gql.Limits{}) {
+		limits = gql.Limits{MaxDepth: 8, MaxComplexity: 1000}
+	}
+
+	executor, err := gql.NewExecutor(manager, manager, limits)
+	if err != nil {
+		t.Fatalf("Failed to create executor: %v", err)
+	}
+	return executor, manager
+}
+
+// expectData checks that a query succeeded with the expected data, given as JSON
+func expectData(t *testing.T, result *graphql.Result, expected string) {
+	t.Helper()
+	if result.HasErrors() {
+		t.Fatalf("Expected no errors, got %v", result.Errors)
+	}
+	actualJSON, _ := json.Marshal(result.Data)
+	var actual, want interface{}
+	json.Unmarshal(actualJSON, &actual)
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("Bad expected JSON: %v", err)
+	}
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("Expected %s, got %s", expected, actualJSON)
+	}
+}
+
+// expectRejected checks that a query couldn't be run, with an error containing the
+// given text
+func expectRejected(t *testing.T, result *graphql.Result, text string) {
+	t.Helper()
+	if result.Data != nil || !result.HasErrors() {
+		t.Fatalf("Expected the query to be rejected, got %v", result)
+	}
+	if !strings.Contains(result.Errors[0].Message, text) {
+		t.Errorf("Expected an error about %q, got %q", text, result.Errors[0].Message)
+	}
+}
+
+// TestRecipe tests that a recipe's fields can be queried
+func TestRecipe(t *testing.T) {
+	executor, manager := newExecutor(t, gql.Limits{})
+
+	result := executor.Execute(context.Background(), gql.Request{
+		Query: `query($id: ID!) {
+			recipe(id: $id) {
+				id name ingredients { name quantity } tags steps
+				comments { comment author date }
+				stats { timesCooked averageRating lastCooked }
+				cookLog(limit: 1) { rating date }
+			}
+		}`,
+		Variables: map[string]interface{}{"id": soupID.Hex()},
+	})
+	expectData(t, result, `{"recipe": {
+		"id": "`+soupID.Hex()+`", "name": "Soup",
+		"ingredients": [{"name": "Tomato", "quantity": "4"}], "tags": ["dinner", "soup"], "steps": [],
+		"comments": [{"comment": "Lovely", "author": "alice", "date": "2023-05-06T18:30:00Z"}],
+		"stats": {"timesCooked": 2, "averageRating": 4.5, "lastCooked": "2023-05-06T18:30:00Z"},
+		"cookLog": [{"rating": 5, "date": "2023-05-06T18:30:00Z"}]
+	}}`)
+
+	// Recipes that can't be found are null
+	result = executor.Execute(context.Background(), gql.Request{Query: `{ recipe(id: "` + missing.Hex() + `") { name } }`})
+	expectData(t, result, `{"recipe": null}`)
+
+	// The bread has never been cooked
+	result = executor.Execute(context.Background(), gql.Request{Query: `{ recipe(id: "` + bread.Hex() + `") { stats { lastCooked } } }`})
+	expectData(t, result, `{"recipe": {"stats": {"lastCooked": null}}}`)
+
+	if len(manager.lookups) != 3 {
+		t.Errorf("Expected one lookup per query, got %v", manager.lookups)
+	}
+}
+
+// TestBatching tests that recipes needed at the same level of a query are looked up
+// together, and only once each
+func TestBatching(t *testing.T) {
+	executor, manager := newExecutor(t, gql.Limits{})
+
+	result := executor.Execute(context.Background(), gql.Request{Query: `{
+		first: recipe(id: "` + soupID.Hex() + `") { name }
+		again: recipe(id: "` + soupID.Hex() + `") { name }
+		recipes(ids: ["` + bread.Hex() + `", "` + missing.Hex() + `"]) { name }
+	}`})
+	expectData(t, result, `{"first": {"name": "Soup"}, "again": {"name": "Soup"}, "recipes": [{"name": "Bread"}, null]}`)
+	if len(manager.lookups) != 1 || len(manager.lookups[0]) != 3 {
+		t.Errorf("Expected a single lookup of 3 recipes, got %v", manager.lookups)
+	}
+
+	// Each entry in each cook log refers back to its recipe
+	manager.lookups = nil
+	result = executor.Execute(context.Background(), gql.Request{Query: `{
+		search { cookLog { rating recipe { name } } }
+	}`})
+	expectData(t, result, `{"search": [
+		{"cookLog": [{"rating": 5, "recipe": {"name": "Soup"}}, {"rating": 4, "recipe": {"name": "Soup"}}]},
+		{"cookLog": [{"rating": 3, "recipe": {"name": "Bread"}}]}
+	]}`)
+	if len(manager.lookups) != 1 || len(manager.lookups[0]) != 2 {
+		t.Errorf("Expected a single lookup of 2 recipes, got %v", manager.lookups)
+	}
+
+	// Lookups that fail are reported on each field that needed them
+	manager.err = errors.New("database is down")
+	result = executor.Execute(context.Background(), gql.Request{Query: `{
+		a: recipe(id: "` + soupID.Hex() + `") { name }
+		b: recipe(id: "` + bread.Hex() + `") { name }
+	}`})
+	if len(result.Errors) != 2 || !strings.Contains(result.Errors[0].Message, "database is down") {
+		t.Errorf("Expected an error for each recipe, got %v", result.Errors)
+	}
+}
+
+// TestSearch tests that search arguments are passed on to the recipe manager
+func TestSearch(t *testing.T) {
+	executor, manager := newExecutor(t, gql.Limits{})
+
+	viewer := recipes.Viewer{UserID: "alice"}
+	ctx := recipes.WithViewer(context.Background(), viewer)
+	result := executor.Execute(ctx, gql.Request{Query: `{
+		search(query: "tomato", tags: ["dinner"], createdAfter: "2023-01-01T00:00:00Z",
+			sortBy: TIMES_COOKED, descending: true, limit: 1) { name }
+		tags
+	}`})
+	expectData(t, result, `{"search": [{"name": "Soup"}], "tags": ["dinner", "soup"]}`)
+
+	expected := recipes.ListOptions{
+		Query:        "tomato",
+		Tags:         []string{"dinner"},
+		CreatedAfter: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		SortBy:       recipes.SortByTimesCooked,
+		Descending:   true,
+	}
+	if !reflect.DeepEqual(manager.listOpts, expected) {
+		t.Errorf("Expected options %+v, got %+v", expected, manager.listOpts)
+	}
+	if manager.viewer != viewer {
+		t.Errorf("Expected the search to be made as %v, got %v", viewer, manager.viewer)
+	}
+
+	// Arguments that don't fit the schema are rejected before anything is looked up
+	expectRejected(t, executor.Execute(ctx, gql.Request{Query: `{ search(sortBy: COLOUR) { name } }`}), "COLOUR")
+	expectRejected(t, executor.Execute(ctx, gql.Request{Query: `{ search { calories } }`}), "calories")
+	expectRejected(t, executor.Execute(ctx, gql.Request{Query: `{ search { name }`}), "Syntax Error")
+
+	// Negative limits fail the field
+	result = executor.Execute(ctx, gql.Request{Query: `{ tags search(limit: -1) { name } }`})
+	if !result.HasErrors() || !strings.Contains(result.Errors[0].Message, "limit") {
+		t.Errorf("Expected an error about the limit, got %v", result.Errors)
+	}
+}
+
+// TestLimits tests that queries that are too deep or complex are rejected
+func TestLimits(t *testing.T) {
+	executor, manager := newExecutor(t, gql.Limits{MaxDepth: 4, MaxComplexity: 50})
+
+	// A depth of 4 is fine, but 5 isn't
+	query := `{ search(limit: 1) { cookLog(limit: 1) { recipe { name } } } }`
+	result := executor.Execute(context.Background(), gql.Request{Query: query})
+	if result.HasErrors() {
+		t.Errorf("Expected a depth of 4 to be allowed, got %v", result.Errors)
+	}
+	query = `{ search(limit: 1) { cookLog(limit: 1) { recipe { stats { timesCooked } } } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query}), "5 levels deep")
+
+	// Fragments count too
+	query = `{ search(limit: 1) { ...log } }
+		fragment log on Recipe { cookLog(limit: 1) { recipe { stats { timesCooked } } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query}), "5 levels deep")
+
+	// Lists are assumed to have 10 items unless the query asks for fewer, so this costs
+	// 1 + 10 * (1 + 10 * 1) = 111
+	query = `{ search { cookLog { rating } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query}), "complexity of 111")
+	query = `query($n: Int) { search(limit: $n) { cookLog(limit: 2) { rating } } }`
+	result = executor.Execute(context.Background(), gql.Request{Query: query, Variables: map[string]interface{}{"n": float64(3)}})
+	if result.HasErrors() {
+		t.Errorf("Expected a complexity of 1 + 3 * (1 + 2 * 1) = 10 to be allowed, got %v", result.Errors)
+	}
+
+	// Every operation in the document is checked
+	query = `query small { tags } query big { search { cookLog { rating } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query, OperationName: "small"}), "complexity")
+
+	// Introspection counts too, though its lists only count once
+	query = `{ __schema { types { name fields { name } } } }`
+	if result := executor.Execute(context.Background(), gql.Request{Query: query}); result.HasErrors() {
+		t.Errorf("Expected shallow introspection to be allowed, got %v", result.Errors)
+	}
+	query = `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query}), "7 levels deep")
+	query = `{ __type(name: "Recipe") { fields { type { ...ref } } } }
+		fragment ref on __Type { ofType { ofType { name } } }`
+	expectRejected(t, executor.Execute(context.Background(), gql.Request{Query: query}), "6 levels deep")
+
+	// Nothing is looked up for queries that are rejected
+	manager.lookups = nil
+	executor.Execute(context.Background(), gql.Request{Query: `{ recipe(id: "` + soupID.Hex() + `") { cookLog { recipe { cookLog { rating } } } } }`})
+	if len(manager.lookups) != 0 {
+		t.Errorf("Expected no lookups for a rejected query, got %v", manager.lookups)
+	}
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define helpers for structured logging that follows each request through the server
+
+// ParseLevel converts a level name (debug, info, warn, or error) to a slog level
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	return level, nil
+}
+
+// New creates a logger that writes JSON lines at or above the given level. Records
+// logged with a context that has a request ID (see WithRequestID) include it.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler adds the request ID from the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context that records the ID of the request
+// being handled
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request being handled, or "" if none was
+// recorded
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDPattern matches request IDs that are safe to accept from clients and echo
+// back in logs and headers
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ValidRequestID returns true if a request ID sent by a client can be used as is
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// Request IDs only need to be unique enough to find requests in the logs
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// DurationMS returns an attribute giving a duration in milliseconds, which is easier to
+// read and query in JSON logs than nanoseconds
+func DurationMS(duration time.Duration) slog.Attr {
+	return slog.Float64("duration_ms", float64(duration.Microseconds())/1000)
+}
+
+// SlowQueryObserver logs every recipe manager operation at debug level, and those that
+// take longer than the threshold (if it isn't zero) as warnings. Use it with
+// recipes.Instrument.
+func SlowQueryObserver(logger *slog.Logger, threshold time.Duration) recipes.Observer {
+	return func(ctx context.Context, operation string, duration time.Duration, err error) {
+		level, message := slog.LevelDebug, "backend operation"
+		if threshold > 0 && duration >= threshold {
+			level, message = slog.LevelWarn, "slow backend operation"
+		}
+		attrs := []any{
+			slog.String("operation", operation),
+			DurationMS(duration),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.Log(ctx, level, message, attrs...)
+	}
+}
@@ -0,0 +1,111 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/logging"
+)
+
+// decodeLines parses each JSON line written by a logger
+func decodeLines(t *testing.T, output *bytes.Buffer) []map[string]any {
+	lines := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		lines = append(lines, record)
+	}
+	return lines
+}
+
+// TestParseLevel tests the ParseLevel function
+func TestParseLevel(t *testing.T) {
+	expected := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for name, level := range expected {
+		parsed, err := logging.ParseLevel(name)
+		if err != nil || parsed != level {
+			t.Errorf("Expected %q to parse as %v, got %v, %v", name, level, parsed, err)
+		}
+	}
+	if _, err := logging.ParseLevel("loud"); err == nil {
+		t.Errorf("Expected %q to be invalid", "loud")
+	}
+}
+
+// TestRequestIDs tests the NewRequestID and ValidRequestID functions
+func TestRequestIDs(t *testing.T) {
+	first, second := logging.NewRequestID(), logging.NewRequestID()
+	if first == second || !logging.ValidRequestID(first) {
+		t.Errorf("Expected unique, valid request IDs, got %q and %q", first, second)
+	}
+
+	for _, id := range []string{"abc-123", "req.1:2_3"} {
+		if !logging.ValidRequestID(id) {
+			t.Errorf("Expected request ID %q to be valid", id)
+		}
+	}
+	for _, id := range []string{"", "has space", "new\nline", strings.Repeat("a", 129)} {
+		if logging.ValidRequestID(id) {
+			t.Errorf("Expected request ID %q to be invalid", id)
+		}
+	}
+}
+
+// TestRequestIDInLogs tests that records logged with a request context include its ID
+func TestRequestIDInLogs(t *testing.T) {
+	var output bytes.Buffer
+	logger := logging.New(&output, slog.LevelInfo).With("component", "test")
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "with ID")
+	logger.Info("without ID")
+	logger.Debug("too quiet")
+
+	lines := decodeLines(t, &output)
+	if len(lines) != 2 {
+		t.Fatalf("Expected two lines, got %v", lines)
+	}
+	if lines[0]["request_id"] != "req-1" || lines[0]["component"] != "test" {
+		t.Errorf("Expected the request ID and logger attributes, got %v", lines[0])
+	}
+	if _, ok := lines[1]["request_id"]; ok {
+		t.Errorf("Expected no request ID, got %v", lines[1])
+	}
+}
+
+// TestSlowQueryObserver tests that slow operations are logged as warnings
+func TestSlowQueryObserver(t *testing.T) {
+	var output bytes.Buffer
+	observe := logging.SlowQueryObserver(logging.New(&output, slog.LevelInfo), 100*time.Millisecond)
+
+	ctx := logging.WithRequestID(context.Background(), "req-2")
+	observe(ctx, "GetRecipeByID", time.Millisecond, nil)
+	observe(ctx, "ListRecipes", 250*time.Millisecond, errors.New("timed out"))
+
+	// Only the slow operation is logged at info level
+	lines := decodeLines(t, &output)
+	if len(lines) != 1 {
+		t.Fatalf("Expected one line, got %v", lines)
+	}
+	line := lines[0]
+	if line["level"] != "WARN" || line["operation"] != "ListRecipes" || line["duration_ms"] != 250.0 ||
+		line["error"] != "timed out" || line["request_id"] != "req-2" {
+		t.Errorf("Expected a slow query warning, got %v", line)
+	}
+}
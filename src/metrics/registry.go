@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Define a minimal set of metrics that can be exposed in the Prometheus text format
+// (see https://prometheus.io/docs/instrumenting/exposition_formats/)
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds, used for latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is anything that can write itself in the text format
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Registry holds a set of metrics and writes them out together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric to the registry
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the registry in the Prometheus text format, in the
+// order they were registered
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterVec is a set of counters, one for each combination of label values
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+// NewCounterVec registers a new set of counters with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds the given amount to the counter with the given label values
+func (c *CounterVec) Add(amount float64, values ...string) {
+	key := labelString(c.labels, values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += amount
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatFloat(c.values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a set of histograms, one for each combination of label values
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	histograms map[string]*histogram
+}
+
+// histogram counts the observations that fall into each bucket
+type histogram struct {
+	// counts[i] is the number of observations no bigger than buckets[i]
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a new set of histograms with the given bucket upper bounds
+// (in increasing order) and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name: name, help: help, labels: labels, buckets: buckets,
+		histograms: map[string]*histogram{},
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records a value in the histogram with the given label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := labelString(h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.histograms[key]
+		for i, bound := range h.buckets {
+			le := withLabel(key, "le", formatFloat(bound))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, le, hist.counts[i]); err != nil {
+				return err
+			}
+		}
+		le := withLabel(key, "le", "+Inf")
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, le, hist.count,
+			h.name, key, formatFloat(hist.sum),
+			h.name, key, hist.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc is a gauge whose value is worked out each time the metrics are written
+type GaugeFunc struct {
+	name, help string
+	value      func() (float64, error)
+}
+
+// NewGaugeFunc registers a gauge that calls the given function for its value. If the
+// function fails, the gauge is left out.
+func (r *Registry) NewGaugeFunc(name, help string, value func() (float64, error)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	value, err := g.value()
+	if err != nil {
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n",
+		g.name, g.help, g.name, g.name, formatFloat(value))
+	return err
+}
+
+// labelString renders label names and values as {name="value",...}, or "" if there are
+// no labels. Missing values are left empty.
+func labelString(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + `="` + escapeLabel(value) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel adds one more label to a rendered label string
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+// escapeLabel escapes a label value as the text format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat renders a number the way Prometheus expects
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the map in order, so output is stable
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
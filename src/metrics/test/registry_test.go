@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dawsonc/recipes/src/metrics"
+)
+
+// TestRegistry tests that each kind of metric is written in the Prometheus text format
+func TestRegistry(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.NewCounterVec("requests_total", "Requests handled.", "route", "status")
+	durations := registry.NewHistogramVec("duration_seconds", "Time taken.", []float64{0.1, 1}, "route")
+	registry.NewGaugeFunc("recipes", "Number of recipes.", func() (float64, error) { return 42, nil })
+	registry.NewGaugeFunc("broken", "Always fails.", func() (float64, error) { return 0, errors.New("down") })
+
+	requests.Inc("/api/recipes", "200")
+	requests.Inc("/api/recipes", "200")
+	requests.Inc(`/odd"route`, "500")
+	durations.Observe(0.05, "/api/recipes")
+	durations.Observe(0.5, "/api/recipes")
+	durations.Observe(5, "/api/recipes")
+
+	var output strings.Builder
+	if err := registry.Write(&output); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	expected := `# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{route="/api/recipes",status="200"} 2
+requests_total{route="/odd\"route",status="500"} 1
+# HELP duration_seconds Time taken.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/api/recipes",le="0.1"} 1
+duration_seconds_bucket{route="/api/recipes",le="1"} 2
+duration_seconds_bucket{route="/api/recipes",le="+Inf"} 3
+duration_seconds_sum{route="/api/recipes"} 5.55
+duration_seconds_count{route="/api/recipes"} 3
+# HELP recipes Number of recipes.
+# TYPE recipes gauge
+recipes 42
+`
+	if output.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, output.String())
+	}
+}
+
+// TestCounterWithoutLabels tests counters that have no labels
+func TestCounterWithoutLabels(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("starts_total", "Times started.")
+	counter.Add(3)
+
+	var output strings.Builder
+	if err := registry.Write(&output); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	if !strings.Contains(output.String(), "\nstarts_total 3\n") {
+		t.Errorf("Expected an unlabelled counter, got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Define a token bucket rate limiter that keeps a separate budget for each client
+
+// sweepInterval is how often buckets that have refilled are forgotten
+const sweepInterval = time.Minute
+
+// Limiter lets each key make requests at an average rate, with short bursts above it.
+// Each key has a bucket of up to burst tokens that refills at the average rate, and
+// each request takes one token. It is safe to use from several goroutines.
+type Limiter struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is how many tokens a key had at a moment in time
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter makes a limiter allowing perMinute requests a minute for each key, in
+// bursts of up to burst requests. A perMinute of zero or less allows everything.
+func NewLimiter(perMinute, burst int) *Limiter {
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token for the key if it has one. If it doesn't, Allow returns false
+// along with how long until it will.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	// New keys start with a full bucket
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.refill(now, l.rate, l.burst)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Len returns the number of keys the limiter is keeping track of
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func (b *bucket) refill(now time.Time, rate, burst float64) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+		b.updated = now
+	}
+}
+
+// sweep forgets the buckets that have refilled, since they are no different from new
+// ones, so that clients that have gone away don't use up memory
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now, l.rate, l.burst)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/ratelimit"
+)
+
+// TestLimiterBurst tests that a client can make a burst of requests, then has to wait
+func TestLimiterBurst(t *testing.T) {
+	limiter := ratelimit.NewLimiter(60, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("alice", now); !allowed {
+			t.Fatalf("Expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	allowed, wait := limiter.Allow("alice", now)
+	if allowed {
+		t.Fatalf("Expected the request after the burst to be limited")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("Expected to wait up to a second at 60 a minute, got %v", wait)
+	}
+
+	// Other clients have their own budget
+	if allowed, _ := limiter.Allow("bob", now); !allowed {
+		t.Errorf("Expected another client to be allowed")
+	}
+
+	// Waiting as long as we were told is enough for one more request, but not two
+	now = now.Add(wait)
+	if allowed, _ := limiter.Allow("alice", now); !allowed {
+		t.Errorf("Expected a request to be allowed after waiting")
+	}
+	if allowed, _ := limiter.Allow("alice", now); allowed {
+		t.Errorf("Expected only one request to be allowed after waiting")
+	}
+}
+
+// TestLimiterRefill tests that budgets refill at the average rate, up to the burst
+func TestLimiterRefill(t *testing.T) {
+	limiter := ratelimit.NewLimiter(120, 5)
+	now := time.Now()
+
+	// Use up the burst, then wait long enough for far more than the burst to refill
+	for i := 0; i < 5; i++ {
+		limiter.Allow("alice", now)
+	}
+	now = now.Add(time.Minute)
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("alice", now); ok {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("Expected the budget to refill to the burst of 5, got %d", allowed)
+	}
+
+	// Over a minute, the average rate is allowed
+	allowed = 0
+	for i := 0; i < 120; i++ {
+		now = now.Add(500 * time.Millisecond)
+		if ok, _ := limiter.Allow("alice", now); ok {
+			allowed++
+		}
+	}
+	if allowed != 120 {
+		t.Errorf("Expected 120 requests a minute to be allowed, got %d", allowed)
+	}
+}
+
+// TestLimiterUnlimited tests that a rate of zero turns the limit off
+func TestLimiterUnlimited(t *testing.T) {
+	limiter := ratelimit.NewLimiter(0, 0)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		if allowed, _ := limiter.Allow("alice", now); !allowed {
+			t.Fatalf("Expected every request to be allowed")
+		}
+	}
+}
+
+// TestLimiterForgetsIdleClients tests that clients whose budget has refilled are forgotten
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	limiter := ratelimit.NewLimiter(60, 10)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.Allow(fmt.Sprintf("client-%d", i), now)
+	}
+	if limiter.Len() != 100 {
+		t.Fatalf("Expected 100 clients, got %d", limiter.Len())
+	}
+
+	// After long enough for every budget to refill, only the newest client is kept
+	limiter.Allow("newcomer", now.Add(time.Hour))
+	if limiter.Len() != 1 {
+		t.Errorf("Expected idle clients to be forgotten, got %d", limiter.Len())
+	}
+}
+
+// TestLimiterConcurrent tests that the limiter never allows more than the burst at once
+func TestLimiterConcurrent(t *testing.T) {
+	limiter := ratelimit.NewLimiter(1, 50)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := limiter.Allow("alice", now); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 50 {
+		t.Errorf("Expected exactly the burst of 50 to be allowed, got %d", allowed)
+	}
+}
@@ -0,0 +1,18 @@
+package recipes
+
+import "context"
+
+// Define helpers for recording who is acting on the recipe manager
+
+type actorKey struct{}
+
+// WithActor returns a copy of the context that records the name of the acting user
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the name of the acting user, or "" if none was recorded
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,59 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Define structs for keeping a log of each time a recipe is cooked
+
+// CookLogEntry records a single time that a recipe was cooked
+type CookLogEntry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	RecipeID primitive.ObjectID `bson:"recipe_id"`
+	Date     time.Time          `bson:"date"`
+	Rating   int                `bson:"rating"`
+	Cook     string             `bson:"cook"`
+	Notes    string             `bson:"notes"`
+}
+
+// CookStats summarizes the cook log of a recipe
+type CookStats struct {
+	TimesCooked   int        `bson:"times_cooked"`
+	AverageRating float64    `bson:"average_rating"`
+	LastCooked    *time.Time `bson:"last_cooked,omitempty"`
+}
+
+// Range of allowed ratings
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Validate checks that a cook log entry can be saved
+func (entry CookLogEntry) Validate() error {
+	if entry.Rating < MinRating || entry.Rating > MaxRating {
+		return fmt.Errorf("invalid rating %d: must be between %d and %d",
+			entry.Rating, MinRating, MaxRating)
+	}
+	if entry.Date.After(time.Now().Add(24 * time.Hour)) {
+		return fmt.Errorf("invalid date %v: recipes can't be cooked in the future", entry.Date)
+	}
+
+	return nil
+}
+
+// Define an interface for managing the cook log. Logging a cook also keeps the Stats
+// of the recipe up to date.
+type CookLog interface {
+	// LogCook records that a recipe was cooked and returns the ID of the new entry. If
+	// the date or cook are not given, they default to now and the actor in the context.
+	LogCook(ctx context.Context, entry CookLogEntry) (string, error)
+	// GetCookLog returns every time the recipe with the given ID was cooked, newest first
+	GetCookLog(ctx context.Context, recipeID string) ([]CookLogEntry, error)
+	// DeleteCookLogEntry removes an entry from the cook log of a recipe
+	DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) error
+}
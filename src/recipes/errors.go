@@ -0,0 +1,25 @@
+package recipes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Define errors that recipe managers return for common failure cases
+
+// ErrNotFound is returned when a recipe (or something attached to it) does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrForbidden is returned when the viewer may see a recipe but not make the requested change
+var ErrForbidden = errors.New("forbidden")
+
+// BulkError is returned by AddRecipes when some of the recipes could not be added. The
+// others were added anyway.
+type BulkError struct {
+	// Errors holds why each recipe that wasn't added failed, by its index
+	Errors map[int]error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("failed to add %d recipes", len(e.Errors))
+}
@@ -0,0 +1,357 @@
+package recipes
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Define events that report changes to recipes as they happen
+
+// Kinds of change that an event can report
+const (
+	// EventCreated is sent when a recipe is added, or restored from the trash
+	EventCreated = "created"
+	// EventUpdated is sent when a recipe, its comments, or who can see it changes
+	EventUpdated = "updated"
+	// EventDeleted is sent when a recipe is moved to the trash
+	EventDeleted = "deleted"
+)
+
+// eventBuffer is how many events each subscriber can fall behind by
+const eventBuffer = 64
+
+// Event reports a single change to a recipe
+type Event struct {
+	// Type is one of the Event* kinds of change
+	Type     string
+	RecipeID string
+	// Revision is the recipe's revision after the change
+	Revision int
+	// Actor is the user who made the change, if known
+	Actor string
+	Time  time.Time
+
+	// access holds who may see the recipe, without anything else about it
+	access Recipe
+}
+
+// NewEvent makes an event for a change to the given recipe
+func NewEvent(eventType string, recipe Recipe, actor string, at time.Time) Event {
+	return Event{
+		Type:     eventType,
+		RecipeID: recipe.ID.Hex(),
+		Revision: recipe.Revision,
+		Actor:    actor,
+		Time:     at,
+		access: Recipe{
+			Owner:      recipe.Owner,
+			Household:  recipe.Household,
+			Visibility: recipe.Visibility,
+			Shares:     recipe.Shares,
+		},
+	}
+}
+
+// VisibleTo returns true if the viewer may see the recipe the event is about
+func (event Event) VisibleTo(viewer Viewer) bool {
+	return event.access.CanView(viewer)
+}
+
+// EventSource lets subscribers follow changes to recipes as they happen
+type EventSource interface {
+	// Subscribe returns a channel of events for every change from now on, whoever can
+	// see it. The channel is closed once the context is done, or if the subscriber falls
+	// too far behind (in which case it should subscribe again and catch up).
+	Subscribe(ctx context.Context) (<-chan Event, error)
+}
+
+// Bus passes events to everyone subscribed to them, within a single server. It is safe
+// to use from several goroutines.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	queues      map[*eventQueue]struct{}
+	dropped     atomic.Int64
+}
+
+// eventQueue holds the events for a subscriber that never misses any, until it is ready
+// for them
+type eventQueue struct {
+	mu      sync.Mutex
+	pending []Event
+	// ready is signalled whenever events are added
+	ready chan struct{}
+}
+
+// NewBus makes a bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{}), queues: make(map[*eventQueue]struct{})}
+}
+
+// Dropped returns how many subscribers have been dropped for falling too far behind
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Reliable returns an event source for the bus whose subscribers never miss an event.
+// Events wait in memory for as long as a subscriber takes to get to them, so it is
+// meant for the server's own work, such as queueing webhook deliveries, rather than
+// for clients.
+func (b *Bus) Reliable() EventSource {
+	return reliableBus{b}
+}
+
+type reliableBus struct {
+	bus *Bus
+}
+
+// Subscribe returns a channel of every event published from now on, which is only
+// closed once the context is done
+func (r reliableBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	queue := &eventQueue{ready: make(chan struct{}, 1)}
+	r.bus.mu.Lock()
+	r.bus.queues[queue] = struct{}{}
+	r.bus.mu.Unlock()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			r.bus.mu.Lock()
+			delete(r.bus.queues, queue)
+			r.bus.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-queue.ready:
+			}
+			for _, event := range queue.take() {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// add queues an event and lets the subscriber know, without waiting for it
+func (q *eventQueue) add(event Event) {
+	q.mu.Lock()
+	q.pending = append(q.pending, event)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the queued events, in the order they were added, and empties the queue
+func (q *eventQueue) take() []Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := q.pending
+	q.pending = nil
+	return pending
+}
+
+// Subscribe returns a channel of the events published from now on
+func (b *Bus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	events := make(chan Event, eventBuffer)
+	b.mu.Lock()
+	b.subscribers[events] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(events)
+	}()
+	return events, nil
+}
+
+// Publish sends an event to every subscriber without waiting for them. Subscribers
+// whose channels are full are dropped rather than holding up everyone else, and
+// reliable ones (see Reliable) have it queued.
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(b.subscribers, events)
+			close(events)
+			b.dropped.Add(1)
+			slog.Warn("dropped an event subscriber that fell behind", "buffer", eventBuffer)
+		}
+	}
+	for queue := range b.queues {
+		queue.add(event)
+	}
+}
+
+// unsubscribe stops sending events to a subscriber and closes its channel, unless that
+// has already happened
+func (b *Bus) unsubscribe(events chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[events]; ok {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+// PublishChanges wraps a recipe manager so that every change made through it is
+// published on the bus. Reads, and purging recipes that are already in the trash, go
+// straight to the wrapped manager.
+func PublishChanges(manager RecipeManager, bus *Bus) RecipeManager {
+	return &publisher{RecipeManager: manager, bus: bus}
+}
+
+type publisher struct {
+	RecipeManager
+	bus *Bus
+}
+
+// publish looks up the recipe as it is after a successful change and publishes an
+// event for it. The change has already happened by then, so failures are logged rather
+// than returned.
+func (p *publisher) publish(ctx context.Context, eventType, id string) {
+	recipe, err := p.RecipeManager.GetRecipeByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to look up changed recipe for its event",
+			"event", eventType, "recipe_id", id, "error", err)
+		return
+	}
+	p.bus.Publish(NewEvent(eventType, recipe, ActorFromContext(ctx), time.Now().UTC()))
+}
+
+func (p *publisher) AddRecipe(ctx context.Context, recipe Recipe) (string, error) {
+	id, err := p.RecipeManager.AddRecipe(ctx, recipe)
+	if err == nil {
+		p.publish(ctx, EventCreated, id)
+	}
+	return id, err
+}
+
+func (p *publisher) AddRecipes(ctx context.Context, recipes []Recipe) ([]string, error) {
+	ids, err := p.RecipeManager.AddRecipes(ctx, recipes)
+
+	// Look up every recipe that was added at once, rather than one at a time
+	var added []string
+	for _, id := range ids {
+		if id != "" {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		return ids, err
+	}
+	found, lookupErr := p.RecipeManager.GetRecipesByIDs(ctx, added)
+	if lookupErr != nil {
+		slog.ErrorContext(ctx, "failed to look up added recipes for their events",
+			"event", EventCreated, "recipes", len(added), "error", lookupErr)
+		return ids, err
+	}
+	now := time.Now().UTC()
+	for _, recipe := range found {
+		p.bus.Publish(NewEvent(EventCreated, recipe, ActorFromContext(ctx), now))
+	}
+	return ids, err
+}
+
+func (p *publisher) ImportRecipes(ctx context.Context, recipes []Recipe) error {
+	if err := p.RecipeManager.ImportRecipes(ctx, recipes); err != nil {
+		return err
+	}
+
+	// Imported recipes are new as far as subscribers know, unless they went straight
+	// into the trash. Look them up as they were stored, with their new revisions; the
+	// ones in the trash can't be looked up.
+	ids := make([]string, len(recipes))
+	for i, recipe := range recipes {
+		ids[i] = recipe.ID.Hex()
+	}
+	found, err := p.RecipeManager.GetRecipesByIDs(ctx, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to look up imported recipes for their events",
+			"event", EventCreated, "recipes", len(ids), "error", err)
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, recipe := range found {
+		p.bus.Publish(NewEvent(EventCreated, recipe, ActorFromContext(ctx), now))
+	}
+	return nil
+}
+
+func (p *publisher) DeleteRecipe(ctx context.Context, id string) error {
+	// Recipes in the trash can't be looked up, so remember it as it was
+	recipe, err := p.RecipeManager.GetRecipeByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := p.RecipeManager.DeleteRecipe(ctx, id); err != nil {
+		return err
+	}
+	p.bus.Publish(NewEvent(EventDeleted, recipe, ActorFromContext(ctx), time.Now().UTC()))
+	return nil
+}
+
+func (p *publisher) UpdateRecipe(ctx context.Context, recipe Recipe) error {
+	return p.updated(ctx, recipe.ID.Hex(), p.RecipeManager.UpdateRecipe(ctx, recipe))
+}
+
+func (p *publisher) RestoreRevision(ctx context.Context, id string, number int) error {
+	return p.updated(ctx, id, p.RecipeManager.RestoreRevision(ctx, id, number))
+}
+
+func (p *publisher) RestoreRecipe(ctx context.Context, id string) error {
+	err := p.RecipeManager.RestoreRecipe(ctx, id)
+	if err == nil {
+		p.publish(ctx, EventCreated, id)
+	}
+	return err
+}
+
+func (p *publisher) AddComment(ctx context.Context, recipeID string, comment Comments) (string, error) {
+	id, err := p.RecipeManager.AddComment(ctx, recipeID, comment)
+	return id, p.updated(ctx, recipeID, err)
+}
+
+func (p *publisher) UpdateComment(ctx context.Context, recipeID string, comment Comments) error {
+	return p.updated(ctx, recipeID, p.RecipeManager.UpdateComment(ctx, recipeID, comment))
+}
+
+func (p *publisher) DeleteComment(ctx context.Context, recipeID, commentID string) error {
+	return p.updated(ctx, recipeID, p.RecipeManager.DeleteComment(ctx, recipeID, commentID))
+}
+
+func (p *publisher) SetVisibility(ctx context.Context, id, visibility string) error {
+	return p.updated(ctx, id, p.RecipeManager.SetVisibility(ctx, id, visibility))
+}
+
+func (p *publisher) ShareRecipe(ctx context.Context, id string, grant ShareGrant) error {
+	return p.updated(ctx, id, p.RecipeManager.ShareRecipe(ctx, id, grant))
+}
+
+func (p *publisher) UnshareRecipe(ctx context.Context, id, userID string) error {
+	return p.updated(ctx, id, p.RecipeManager.UnshareRecipe(ctx, id, userID))
+}
+
+// updated publishes an update to the recipe if the change succeeded, and passes on the
+// error from making it
+func (p *publisher) updated(ctx context.Context, id string, err error) error {
+	if err == nil {
+		p.publish(ctx, EventUpdated, id)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package recipes
+
+import (
+	"context"
+	"time"
+)
+
+// Define a recipe manager that reports how long every operation takes
+
+// Observer is told about every operation on an instrumented recipe manager once it
+// finishes, with how long it took and the error it returned (if any)
+type Observer func(ctx context.Context, operation string, duration time.Duration, err error)
+
+// instrumentedManager wraps another recipe manager, timing every call to it. Every
+// method is wrapped explicitly, so that new methods can't be missed.
+type instrumentedManager struct {
+	manager RecipeManager
+	observe Observer
+}
+
+// Instrument wraps the recipe manager so that the observer is told about every
+// operation on it
+func Instrument(manager RecipeManager, observe Observer) RecipeManager {
+	return &instrumentedManager{manager: manager, observe: observe}
+}
+
+// observed reports an operation that started at the given time to the observer. It is
+// deferred, so it takes a pointer to the error the operation ends up returning.
+func (m *instrumentedManager) observed(ctx context.Context, operation string, start time.Time, err *error) {
+	m.observe(ctx, operation, time.Since(start), *err)
+}
+
+func (m *instrumentedManager) AddRecipe(ctx context.Context, recipe Recipe) (result string, err error) {
+	defer m.observed(ctx, "AddRecipe", time.Now(), &err)
+	return m.manager.AddRecipe(ctx, recipe)
+}
+
+func (m *instrumentedManager) AddRecipes(ctx context.Context, recipes []Recipe) (result []string, err error) {
+	defer m.observed(ctx, "AddRecipes", time.Now(), &err)
+	return m.manager.AddRecipes(ctx, recipes)
+}
+
+func (m *instrumentedManager) DeleteRecipe(ctx context.Context, id string) (err error) {
+	defer m.observed(ctx, "DeleteRecipe", time.Now(), &err)
+	return m.manager.DeleteRecipe(ctx, id)
+}
+
+func (m *instrumentedManager) UpdateRecipe(ctx context.Context, recipe Recipe) (err error) {
+	defer m.observed(ctx, "UpdateRecipe", time.Now(), &err)
+	return m.manager.UpdateRecipe(ctx, recipe)
+}
+
+func (m *instrumentedManager) GetAllRecipes(ctx context.Context) (result []Recipe, err error) {
+	defer m.observed(ctx, "GetAllRecipes", time.Now(), &err)
+	return m.manager.GetAllRecipes(ctx)
+}
+
+func (m *instrumentedManager) CountRecipes(ctx context.Context) (result int, err error) {
+	defer m.observed(ctx, "CountRecipes", time.Now(), &err)
+	return m.manager.CountRecipes(ctx)
+}
+
+func (m *instrumentedManager) GetRecipeByID(ctx context.Context, id string) (result Recipe, err error) {
+	defer m.observed(ctx, "GetRecipeByID", time.Now(), &err)
+	return m.manager.GetRecipeByID(ctx, id)
+}
+
+func (m *instrumentedManager) GetRecipesByIDs(ctx context.Context, ids []string) (result []Recipe, err error) {
+	defer m.observed(ctx, "GetRecipesByIDs", time.Now(), &err)
+	return m.manager.GetRecipesByIDs(ctx, ids)
+}
+
+func (m *instrumentedManager) GetRecipesByTags(ctx context.Context, tags []string) (result []Recipe, err error) {
+	defer m.observed(ctx, "GetRecipesByTags", time.Now(), &err)
+	return m.manager.GetRecipesByTags(ctx, tags)
+}
+
+func (m *instrumentedManager) GetTags(ctx context.Context) (result []string, err error) {
+	defer m.observed(ctx, "GetTags", time.Now(), &err)
+	return m.manager.GetTags(ctx)
+}
+
+func (m *instrumentedManager) SearchRecipes(ctx context.Context, query string, tags []string) (result []Recipe, err error) {
+	defer m.observed(ctx, "SearchRecipes", time.Now(), &err)
+	return m.manager.SearchRecipes(ctx, query, tags)
+}
+
+func (m *instrumentedManager) ListRecipes(ctx context.Context, opts ListOptions) (result []Recipe, err error) {
+	defer m.observed(ctx, "ListRecipes", time.Now(), &err)
+	return m.manager.ListRecipes(ctx, opts)
+}
+
+func (m *instrumentedManager) ExportRecipes(ctx context.Context, fn func(Recipe) error) (err error) {
+	defer m.observed(ctx, "ExportRecipes", time.Now(), &err)
+	return m.manager.ExportRecipes(ctx, fn)
+}
+
+func (m *instrumentedManager) ImportRecipes(ctx context.Context, recipes []Recipe) (err error) {
+	defer m.observed(ctx, "ImportRecipes", time.Now(), &err)
+	return m.manager.ImportRecipes(ctx, recipes)
+}
+
+func (m *instrumentedManager) GetRevisions(ctx context.Context, id string) (result []Revision, err error) {
+	defer m.observed(ctx, "GetRevisions", time.Now(), &err)
+	return m.manager.GetRevisions(ctx, id)
+}
+
+func (m *instrumentedManager) GetRevision(ctx context.Context, id string, number int) (result Revision, err error) {
+	defer m.observed(ctx, "GetRevision", time.Now(), &err)
+	return m.manager.GetRevision(ctx, id, number)
+}
+
+func (m *instrumentedManager) RestoreRevision(ctx context.Context, id string, number int) (err error) {
+	defer m.observed(ctx, "RestoreRevision", time.Now(), &err)
+	return m.manager.RestoreRevision(ctx, id, number)
+}
+
+func (m *instrumentedManager) GetTrash(ctx context.Context) (result []Recipe, err error) {
+	defer m.observed(ctx, "GetTrash", time.Now(), &err)
+	return m.manager.GetTrash(ctx)
+}
+
+func (m *instrumentedManager) RestoreRecipe(ctx context.Context, id string) (err error) {
+	defer m.observed(ctx, "RestoreRecipe", time.Now(), &err)
+	return m.manager.RestoreRecipe(ctx, id)
+}
+
+func (m *instrumentedManager) PurgeTrash(ctx context.Context, before time.Time) (result int, err error) {
+	defer m.observed(ctx, "PurgeTrash", time.Now(), &err)
+	return m.manager.PurgeTrash(ctx, before)
+}
+
+func (m *instrumentedManager) PurgeRecipes(ctx context.Context, ids []string) (result []string, err error) {
+	defer m.observed(ctx, "PurgeRecipes", time.Now(), &err)
+	return m.manager.PurgeRecipes(ctx, ids)
+}
+
+func (m *instrumentedManager) AddComment(ctx context.Context, recipeID string, comment Comments) (result string, err error) {
+	defer m.observed(ctx, "AddComment", time.Now(), &err)
+	return m.manager.AddComment(ctx, recipeID, comment)
+}
+
+func (m *instrumentedManager) UpdateComment(ctx context.Context, recipeID string, comment Comments) (err error) {
+	defer m.observed(ctx, "UpdateComment", time.Now(), &err)
+	return m.manager.UpdateComment(ctx, recipeID, comment)
+}
+
+func (m *instrumentedManager) DeleteComment(ctx context.Context, recipeID, commentID string) (err error) {
+	defer m.observed(ctx, "DeleteComment", time.Now(), &err)
+	return m.manager.DeleteComment(ctx, recipeID, commentID)
+}
+
+func (m *instrumentedManager) SetVisibility(ctx context.Context, id, visibility string) (err error) {
+	defer m.observed(ctx, "SetVisibility", time.Now(), &err)
+	return m.manager.SetVisibility(ctx, id, visibility)
+}
+
+func (m *instrumentedManager) ShareRecipe(ctx context.Context, id string, grant ShareGrant) (err error) {
+	defer m.observed(ctx, "ShareRecipe", time.Now(), &err)
+	return m.manager.ShareRecipe(ctx, id, grant)
+}
+
+func (m *instrumentedManager) UnshareRecipe(ctx context.Context, id, userID string) (err error) {
+	defer m.observed(ctx, "UnshareRecipe", time.Now(), &err)
+	return m.manager.UnshareRecipe(ctx, id, userID)
+}
+
+func (m *instrumentedManager) Ping(ctx context.Context) (err error) {
+	defer m.observed(ctx, "Ping", time.Now(), &err)
+	return m.manager.Ping(ctx)
+}
+
+// Close isn't timed, since it only happens once at shutdown
+func (m *instrumentedManager) Close() error {
+	return m.manager.Close()
+}
+
+// instrumentedCookLog wraps another cook log, timing every call to it
+type instrumentedCookLog struct {
+	log     CookLog
+	observe Observer
+}
+
+// InstrumentCookLog wraps the cook log so that the observer is told about every
+// operation on it
+func InstrumentCookLog(log CookLog, observe Observer) CookLog {
+	return &instrumentedCookLog{log: log, observe: observe}
+}
+
+func (m *instrumentedCookLog) observed(ctx context.Context, operation string, start time.Time, err *error) {
+	m.observe(ctx, operation, time.Since(start), *err)
+}
+
+func (m *instrumentedCookLog) LogCook(ctx context.Context, entry CookLogEntry) (result string, err error) {
+	defer m.observed(ctx, "LogCook", time.Now(), &err)
+	return m.log.LogCook(ctx, entry)
+}
+
+func (m *instrumentedCookLog) GetCookLog(ctx context.Context, recipeID string) (result []CookLogEntry, err error) {
+	defer m.observed(ctx, "GetCookLog", time.Now(), &err)
+	return m.log.GetCookLog(ctx, recipeID)
+}
+
+func (m *instrumentedCookLog) DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) (err error) {
+	defer m.observed(ctx, "DeleteCookLogEntry", time.Now(), &err)
+	return m.log.DeleteCookLogEntry(ctx, recipeID, entryID)
+}
+
+// instrumentedShareLinks wraps other share links, timing every call to them
+type instrumentedShareLinks struct {
+	links   ShareLinks
+	observe Observer
+}
+
+// InstrumentShareLinks wraps the share links so that the observer is told about every
+// operation on them
+func InstrumentShareLinks(links ShareLinks, observe Observer) ShareLinks {
+	return &instrumentedShareLinks{links: links, observe: observe}
+}
+
+func (m *instrumentedShareLinks) observed(ctx context.Context, operation string, start time.Time, err *error) {
+	m.observe(ctx, operation, time.Since(start), *err)
+}
+
+func (m *instrumentedShareLinks) CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (token string, link ShareLink, err error) {
+	defer m.observed(ctx, "CreateShareLink", time.Now(), &err)
+	return m.links.CreateShareLink(ctx, recipeID, expiresAt)
+}
+
+func (m *instrumentedShareLinks) GetShareLinks(ctx context.Context, recipeID string) (result []ShareLink, err error) {
+	defer m.observed(ctx, "GetShareLinks", time.Now(), &err)
+	return m.links.GetShareLinks(ctx, recipeID)
+}
+
+func (m *instrumentedShareLinks) RevokeShareLink(ctx context.Context, recipeID, linkID string) (err error) {
+	defer m.observed(ctx, "RevokeShareLink", time.Now(), &err)
+	return m.links.RevokeShareLink(ctx, recipeID, linkID)
+}
+
+func (m *instrumentedShareLinks) GetSharedRecipe(ctx context.Context, token string) (result Recipe, err error) {
+	defer m.observed(ctx, "GetSharedRecipe", time.Now(), &err)
+	return m.links.GetSharedRecipe(ctx, token)
+}
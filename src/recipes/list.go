@@ -0,0 +1,77 @@
+package recipes
+
+import (
+	"fmt"
+	"time"
+)
+
+// Define options for listing recipes
+
+// ListOptions narrows down and orders the recipes returned by ListRecipes. Zero values
+// leave the corresponding filter unset.
+type ListOptions struct {
+	// Query matches recipes whose name, description, or comments contain the query
+	Query string
+	// Tags matches recipes that have all of the given tags
+	Tags []string
+
+	// CreatedAfter and CreatedBefore match recipes created within a date range
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// UpdatedAfter and UpdatedBefore match recipes last changed within a date range
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// SortBy orders the recipes by one of the Sort* keys (unordered if empty)
+	SortBy string
+	// Descending reverses the sort order
+	Descending bool
+}
+
+// Keys that recipes can be sorted by
+const (
+	SortByName        = "name"
+	SortByCreated     = "created"
+	SortByUpdated     = "updated"
+	SortByRating      = "rating"
+	SortByTimesCooked = "times_cooked"
+	SortByLastCooked  = "last_cooked"
+)
+
+// sortKeys lists all of the valid values for ListOptions.SortBy
+var sortKeys = []string{
+	SortByName, SortByCreated, SortByUpdated,
+	SortByRating, SortByTimesCooked, SortByLastCooked,
+}
+
+// Validate checks that the options make sense before they are used in a query
+func (opts ListOptions) Validate() error {
+	// The sort key must be one we know about
+	if opts.SortBy != "" && !contains(sortKeys, opts.SortBy) {
+		return fmt.Errorf("invalid sort key %q: expected one of %v", opts.SortBy, sortKeys)
+	}
+
+	// Date ranges must not be backwards
+	if !opts.CreatedAfter.IsZero() && !opts.CreatedBefore.IsZero() &&
+		opts.CreatedBefore.Before(opts.CreatedAfter) {
+		return fmt.Errorf("invalid created date range: %v is before %v",
+			opts.CreatedBefore, opts.CreatedAfter)
+	}
+	if !opts.UpdatedAfter.IsZero() && !opts.UpdatedBefore.IsZero() &&
+		opts.UpdatedBefore.Before(opts.UpdatedAfter) {
+		return fmt.Errorf("invalid updated date range: %v is before %v",
+			opts.UpdatedBefore, opts.UpdatedAfter)
+	}
+
+	return nil
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
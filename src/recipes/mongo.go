@@ -2,6 +2,8 @@ package recipes
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,6 +12,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// notDeleted matches documents that have not been moved to the trash
+var notDeleted = bson.M{"$exists": false}
+
 // Define a MongoDB recipe manager that implements the RecipeManager interface
 type MongoRecipeManager struct {
 	client         *mongo.Client
@@ -28,9 +33,10 @@ func CreateMongoRecipeManager(uri, dbName, collectionName string) (*MongoRecipeM
 		return nil, err
 	}
 
-	// Check the connection
+	// Check the connection, letting go of the client if it doesn't work
 	err = client.Ping(context.Background(), nil)
 	if err != nil {
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
@@ -44,104 +50,349 @@ func CreateMongoRecipeManager(uri, dbName, collectionName string) (*MongoRecipeM
 	return recipeManager, nil
 }
 
+// Ping checks that MongoDB is reachable
+func (m *MongoRecipeManager) Ping(ctx context.Context) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return m.client.Ping(ctx, nil)
+}
+
+// Close disconnects from MongoDB, waiting for operations in progress to finish. The
+// recipe manager can't be used afterwards.
+func (m *MongoRecipeManager) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return m.client.Disconnect(ctx)
+}
+
 // AddRecipe adds a recipe to the recipe manager and returns the ID of the new recipe
-func (m *MongoRecipeManager) AddRecipe(recipe Recipe) (string, error) {
+func (m *MongoRecipeManager) AddRecipe(ctx context.Context, recipe Recipe) (string, error) {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Add the recipe
+	// Add the recipe as the first revision
+	if err := prepareNewRecipe(ctx, &recipe, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		return "", err
+	}
 	result, err := collection.InsertOne(ctx, recipe)
 	if err != nil {
 		return "", err
 	}
+	recipe.ID = result.InsertedID.(primitive.ObjectID)
 
-	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+	// Record the new recipe in its revision history
+	if err := m.recordRevision(ctx, recipe, RevisionCreated, 0); err != nil {
+		return "", err
+	}
+
+	return recipe.ID.Hex(), nil
 }
 
-// DeleteRecipe deletes a recipe from the recipe manager
-func (m *MongoRecipeManager) DeleteRecipe(id string) error {
+// AddRecipes adds several recipes at once and returns their IDs in the same order
+func (m *MongoRecipeManager) AddRecipes(ctx context.Context, recipes []Recipe) ([]string, error) {
+	if len(recipes) > MaxBulkRecipes {
+		return nil, fmt.Errorf("too many recipes: at most %d can be added at once, got %d", MaxBulkRecipes, len(recipes))
+	}
+	if len(recipes) == 0 {
+		return []string{}, nil
+	}
+
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	// Delete the recipe
-	objID, err := primitive.ObjectIDFromHex(id)
+	// Add every recipe as its first revision, with its ID chosen up front so that the
+	// IDs are known even if some of them fail
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	prepared := make([]Recipe, len(recipes))
+	documents := make([]interface{}, len(recipes))
+	for i, recipe := range recipes {
+		if err := prepareNewRecipe(ctx, &recipe, now); err != nil {
+			return nil, err
+		}
+		recipe.ID = primitive.NewObjectID()
+		prepared[i], documents[i] = recipe, recipe
+	}
+
+	// Insert them all in one go, carrying on past any that fail
+	failed := make(map[int]error)
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		for _, writeErr := range bulkErr.WriteErrors {
+			failed[writeErr.Index] = writeErr
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Record the new recipes in their revision histories
+	ids := make([]string, len(prepared))
+	revisions := make([]interface{}, 0, len(prepared))
+	for i, recipe := range prepared {
+		if failed[i] == nil {
+			ids[i] = recipe.ID.Hex()
+			revisions = append(revisions, newRevision(ctx, recipe, RevisionCreated, 0))
+		}
+	}
+	if len(revisions) > 0 {
+		if _, err := m.revisionCollection().InsertMany(ctx, revisions); err != nil {
+			return ids, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return ids, &BulkError{Errors: failed}
+	}
+	return ids, nil
+}
+
+// prepareNewRecipe sets up a recipe to be added as the first revision: it belongs to
+// the user that adds it and their household, and notes when and by whom it was created
+func prepareNewRecipe(ctx context.Context, recipe *Recipe, now time.Time) error {
+	if err := setOwnership(ctx, recipe); err != nil {
+		return err
+	}
+
+	recipe.Revision = 1
+	recipe.DeletedAt = nil
+	recipe.Stats = CookStats{}
+	recipe.CreatedAt, recipe.UpdatedAt = now, now
+	recipe.CreatedBy = ActorFromContext(ctx)
+	recipe.UpdatedBy = recipe.CreatedBy
+
+	// Make sure any comments added with the recipe can be edited later
+	for i := range recipe.Comments {
+		if recipe.Comments[i].ID.IsZero() {
+			recipe.Comments[i].ID = primitive.NewObjectID()
+		}
+	}
+	return nil
+}
+
+// ExportRecipes calls fn with every recipe the viewer can see, one at a time, in the
+// order they were added
+func (m *MongoRecipeManager) ExportRecipes(ctx context.Context, fn func(Recipe) error) error {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// There's no timeout, since an export takes as long as its caller takes to handle
+	// each recipe; the cursor fetches them from the database a batch at a time
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+	cursor, err := collection.Find(ctx, listFilter(ctx, ListOptions{}), opts)
 	if err != nil {
 		return err
 	}
-	_, err = collection.DeleteOne(ctx, bson.M{"_id": objID})
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var recipe Recipe
+		if err := cursor.Decode(&recipe); err != nil {
+			return err
+		}
+		if err := fn(recipe); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ImportRecipes stores recipes as they are given, replacing any recipe with the same ID.
+// Each is recorded as the next revision of the history already here, if any, and its
+// cook stats are worked out from the cook log here, since neither comes with it.
+func (m *MongoRecipeManager) ImportRecipes(ctx context.Context, recipes []Recipe) error {
+	if viewer, ok := ViewerFromContext(ctx); ok && !viewer.Admin {
+		return ErrForbidden
+	}
+	if len(recipes) == 0 {
+		return nil
+	}
+
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	ids := make([]primitive.ObjectID, len(recipes))
+	for i, recipe := range recipes {
+		if recipe.ID.IsZero() {
+			return fmt.Errorf("recipe %d (%q) has no ID", i, recipe.Name)
+		}
+		if recipe.Visibility != "" {
+			if err := ValidateVisibility(recipe.Visibility); err != nil {
+				return fmt.Errorf("recipe %s: %w", recipe.ID.Hex(), err)
+			}
+		}
+		ids[i] = recipe.ID
+	}
+
+	// Find where each recipe's history and cook log are up to
+	latest, err := m.latestRevisions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	stats, err := m.cookStats(ctx, ids)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Replace each recipe by its ID, adding the ones that don't exist yet, then record
+	// them in their histories
+	models := make([]mongo.WriteModel, len(recipes))
+	revisions := make([]interface{}, len(recipes))
+	for i, recipe := range recipes {
+		recipe.Revision = latest[recipe.ID] + 1
+		recipe.Stats = stats[recipe.ID]
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": recipe.ID}).
+			SetReplacement(recipe).
+			SetUpsert(true)
+		revisions[i] = newRevision(ctx, recipe, RevisionImported, 0)
+	}
+	if _, err := collection.BulkWrite(ctx, models); err != nil {
+		return err
+	}
+	_, err = m.revisionCollection().InsertMany(ctx, revisions)
+	return err
 }
 
-// UpdateRecipe updates a recipe in the recipe manager
-func (m *MongoRecipeManager) UpdateRecipe(recipe Recipe) error {
+// DeleteRecipe moves a recipe to the trash
+func (m *MongoRecipeManager) DeleteRecipe(ctx context.Context, id string) error {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Update the recipe
-	_, err := collection.UpdateOne(ctx, bson.M{"_id": recipe.ID}, bson.M{"$set": recipe})
+	// Mark the recipe as deleted, unless it is already in the trash
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
+	filter := bson.M{"_id": objID, "deleted_at": notDeleted}
+	if _, err := m.checkAccess(ctx, filter, accessEdit); err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now().UTC()}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
 
 	return nil
 }
 
-// GetAllRecipes returns all recipes in the recipe manager
-func (m *MongoRecipeManager) GetAllRecipes() ([]Recipe, error) {
+// UpdateRecipe updates a recipe in the recipe manager
+func (m *MongoRecipeManager) UpdateRecipe(ctx context.Context, recipe Recipe) error {
+	return m.updateRecipe(ctx, recipe, RevisionUpdated, 0)
+}
+
+// updateRecipe updates a recipe and records the result as a new revision created by
+// the given action
+func (m *MongoRecipeManager) updateRecipe(ctx context.Context, recipe Recipe, action string, restoredFrom int) error {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Find all documents in the collection
-	cursor, err := collection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, err
+	// Make sure the recipe exists and can be changed by the viewer
+	filter := bson.M{"_id": recipe.ID, "deleted_at": notDeleted}
+	if _, err := m.checkAccess(ctx, filter, accessEdit); err != nil {
+		return err
 	}
-	defer cursor.Close(ctx)
 
-	// Decode each document into a Recipe object
-	var recipes []Recipe
-	for cursor.Next(ctx) {
-		var recipe Recipe
-		if err := cursor.Decode(&recipe); err != nil {
-			return nil, err
-		}
-		recipes = append(recipes, recipe)
+	// Update the recipe, bumping its revision number, and get the updated document back
+	fields, err := recipeUpdateFields(recipe)
+	if err != nil {
+		return err
+	}
+	fields["updated_at"] = time.Now().UTC().Truncate(time.Millisecond)
+	fields["updated_by"] = ActorFromContext(ctx)
+	update := bson.M{"$set": fields, "$inc": bson.M{"revision": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated Recipe
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	if err := cursor.Err(); err != nil {
+	// Record the updated recipe in its revision history
+	return m.recordRevision(ctx, updated, action, restoredFrom)
+}
+
+// recipeUpdateFields converts a recipe into the fields to $set when updating it,
+// leaving out the fields that are managed by the recipe manager
+func recipeUpdateFields(recipe Recipe) (bson.M, error) {
+	data, err := bson.Marshal(recipe)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(data, &fields); err != nil {
 		return nil, err
 	}
 
-	return recipes, nil
+	delete(fields, "_id")
+	delete(fields, "revision")
+	delete(fields, "deleted_at")
+	delete(fields, "created_at")
+	delete(fields, "created_by")
+	delete(fields, "comments")
+	delete(fields, "stats")
+	delete(fields, "owner")
+	delete(fields, "household")
+	delete(fields, "visibility")
+	delete(fields, "shares")
+	return fields, nil
+}
+
+// GetAllRecipes returns all recipes in the recipe manager
+func (m *MongoRecipeManager) GetAllRecipes(ctx context.Context) ([]Recipe, error) {
+	return m.ListRecipes(ctx, ListOptions{})
+}
+
+// CountRecipes returns how many recipes the viewer can see, leaving out the trash
+func (m *MongoRecipeManager) CountRecipes(ctx context.Context) (int, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Count the documents without loading them
+	count, err := collection.CountDocuments(ctx, listFilter(ctx, ListOptions{}))
+	return int(count), err
 }
 
 // GetRecipeByID returns a recipe with the given ID
-func (m *MongoRecipeManager) GetRecipeByID(id string) (Recipe, error) {
+func (m *MongoRecipeManager) GetRecipeByID(ctx context.Context, id string) (Recipe, error) {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// Find the document with the given ID
@@ -150,66 +401,74 @@ func (m *MongoRecipeManager) GetRecipeByID(id string) (Recipe, error) {
 		return Recipe{}, err
 	}
 	var recipe Recipe
-	if err := collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&recipe); err != nil {
+	filter := bson.M{"$and": []bson.M{{"_id": objID, "deleted_at": notDeleted}, viewerFilter(ctx)}}
+	err = collection.FindOne(ctx, filter).Decode(&recipe)
+	if err == mongo.ErrNoDocuments {
+		return recipe, ErrNotFound
+	}
+	if err != nil {
 		return recipe, err
 	}
 
 	return recipe, nil
 }
 
-// GetRecipesByTags returns all recipes with the given tags
-func (m *MongoRecipeManager) GetRecipesByTags(tags []string) ([]Recipe, error) {
+// GetRecipesByIDs returns the recipes with the given IDs, leaving out any that don't
+// exist, are in the trash, or can't be seen by the viewer
+func (m *MongoRecipeManager) GetRecipesByIDs(ctx context.Context, ids []string) ([]Recipe, error) {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// create a filter to match recipes with the given tags
-	filter := bson.M{"tags": bson.M{"$all": tags}}
+	// IDs that aren't valid can't match anything, so they are skipped rather than
+	// failing the whole lookup
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	recipes := make([]Recipe, 0)
+	if len(objIDs) == 0 {
+		return recipes, nil
+	}
 
-	// execute the find operation and get the result cursor
+	// Find all of the documents in one query
+	filter := bson.M{"$and": []bson.M{{"_id": bson.M{"$in": objIDs}, "deleted_at": notDeleted}, viewerFilter(ctx)}}
 	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	// iterate over the cursor and decode each document into a Recipe object
-	var recipes []Recipe
-	for cursor.Next(ctx) {
-		var recipe Recipe
-		err := cursor.Decode(&recipe)
-		if err != nil {
-			return nil, err
-		}
-		recipes = append(recipes, recipe)
-	}
-
-	// check if there were any errors during iteration
-	if err := cursor.Err(); err != nil {
+	if err := cursor.All(ctx, &recipes); err != nil {
 		return nil, err
 	}
 
-	// close the cursor and return the recipes
-	err = cursor.Close(ctx)
-	if err != nil {
-		return nil, err
-	}
 	return recipes, nil
 }
 
+// GetRecipesByTags returns all recipes with the given tags
+func (m *MongoRecipeManager) GetRecipesByTags(ctx context.Context, tags []string) ([]Recipe, error) {
+	return m.ListRecipes(ctx, ListOptions{Tags: tags})
+}
+
 // GetTags returns all tags in the recipe manager
-func (m *MongoRecipeManager) GetTags() ([]string, error) {
+func (m *MongoRecipeManager) GetTags(ctx context.Context) ([]string, error) {
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Find all documents in the collection
-	cursor, err := collection.Find(ctx, bson.M{})
+	// Find all documents in the collection that are not in the trash and that the
+	// viewer can see
+	filter := bson.M{"$and": []bson.M{{"deleted_at": notDeleted}, viewerFilter(ctx)}}
+	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -242,59 +501,675 @@ func (m *MongoRecipeManager) GetTags() ([]string, error) {
 }
 
 // SearchRecipes returns all recipes that match the given query string and tags
-func (m *MongoRecipeManager) SearchRecipes(query string, tags []string) ([]Recipe, error) {
+func (m *MongoRecipeManager) SearchRecipes(ctx context.Context, query string, tags []string) ([]Recipe, error) {
+	return m.ListRecipes(ctx, ListOptions{Query: query, Tags: tags})
+}
+
+// sortFields maps each sort key to the document field it sorts by
+var sortFields = map[string]string{
+	SortByName:        "name",
+	SortByCreated:     "created_at",
+	SortByUpdated:     "updated_at",
+	SortByRating:      "stats.average_rating",
+	SortByTimesCooked: "stats.times_cooked",
+	SortByLastCooked:  "stats.last_cooked",
+}
+
+// ListRecipes returns all recipes that match the given options, in the requested order
+func (m *MongoRecipeManager) ListRecipes(ctx context.Context, opts ListOptions) ([]Recipe, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Get the collection handle
 	collection := m.client.Database(m.dbName).Collection(m.collectionName)
 
 	// Use a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// create a filter to match recipes with the given tags and the search query
-	query_filter := bson.M{"$regex": query, "$options": "i"}
-	var tags_filter bson.M
-	if len(tags) > 0 {
-		// Only search for tags if we've been given a list of tags to search
-		tags_filter = bson.M{"tags": bson.M{"$all": tags}}
-	} else {
-		tags_filter = bson.M{"tags": bson.M{"$exists": true}}
-	}
-	filter := bson.M{
-		"$and": []bson.M{
-			tags_filter,
-			{"$or": []bson.M{
-				{"title": query_filter},
-				{"description": query_filter},
-				{"comments": bson.M{"$elemMatch": bson.M{"comment": query_filter}}},
-			}},
-		}}
+	// Sort the results if requested
+	findOptions := options.Find()
+	if opts.SortBy != "" {
+		direction := 1
+		if opts.Descending {
+			direction = -1
+		}
+		findOptions.SetSort(bson.D{{Key: sortFields[opts.SortBy], Value: direction}})
+	}
 
 	// execute the find operation and get the result cursor
-	cursor, err := collection.Find(ctx, filter)
+	cursor, err := collection.Find(ctx, listFilter(ctx, opts), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// Decode all of the matching recipes
+	recipes := make([]Recipe, 0)
+	if err := cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+
+	return recipes, nil
+}
+
+// listFilter builds a filter that matches the recipes described by the list options.
+// Recipes in the trash and recipes the viewer can't see never match.
+func listFilter(ctx context.Context, opts ListOptions) bson.M {
+	filters := []bson.M{{"deleted_at": notDeleted}, viewerFilter(ctx)}
+
+	// Match recipes with all of the given tags
+	if len(opts.Tags) > 0 {
+		filters = append(filters, bson.M{"tags": bson.M{"$all": opts.Tags}})
+	}
+
+	// Match the search query against the name, description, and comments
+	if opts.Query != "" {
+		query_filter := bson.M{"$regex": opts.Query, "$options": "i"}
+		filters = append(filters, bson.M{"$or": []bson.M{
+			{"name": query_filter},
+			{"description": query_filter},
+			{"comments": bson.M{"$elemMatch": bson.M{"comment": query_filter}}},
+		}})
+	}
+
+	// Match the date ranges, leaving out any unset bounds
+	filters = appendDateRange(filters, "created_at", opts.CreatedAfter, opts.CreatedBefore)
+	filters = appendDateRange(filters, "updated_at", opts.UpdatedAfter, opts.UpdatedBefore)
+
+	return bson.M{"$and": filters}
+}
+
+// appendDateRange adds a filter on the given date field for any non-zero bounds
+func appendDateRange(filters []bson.M, field string, after, before time.Time) []bson.M {
+	dateRange := bson.M{}
+	if !after.IsZero() {
+		dateRange["$gte"] = after
+	}
+	if !before.IsZero() {
+		dateRange["$lt"] = before
+	}
+	if len(dateRange) == 0 {
+		return filters
+	}
+
+	return append(filters, bson.M{field: dateRange})
+}
+
+// revisionCollection returns the collection holding the revision history of recipes
+func (m *MongoRecipeManager) revisionCollection() *mongo.Collection {
+	return m.client.Database(m.dbName).Collection(m.collectionName + "_revisions")
+}
+
+// recordRevision appends a snapshot of the recipe to its revision history
+func (m *MongoRecipeManager) recordRevision(ctx context.Context, recipe Recipe, action string, restoredFrom int) error {
+	_, err := m.revisionCollection().InsertOne(ctx, newRevision(ctx, recipe, action, restoredFrom))
+	return err
+}
+
+// newRevision makes the revision recording a change to a recipe by the actor in the
+// context
+func newRevision(ctx context.Context, recipe Recipe, action string, restoredFrom int) Revision {
+	return Revision{
+		RecipeID:     recipe.ID,
+		Number:       recipe.Revision,
+		Action:       action,
+		Author:       ActorFromContext(ctx),
+		Date:         time.Now().UTC(),
+		RestoredFrom: restoredFrom,
+		Recipe:       recipe,
+	}
+}
+
+// latestRevisions returns the number of the latest revision in the history of each of
+// the recipes with the given IDs. Recipes with no history are left out.
+func (m *MongoRecipeManager) latestRevisions(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"recipe_id": bson.M{"$in": ids}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$recipe_id", "number": bson.M{"$max": "$number"}}}},
+	}
+	cursor, err := m.revisionCollection().Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	// iterate over the cursor and decode each document into a Recipe object
-	var recipes []Recipe
+	latest := make(map[primitive.ObjectID]int)
 	for cursor.Next(ctx) {
-		var recipe Recipe
-		err := cursor.Decode(&recipe)
-		if err != nil {
+		var result struct {
+			RecipeID primitive.ObjectID `bson:"_id"`
+			Number   int                `bson:"number"`
+		}
+		if err := cursor.Decode(&result); err != nil {
 			return nil, err
 		}
-		recipes = append(recipes, recipe)
+		latest[result.RecipeID] = result.Number
 	}
+	return latest, cursor.Err()
+}
 
-	// check if there were any errors during iteration
-	if err := cursor.Err(); err != nil {
+// GetRevisions returns the revision history of the recipe with the given ID, oldest first
+func (m *MongoRecipeManager) GetRevisions(ctx context.Context, id string) ([]Revision, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find all revisions of the recipe in order, as long as the viewer can see it
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessView); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.M{"number": 1})
+	cursor, err := m.revisionCollection().Find(ctx, bson.M{"recipe_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// Decode all of the revisions
+	revisions := make([]Revision, 0)
+	if err := cursor.All(ctx, &revisions); err != nil {
 		return nil, err
 	}
 
-	// close the cursor and return the recipes
-	err = cursor.Close(ctx)
+	// A recipe that was never added has no history at all
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return revisions, nil
+}
+
+// GetRevision returns a single revision of the recipe with the given ID
+func (m *MongoRecipeManager) GetRevision(ctx context.Context, id string, number int) (Revision, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find the revision with the given number, as long as the viewer can see the recipe
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Revision{}, err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessView); err != nil {
+		return Revision{}, err
+	}
+	var revision Revision
+	filter := bson.M{"recipe_id": objID, "number": number}
+	err = m.revisionCollection().FindOne(ctx, filter).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return revision, ErrNotFound
+	}
+	if err != nil {
+		return revision, err
+	}
+
+	return revision, nil
+}
+
+// RestoreRevision makes an old revision of a recipe current again by recording it as
+// a new revision
+func (m *MongoRecipeManager) RestoreRevision(ctx context.Context, id string, number int) error {
+	// Look up the revision to restore
+	revision, err := m.GetRevision(ctx, id, number)
+	if err != nil {
+		return err
+	}
+
+	// Write the old snapshot back as the latest version of the recipe
+	return m.updateRecipe(ctx, revision.Recipe, RevisionRestored, number)
+}
+
+// GetTrash returns all recipes that have been deleted but not yet purged
+func (m *MongoRecipeManager) GetTrash(ctx context.Context) ([]Recipe, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find all deleted recipes, most recently deleted first
+	opts := options.Find().SetSort(bson.M{"deleted_at": -1})
+	filter := bson.M{"$and": []bson.M{{"deleted_at": bson.M{"$exists": true}}, viewerFilter(ctx)}}
+	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
+
+	// Decode all of the recipes
+	recipes := make([]Recipe, 0)
+	if err := cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+
 	return recipes, nil
 }
+
+// RestoreRecipe moves a recipe out of the trash
+func (m *MongoRecipeManager) RestoreRecipe(ctx context.Context, id string) error {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Clear the deletion mark, as long as the recipe is actually in the trash
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID, "deleted_at": bson.M{"$exists": true}}
+	if _, err := m.checkAccess(ctx, filter, accessEdit); err != nil {
+		return err
+	}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently removes recipes that were deleted before the given time,
+// along with their revision history, cook log, and share links, and returns how many
+// recipes were removed
+func (m *MongoRecipeManager) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	if viewer, ok := ViewerFromContext(ctx); ok && !viewer.Admin {
+		return 0, ErrForbidden
+	}
+	purged, err := m.purge(ctx, bson.M{"deleted_at": bson.M{"$lt": before}})
+	return len(purged), err
+}
+
+// PurgeRecipes permanently removes the recipes in the trash with the given IDs, along
+// with their revision history, cook log, and share links, and returns the IDs of the
+// ones that were removed
+func (m *MongoRecipeManager) PurgeRecipes(ctx context.Context, ids []string) ([]string, error) {
+	if viewer, ok := ViewerFromContext(ctx); ok && !viewer.Admin {
+		return nil, ErrForbidden
+	}
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		objIDs = append(objIDs, objID)
+	}
+	if len(objIDs) == 0 {
+		return nil, nil
+	}
+	purged, err := m.purge(ctx, bson.M{"_id": bson.M{"$in": objIDs}, "deleted_at": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	purgedIDs := make([]string, len(purged))
+	for i, id := range purged {
+		purgedIDs[i] = id.Hex()
+	}
+	return purgedIDs, nil
+}
+
+// purge permanently removes the recipes in the trash that match the filter, along with
+// their revision history, cook log, and share links, and returns the IDs of the recipes
+// that were removed
+func (m *MongoRecipeManager) purge(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find the IDs of the recipes to purge
+	ids, err := collection.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Remove the recipes, checking again that each one is still in the trash in case it
+	// was restored since they were found
+	_, err = collection.DeleteMany(ctx, bson.M{"$and": []bson.M{{"_id": bson.M{"$in": ids}}, filter}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Then the history, cook log, and share links of the ones that are really gone,
+	// leaving alone any that were restored in the meantime
+	kept, err := collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	isKept := make(map[primitive.ObjectID]bool, len(kept))
+	for _, id := range kept {
+		isKept[id.(primitive.ObjectID)] = true
+	}
+	var purged []primitive.ObjectID
+	for _, id := range ids {
+		if id := id.(primitive.ObjectID); !isKept[id] {
+			purged = append(purged, id)
+		}
+	}
+	if len(purged) == 0 {
+		return nil, nil
+	}
+	gone := bson.M{"$in": purged}
+	_, err = m.revisionCollection().DeleteMany(ctx, bson.M{"recipe_id": gone})
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.cookLogCollection().DeleteMany(ctx, bson.M{"recipe_id": gone})
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.shareLinkCollection().DeleteMany(ctx, bson.M{"recipe_id": gone})
+	if err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+// AddComment appends a comment to a recipe and returns the ID of the new comment. The
+// comment's date and author are set from the current time and the actor in the context.
+func (m *MongoRecipeManager) AddComment(ctx context.Context, recipeID string, comment Comments) (string, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Anyone who can see a recipe can comment on it
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return "", err
+	}
+	filter := bson.M{"_id": objID, "deleted_at": notDeleted}
+	if _, err := m.checkAccess(ctx, filter, accessView); err != nil {
+		return "", err
+	}
+
+	// Recipes saved without comments have a null list, which can't be pushed to, so
+	// replace it with an empty list first. This only ever touches null lists, so it
+	// can't clobber comments being added at the same time.
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "comments": nil},
+		bson.M{"$set": bson.M{"comments": bson.A{}}})
+	if err != nil {
+		return "", err
+	}
+
+	// Fill in the server-side fields and append the comment in a single operation
+	comment.ID = primitive.NewObjectID()
+	comment.Author = ActorFromContext(ctx)
+	comment.Date = time.Now().UTC().Truncate(time.Millisecond)
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"comments": comment}})
+	if err != nil {
+		return "", err
+	}
+	if result.MatchedCount == 0 {
+		return "", ErrNotFound
+	}
+
+	return comment.ID.Hex(), nil
+}
+
+// UpdateComment changes the text of an existing comment on a recipe
+func (m *MongoRecipeManager) UpdateComment(ctx context.Context, recipeID string, comment Comments) error {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Update just the text of the matching comment, leaving the others alone
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID, "comments.id": comment.ID, "deleted_at": notDeleted}
+	if err := m.checkCommentAccess(ctx, filter, comment.ID); err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"comments.$.comment": comment.Comment}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteComment removes a comment from a recipe
+func (m *MongoRecipeManager) DeleteComment(ctx context.Context, recipeID, commentID string) error {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Pull the matching comment out of the list
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return err
+	}
+	commentObjID, err := primitive.ObjectIDFromHex(commentID)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID, "comments.id": commentObjID, "deleted_at": notDeleted}
+	if err := m.checkCommentAccess(ctx, filter, commentObjID); err != nil {
+		return err
+	}
+	update := bson.M{"$pull": bson.M{"comments": bson.M{"id": commentObjID}}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Levels of access to a recipe, each of which includes the ones before it
+const (
+	accessView = iota
+	accessEdit
+	accessManage
+)
+
+// viewerFilter returns a filter that matches the recipes the viewer in the context may
+// see (see Recipe.CanView)
+func viewerFilter(ctx context.Context) bson.M {
+	viewer, ok := ViewerFromContext(ctx)
+	if !ok || viewer.Admin {
+		return bson.M{}
+	}
+
+	// Everyone can see public recipes (including those from before visibility existed)
+	visible := []bson.M{{"visibility": bson.M{"$in": bson.A{VisibilityPublic, "", nil}}}}
+
+	// Users can also see their own recipes, their household's, and ones shared with them
+	if viewer.UserID != "" {
+		visible = append(visible,
+			bson.M{"owner": viewer.UserID},
+			bson.M{"shares.user_id": viewer.UserID})
+	}
+	if viewer.HouseholdID != "" {
+		visible = append(visible,
+			bson.M{"household": viewer.HouseholdID, "visibility": VisibilityHousehold})
+	}
+
+	return bson.M{"$or": visible}
+}
+
+// checkAccess finds the recipe matching the filter and makes sure the viewer in the
+// context has the given level of access to it. Recipes the viewer can't see at all are
+// reported as not found, rather than revealing that they exist.
+func (m *MongoRecipeManager) checkAccess(ctx context.Context, filter bson.M, level int) (Recipe, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Find the recipe
+	var recipe Recipe
+	err := collection.FindOne(ctx, filter).Decode(&recipe)
+	if err == mongo.ErrNoDocuments {
+		return recipe, ErrNotFound
+	}
+	if err != nil {
+		return recipe, err
+	}
+
+	// The server itself can do anything
+	viewer, ok := ViewerFromContext(ctx)
+	if !ok {
+		return recipe, nil
+	}
+
+	// Check the viewer's access
+	switch {
+	case !recipe.CanView(viewer):
+		return recipe, ErrNotFound
+	case level >= accessEdit && !recipe.CanEdit(viewer):
+		return recipe, ErrForbidden
+	case level >= accessManage && !recipe.CanManage(viewer):
+		return recipe, ErrForbidden
+	}
+
+	return recipe, nil
+}
+
+// checkCommentAccess makes sure the viewer in the context may change a comment on the
+// recipe matching the filter: either they wrote it or they can edit the recipe
+func (m *MongoRecipeManager) checkCommentAccess(ctx context.Context, filter bson.M, commentID primitive.ObjectID) error {
+	recipe, err := m.checkAccess(ctx, filter, accessView)
+	if err != nil {
+		return err
+	}
+
+	viewer, ok := ViewerFromContext(ctx)
+	if !ok || recipe.CanEdit(viewer) {
+		return nil
+	}
+	for _, comment := range recipe.Comments {
+		if comment.ID == commentID && viewer.UserID != "" && comment.Author == ActorFromContext(ctx) {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
+
+// setOwnership makes a new recipe belong to the viewer in the context and their
+// household, and checks or defaults its visibility. Recipes added by the server itself
+// keep whatever ownership they were given.
+func setOwnership(ctx context.Context, recipe *Recipe) error {
+	viewer, ok := ViewerFromContext(ctx)
+	if ok {
+		if viewer.UserID == "" {
+			return ErrForbidden
+		}
+		recipe.Owner = viewer.UserID
+		recipe.Household = viewer.HouseholdID
+		recipe.Shares = nil
+
+		// Share with the household by default if there is one
+		if recipe.Visibility == "" && viewer.HouseholdID != "" {
+			recipe.Visibility = VisibilityHousehold
+		} else if recipe.Visibility == "" {
+			recipe.Visibility = VisibilityPrivate
+		}
+	}
+
+	if recipe.Visibility == "" {
+		return nil
+	}
+	return ValidateVisibility(recipe.Visibility)
+}
+
+// SetVisibility changes who can see a recipe
+func (m *MongoRecipeManager) SetVisibility(ctx context.Context, id, visibility string) error {
+	if err := ValidateVisibility(visibility); err != nil {
+		return err
+	}
+	return m.changeSharing(ctx, id, bson.M{"$set": bson.M{"visibility": visibility}})
+}
+
+// ShareRecipe gives a user permission to view or edit a recipe, replacing any
+// permission they had before
+func (m *MongoRecipeManager) ShareRecipe(ctx context.Context, id string, grant ShareGrant) error {
+	if err := ValidatePermission(grant.Permission); err != nil {
+		return err
+	}
+
+	// Drop the old grant and add the new one in a single update, so that two grants for
+	// the same user at once can't leave them with two. The user ID and grant are literals
+	// so that IDs starting with $ aren't read as field paths.
+	shares := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$shares", bson.A{}}},
+		"cond":  bson.M{"$ne": bson.A{"$$this.user_id", bson.M{"$literal": grant.UserID}}},
+	}}
+	return m.changeSharing(ctx, id, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"shares": bson.M{"$concatArrays": bson.A{
+			shares, bson.A{bson.M{"$literal": grant}},
+		}}}}},
+	})
+}
+
+// UnshareRecipe takes away any permission a user was given on a recipe
+func (m *MongoRecipeManager) UnshareRecipe(ctx context.Context, id, userID string) error {
+	return m.changeSharing(ctx, id, bson.M{"$pull": bson.M{"shares": bson.M{"user_id": userID}}})
+}
+
+// changeSharing applies an update (a document or a pipeline) to the sharing settings of
+// a recipe, as long as the viewer in the context is allowed to manage them
+func (m *MongoRecipeManager) changeSharing(ctx context.Context, id string, update interface{}) error {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make sure the viewer is allowed to manage the recipe
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": objID, "deleted_at": notDeleted}
+	if _, err := m.checkAccess(ctx, filter, accessManage); err != nil {
+		return err
+	}
+
+	// Recipes saved without shares have a null list, which can't be pushed to
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "shares": nil},
+		bson.M{"$set": bson.M{"shares": bson.A{}}})
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateOne(ctx, filter, update)
+	return err
+}
@@ -0,0 +1,161 @@
+package recipes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Implement the CookLog interface for the MongoDB recipe manager
+
+// cookLogCollection returns the collection holding the cook log of every recipe
+func (m *MongoRecipeManager) cookLogCollection() *mongo.Collection {
+	return m.client.Database(m.dbName).Collection(m.collectionName + "_cooklog")
+}
+
+// LogCook records that a recipe was cooked and returns the ID of the new entry
+func (m *MongoRecipeManager) LogCook(ctx context.Context, entry CookLogEntry) (string, error) {
+	// Fill in the defaults and make sure the entry is valid
+	if entry.Date.IsZero() {
+		entry.Date = time.Now().UTC()
+	}
+	entry.Date = entry.Date.Truncate(time.Millisecond)
+	if entry.Cook == "" {
+		entry.Cook = ActorFromContext(ctx)
+	}
+	if err := entry.Validate(); err != nil {
+		return "", err
+	}
+
+	// Only log cooks of recipes that exist
+	if _, err := m.GetRecipeByID(ctx, entry.RecipeID.Hex()); err != nil {
+		return "", err
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Add the entry, then bring the recipe's stats up to date
+	entry.ID = primitive.NilObjectID
+	result, err := m.cookLogCollection().InsertOne(ctx, entry)
+	if err != nil {
+		return "", err
+	}
+	if err := m.refreshCookStats(ctx, entry.RecipeID); err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetCookLog returns every time the recipe with the given ID was cooked, newest first
+func (m *MongoRecipeManager) GetCookLog(ctx context.Context, recipeID string) ([]CookLogEntry, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find all entries for the recipe, as long as the viewer can see it
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessView); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	cursor, err := m.cookLogCollection().Find(ctx, bson.M{"recipe_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// Decode all of the entries
+	entries := make([]CookLogEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DeleteCookLogEntry removes an entry from the cook log of a recipe
+func (m *MongoRecipeManager) DeleteCookLogEntry(ctx context.Context, recipeID, entryID string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Delete the entry, making sure it belongs to the given recipe and that the viewer
+	// can edit that recipe
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessEdit); err != nil {
+		return err
+	}
+	entryObjID, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		return err
+	}
+	result, err := m.cookLogCollection().DeleteOne(ctx, bson.M{"_id": entryObjID, "recipe_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	// Bring the recipe's stats up to date
+	return m.refreshCookStats(ctx, objID)
+}
+
+// refreshCookStats recomputes the stats of a recipe from its cook log. The stats are
+// stored on the recipe itself so that recipes can be sorted by them.
+func (m *MongoRecipeManager) refreshCookStats(ctx context.Context, recipeID primitive.ObjectID) error {
+	stats, err := m.cookStats(ctx, []primitive.ObjectID{recipeID})
+	if err != nil {
+		return err
+	}
+
+	// Save the stats on the recipe
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": recipeID}, bson.M{"$set": bson.M{"stats": stats[recipeID]}})
+	return err
+}
+
+// cookStats summarizes the cook logs of the recipes with the given IDs. Recipes with no
+// log entries are left out, since their stats are empty.
+func (m *MongoRecipeManager) cookStats(ctx context.Context, recipeIDs []primitive.ObjectID) (map[primitive.ObjectID]CookStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"recipe_id": bson.M{"$in": recipeIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$recipe_id",
+			"times_cooked":   bson.M{"$sum": 1},
+			"average_rating": bson.M{"$avg": "$rating"},
+			"last_cooked":    bson.M{"$max": "$date"},
+		}}},
+	}
+	cursor, err := m.cookLogCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	stats := make(map[primitive.ObjectID]CookStats)
+	for cursor.Next(ctx) {
+		var result struct {
+			RecipeID  primitive.ObjectID `bson:"_id"`
+			CookStats `bson:",inline"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		stats[result.RecipeID] = result.CookStats
+	}
+	return stats, cursor.Err()
+}
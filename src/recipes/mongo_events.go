@@ -0,0 +1,97 @@
+package recipes
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Define a MongoDB event source that follows changes to recipes using change streams
+
+// recipeChange is the part of a change stream event that Subscribe looks at
+type recipeChange struct {
+	OperationType     string              `bson:"operationType"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+	FullDocument      *Recipe             `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// Subscribe follows changes to recipes made by any server using the same database, not
+// just this one. Change streams need MongoDB to run as a replica set, so this returns
+// an error on a standalone server.
+func (m *MongoRecipeManager) Subscribe(ctx context.Context) (<-chan Event, error) {
+	// Get the collection handle
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+
+	// Watch for recipes being added and changed, along with how they look afterwards.
+	// Purged recipes were already reported when they were moved to the trash.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}}}}},
+	}
+	stream, err := collection.Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event, eventBuffer)
+	go func() {
+		defer close(events)
+		defer stream.Close(context.Background())
+
+		for stream.Next(ctx) {
+			var change recipeChange
+			if err := stream.Decode(&change); err != nil {
+				slog.ErrorContext(ctx, "failed to decode recipe change", "error", err)
+				continue
+			}
+			// The recipe may have been purged before its latest version was looked up
+			if change.FullDocument == nil {
+				continue
+			}
+
+			select {
+			case events <- change.event():
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "stopped following recipe changes", "error", err)
+		}
+	}()
+	return events, nil
+}
+
+// event converts a change to a recipe into an event. Moving a recipe to the trash sets
+// its deletion time, and restoring it removes it. The actor is only known when the
+// change set who last updated the recipe.
+func (change recipeChange) event() Event {
+	eventType := EventUpdated
+	updated := change.UpdateDescription.UpdatedFields
+	_, deleted := updated["deleted_at"]
+	_, byActor := updated["updated_by"]
+	switch {
+	case change.OperationType == "insert":
+		eventType = EventCreated
+		byActor = true
+	case deleted:
+		eventType = EventDeleted
+	case contains(change.UpdateDescription.RemovedFields, "deleted_at"):
+		eventType = EventCreated
+	}
+
+	actor := ""
+	if byActor {
+		actor = change.FullDocument.UpdatedBy
+	}
+	at := time.Unix(int64(change.ClusterTime.T), 0).UTC()
+	return NewEvent(eventType, *change.FullDocument, actor, at)
+}
@@ -0,0 +1,156 @@
+package recipes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// Implement the ShareLinks interface for the MongoDB recipe manager
+
+// shareLinkCollection returns the collection holding the share links of every recipe
+func (m *MongoRecipeManager) shareLinkCollection() *mongo.Collection {
+	return m.client.Database(m.dbName).Collection(m.collectionName + "_sharelinks")
+}
+
+// CreateShareLink creates a link to the recipe with the given ID and returns its token
+func (m *MongoRecipeManager) CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (string, ShareLink, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Only people who can manage a recipe's sharing can make it public
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return "", ShareLink{}, err
+	}
+	filter := bson.M{"_id": objID, "deleted_at": notDeleted}
+	if _, err := m.checkAccess(ctx, filter, accessManage); err != nil {
+		return "", ShareLink{}, err
+	}
+
+	// Generate the token and store its hash
+	token, err := users.NewToken()
+	if err != nil {
+		return "", ShareLink{}, err
+	}
+	link := ShareLink{
+		RecipeID:  objID,
+		TokenHash: users.HashToken(token),
+		CreatedBy: ActorFromContext(ctx),
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if expiresAt != nil {
+		expires := expiresAt.UTC().Truncate(time.Millisecond)
+		link.ExpiresAt = &expires
+	}
+	result, err := m.shareLinkCollection().InsertOne(ctx, link)
+	if err != nil {
+		return "", ShareLink{}, err
+	}
+	link.ID = result.InsertedID.(primitive.ObjectID)
+
+	return token, link, nil
+}
+
+// GetShareLinks returns the links to the recipe with the given ID, oldest first
+func (m *MongoRecipeManager) GetShareLinks(ctx context.Context, recipeID string) ([]ShareLink, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make sure the viewer can manage the recipe's sharing
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessManage); err != nil {
+		return nil, err
+	}
+
+	// Find all links to the recipe
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := m.shareLinkCollection().Find(ctx, bson.M{"recipe_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	links := make([]ShareLink, 0)
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// RevokeShareLink deletes a link to a recipe so that its token stops working
+func (m *MongoRecipeManager) RevokeShareLink(ctx context.Context, recipeID, linkID string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Make sure the viewer can manage the recipe's sharing
+	objID, err := primitive.ObjectIDFromHex(recipeID)
+	if err != nil {
+		return err
+	}
+	if _, err := m.checkAccess(ctx, bson.M{"_id": objID}, accessManage); err != nil {
+		return err
+	}
+
+	// Delete the link, making sure it belongs to the given recipe
+	linkObjID, err := primitive.ObjectIDFromHex(linkID)
+	if err != nil {
+		return err
+	}
+	result, err := m.shareLinkCollection().DeleteOne(ctx, bson.M{"_id": linkObjID, "recipe_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetSharedRecipe returns the recipe that an unexpired link token points to
+func (m *MongoRecipeManager) GetSharedRecipe(ctx context.Context, token string) (Recipe, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find the link, treating expired links as if they didn't exist
+	var link ShareLink
+	err := m.shareLinkCollection().FindOne(ctx, bson.M{"token_hash": users.HashToken(token)}).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return Recipe{}, ErrNotFound
+	}
+	if err != nil {
+		return Recipe{}, err
+	}
+	if link.Expired(time.Now()) {
+		return Recipe{}, ErrNotFound
+	}
+
+	// The link gives access to the recipe whoever the viewer is, as long as it hasn't
+	// been deleted
+	var recipe Recipe
+	collection := m.client.Database(m.dbName).Collection(m.collectionName)
+	err = collection.FindOne(ctx, bson.M{"_id": link.RecipeID, "deleted_at": notDeleted}).Decode(&recipe)
+	if err == mongo.ErrNoDocuments {
+		return Recipe{}, ErrNotFound
+	}
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	return PublicRecipe(recipe), nil
+}
@@ -17,6 +17,17 @@ type Recipe struct {
 	Steps       []string           `bson:"steps"`
 	Tags        []string           `bson:"tags"`
 	Comments    []Comments         `bson:"comments"`
+	Revision    int                `bson:"revision"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	CreatedBy   string             `bson:"created_by"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+	UpdatedBy   string             `bson:"updated_by"`
+	DeletedAt   *time.Time         `bson:"deleted_at,omitempty"`
+	Stats       CookStats          `bson:"stats"`
+	Owner       string             `bson:"owner"`
+	Household   string             `bson:"household"`
+	Visibility  string             `bson:"visibility"`
+	Shares      []ShareGrant       `bson:"shares"`
 }
 
 type Ingredient struct {
@@ -25,9 +36,10 @@ type Ingredient struct {
 }
 
 type Comments struct {
-	Comment string    `bson:"comment"`
-	Author  string    `bson:"author"`
-	Date    time.Time `bson:"date"`
+	ID      primitive.ObjectID `bson:"id"`
+	Comment string             `bson:"comment"`
+	Author  string             `bson:"author"`
+	Date    time.Time          `bson:"date"`
 }
 
 // Define functions for operating on recipes
@@ -1,21 +1,99 @@
 package recipes
 
-// Define an interface for a generic recipe manager
+import (
+	"context"
+	"time"
+)
+
+// MaxBulkRecipes is the most recipes that can be added with a single call to AddRecipes
+const MaxBulkRecipes = 1000
+
+// Define an interface for a generic recipe manager. If the context has a Viewer (see
+// WithViewer), every method only sees and changes the recipes that viewer may, and
+// returns ErrNotFound or ErrForbidden otherwise.
 type RecipeManager interface {
-	// AddRecipe adds a recipe to the recipe manager and returns the ID of the new recipe
-	AddRecipe(recipe Recipe) (string, error)
-	// DeleteRecipe deletes a recipe from the recipe manager
-	DeleteRecipe(id string) error
-	// UpdateRecipe updates a recipe in the recipe manager
-	UpdateRecipe(recipe Recipe) error
+	// AddRecipe adds a recipe to the recipe manager and returns the ID of the new recipe.
+	// The recipe becomes revision 1 of its history, and its creation and update times
+	// and authors are set from the current time and the actor in the context. It is
+	// owned by the viewer and their household.
+	AddRecipe(ctx context.Context, recipe Recipe) (string, error)
+	// AddRecipes adds several recipes at once (at most MaxBulkRecipes), as AddRecipe
+	// would add each of them, and returns their IDs in the same order. If only some of
+	// them can be added, the rest are still added: their IDs are returned along with a
+	// *BulkError, and the recipes that failed have an empty ID.
+	AddRecipes(ctx context.Context, recipes []Recipe) ([]string, error)
+	// DeleteRecipe moves a recipe to the trash. Recipes in the trash are left out of
+	// every other query until they are restored or purged.
+	DeleteRecipe(ctx context.Context, id string) error
+	// UpdateRecipe updates a recipe in the recipe manager, recording a new revision and
+	// setting its update time and author. The creation time and author are left alone,
+	// as are the comments and sharing settings (use their own methods to change those).
+	UpdateRecipe(ctx context.Context, recipe Recipe) error
 	// GetAllRecipes returns all recipes in the recipe manager
-	GetAllRecipes() ([]Recipe, error)
+	GetAllRecipes(ctx context.Context) ([]Recipe, error)
+	// CountRecipes returns how many recipes the viewer can see, leaving out the trash
+	CountRecipes(ctx context.Context) (int, error)
 	// GetRecipeByID returns a recipe with the given ID
-	GetRecipeByID(id string) (Recipe, error)
+	GetRecipeByID(ctx context.Context, id string) (Recipe, error)
+	// GetRecipesByIDs returns the recipes with the given IDs in a single lookup, in no
+	// particular order. IDs that don't match a recipe the viewer can see are left out.
+	GetRecipesByIDs(ctx context.Context, ids []string) ([]Recipe, error)
 	// GetRecipesByTags returns all recipes with the given tags
-	GetRecipesByTags(tags []string) ([]Recipe, error)
+	GetRecipesByTags(ctx context.Context, tags []string) ([]Recipe, error)
 	// GetTags returns all tags in the recipe manager
-	GetTags() ([]string, error)
+	GetTags(ctx context.Context) ([]string, error)
 	// SearchRecipes returns all recipes that match the given query string and tags
-	SearchRecipes(query string, tags []string) ([]Recipe, error)
+	SearchRecipes(ctx context.Context, query string, tags []string) ([]Recipe, error)
+	// ListRecipes returns all recipes that match the given options, in the requested order
+	ListRecipes(ctx context.Context, opts ListOptions) ([]Recipe, error)
+	// ExportRecipes calls fn with every recipe, one at a time, without loading them all
+	// into memory at once. It stops at the first error fn returns, and returns it.
+	ExportRecipes(ctx context.Context, fn func(Recipe) error) error
+	// ImportRecipes stores recipes as they are given, keeping their IDs, owners, and
+	// timestamps, and replacing any recipe with the same ID. It is for restoring backups,
+	// so only admins may use it if the context has a Viewer. Backups have no revision
+	// histories or cook logs, so each recipe is recorded as the next revision of the
+	// history of the recipe with the same ID (or its first revision, if there is none),
+	// and its Stats are worked out from the cook log already kept for it.
+	ImportRecipes(ctx context.Context, recipes []Recipe) error
+
+	// GetRevisions returns the revision history of the recipe with the given ID, oldest first
+	GetRevisions(ctx context.Context, id string) ([]Revision, error)
+	// GetRevision returns a single revision of the recipe with the given ID
+	GetRevision(ctx context.Context, id string, number int) (Revision, error)
+	// RestoreRevision makes an old revision current again by recording it as a new revision
+	RestoreRevision(ctx context.Context, id string, number int) error
+
+	// GetTrash returns all recipes that have been deleted but not yet purged
+	GetTrash(ctx context.Context) ([]Recipe, error)
+	// RestoreRecipe moves a recipe out of the trash
+	RestoreRecipe(ctx context.Context, id string) error
+	// PurgeTrash permanently removes recipes that were deleted before the given time and
+	// returns how many were removed. Only admins may use it if the context has a Viewer.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	// PurgeRecipes permanently removes the recipes in the trash with the given IDs and
+	// returns the IDs of the ones that were removed. Recipes that aren't in the trash are
+	// left alone. Only admins may use it if the context has a Viewer.
+	PurgeRecipes(ctx context.Context, ids []string) ([]string, error)
+
+	// AddComment appends a comment to a recipe and returns the ID of the new comment. The
+	// comment's date and author are set from the current time and the actor in the context.
+	AddComment(ctx context.Context, recipeID string, comment Comments) (string, error)
+	// UpdateComment changes the text of an existing comment on a recipe
+	UpdateComment(ctx context.Context, recipeID string, comment Comments) error
+	// DeleteComment removes a comment from a recipe
+	DeleteComment(ctx context.Context, recipeID, commentID string) error
+
+	// SetVisibility changes who can see a recipe
+	SetVisibility(ctx context.Context, id, visibility string) error
+	// ShareRecipe gives a user permission to view or edit a recipe, replacing any
+	// permission they had before
+	ShareRecipe(ctx context.Context, id string, grant ShareGrant) error
+	// UnshareRecipe takes away any permission a user was given on a recipe
+	UnshareRecipe(ctx context.Context, id, userID string) error
+
+	// Ping checks that the recipe manager can reach its storage
+	Ping(ctx context.Context) error
+	// Close releases the recipe manager's connections once it is no longer needed
+	Close() error
 }
@@ -0,0 +1,115 @@
+package recipes
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Define structs for the revision history of a recipe
+
+// Revision is a full snapshot of a recipe, recorded each time the recipe is changed
+type Revision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	RecipeID     primitive.ObjectID `bson:"recipe_id"`
+	Number       int                `bson:"number"`
+	Action       string             `bson:"action"`
+	Author       string             `bson:"author"`
+	Date         time.Time          `bson:"date"`
+	RestoredFrom int                `bson:"restored_from,omitempty"`
+	Recipe       Recipe             `bson:"recipe"`
+}
+
+// Actions that can create a new revision
+const (
+	RevisionCreated  = "create"
+	RevisionUpdated  = "update"
+	RevisionRestored = "restore"
+	// RevisionImported is recorded when a recipe is restored from a backup
+	RevisionImported = "import"
+)
+
+// FieldChange describes how a single field of a recipe differs between two versions.
+// For list fields, Added and Removed hold the individual items that changed.
+type FieldChange struct {
+	Field   string
+	Old     interface{}
+	New     interface{}
+	Added   []string
+	Removed []string
+}
+
+// DiffRecipes returns the changes needed to turn the old recipe into the new one
+func DiffRecipes(old, new Recipe) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	// Scalar fields are reported with their old and new values
+	if old.Name != new.Name {
+		changes = append(changes, FieldChange{Field: "Name", Old: old.Name, New: new.Name})
+	}
+	if old.Description != new.Description {
+		changes = append(changes, FieldChange{
+			Field: "Description", Old: old.Description, New: new.Description})
+	}
+
+	// List fields also report which items were added and removed
+	oldIngredients := ingredientStrings(old.Ingredients)
+	newIngredients := ingredientStrings(new.Ingredients)
+	if change, changed := diffLists("Ingredients", oldIngredients, newIngredients); changed {
+		change.Old, change.New = old.Ingredients, new.Ingredients
+		changes = append(changes, change)
+	}
+	if change, changed := diffLists("Steps", old.Steps, new.Steps); changed {
+		change.Old, change.New = old.Steps, new.Steps
+		changes = append(changes, change)
+	}
+	if change, changed := diffLists("Tags", old.Tags, new.Tags); changed {
+		change.Old, change.New = old.Tags, new.Tags
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// ingredientStrings renders each ingredient as a single "quantity name" string
+func ingredientStrings(ingredients []Ingredient) []string {
+	strs := make([]string, len(ingredients))
+	for i, ingredient := range ingredients {
+		strs[i] = ingredient.Quantity + " " + ingredient.Name
+	}
+	return strs
+}
+
+// diffLists compares two lists and reports the items added and removed (as a
+// multiset, so duplicates are counted). Reordering counts as a change with no
+// items added or removed.
+func diffLists(field string, old, new []string) (FieldChange, bool) {
+	change := FieldChange{Field: field}
+
+	// Count the items in the old list, then cancel them out against the new list
+	counts := make(map[string]int)
+	for _, item := range old {
+		counts[item]++
+	}
+	for _, item := range new {
+		if counts[item] > 0 {
+			counts[item]--
+		} else {
+			change.Added = append(change.Added, item)
+		}
+	}
+	for _, item := range old {
+		if counts[item] > 0 {
+			counts[item]--
+			change.Removed = append(change.Removed, item)
+		}
+	}
+
+	// The lists may also differ only in order
+	changed := len(change.Added) > 0 || len(change.Removed) > 0 || len(old) != len(new)
+	for i := 0; !changed && i < len(old); i++ {
+		changed = old[i] != new[i]
+	}
+
+	return change, changed
+}
@@ -0,0 +1,56 @@
+package recipes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Define public share links, which let people without an account read a single recipe
+
+// ShareLink gives anyone with its token read-only access to a recipe until it expires
+// or is revoked
+type ShareLink struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	RecipeID  primitive.ObjectID `bson:"recipe_id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedBy string             `bson:"created_by"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty"`
+}
+
+// Expired returns true if the link has an expiry time that has passed
+func (link ShareLink) Expired(now time.Time) bool {
+	return link.ExpiresAt != nil && !now.Before(*link.ExpiresAt)
+}
+
+// PublicRecipe returns a copy of the recipe with the details that only matter to the
+// library (who it belongs to, who else it is shared with, who changed it, and its
+// comments) removed, for showing to people outside the library
+func PublicRecipe(recipe Recipe) Recipe {
+	recipe.Owner = ""
+	recipe.Household = ""
+	recipe.Shares = nil
+	recipe.Comments = nil
+	recipe.CreatedBy = ""
+	recipe.UpdatedBy = ""
+	return recipe
+}
+
+// Define an interface for managing public share links. Creating, listing, and revoking
+// links needs permission to manage the recipe's sharing (see Recipe.CanManage).
+type ShareLinks interface {
+	// CreateShareLink creates a link to the recipe with the given ID, which expires at
+	// the given time (or never if it is nil), and returns the token for the link along
+	// with its details. The token is not stored, so can't be shown again.
+	CreateShareLink(ctx context.Context, recipeID string, expiresAt *time.Time) (string, ShareLink, error)
+	// GetShareLinks returns the links to the recipe with the given ID, oldest first
+	GetShareLinks(ctx context.Context, recipeID string) ([]ShareLink, error)
+	// RevokeShareLink deletes a link to a recipe so that its token stops working
+	RevokeShareLink(ctx context.Context, recipeID, linkID string) error
+	// GetSharedRecipe returns the recipe that an unexpired link token points to, with
+	// its private details removed (see PublicRecipe). Anyone can call this, whatever
+	// the viewer in the context.
+	GetSharedRecipe(ctx context.Context, token string) (Recipe, error)
+}
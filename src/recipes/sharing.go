@@ -0,0 +1,130 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+)
+
+// Define who may see and change each recipe
+
+// Visibility levels for recipes. Recipes saved before visibility existed have an
+// empty visibility, which is treated as public.
+const (
+	VisibilityPrivate   = "private"
+	VisibilityHousehold = "household"
+	VisibilityPublic    = "public"
+)
+
+// Permissions that can be granted when sharing a recipe with another user
+const (
+	PermissionView = "view"
+	PermissionEdit = "edit"
+)
+
+// ShareGrant gives a single user access to a recipe they could not otherwise see
+type ShareGrant struct {
+	UserID     string `bson:"user_id"`
+	Permission string `bson:"permission"`
+}
+
+// Viewer describes the user on whose behalf the recipe manager is being called. A
+// viewer with no UserID is an anonymous visitor.
+type Viewer struct {
+	UserID      string
+	HouseholdID string
+	Admin       bool
+}
+
+type viewerKey struct{}
+
+// WithViewer returns a copy of the context that limits the recipe manager to the
+// recipes the viewer may see and change
+func WithViewer(ctx context.Context, viewer Viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer)
+}
+
+// ViewerFromContext returns the viewer recorded in the context. If there is none, the
+// caller is the server itself (e.g. a background job), which may see everything.
+func ViewerFromContext(ctx context.Context) (Viewer, bool) {
+	viewer, ok := ctx.Value(viewerKey{}).(Viewer)
+	return viewer, ok
+}
+
+// ValidateVisibility checks that a visibility is one of the known levels
+func ValidateVisibility(visibility string) error {
+	switch visibility {
+	case VisibilityPrivate, VisibilityHousehold, VisibilityPublic:
+		return nil
+	}
+	return fmt.Errorf("invalid visibility %q: expected %s, %s, or %s",
+		visibility, VisibilityPrivate, VisibilityHousehold, VisibilityPublic)
+}
+
+// ValidatePermission checks that a permission is one of the known permissions
+func ValidatePermission(permission string) error {
+	switch permission {
+	case PermissionView, PermissionEdit:
+		return nil
+	}
+	return fmt.Errorf("invalid permission %q: expected %s or %s",
+		permission, PermissionView, PermissionEdit)
+}
+
+// CanView returns true if the viewer may see the recipe
+func (recipe *Recipe) CanView(viewer Viewer) bool {
+	switch {
+	case viewer.Admin, recipe.isOwnedBy(viewer):
+		return true
+	case recipe.Visibility == VisibilityPublic || recipe.Visibility == "":
+		return true
+	case recipe.Visibility == VisibilityHousehold && recipe.inHouseholdOf(viewer):
+		return true
+	}
+	return recipe.grantFor(viewer) != ""
+}
+
+// CanEdit returns true if the viewer may change the recipe. Only logged-in users can
+// change recipes: their own, their household's, and ones shared with them for editing.
+// Recipes left over from before recipes had owners can only be changed by admins.
+func (recipe *Recipe) CanEdit(viewer Viewer) bool {
+	switch {
+	case viewer.UserID == "":
+		return false
+	case viewer.Admin, recipe.isOwnedBy(viewer):
+		return true
+	case recipe.Visibility != VisibilityPrivate && recipe.inHouseholdOf(viewer):
+		return true
+	}
+	return recipe.grantFor(viewer) == PermissionEdit
+}
+
+// CanManage returns true if the viewer may change who can see the recipe. Only admins
+// can manage recipes that have no owner.
+func (recipe *Recipe) CanManage(viewer Viewer) bool {
+	return viewer.UserID != "" && (viewer.Admin || recipe.isOwnedBy(viewer))
+}
+
+// isOwnedBy returns true if the viewer created the recipe
+func (recipe *Recipe) isOwnedBy(viewer Viewer) bool {
+	return viewer.UserID != "" && recipe.Owner == viewer.UserID
+}
+
+// inHouseholdOf returns true if the recipe belongs to the viewer's household
+func (recipe *Recipe) inHouseholdOf(viewer Viewer) bool {
+	return viewer.HouseholdID != "" && recipe.Household == viewer.HouseholdID
+}
+
+// grantFor returns the permission the recipe has been shared with the viewer with, or
+// "" if it hasn't been shared with them
+func (recipe *Recipe) grantFor(viewer Viewer) string {
+	if viewer.UserID == "" {
+		return ""
+	}
+	permission := ""
+	for _, grant := range recipe.Shares {
+		if grant.UserID == viewer.UserID && permission != PermissionEdit {
+			permission = grant.Permission
+		}
+	}
+	return permission
+}
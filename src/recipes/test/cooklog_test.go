@@ -0,0 +1,33 @@
+package recipes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// TestCookLogEntryValidate tests the CookLogEntry.Validate function
+func TestCookLogEntryValidate(t *testing.T) {
+	// Every rating from 1 to 5 is valid
+	for rating := recipes.MinRating; rating <= recipes.MaxRating; rating++ {
+		entry := recipes.CookLogEntry{Rating: rating, Date: time.Now()}
+		if err := entry.Validate(); err != nil {
+			t.Errorf("Expected rating %d to be valid, got %v", rating, err)
+		}
+	}
+
+	// Ratings outside that range are not
+	for _, rating := range []int{0, 6, -1} {
+		entry := recipes.CookLogEntry{Rating: rating, Date: time.Now()}
+		if err := entry.Validate(); err == nil {
+			t.Errorf("Expected rating %d to be invalid", rating)
+		}
+	}
+
+	// Recipes can't be cooked in the future
+	entry := recipes.CookLogEntry{Rating: 3, Date: time.Now().Add(7 * 24 * time.Hour)}
+	if err := entry.Validate(); err == nil {
+		t.Errorf("Expected a date in the future to be invalid")
+	}
+}
@@ -0,0 +1,243 @@
+package recipes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// memoryManager keeps recipes in a map, bumping the revision on each update. Methods
+// the tests don't use panic.
+type memoryManager struct {
+	recipes.RecipeManager
+	recipes map[string]recipes.Recipe
+}
+
+func (m *memoryManager) AddRecipe(ctx context.Context, recipe recipes.Recipe) (string, error) {
+	recipe.ID = primitive.NewObjectID()
+	recipe.Revision = 1
+	m.recipes[recipe.ID.Hex()] = recipe
+	return recipe.ID.Hex(), nil
+}
+
+// AddRecipes adds each recipe in turn, failing the ones without a name
+func (m *memoryManager) AddRecipes(ctx context.Context, batch []recipes.Recipe) ([]string, error) {
+	ids := make([]string, len(batch))
+	failed := make(map[int]error)
+	for i, recipe := range batch {
+		if recipe.Name == "" {
+			failed[i] = errors.New("no name")
+			continue
+		}
+		ids[i], _ = m.AddRecipe(ctx, recipe)
+	}
+	if len(failed) > 0 {
+		return ids, &recipes.BulkError{Errors: failed}
+	}
+	return ids, nil
+}
+
+func (m *memoryManager) GetRecipesByIDs(ctx context.Context, ids []string) ([]recipes.Recipe, error) {
+	var found []recipes.Recipe
+	for _, id := range ids {
+		if recipe, ok := m.recipes[id]; ok {
+			found = append(found, recipe)
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryManager) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	recipe, ok := m.recipes[id]
+	if !ok {
+		return recipes.Recipe{}, recipes.ErrNotFound
+	}
+	return recipe, nil
+}
+
+func (m *memoryManager) UpdateRecipe(ctx context.Context, recipe recipes.Recipe) error {
+	old, ok := m.recipes[recipe.ID.Hex()]
+	if !ok {
+		return recipes.ErrNotFound
+	}
+	recipe.Revision = old.Revision + 1
+	m.recipes[recipe.ID.Hex()] = recipe
+	return nil
+}
+
+func (m *memoryManager) DeleteRecipe(ctx context.Context, id string) error {
+	if _, ok := m.recipes[id]; !ok {
+		return recipes.ErrNotFound
+	}
+	delete(m.recipes, id)
+	return nil
+}
+
+// receive waits for the next event from a subscription
+func receive(t *testing.T, events <-chan recipes.Event) recipes.Event {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Expected an event, but the subscription was closed")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return recipes.Event{}
+}
+
+// TestPublishChanges tests that changes made through the wrapped manager are published,
+// and failed changes aren't
+func TestPublishChanges(t *testing.T) {
+	bus := recipes.NewBus()
+	manager := recipes.PublishChanges(&memoryManager{recipes: make(map[string]recipes.Recipe)}, bus)
+	events, _ := bus.Subscribe(context.Background())
+	ctx := recipes.WithActor(context.Background(), "alice")
+
+	recipe := recipes.Recipe{Name: "Soup", Owner: "alice-id", Visibility: recipes.VisibilityPrivate}
+	id, err := manager.AddRecipe(ctx, recipe)
+	if err != nil {
+		t.Fatalf("Failed to add recipe: %v", err)
+	}
+	event := receive(t, events)
+	if event.Type != recipes.EventCreated || event.RecipeID != id || event.Revision != 1 || event.Actor != "alice" {
+		t.Errorf("Expected a created event for revision 1 by alice, got %+v", event)
+	}
+
+	// Updates report the revision they made
+	recipe.SetID(id)
+	recipe.Name = "Stew"
+	if err := manager.UpdateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("Failed to update recipe: %v", err)
+	}
+	if event := receive(t, events); event.Type != recipes.EventUpdated || event.Revision != 2 {
+		t.Errorf("Expected an updated event for revision 2, got %+v", event)
+	}
+
+	// Deleted recipes are reported as they were before they went
+	if err := manager.DeleteRecipe(ctx, id); err != nil {
+		t.Fatalf("Failed to delete recipe: %v", err)
+	}
+	event = receive(t, events)
+	if event.Type != recipes.EventDeleted || event.RecipeID != id || event.Revision != 2 {
+		t.Errorf("Expected a deleted event for revision 2, got %+v", event)
+	}
+
+	// Only the owner can see the private recipe the events are about
+	if !event.VisibleTo(recipes.Viewer{UserID: "alice-id"}) || event.VisibleTo(recipes.Viewer{UserID: "bob-id"}) {
+		t.Error("Expected only the owner to see events about their private recipe")
+	}
+
+	// Failed changes aren't published
+	if err := manager.DeleteRecipe(ctx, id); err == nil {
+		t.Error("Expected deleting a missing recipe to fail")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event for a failed change, got %+v", event)
+	default:
+	}
+}
+
+// TestPublishBulkChanges tests that each recipe added in bulk is published, and the
+// ones that failed aren't
+func TestPublishBulkChanges(t *testing.T) {
+	bus := recipes.NewBus()
+	manager := recipes.PublishChanges(&memoryManager{recipes: make(map[string]recipes.Recipe)}, bus)
+	events, _ := bus.Subscribe(context.Background())
+	ctx := recipes.WithActor(context.Background(), "alice")
+
+	ids, err := manager.AddRecipes(ctx, []recipes.Recipe{{Name: "Soup"}, {}, {Name: "Stew"}})
+	var bulkErr *recipes.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Errors) != 1 || bulkErr.Errors[1] == nil {
+		t.Fatalf("Expected the second recipe to fail, got %v", err)
+	}
+
+	published := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		event := receive(t, events)
+		if event.Type != recipes.EventCreated || event.Actor != "alice" {
+			t.Errorf("Expected a created event by alice, got %+v", event)
+		}
+		published[event.RecipeID] = true
+	}
+	if !published[ids[0]] || !published[ids[2]] {
+		t.Errorf("Expected events for %v, got %v", ids, published)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected no event for the failed recipe, got %+v", event)
+	default:
+	}
+}
+
+// TestBus tests that subscribers get every event until they go away or fall behind
+func TestBus(t *testing.T) {
+	bus := recipes.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	first, _ := bus.Subscribe(ctx)
+	second, _ := bus.Subscribe(context.Background())
+
+	// Everyone gets each event
+	bus.Publish(recipes.Event{Type: recipes.EventUpdated, RecipeID: "1"})
+	if receive(t, first).RecipeID != "1" || receive(t, second).RecipeID != "1" {
+		t.Error("Expected both subscribers to get the event")
+	}
+
+	// Subscriptions end with their context
+	cancel()
+	select {
+	case _, ok := <-first:
+		if ok {
+			t.Error("Expected the subscription to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for the subscription to close")
+	}
+
+	// Subscribers that never read are dropped once they fall too far behind, without
+	// holding up anyone else
+	for i := 0; i < 1000; i++ {
+		bus.Publish(recipes.Event{Type: recipes.EventUpdated})
+	}
+	closed := false
+	for !closed {
+		_, ok := <-second
+		closed = !ok
+	}
+	if bus.Dropped() != 1 {
+		t.Errorf("Expected 1 subscriber to have been dropped, got %d", bus.Dropped())
+	}
+}
+
+// TestBusReliable tests that reliable subscribers get every event in order, however far
+// behind they fall
+func TestBusReliable(t *testing.T) {
+	bus := recipes.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, _ := bus.Reliable().Subscribe(ctx)
+
+	for i := 0; i < 1000; i++ {
+		bus.Publish(recipes.Event{Type: recipes.EventCreated, Revision: i})
+	}
+	for i := 0; i < 1000; i++ {
+		if event := receive(t, events); event.Revision != i {
+			t.Fatalf("Expected event %d, got %+v", i, event)
+		}
+	}
+	if bus.Dropped() != 0 {
+		t.Errorf("Expected no subscribers to have been dropped, got %d", bus.Dropped())
+	}
+
+	// The subscription still ends with its context
+	cancel()
+	for range events {
+	}
+}
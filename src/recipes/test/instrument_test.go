@@ -0,0 +1,97 @@
+package recipes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// slowManager takes a while to get recipes, and fails to delete them
+type slowManager struct {
+	recipes.RecipeManager
+}
+
+func (m *slowManager) GetRecipeByID(ctx context.Context, id string) (recipes.Recipe, error) {
+	time.Sleep(10 * time.Millisecond)
+	return recipes.Recipe{Name: "Soup"}, nil
+}
+
+func (m *slowManager) DeleteRecipe(ctx context.Context, id string) error {
+	return recipes.ErrNotFound
+}
+
+// TestInstrument tests that an instrumented recipe manager reports every operation
+func TestInstrument(t *testing.T) {
+	type observation struct {
+		operation string
+		duration  time.Duration
+		err       error
+	}
+	var observations []observation
+	manager := recipes.Instrument(&slowManager{}, func(ctx context.Context, operation string, duration time.Duration, err error) {
+		observations = append(observations, observation{operation, duration, err})
+	})
+
+	// Results and errors should pass straight through
+	recipe, err := manager.GetRecipeByID(context.Background(), "1")
+	if err != nil || recipe.Name != "Soup" {
+		t.Fatalf("Expected the wrapped recipe, got %v, %v", recipe, err)
+	}
+	if err := manager.DeleteRecipe(context.Background(), "1"); !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected the wrapped error, got %v", err)
+	}
+
+	// And both operations should have been observed
+	if len(observations) != 2 {
+		t.Fatalf("Expected two observations, got %v", observations)
+	}
+	if observations[0].operation != "GetRecipeByID" || observations[0].duration < 10*time.Millisecond ||
+		observations[0].err != nil {
+		t.Errorf("Expected a slow, successful GetRecipeByID, got %v", observations[0])
+	}
+	if observations[1].operation != "DeleteRecipe" || !errors.Is(observations[1].err, recipes.ErrNotFound) {
+		t.Errorf("Expected a failed DeleteRecipe, got %v", observations[1])
+	}
+}
+
+// fakeLinks implements the cook log and share links, failing to revoke links
+type fakeLinks struct {
+	recipes.CookLog
+	recipes.ShareLinks
+}
+
+func (l *fakeLinks) LogCook(ctx context.Context, entry recipes.CookLogEntry) (string, error) {
+	return "cook", nil
+}
+
+func (l *fakeLinks) RevokeShareLink(ctx context.Context, recipeID, linkID string) error {
+	return recipes.ErrNotFound
+}
+
+// TestInstrumentCookLogAndShareLinks tests that the instrumented cook log and share links
+// report their operations too
+func TestInstrumentCookLogAndShareLinks(t *testing.T) {
+	var operations []string
+	var errs []error
+	observe := func(ctx context.Context, operation string, duration time.Duration, err error) {
+		operations = append(operations, operation)
+		errs = append(errs, err)
+	}
+	cookLog := recipes.InstrumentCookLog(&fakeLinks{}, observe)
+	shareLinks := recipes.InstrumentShareLinks(&fakeLinks{}, observe)
+
+	if id, err := cookLog.LogCook(context.Background(), recipes.CookLogEntry{}); err != nil || id != "cook" {
+		t.Fatalf("Expected the wrapped ID, got %q, %v", id, err)
+	}
+	if err := shareLinks.RevokeShareLink(context.Background(), "1", "2"); !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected the wrapped error, got %v", err)
+	}
+
+	if len(operations) != 2 || operations[0] != "LogCook" || errs[0] != nil ||
+		operations[1] != "RevokeShareLink" || !errors.Is(errs[1], recipes.ErrNotFound) {
+		t.Errorf("Expected a successful LogCook and a failed RevokeShareLink, got %v %v", operations, errs)
+	}
+}
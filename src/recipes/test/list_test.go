@@ -0,0 +1,41 @@
+package recipes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// TestListOptionsValidate tests the ListOptions.Validate function
+func TestListOptionsValidate(t *testing.T) {
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+
+	// The empty options, known sort keys, and forward date ranges are all valid
+	valid := []recipes.ListOptions{
+		{},
+		{SortBy: recipes.SortByName},
+		{SortBy: recipes.SortByUpdated, Descending: true},
+		{CreatedAfter: yesterday, CreatedBefore: now},
+		{UpdatedAfter: yesterday},
+		{UpdatedBefore: yesterday},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Expected options %v to be valid, got %v", opts, err)
+		}
+	}
+
+	// Unknown sort keys and backwards date ranges are not
+	invalid := []recipes.ListOptions{
+		{SortBy: "color"},
+		{CreatedAfter: now, CreatedBefore: yesterday},
+		{UpdatedAfter: now, UpdatedBefore: yesterday},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected options %v to be invalid", opts)
+		}
+	}
+}
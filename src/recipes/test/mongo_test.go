@@ -2,11 +2,14 @@ package recipes_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dawsonc/recipes/src/recipes"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -103,42 +106,190 @@ func TestAddRecipe(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Check that the recipe was added correctly
-	recipe, err := recipeManager.GetRecipeByID(recipeID)
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
 	if err != nil {
 		t.Fatalf("Failed to get test recipe: %v", err)
 	}
 	// The ID may have been updated by the database, so set it to the expected value
-	// before comparing
+	// before comparing. New recipes always start at revision 1, and their timestamps
+	// are set by the recipe manager.
 	recipe.ID = testRecipe1.ID
-	if !reflect.DeepEqual(recipe, testRecipe1) {
+	if recipe.CreatedAt.IsZero() || recipe.UpdatedAt != recipe.CreatedAt {
+		t.Fatalf("Test recipe timestamps were not set correctly: %v", recipe)
+	}
+	expected := testRecipe1
+	expected.Revision = 1
+	expected.CreatedAt, expected.UpdatedAt = recipe.CreatedAt, recipe.UpdatedAt
+	if !reflect.DeepEqual(recipe, expected) {
 		t.Fatalf("Test recipe was not added correctly")
 	}
 
 	// Add the second test recipe
-	recipeID, err = recipeManager.AddRecipe(testRecipe2)
+	recipeID, err = recipeManager.AddRecipe(context.Background(), testRecipe2)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Check that the recipe was added correctly
-	recipe, err = recipeManager.GetRecipeByID(recipeID)
+	recipe, err = recipeManager.GetRecipeByID(context.Background(), recipeID)
 	if err != nil {
 		t.Fatalf("Failed to get test recipe: %v", err)
 	}
 	// The ID may have been updated by the database, so set it to the expected value
 	// before comparing
 	recipe.ID = testRecipe1.ID
-	if !reflect.DeepEqual(recipe, testRecipe2) {
+	expected = testRecipe2
+	expected.Revision = 1
+	expected.CreatedAt, expected.UpdatedAt = recipe.CreatedAt, recipe.UpdatedAt
+	if !reflect.DeepEqual(recipe, expected) {
 		t.Fatalf("Test recipe was not added correctly")
 	}
 }
 
+// TestAddRecipes tests adding several recipes at once, and exporting them again
+func TestAddRecipes(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes together as one user, keeping one of them private
+	ctx := recipes.WithActor(recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "owner"}), "alice")
+	public, private := testRecipe1, testRecipe2
+	public.Visibility, private.Visibility = recipes.VisibilityPublic, recipes.VisibilityPrivate
+	ids, err := recipeManager.AddRecipes(ctx, []recipes.Recipe{public, private})
+	if err != nil {
+		t.Fatalf("Failed to add test recipes: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 IDs, got %v", ids)
+	}
+
+	// Each recipe should be added just as AddRecipe would, in the same order
+	for i, expected := range []recipes.Recipe{public, private} {
+		recipe, err := recipeManager.GetRecipeByID(ctx, ids[i])
+		if err != nil {
+			t.Fatalf("Failed to get test recipe: %v", err)
+		}
+		if recipe.Name != expected.Name || recipe.Revision != 1 || recipe.Owner != "owner" || recipe.CreatedBy != "alice" {
+			t.Errorf("Test recipe was not added correctly: %v", recipe)
+		}
+		revisions, err := recipeManager.GetRevisions(ctx, ids[i])
+		if err != nil || len(revisions) != 1 || revisions[0].Action != recipes.RevisionCreated {
+			t.Errorf("Expected the recipe's first revision, got %v (%v)", revisions, err)
+		}
+	}
+
+	// Too many recipes are turned away
+	if _, err := recipeManager.AddRecipes(ctx, make([]recipes.Recipe, recipes.MaxBulkRecipes+1)); err == nil {
+		t.Error("Expected too many recipes to be rejected")
+	}
+
+	// The owner's export has both recipes, in the order they were added, but other
+	// users only see the one that isn't private
+	var exported []string
+	err = recipeManager.ExportRecipes(ctx, func(recipe recipes.Recipe) error {
+		exported = append(exported, recipe.ID.Hex())
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(exported, ids) {
+		t.Errorf("Expected the owner to export %v, got %v (%v)", ids, exported, err)
+	}
+	exported = nil
+	err = recipeManager.ExportRecipes(recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "friend"}), func(recipe recipes.Recipe) error {
+		exported = append(exported, recipe.ID.Hex())
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(exported, ids[:1]) {
+		t.Errorf("Expected a friend to export %v, got %v (%v)", ids[:1], exported, err)
+	}
+
+	// Errors from the callback stop the export
+	stop := errors.New("stop")
+	err = recipeManager.ExportRecipes(ctx, func(recipe recipes.Recipe) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected the callback's error, got %v", err)
+	}
+}
+
+// TestImportRecipes tests that imported recipes are stored as they were given, start or
+// continue their revision histories, and that only admins can import them
+func TestImportRecipes(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Import a recipe as it might come from a backup
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	recipe := testRecipe1
+	recipe.ID = primitive.NewObjectID()
+	recipe.Revision, recipe.CreatedAt, recipe.CreatedBy = 4, created, "alice"
+	recipe.Owner, recipe.Visibility = "owner", recipes.VisibilityPrivate
+	recipe.Stats = recipes.CookStats{TimesCooked: 3, AverageRating: 4}
+	if err := recipeManager.ImportRecipes(context.Background(), []recipes.Recipe{recipe}); err != nil {
+		t.Fatalf("Failed to import test recipe: %v", err)
+	}
+	imported, err := recipeManager.GetRecipeByID(context.Background(), recipe.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if !imported.CreatedAt.Equal(created) || imported.Owner != "owner" || imported.CreatedBy != "alice" {
+		t.Errorf("Test recipe was not imported as it was: %v", imported)
+	}
+
+	// It has no history or cook log here, so it starts both afresh
+	if imported.Revision != 1 || imported.Stats.TimesCooked != 0 {
+		t.Errorf("Expected revision 1 with no cooks, got revision %d with %+v", imported.Revision, imported.Stats)
+	}
+	revisions, err := recipeManager.GetRevisions(context.Background(), recipe.ID.Hex())
+	if err != nil || len(revisions) != 1 || revisions[0].Action != recipes.RevisionImported {
+		t.Errorf("Expected the import to be the first revision, got %v (%v)", revisions, err)
+	}
+
+	// Importing it again replaces it rather than adding another
+	recipe.Name = "Renamed"
+	admin := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "admin", Admin: true})
+	if err := recipeManager.ImportRecipes(admin, []recipes.Recipe{recipe}); err != nil {
+		t.Fatalf("Failed to import test recipe again: %v", err)
+	}
+	all, err := recipeManager.GetAllRecipes(context.Background())
+	if err != nil || len(all) != 1 || all[0].Name != "Renamed" || all[0].Revision != 2 {
+		t.Errorf("Expected the recipe to be replaced as revision 2, got %v (%v)", all, err)
+	}
+
+	// Other users can't import recipes, and recipes need IDs
+	owner := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "owner"})
+	if err := recipeManager.ImportRecipes(owner, []recipes.Recipe{recipe}); !errors.Is(err, recipes.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden importing as a user, got %v", err)
+	}
+	if err := recipeManager.ImportRecipes(context.Background(), []recipes.Recipe{testRecipe2}); err == nil {
+		t.Error("Expected a recipe without an ID to be rejected")
+	}
+}
+
 // TestDeleteRecipe tests the DeleteRecipe function
 func TestDeleteRecipe(t *testing.T) {
 	// Set up the test database
@@ -155,19 +306,19 @@ func TestDeleteRecipe(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Delete the recipe
-	err = recipeManager.DeleteRecipe(recipeID)
+	err = recipeManager.DeleteRecipe(context.Background(), recipeID)
 	if err != nil {
 		t.Fatalf("Failed to delete test recipe: %v", err)
 	}
 
 	// Check that the recipe was deleted
-	_, err = recipeManager.GetRecipeByID(recipeID)
+	_, err = recipeManager.GetRecipeByID(context.Background(), recipeID)
 	if err == nil {
 		t.Fatalf("Test recipe was not deleted")
 	}
@@ -189,7 +340,7 @@ func TestUpdateRecipe(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
@@ -198,17 +349,23 @@ func TestUpdateRecipe(t *testing.T) {
 	var testRecipe1Copy = testRecipe1
 	testRecipe1Copy.ID, _ = primitive.ObjectIDFromHex(recipeID)
 	testRecipe1Copy.Name = "Updated Test Recipe"
-	err = recipeManager.UpdateRecipe(testRecipe1Copy)
+	err = recipeManager.UpdateRecipe(context.Background(), testRecipe1Copy)
 	if err != nil {
 		t.Fatalf("Failed to update test recipe: %v", err)
 	}
 
 	// Check that the recipe was updated
-	recipe, err := recipeManager.GetRecipeByID(recipeID)
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
 	if err != nil {
 		t.Fatalf("Failed to get test recipe: %v", err)
 	}
-	// The ID should not have been updated when updating the recipe
+	// The ID should not have been updated when updating the recipe, but the revision
+	// number and update time should have been bumped
+	if recipe.UpdatedAt.Before(recipe.CreatedAt) {
+		t.Fatalf("Test recipe update time was not set correctly: %v", recipe)
+	}
+	testRecipe1Copy.Revision = 2
+	testRecipe1Copy.CreatedAt, testRecipe1Copy.UpdatedAt = recipe.CreatedAt, recipe.UpdatedAt
 	if !reflect.DeepEqual(recipe, testRecipe1Copy) {
 		t.Fatalf("Test recipe was not updated correctly."+
 			"Expected: %v, got: %v", testRecipe1Copy, recipe)
@@ -231,19 +388,19 @@ func TestGetAllRecipes(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID1, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Add the second test recipe
-	recipeID2, err := recipeManager.AddRecipe(testRecipe2)
+	recipeID2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Get all recipes
-	recipes, err := recipeManager.GetAllRecipes()
+	recipes, err := recipeManager.GetAllRecipes(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get all recipes: %v", err)
 	}
@@ -262,6 +419,62 @@ func TestGetAllRecipes(t *testing.T) {
 	}
 }
 
+// TestGetRecipesByIDs tests the GetRecipesByIDs function
+func TestGetRecipesByIDs(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes, and a private one that only its owner can see
+	ctx := context.Background()
+	recipeID1, err := recipeManager.AddRecipe(ctx, testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	recipeID2, err := recipeManager.AddRecipe(ctx, testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	owner := recipes.WithViewer(ctx, recipes.Viewer{UserID: "owner"})
+	private := testRecipe2
+	private.Visibility = recipes.VisibilityPrivate
+	privateID, err := recipeManager.AddRecipe(owner, private)
+	if err != nil {
+		t.Fatalf("Failed to add private recipe: %v", err)
+	}
+
+	// Missing and invalid IDs are left out rather than failing the lookup
+	ids := []string{recipeID1, recipeID2, privateID, primitive.NewObjectID().Hex(), "not-an-id"}
+	found, err := recipeManager.GetRecipesByIDs(recipes.WithViewer(ctx, recipes.Viewer{UserID: "friend"}), ids)
+	if err != nil {
+		t.Fatalf("Failed to get recipes by IDs: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("Expected 2 recipes, got %d", len(found))
+	}
+
+	// The owner can see their private recipe too
+	found, err = recipeManager.GetRecipesByIDs(owner, ids)
+	if err != nil || len(found) != 3 {
+		t.Fatalf("Expected 3 recipes for the owner, got %d (%v)", len(found), err)
+	}
+
+	// Nothing to look up
+	found, err = recipeManager.GetRecipesByIDs(ctx, nil)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("Expected no recipes, got %d (%v)", len(found), err)
+	}
+}
+
 // TestGetRecipesByTags tests the GetRecipesByTags function
 func TestGetRecipesByTags(t *testing.T) {
 	// Set up the test database
@@ -278,20 +491,20 @@ func TestGetRecipesByTags(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID1, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Add the second test recipe
-	recipeID2, err := recipeManager.AddRecipe(testRecipe2)
+	recipeID2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Get recipes by a tag that both recipes have
 	tags := []string{"Test Tag 1"}
-	recipes, err := recipeManager.GetRecipesByTags(tags)
+	recipes, err := recipeManager.GetRecipesByTags(context.Background(), tags)
 	if err != nil {
 		t.Fatalf("Failed to get recipes with tags %v: %v", tags, err)
 	}
@@ -311,7 +524,7 @@ func TestGetRecipesByTags(t *testing.T) {
 
 	// Get recipes by a tag that only one recipe has
 	tags = []string{"Test Tag 2"}
-	recipes, err = recipeManager.GetRecipesByTags(tags)
+	recipes, err = recipeManager.GetRecipesByTags(context.Background(), tags)
 	if err != nil {
 		t.Fatalf("Failed to get recipes with tags %v: %v", tags, err)
 	}
@@ -341,13 +554,13 @@ func TestSearchRecipes(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	recipeID1, err := recipeManager.AddRecipe(testRecipe1)
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Add the second test recipe
-	recipeID2, err := recipeManager.AddRecipe(testRecipe2)
+	recipeID2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
@@ -355,7 +568,7 @@ func TestSearchRecipes(t *testing.T) {
 	// Search for recipes with the query "recipe" with no tags (should match both)
 	query := "recipe"
 	tags := []string{}
-	recipes, err := recipeManager.SearchRecipes(query, tags)
+	recipes, err := recipeManager.SearchRecipes(context.Background(), query, tags)
 	if err != nil {
 		t.Fatalf("Failed to search for recipes (query %v, tags %v): %v", query, tags, err)
 	}
@@ -377,7 +590,7 @@ func TestSearchRecipes(t *testing.T) {
 	// Now filter the search to only recipes with the tag "Test Tag 2" (should only
 	// match the first recipe)
 	tags = []string{"Test Tag 2"}
-	recipes, err = recipeManager.SearchRecipes(query, tags)
+	recipes, err = recipeManager.SearchRecipes(context.Background(), query, tags)
 	if err != nil {
 		t.Fatalf("Failed to search for recipes (query %v, tags %v): %v", query, tags, err)
 	}
@@ -392,6 +605,44 @@ func TestSearchRecipes(t *testing.T) {
 	}
 }
 
+// TestCountRecipes tests that CountRecipes leaves out recipes in the trash
+func TestCountRecipes(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes and move the second to the trash
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	id2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	if err := recipeManager.DeleteRecipe(context.Background(), id2); err != nil {
+		t.Fatalf("Failed to delete test recipe: %v", err)
+	}
+
+	// Only the first recipe should be counted
+	count, err := recipeManager.CountRecipes(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count recipes: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Incorrect number of recipes counted: expected %v, got %v", 1, count)
+	}
+}
+
 // TestGetTags tests the GetTags function
 func TestGetTags(t *testing.T) {
 	// Set up the test database
@@ -408,19 +659,19 @@ func TestGetTags(t *testing.T) {
 	}
 
 	// Add the first test recipe
-	_, err = recipeManager.AddRecipe(testRecipe1)
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe1)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Add the second test recipe
-	_, err = recipeManager.AddRecipe(testRecipe2)
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe2)
 	if err != nil {
 		t.Fatalf("Failed to add test recipe: %v", err)
 	}
 
 	// Get the tags
-	tags, err := recipeManager.GetTags()
+	tags, err := recipeManager.GetTags(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get tags: %v", err)
 	}
@@ -451,3 +702,966 @@ func isMember(slice []string, value string) bool {
 	}
 	return false
 }
+
+// TestGetRevisions tests that adding and updating recipes records their history
+func TestGetRevisions(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the first test recipe as one user and update it as another
+	recipeID, err := recipeManager.AddRecipe(recipes.WithActor(context.Background(), "alice"), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	var testRecipe1Copy = testRecipe1
+	testRecipe1Copy.ID, _ = primitive.ObjectIDFromHex(recipeID)
+	testRecipe1Copy.Name = "Updated Test Recipe"
+	err = recipeManager.UpdateRecipe(recipes.WithActor(context.Background(), "bob"), testRecipe1Copy)
+	if err != nil {
+		t.Fatalf("Failed to update test recipe: %v", err)
+	}
+
+	// There should be one revision for each change, oldest first
+	revisions, err := recipeManager.GetRevisions(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Incorrect number of revisions: expected %v, got %v", 2, len(revisions))
+	}
+	if revisions[0].Number != 1 || revisions[0].Action != recipes.RevisionCreated || revisions[0].Author != "alice" {
+		t.Fatalf("First revision was not recorded correctly: %v", revisions[0])
+	}
+	if revisions[1].Number != 2 || revisions[1].Action != recipes.RevisionUpdated || revisions[1].Author != "bob" {
+		t.Fatalf("Second revision was not recorded correctly: %v", revisions[1])
+	}
+
+	// Each revision should hold a full snapshot of the recipe at that point
+	if revisions[0].Recipe.Name != testRecipe1.Name {
+		t.Fatalf("Expected first snapshot to be named %v, got %v", testRecipe1.Name, revisions[0].Recipe.Name)
+	}
+	if revisions[1].Recipe.Name != testRecipe1Copy.Name {
+		t.Fatalf("Expected second snapshot to be named %v, got %v", testRecipe1Copy.Name, revisions[1].Recipe.Name)
+	}
+
+	// A single revision can also be retrieved by number
+	revision, err := recipeManager.GetRevision(context.Background(), recipeID, 2)
+	if err != nil {
+		t.Fatalf("Failed to get revision 2: %v", err)
+	}
+	if revision.Recipe.Name != testRecipe1Copy.Name {
+		t.Fatalf("Revision 2 was not retrieved correctly: %v", revision)
+	}
+
+	// Asking for a revision that does not exist should fail with ErrNotFound
+	_, err = recipeManager.GetRevision(context.Background(), recipeID, 3)
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a missing revision, got %v", err)
+	}
+}
+
+// TestRestoreRevision tests the RestoreRevision function
+func TestRestoreRevision(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the first test recipe and update it
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	var testRecipe1Copy = testRecipe1
+	testRecipe1Copy.ID, _ = primitive.ObjectIDFromHex(recipeID)
+	testRecipe1Copy.Name = "Updated Test Recipe"
+	err = recipeManager.UpdateRecipe(context.Background(), testRecipe1Copy)
+	if err != nil {
+		t.Fatalf("Failed to update test recipe: %v", err)
+	}
+
+	// Restore the original version
+	err = recipeManager.RestoreRevision(context.Background(), recipeID, 1)
+	if err != nil {
+		t.Fatalf("Failed to restore revision 1: %v", err)
+	}
+
+	// The recipe should be back to its original name, as a new revision
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if recipe.Name != testRecipe1.Name || recipe.Revision != 3 {
+		t.Fatalf("Revision 1 was not restored correctly: %v", recipe)
+	}
+
+	// The restore should be recorded in the history
+	revision, err := recipeManager.GetRevision(context.Background(), recipeID, 3)
+	if err != nil {
+		t.Fatalf("Failed to get revision 3: %v", err)
+	}
+	if revision.Action != recipes.RevisionRestored || revision.RestoredFrom != 1 {
+		t.Fatalf("Restore was not recorded correctly: %v", revision)
+	}
+}
+
+// TestTrash tests that deleted recipes are moved to the trash and can be restored
+func TestTrash(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes and delete the first
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	err = recipeManager.DeleteRecipe(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to delete test recipe: %v", err)
+	}
+
+	// The deleted recipe should be left out of listings, tag queries, and tags
+	allRecipes, err := recipeManager.GetAllRecipes(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get all recipes: %v", err)
+	}
+	if len(allRecipes) != 1 || allRecipes[0].Name != testRecipe2.Name {
+		t.Fatalf("Deleted recipe was not left out of all recipes: %v", allRecipes)
+	}
+	taggedRecipes, err := recipeManager.GetRecipesByTags(context.Background(), []string{"Test Tag 1"})
+	if err != nil {
+		t.Fatalf("Failed to get recipes by tags: %v", err)
+	}
+	if len(taggedRecipes) != 1 {
+		t.Fatalf("Deleted recipe was not left out of tag query: %v", taggedRecipes)
+	}
+	tags, err := recipeManager.GetTags(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if isMember(tags, "Test Tag 2") {
+		t.Fatalf("Tags of deleted recipe were not left out: %v", tags)
+	}
+
+	// The deleted recipe should be in the trash
+	trash, err := recipeManager.GetTrash(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID.Hex() != recipeID1 || trash[0].DeletedAt == nil {
+		t.Fatalf("Deleted recipe was not moved to the trash: %v", trash)
+	}
+
+	// Deleting it again should fail, since it is no longer a live recipe
+	err = recipeManager.DeleteRecipe(context.Background(), recipeID1)
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when deleting a trashed recipe, got %v", err)
+	}
+
+	// Restore the recipe and make sure it is back
+	err = recipeManager.RestoreRecipe(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to restore test recipe: %v", err)
+	}
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to get restored recipe: %v", err)
+	}
+	if recipe.DeletedAt != nil {
+		t.Fatalf("Restored recipe is still marked as deleted: %v", recipe)
+	}
+	trash, err = recipeManager.GetTrash(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get trash: %v", err)
+	}
+	if len(trash) != 0 {
+		t.Fatalf("Restored recipe was not removed from the trash: %v", trash)
+	}
+}
+
+// TestPurgeTrash tests the PurgeTrash function
+func TestPurgeTrash(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the first test recipe and delete it
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	err = recipeManager.DeleteRecipe(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to delete test recipe: %v", err)
+	}
+
+	// Only admins can purge the trash
+	ctx := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "alice"})
+	if _, err := recipeManager.PurgeTrash(ctx, time.Now().Add(time.Second)); !errors.Is(err, recipes.ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden, got %v", err)
+	}
+
+	// Purging recipes deleted before an hour ago should leave it alone
+	purged, err := recipeManager.PurgeTrash(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if purged != 0 {
+		t.Fatalf("Expected no recipes to be purged, got %v", purged)
+	}
+
+	// Purging everything deleted up to now should remove it and its history
+	purged, err = recipeManager.PurgeTrash(context.Background(), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("Expected 1 recipe to be purged, got %v", purged)
+	}
+	trash, err := recipeManager.GetTrash(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get trash: %v", err)
+	}
+	if len(trash) != 0 {
+		t.Fatalf("Purged recipe is still in the trash: %v", trash)
+	}
+	_, err = recipeManager.GetRevisions(context.Background(), recipeID)
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected purged recipe to have no history, got %v", err)
+	}
+}
+
+// TestPurgeRecipes tests the PurgeRecipes function
+func TestPurgeRecipes(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes and delete them
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	recipeID2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	for _, id := range []string{recipeID1, recipeID2} {
+		if err := recipeManager.DeleteRecipe(context.Background(), id); err != nil {
+			t.Fatalf("Failed to delete test recipe: %v", err)
+		}
+	}
+
+	// Only admins can purge particular recipes
+	ctx := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "alice"})
+	if _, err := recipeManager.PurgeRecipes(ctx, []string{recipeID1}); !errors.Is(err, recipes.ErrForbidden) {
+		t.Fatalf("Expected ErrForbidden, got %v", err)
+	}
+
+	// Purging the first removes it and its history, and leaves the second alone
+	purged, err := recipeManager.PurgeRecipes(context.Background(), []string{recipeID1})
+	if err != nil {
+		t.Fatalf("Failed to purge recipe: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != recipeID1 {
+		t.Fatalf("Expected only the first recipe to be purged, got %v", purged)
+	}
+	trash, err := recipeManager.GetTrash(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID.Hex() != recipeID2 {
+		t.Fatalf("Expected only the second recipe to be left in the trash, got %v", trash)
+	}
+	if _, err := recipeManager.GetRevisions(context.Background(), recipeID1); !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected purged recipe to have no history, got %v", err)
+	}
+
+	// Recipes that aren't in the trash are never purged
+	if err := recipeManager.RestoreRecipe(context.Background(), recipeID2); err != nil {
+		t.Fatalf("Failed to restore recipe: %v", err)
+	}
+	purged, err = recipeManager.PurgeRecipes(context.Background(), []string{recipeID2})
+	if err != nil || len(purged) != 0 {
+		t.Fatalf("Expected nothing to be purged, got %v and %v", purged, err)
+	}
+	if _, err := recipeManager.GetRevisions(context.Background(), recipeID2); err != nil {
+		t.Fatalf("Expected the live recipe to keep its history, got %v", err)
+	}
+}
+
+// TestRecipeAuthorship tests that recipe managers track who created and updated recipes
+func TestRecipeAuthorship(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the first test recipe as one user
+	recipeID, err := recipeManager.AddRecipe(recipes.WithActor(context.Background(), "alice"), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	created, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if created.CreatedBy != "alice" || created.UpdatedBy != "alice" {
+		t.Fatalf("Test recipe authors were not set correctly: %v", created)
+	}
+
+	// Update it as another user, trying to overwrite the creation details too
+	updated := created
+	updated.Name = "Updated Test Recipe"
+	updated.CreatedBy = "mallory"
+	updated.CreatedAt = time.Time{}
+	err = recipeManager.UpdateRecipe(recipes.WithActor(context.Background(), "bob"), updated)
+	if err != nil {
+		t.Fatalf("Failed to update test recipe: %v", err)
+	}
+
+	// Only the update details should have changed
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if recipe.CreatedBy != "alice" || !recipe.CreatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("Test recipe creation details were overwritten: %v", recipe)
+	}
+	if recipe.UpdatedBy != "bob" || recipe.UpdatedAt.Before(created.UpdatedAt) {
+		t.Fatalf("Test recipe update details were not set correctly: %v", recipe)
+	}
+}
+
+// TestListRecipes tests the ListRecipes function
+func TestListRecipes(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the two test recipes a little while apart
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	_, err = recipeManager.AddRecipe(context.Background(), testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+
+	// Sorting by creation time, newest first, should put the second recipe first
+	opts := recipes.ListOptions{SortBy: recipes.SortByCreated, Descending: true}
+	listed, err := recipeManager.ListRecipes(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Failed to list recipes (options %v): %v", opts, err)
+	}
+	if len(listed) != 2 || listed[0].Name != testRecipe2.Name || listed[1].Name != testRecipe1.Name {
+		t.Fatalf("Recipes were not sorted correctly (options %v): %v", opts, listed)
+	}
+
+	// Filtering by creation date should only find the recipe in the range
+	opts = recipes.ListOptions{CreatedAfter: between}
+	listed, err = recipeManager.ListRecipes(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Failed to list recipes (options %v): %v", opts, err)
+	}
+	if len(listed) != 1 || listed[0].Name != testRecipe2.Name {
+		t.Fatalf("Recipes were not filtered correctly (options %v): %v", opts, listed)
+	}
+	opts = recipes.ListOptions{CreatedBefore: between}
+	listed, err = recipeManager.ListRecipes(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Failed to list recipes (options %v): %v", opts, err)
+	}
+	if len(listed) != 1 || listed[0].Name != testRecipe1.Name {
+		t.Fatalf("Recipes were not filtered correctly (options %v): %v", opts, listed)
+	}
+
+	// Date filters should combine with tags
+	opts = recipes.ListOptions{Tags: []string{"Test Tag 2"}, CreatedAfter: between}
+	listed, err = recipeManager.ListRecipes(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Failed to list recipes (options %v): %v", opts, err)
+	}
+	if len(listed) != 0 {
+		t.Fatalf("Recipes were not filtered correctly (options %v): %v", opts, listed)
+	}
+
+	// Invalid options should be rejected
+	_, err = recipeManager.ListRecipes(context.Background(), recipes.ListOptions{SortBy: "color"})
+	if err == nil {
+		t.Fatalf("Expected an error when sorting by an unknown key")
+	}
+}
+
+// TestComments tests the AddComment, UpdateComment, and DeleteComment functions
+func TestComments(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add the first test recipe (which has no comments)
+	recipeID, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+
+	// Add several comments at once from different users
+	authors := []string{"alice", "bob", "carol", "dave"}
+	errs := make(chan error, len(authors))
+	for _, author := range authors {
+		go func(author string) {
+			ctx := recipes.WithActor(context.Background(), author)
+			_, err := recipeManager.AddComment(ctx, recipeID, recipes.Comments{Comment: "Tasty! -" + author})
+			errs <- err
+		}(author)
+	}
+	for range authors {
+		if err := <-errs; err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+	}
+
+	// None of the comments should have clobbered the others
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if len(recipe.Comments) != len(authors) {
+		t.Fatalf("Incorrect number of comments: expected %v, got %v", len(authors), recipe.Comments)
+	}
+	for _, comment := range recipe.Comments {
+		if comment.ID.IsZero() || comment.Date.IsZero() || comment.Comment != "Tasty! -"+comment.Author {
+			t.Fatalf("Comment was not added correctly: %v", comment)
+		}
+	}
+
+	// Updating the recipe should not touch the comments
+	recipe.Comments = nil
+	recipe.Name = "Updated Test Recipe"
+	err = recipeManager.UpdateRecipe(context.Background(), recipe)
+	if err != nil {
+		t.Fatalf("Failed to update test recipe: %v", err)
+	}
+
+	// Edit one comment and delete another
+	recipe, err = recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if len(recipe.Comments) != len(authors) {
+		t.Fatalf("Updating the recipe clobbered its comments: %v", recipe.Comments)
+	}
+	edited := recipe.Comments[0]
+	edited.Comment = "Edited"
+	err = recipeManager.UpdateComment(context.Background(), recipeID, edited)
+	if err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+	deletedID := recipe.Comments[1].ID.Hex()
+	err = recipeManager.DeleteComment(context.Background(), recipeID, deletedID)
+	if err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+
+	// Check that only those comments changed
+	recipe, err = recipeManager.GetRecipeByID(context.Background(), recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if len(recipe.Comments) != len(authors)-1 {
+		t.Fatalf("Comment was not deleted: %v", recipe.Comments)
+	}
+	if recipe.Comments[0].Comment != "Edited" || recipe.Comments[0].Author != edited.Author {
+		t.Fatalf("Comment was not updated correctly: %v", recipe.Comments[0])
+	}
+
+	// Changing a comment that no longer exists should fail with ErrNotFound
+	err = recipeManager.DeleteComment(context.Background(), recipeID, deletedID)
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when deleting a missing comment, got %v", err)
+	}
+	_, err = recipeManager.AddComment(context.Background(), primitive.NewObjectID().Hex(), recipes.Comments{Comment: "Hello"})
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when commenting on a missing recipe, got %v", err)
+	}
+}
+
+// TestCookLog tests the LogCook, GetCookLog, and DeleteCookLogEntry functions
+func TestCookLog(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add both test recipes
+	recipeID1, err := recipeManager.AddRecipe(context.Background(), testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	recipeID2, err := recipeManager.AddRecipe(context.Background(), testRecipe2)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	objID1, _ := primitive.ObjectIDFromHex(recipeID1)
+	objID2, _ := primitive.ObjectIDFromHex(recipeID2)
+
+	// Cook the first recipe twice and the second once
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour).UTC().Truncate(time.Millisecond)
+	yesterday := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Millisecond)
+	entries := []recipes.CookLogEntry{
+		{RecipeID: objID1, Date: lastWeek, Rating: 2},
+		{RecipeID: objID1, Date: yesterday, Rating: 5, Notes: "Doubled the garlic"},
+		{RecipeID: objID2, Date: yesterday, Rating: 4},
+	}
+	entryIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		entryIDs[i], err = recipeManager.LogCook(recipes.WithActor(context.Background(), "alice"), entry)
+		if err != nil {
+			t.Fatalf("Failed to log cook %v: %v", entry, err)
+		}
+	}
+
+	// The log should have both entries for the first recipe, newest first
+	cookLog, err := recipeManager.GetCookLog(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to get cook log: %v", err)
+	}
+	if len(cookLog) != 2 || cookLog[0].Notes != "Doubled the garlic" || cookLog[0].Cook != "alice" {
+		t.Fatalf("Cook log was not retrieved correctly: %v", cookLog)
+	}
+
+	// The stats on the first recipe should be up to date
+	recipe, err := recipeManager.GetRecipeByID(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if recipe.Stats.TimesCooked != 2 || recipe.Stats.AverageRating != 3.5 ||
+		recipe.Stats.LastCooked == nil || !recipe.Stats.LastCooked.Equal(yesterday) {
+		t.Fatalf("Cook stats were not computed correctly: %v", recipe.Stats)
+	}
+
+	// Sorting by rating should put the second recipe first
+	opts := recipes.ListOptions{SortBy: recipes.SortByRating, Descending: true}
+	listed, err := recipeManager.ListRecipes(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Failed to list recipes (options %v): %v", opts, err)
+	}
+	if len(listed) != 2 || listed[0].ID != objID2 {
+		t.Fatalf("Recipes were not sorted by rating: %v", listed)
+	}
+
+	// Deleting the bad review should bring the average up
+	err = recipeManager.DeleteCookLogEntry(context.Background(), recipeID1, entryIDs[0])
+	if err != nil {
+		t.Fatalf("Failed to delete cook log entry: %v", err)
+	}
+	recipe, err = recipeManager.GetRecipeByID(context.Background(), recipeID1)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if recipe.Stats.TimesCooked != 1 || recipe.Stats.AverageRating != 5 {
+		t.Fatalf("Cook stats were not updated after deleting an entry: %v", recipe.Stats)
+	}
+
+	// Entries can only be deleted through the recipe they belong to
+	err = recipeManager.DeleteCookLogEntry(context.Background(), recipeID1, entryIDs[2])
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when deleting another recipe's entry, got %v", err)
+	}
+
+	// Cooks can't be logged for recipes that don't exist
+	_, err = recipeManager.LogCook(context.Background(), recipes.CookLogEntry{RecipeID: primitive.NewObjectID(), Rating: 3})
+	if !errors.Is(err, recipes.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when logging a missing recipe, got %v", err)
+	}
+}
+
+// TestSharing tests that recipes are only visible to and editable by the right users
+func TestSharing(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Set up some viewers
+	owner := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "owner", HouseholdID: "home"})
+	housemate := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "housemate", HouseholdID: "home"})
+	friend := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "friend"})
+	anonymous := recipes.WithViewer(context.Background(), recipes.Viewer{})
+
+	// Anonymous visitors can't add recipes
+	if _, err := recipeManager.AddRecipe(anonymous, testRecipe1); !errors.Is(err, recipes.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden adding a recipe anonymously, got %v", err)
+	}
+
+	// New recipes belong to the owner and are shared with their household
+	recipeID, err := recipeManager.AddRecipe(owner, testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	recipe, err := recipeManager.GetRecipeByID(housemate, recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe as a housemate: %v", err)
+	}
+	if recipe.Owner != "owner" || recipe.Household != "home" || recipe.Visibility != recipes.VisibilityHousehold {
+		t.Errorf("Expected the recipe to belong to the owner's household, got %q, %q, %q",
+			recipe.Owner, recipe.Household, recipe.Visibility)
+	}
+
+	// Nobody outside the household can see it
+	if _, err := recipeManager.GetRecipeByID(friend, recipeID); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting the recipe as a friend, got %v", err)
+	}
+	all, err := recipeManager.GetAllRecipes(anonymous)
+	if err != nil {
+		t.Fatalf("Failed to get all recipes: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("Expected anonymous visitors to see no recipes, got %d", len(all))
+	}
+
+	// Only the owner can change who sees it
+	err = recipeManager.SetVisibility(housemate, recipeID, recipes.VisibilityPrivate)
+	if !errors.Is(err, recipes.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden changing visibility as a housemate, got %v", err)
+	}
+	err = recipeManager.SetVisibility(owner, recipeID, recipes.VisibilityPrivate)
+	if err != nil {
+		t.Fatalf("Failed to make the recipe private: %v", err)
+	}
+	if _, err := recipeManager.GetRecipeByID(housemate, recipeID); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting a private recipe as a housemate, got %v", err)
+	}
+
+	// Sharing it with a friend for viewing lets them see it but not change it
+	grant := recipes.ShareGrant{UserID: "friend", Permission: recipes.PermissionView}
+	if err := recipeManager.ShareRecipe(owner, recipeID, grant); err != nil {
+		t.Fatalf("Failed to share recipe: %v", err)
+	}
+	recipe, err = recipeManager.GetRecipeByID(friend, recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get shared recipe: %v", err)
+	}
+	recipe.Name = "Changed by a friend"
+	if err := recipeManager.UpdateRecipe(friend, recipe); !errors.Is(err, recipes.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden updating as a viewer, got %v", err)
+	}
+	if err := recipeManager.DeleteRecipe(friend, recipeID); !errors.Is(err, recipes.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden deleting as a viewer, got %v", err)
+	}
+
+	// Upgrading the share to editing lets them change it, without a second grant
+	grant.Permission = recipes.PermissionEdit
+	if err := recipeManager.ShareRecipe(owner, recipeID, grant); err != nil {
+		t.Fatalf("Failed to share recipe: %v", err)
+	}
+	if err := recipeManager.UpdateRecipe(friend, recipe); err != nil {
+		t.Fatalf("Failed to update recipe as an editor: %v", err)
+	}
+	recipe, err = recipeManager.GetRecipeByID(owner, recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if recipe.Name != "Changed by a friend" || len(recipe.Shares) != 1 {
+		t.Errorf("Expected the friend's change and one share, got %q and %v", recipe.Name, recipe.Shares)
+	}
+
+	// Granting at the same time still leaves the friend with one share
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := recipeManager.ShareRecipe(owner, recipeID, grant); err != nil {
+				t.Errorf("Failed to share recipe: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	recipe, err = recipeManager.GetRecipeByID(owner, recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get test recipe: %v", err)
+	}
+	if len(recipe.Shares) != 1 {
+		t.Errorf("Expected one share after granting at the same time, got %v", recipe.Shares)
+	}
+
+	// Unsharing takes the recipe away again
+	if err := recipeManager.UnshareRecipe(owner, recipeID, "friend"); err != nil {
+		t.Fatalf("Failed to unshare recipe: %v", err)
+	}
+	if _, err := recipeManager.GetRecipeByID(friend, recipeID); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting an unshared recipe, got %v", err)
+	}
+}
+
+// TestShareLinks tests creating, using, and revoking public share links
+func TestShareLinks(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+
+	// Add a private recipe
+	owner := recipes.WithViewer(context.Background(), recipes.Viewer{UserID: "owner"})
+	anonymous := recipes.WithViewer(context.Background(), recipes.Viewer{})
+	recipeID, err := recipeManager.AddRecipe(owner, testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+
+	// Only the owner can create links to it
+	if _, _, err := recipeManager.CreateShareLink(anonymous, recipeID, nil); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound creating a link anonymously, got %v", err)
+	}
+	token, link, err := recipeManager.CreateShareLink(owner, recipeID, nil)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+
+	// Anyone with the token can read the recipe, without seeing who owns it
+	recipe, err := recipeManager.GetSharedRecipe(anonymous, token)
+	if err != nil {
+		t.Fatalf("Failed to get shared recipe: %v", err)
+	}
+	if recipe.Name != testRecipe1.Name || recipe.Owner != "" {
+		t.Errorf("Expected the public recipe, got %v", recipe)
+	}
+	if _, err := recipeManager.GetSharedRecipe(anonymous, "guess"); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	// Expired links don't work
+	past := time.Now().Add(-time.Minute)
+	expiredToken, _, err := recipeManager.CreateShareLink(owner, recipeID, &past)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	if _, err := recipeManager.GetSharedRecipe(anonymous, expiredToken); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an expired link, got %v", err)
+	}
+
+	// Both links are listed
+	links, err := recipeManager.GetShareLinks(owner, recipeID)
+	if err != nil {
+		t.Fatalf("Failed to get share links: %v", err)
+	}
+	if len(links) != 2 || links[0].ID != link.ID {
+		t.Errorf("Expected two links, got %v", links)
+	}
+
+	// Revoked links don't work either
+	if err := recipeManager.RevokeShareLink(owner, recipeID, link.ID.Hex()); err != nil {
+		t.Fatalf("Failed to revoke share link: %v", err)
+	}
+	if _, err := recipeManager.GetSharedRecipe(anonymous, token); !errors.Is(err, recipes.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a revoked link, got %v", err)
+	}
+}
+
+// TestPing tests that a recipe manager can reach MongoDB until it is closed
+func TestPing(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager, which should be able to reach the database
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+	if err := recipeManager.Ping(context.Background()); err != nil {
+		t.Fatalf("Failed to ping database: %v", err)
+	}
+
+	// Then close it
+	if err := recipeManager.Close(); err != nil {
+		t.Fatalf("Failed to close recipe manager: %v", err)
+	}
+
+	// It should refuse to do anything else
+	if _, err := recipeManager.GetAllRecipes(context.Background()); err == nil {
+		t.Errorf("Expected an error using a closed recipe manager")
+	}
+	if err := recipeManager.Ping(context.Background()); err == nil {
+		t.Errorf("Expected an error pinging with a closed recipe manager")
+	}
+}
+
+// TestChangeStreams tests that changes to recipes are followed with change streams,
+// which only work when MongoDB runs as a replica set
+func TestChangeStreams(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new recipe manager and follow its changes
+	recipeManager, err := recipes.CreateMongoRecipeManager(testURI, testDBName, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to create recipe manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := recipeManager.Subscribe(ctx)
+	if err != nil {
+		t.Skipf("Change streams aren't available (MongoDB isn't a replica set?): %v", err)
+	}
+
+	// Add, update, delete, and restore a recipe
+	actor := recipes.WithActor(context.Background(), "alice")
+	recipeID, err := recipeManager.AddRecipe(actor, testRecipe1)
+	if err != nil {
+		t.Fatalf("Failed to add test recipe: %v", err)
+	}
+	updated := testRecipe1
+	updated.SetID(recipeID)
+	updated.Name = "Updated"
+	if err := recipeManager.UpdateRecipe(actor, updated); err != nil {
+		t.Fatalf("Failed to update test recipe: %v", err)
+	}
+	if err := recipeManager.DeleteRecipe(actor, recipeID); err != nil {
+		t.Fatalf("Failed to delete test recipe: %v", err)
+	}
+	if err := recipeManager.RestoreRecipe(actor, recipeID); err != nil {
+		t.Fatalf("Failed to restore test recipe: %v", err)
+	}
+
+	// Each change should be reported in order
+	expected := []struct {
+		eventType string
+		revision  int
+		actor     string
+	}{
+		{recipes.EventCreated, 1, "alice"},
+		{recipes.EventUpdated, 2, "alice"},
+		{recipes.EventDeleted, 2, ""},
+		{recipes.EventCreated, 2, ""},
+	}
+	for _, want := range expected {
+		select {
+		case event := <-events:
+			if event.Type != want.eventType || event.RecipeID != recipeID || event.Revision != want.revision ||
+				event.Actor != want.actor {
+				t.Errorf("Expected a %s event for revision %d by %q, got %+v",
+					want.eventType, want.revision, want.actor, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for a %s event", want.eventType)
+		}
+	}
+
+	// The subscription ends with its context
+	cancel()
+	for range events {
+	}
+}
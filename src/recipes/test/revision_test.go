@@ -0,0 +1,88 @@
+package recipes_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define recipes to compare. These are separate from the shared test recipes, since
+// other tests modify the tags of those in place.
+var oldRecipe = recipes.Recipe{
+	Name:        "Pancakes",
+	Description: "Fluffy pancakes",
+	Ingredients: []recipes.Ingredient{
+		{Name: "Flour", Quantity: "1 cup"},
+		{Name: "Milk", Quantity: "1 cup"},
+	},
+	Steps: []string{"Mix", "Fry"},
+	Tags:  []string{"Breakfast", "Sweet"},
+}
+var newRecipe = recipes.Recipe{
+	Name:        "Buttermilk Pancakes",
+	Description: "Fluffier pancakes",
+	Ingredients: []recipes.Ingredient{
+		{Name: "Flour", Quantity: "1 cup"},
+		{Name: "Buttermilk", Quantity: "1 cup"},
+	},
+	Steps: []string{"Mix", "Fry"},
+	Tags:  []string{"Breakfast", "Weekend"},
+}
+
+// TestDiffRecipes tests the DiffRecipes function
+func TestDiffRecipes(t *testing.T) {
+	// Comparing a recipe with itself should find no changes
+	changes := recipes.DiffRecipes(oldRecipe, oldRecipe)
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+
+	// Comparing two different recipes should find changes to every field but the steps
+	changes = recipes.DiffRecipes(oldRecipe, newRecipe)
+	fields := make(map[string]recipes.FieldChange)
+	for _, change := range changes {
+		fields[change.Field] = change
+	}
+	for _, field := range []string{"Name", "Description", "Ingredients", "Tags"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("Expected a change to %s, got %v", field, changes)
+		}
+	}
+	if _, ok := fields["Steps"]; ok {
+		t.Errorf("Expected no change to Steps, got %v", fields["Steps"])
+	}
+
+	// Scalar fields should report their old and new values
+	if fields["Name"].Old != oldRecipe.Name || fields["Name"].New != newRecipe.Name {
+		t.Errorf("Name change was not reported correctly: %v", fields["Name"])
+	}
+
+	// List fields should report the items that were added and removed
+	if !reflect.DeepEqual(fields["Tags"].Added, []string{"Weekend"}) {
+		t.Errorf("Expected tag \"Weekend\" to be added, got %v", fields["Tags"].Added)
+	}
+	if !reflect.DeepEqual(fields["Tags"].Removed, []string{"Sweet"}) {
+		t.Errorf("Expected tag \"Sweet\" to be removed, got %v", fields["Tags"].Removed)
+	}
+	expected := []string{"1 cup Buttermilk"}
+	if !reflect.DeepEqual(fields["Ingredients"].Added, expected) {
+		t.Errorf("Expected ingredients %v to be added, got %v", expected, fields["Ingredients"].Added)
+	}
+}
+
+// TestDiffRecipesReorder tests that DiffRecipes notices reordered steps
+func TestDiffRecipesReorder(t *testing.T) {
+	// Swap the steps of a copy of the test recipe
+	recipe := oldRecipe
+	recipe.Steps = []string{oldRecipe.Steps[1], oldRecipe.Steps[0]}
+
+	// The steps changed, but nothing was added or removed
+	changes := recipes.DiffRecipes(oldRecipe, recipe)
+	if len(changes) != 1 || changes[0].Field != "Steps" {
+		t.Fatalf("Expected a single change to Steps, got %v", changes)
+	}
+	if len(changes[0].Added) != 0 || len(changes[0].Removed) != 0 {
+		t.Errorf("Expected no steps to be added or removed, got %v", changes[0])
+	}
+}
@@ -0,0 +1,51 @@
+package recipes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// TestShareLinkExpired tests the ShareLink.Expired function
+func TestShareLinkExpired(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	if (recipes.ShareLink{}).Expired(now) {
+		t.Errorf("Expected a link without an expiry time never to expire")
+	}
+	if (recipes.ShareLink{ExpiresAt: &future}).Expired(now) {
+		t.Errorf("Expected a link expiring in the future not to have expired")
+	}
+	if !(recipes.ShareLink{ExpiresAt: &past}).Expired(now) {
+		t.Errorf("Expected a link expiring in the past to have expired")
+	}
+}
+
+// TestPublicRecipe tests the PublicRecipe function
+func TestPublicRecipe(t *testing.T) {
+	recipe := recipes.Recipe{
+		Name:      "Secret Sauce",
+		Owner:     "owner",
+		Household: "home",
+		Shares:    []recipes.ShareGrant{{UserID: "friend", Permission: recipes.PermissionView}},
+		Comments:  []recipes.Comments{{Comment: "Don't tell anyone", Author: "friend"}},
+		CreatedBy: "owner",
+		UpdatedBy: "friend",
+	}
+
+	public := recipes.PublicRecipe(recipe)
+	if public.Name != recipe.Name {
+		t.Errorf("Expected the recipe itself to be kept, got %q", public.Name)
+	}
+	if public.Owner != "" || public.Household != "" || public.Shares != nil {
+		t.Errorf("Expected ownership and shares to be removed, got %v", public)
+	}
+	if public.Comments != nil || public.CreatedBy != "" || public.UpdatedBy != "" {
+		t.Errorf("Expected comments and who changed the recipe to be removed, got %v", public)
+	}
+	if recipe.Owner != "owner" {
+		t.Errorf("Expected the original recipe to be left alone")
+	}
+}
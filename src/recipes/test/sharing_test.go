@@ -0,0 +1,84 @@
+package recipes_test
+
+import (
+	"testing"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// TestRecipePermissions tests the Recipe.CanView, CanEdit, and CanManage functions
+func TestRecipePermissions(t *testing.T) {
+	var (
+		anonymous = recipes.Viewer{}
+		owner     = recipes.Viewer{UserID: "owner", HouseholdID: "home"}
+		housemate = recipes.Viewer{UserID: "housemate", HouseholdID: "home"}
+		friend    = recipes.Viewer{UserID: "friend"}
+		editor    = recipes.Viewer{UserID: "editor"}
+		stranger  = recipes.Viewer{UserID: "stranger", HouseholdID: "elsewhere"}
+		admin     = recipes.Viewer{UserID: "admin", Admin: true}
+	)
+	shares := []recipes.ShareGrant{
+		{UserID: "friend", Permission: recipes.PermissionView},
+		{UserID: "editor", Permission: recipes.PermissionEdit},
+	}
+
+	tests := []struct {
+		name                  string
+		visibility            string
+		owner                 string
+		viewer                recipes.Viewer
+		view, edit, canManage bool
+	}{
+		{"anonymous, public", recipes.VisibilityPublic, "owner", anonymous, true, false, false},
+		{"anonymous, household", recipes.VisibilityHousehold, "owner", anonymous, false, false, false},
+		{"anonymous, legacy", "", "", anonymous, true, false, false},
+		{"owner, private", recipes.VisibilityPrivate, "owner", owner, true, true, true},
+		{"housemate, private", recipes.VisibilityPrivate, "owner", housemate, false, false, false},
+		{"housemate, household", recipes.VisibilityHousehold, "owner", housemate, true, true, false},
+		{"friend, private", recipes.VisibilityPrivate, "owner", friend, true, false, false},
+		{"editor, private", recipes.VisibilityPrivate, "owner", editor, true, true, false},
+		{"stranger, household", recipes.VisibilityHousehold, "owner", stranger, false, false, false},
+		{"stranger, public", recipes.VisibilityPublic, "owner", stranger, true, false, false},
+		{"stranger, legacy", "", "", stranger, true, false, false},
+		{"admin, legacy", "", "", admin, true, true, true},
+		{"admin, private", recipes.VisibilityPrivate, "owner", admin, true, true, true},
+	}
+	for _, test := range tests {
+		recipe := recipes.Recipe{
+			Owner:      test.owner,
+			Household:  "home",
+			Visibility: test.visibility,
+			Shares:     shares,
+		}
+		if got := recipe.CanView(test.viewer); got != test.view {
+			t.Errorf("%s: expected CanView to be %v, got %v", test.name, test.view, got)
+		}
+		if got := recipe.CanEdit(test.viewer); got != test.edit {
+			t.Errorf("%s: expected CanEdit to be %v, got %v", test.name, test.edit, got)
+		}
+		if got := recipe.CanManage(test.viewer); got != test.canManage {
+			t.Errorf("%s: expected CanManage to be %v, got %v", test.name, test.canManage, got)
+		}
+	}
+}
+
+// TestValidateVisibility tests the ValidateVisibility and ValidatePermission functions
+func TestValidateVisibility(t *testing.T) {
+	for _, visibility := range []string{recipes.VisibilityPrivate, recipes.VisibilityHousehold, recipes.VisibilityPublic} {
+		if err := recipes.ValidateVisibility(visibility); err != nil {
+			t.Errorf("Expected visibility %q to be valid, got %v", visibility, err)
+		}
+	}
+	if err := recipes.ValidateVisibility("friends"); err == nil {
+		t.Errorf("Expected visibility %q to be invalid", "friends")
+	}
+
+	for _, permission := range []string{recipes.PermissionView, recipes.PermissionEdit} {
+		if err := recipes.ValidatePermission(permission); err != nil {
+			t.Errorf("Expected permission %q to be valid, got %v", permission, err)
+		}
+	}
+	if err := recipes.ValidatePermission("own"); err == nil {
+		t.Errorf("Expected permission %q to be invalid", "own")
+	}
+}
@@ -0,0 +1,49 @@
+package recipes_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// countingPurger counts how many times the trash is purged
+type countingPurger struct {
+	recipes.RecipeManager
+	purges atomic.Int32
+}
+
+func (m *countingPurger) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	m.purges.Add(1)
+	return 0, nil
+}
+
+// TestRunTrashPurger tests that RunTrashPurger purges right away and stops when its
+// context is cancelled
+func TestRunTrashPurger(t *testing.T) {
+	manager := &countingPurger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		recipes.RunTrashPurger(ctx, manager, time.Hour, time.Hour)
+		close(done)
+	}()
+
+	// Wait for the first purge, then stop the purger
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.purges.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the purger to stop once its context was cancelled")
+	}
+	if purges := manager.purges.Load(); purges != 1 {
+		t.Errorf("Expected one purge, got %d", purges)
+	}
+}
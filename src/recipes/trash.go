@@ -0,0 +1,32 @@
+package recipes
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunTrashPurger periodically purges recipes that have been in the trash for longer
+// than the retention period. It checks once right away and then once per interval,
+// and only returns once the context is cancelled.
+func RunTrashPurger(ctx context.Context, manager RecipeManager, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Purge anything deleted before the start of the retention period
+		purged, err := manager.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge trash", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged trash", "recipes", purged)
+		}
+
+		// Wait for the next check
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
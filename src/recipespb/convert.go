@@ -0,0 +1,138 @@
+package recipespb
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define conversions between the recipes package's types and their messages
+
+// FromRecipe converts a recipe to its message
+func FromRecipe(recipe recipes.Recipe) *Recipe {
+	message := &Recipe{
+		Id:          hexID(recipe.ID),
+		Name:        recipe.Name,
+		Description: recipe.Description,
+		Steps:       recipe.Steps,
+		Tags:        recipe.Tags,
+		Revision:    int32(recipe.Revision),
+		CreatedAt:   fromTime(recipe.CreatedAt),
+		CreatedBy:   recipe.CreatedBy,
+		UpdatedAt:   fromTime(recipe.UpdatedAt),
+		UpdatedBy:   recipe.UpdatedBy,
+		Stats: &CookStats{
+			TimesCooked:   int32(recipe.Stats.TimesCooked),
+			AverageRating: recipe.Stats.AverageRating,
+		},
+		Owner:      recipe.Owner,
+		Household:  recipe.Household,
+		Visibility: recipe.Visibility,
+	}
+	if recipe.DeletedAt != nil {
+		message.DeletedAt = fromTime(*recipe.DeletedAt)
+	}
+	if recipe.Stats.LastCooked != nil {
+		message.Stats.LastCooked = fromTime(*recipe.Stats.LastCooked)
+	}
+	for _, ingredient := range recipe.Ingredients {
+		message.Ingredients = append(message.Ingredients, &Ingredient{Name: ingredient.Name, Quantity: ingredient.Quantity})
+	}
+	for _, comment := range recipe.Comments {
+		message.Comments = append(message.Comments, FromComment(comment))
+	}
+	for _, grant := range recipe.Shares {
+		message.Shares = append(message.Shares, &ShareGrant{UserId: grant.UserID, Permission: grant.Permission})
+	}
+	return message
+}
+
+// ToRecipe converts a message to a recipe. Only the fields that can be set when adding
+// or updating a recipe are kept; the rest are managed by the recipe manager. It returns
+// an error if the ID is set but isn't valid.
+func ToRecipe(message *Recipe) (recipes.Recipe, error) {
+	recipe := recipes.Recipe{
+		Name:        message.GetName(),
+		Description: message.GetDescription(),
+		Steps:       message.GetSteps(),
+		Tags:        message.GetTags(),
+		Visibility:  message.GetVisibility(),
+	}
+	if message.GetId() != "" {
+		if err := recipe.SetID(message.GetId()); err != nil {
+			return recipes.Recipe{}, err
+		}
+	}
+	for _, ingredient := range message.GetIngredients() {
+		recipe.Ingredients = append(recipe.Ingredients, recipes.Ingredient{
+			Name:     ingredient.GetName(),
+			Quantity: ingredient.GetQuantity(),
+		})
+	}
+	return recipe, nil
+}
+
+// FromComment converts a comment to its message
+func FromComment(comment recipes.Comments) *Comment {
+	return &Comment{
+		Id:      hexID(comment.ID),
+		Comment: comment.Comment,
+		Author:  comment.Author,
+		Date:    fromTime(comment.Date),
+	}
+}
+
+// ToComment converts a message to a comment, keeping only its ID and text. It returns
+// an error if the ID is set but isn't valid.
+func ToComment(message *Comment) (recipes.Comments, error) {
+	comment := recipes.Comments{Comment: message.GetComment()}
+	if message.GetId() != "" {
+		id, err := primitive.ObjectIDFromHex(message.GetId())
+		if err != nil {
+			return recipes.Comments{}, err
+		}
+		comment.ID = id
+	}
+	return comment, nil
+}
+
+// FromRevision converts a revision to its message
+func FromRevision(revision recipes.Revision) *Revision {
+	return &Revision{
+		Id:           hexID(revision.ID),
+		RecipeId:     hexID(revision.RecipeID),
+		Number:       int32(revision.Number),
+		Action:       revision.Action,
+		Author:       revision.Author,
+		Date:         fromTime(revision.Date),
+		RestoredFrom: int32(revision.RestoredFrom),
+		Recipe:       FromRecipe(revision.Recipe),
+	}
+}
+
+// ToTime converts a timestamp to a time, with unset timestamps becoming the zero time
+func ToTime(timestamp *timestamppb.Timestamp) time.Time {
+	if timestamp == nil {
+		return time.Time{}
+	}
+	return timestamp.AsTime()
+}
+
+// fromTime converts a time to a timestamp, leaving the zero time unset
+func fromTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// hexID returns the hex form of an ID, or nothing if it isn't set
+func hexID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
@@ -0,0 +1,5 @@
+// Package recipespb holds the protobuf messages and gRPC service for recipes, generated
+// from recipes.proto. Run "go generate" after changing recipes.proto.
+package recipespb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative recipes.proto
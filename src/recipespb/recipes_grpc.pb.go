@@ -0,0 +1,1125 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: recipes.proto
+
+package recipespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RecipeService_AddRecipe_FullMethodName        = "/recipes.v1.RecipeService/AddRecipe"
+	RecipeService_DeleteRecipe_FullMethodName     = "/recipes.v1.RecipeService/DeleteRecipe"
+	RecipeService_UpdateRecipe_FullMethodName     = "/recipes.v1.RecipeService/UpdateRecipe"
+	RecipeService_GetAllRecipes_FullMethodName    = "/recipes.v1.RecipeService/GetAllRecipes"
+	RecipeService_GetRecipeByID_FullMethodName    = "/recipes.v1.RecipeService/GetRecipeByID"
+	RecipeService_GetRecipesByIDs_FullMethodName  = "/recipes.v1.RecipeService/GetRecipesByIDs"
+	RecipeService_GetRecipesByTags_FullMethodName = "/recipes.v1.RecipeService/GetRecipesByTags"
+	RecipeService_GetTags_FullMethodName          = "/recipes.v1.RecipeService/GetTags"
+	RecipeService_SearchRecipes_FullMethodName    = "/recipes.v1.RecipeService/SearchRecipes"
+	RecipeService_ListRecipes_FullMethodName      = "/recipes.v1.RecipeService/ListRecipes"
+	RecipeService_GetRevisions_FullMethodName     = "/recipes.v1.RecipeService/GetRevisions"
+	RecipeService_GetRevision_FullMethodName      = "/recipes.v1.RecipeService/GetRevision"
+	RecipeService_RestoreRevision_FullMethodName  = "/recipes.v1.RecipeService/RestoreRevision"
+	RecipeService_GetTrash_FullMethodName         = "/recipes.v1.RecipeService/GetTrash"
+	RecipeService_RestoreRecipe_FullMethodName    = "/recipes.v1.RecipeService/RestoreRecipe"
+	RecipeService_PurgeTrash_FullMethodName       = "/recipes.v1.RecipeService/PurgeTrash"
+	RecipeService_AddComment_FullMethodName       = "/recipes.v1.RecipeService/AddComment"
+	RecipeService_UpdateComment_FullMethodName    = "/recipes.v1.RecipeService/UpdateComment"
+	RecipeService_DeleteComment_FullMethodName    = "/recipes.v1.RecipeService/DeleteComment"
+	RecipeService_SetVisibility_FullMethodName    = "/recipes.v1.RecipeService/SetVisibility"
+	RecipeService_ShareRecipe_FullMethodName      = "/recipes.v1.RecipeService/ShareRecipe"
+	RecipeService_UnshareRecipe_FullMethodName    = "/recipes.v1.RecipeService/UnshareRecipe"
+)
+
+// RecipeServiceClient is the client API for RecipeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RecipeServiceClient interface {
+	// AddRecipe adds a recipe owned by the user and returns its ID
+	AddRecipe(ctx context.Context, in *AddRecipeRequest, opts ...grpc.CallOption) (*AddRecipeResponse, error)
+	// DeleteRecipe moves a recipe to the trash
+	DeleteRecipe(ctx context.Context, in *DeleteRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UpdateRecipe replaces a recipe, recording a new revision
+	UpdateRecipe(ctx context.Context, in *UpdateRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetAllRecipes streams every recipe the user can see
+	GetAllRecipes(ctx context.Context, in *GetAllRecipesRequest, opts ...grpc.CallOption) (RecipeService_GetAllRecipesClient, error)
+	// GetRecipeByID returns a single recipe
+	GetRecipeByID(ctx context.Context, in *GetRecipeByIDRequest, opts ...grpc.CallOption) (*Recipe, error)
+	// GetRecipesByIDs streams the recipes with the given IDs, in no particular order,
+	// leaving out any the user can't see
+	GetRecipesByIDs(ctx context.Context, in *GetRecipesByIDsRequest, opts ...grpc.CallOption) (RecipeService_GetRecipesByIDsClient, error)
+	// GetRecipesByTags streams the recipes that have all of the given tags
+	GetRecipesByTags(ctx context.Context, in *GetRecipesByTagsRequest, opts ...grpc.CallOption) (RecipeService_GetRecipesByTagsClient, error)
+	// GetTags returns every tag on a recipe the user can see
+	GetTags(ctx context.Context, in *GetTagsRequest, opts ...grpc.CallOption) (*GetTagsResponse, error)
+	// SearchRecipes streams the recipes that match the query and have all of the tags
+	SearchRecipes(ctx context.Context, in *SearchRecipesRequest, opts ...grpc.CallOption) (RecipeService_SearchRecipesClient, error)
+	// ListRecipes streams the recipes that match the filters, in the requested order
+	ListRecipes(ctx context.Context, in *ListRecipesRequest, opts ...grpc.CallOption) (RecipeService_ListRecipesClient, error)
+	// GetRevisions streams the revision history of a recipe, oldest first
+	GetRevisions(ctx context.Context, in *GetRevisionsRequest, opts ...grpc.CallOption) (RecipeService_GetRevisionsClient, error)
+	// GetRevision returns a single revision of a recipe
+	GetRevision(ctx context.Context, in *GetRevisionRequest, opts ...grpc.CallOption) (*Revision, error)
+	// RestoreRevision makes an old revision current again
+	RestoreRevision(ctx context.Context, in *RestoreRevisionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetTrash streams the recipes in the trash
+	GetTrash(ctx context.Context, in *GetTrashRequest, opts ...grpc.CallOption) (RecipeService_GetTrashClient, error)
+	// RestoreRecipe moves a recipe out of the trash
+	RestoreRecipe(ctx context.Context, in *RestoreRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// PurgeTrash permanently removes recipes deleted before the given time. Only admins
+	// can purge the trash.
+	PurgeTrash(ctx context.Context, in *PurgeTrashRequest, opts ...grpc.CallOption) (*PurgeTrashResponse, error)
+	// AddComment adds a comment to a recipe and returns its ID
+	AddComment(ctx context.Context, in *AddCommentRequest, opts ...grpc.CallOption) (*AddCommentResponse, error)
+	// UpdateComment changes the text of a comment
+	UpdateComment(ctx context.Context, in *UpdateCommentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// DeleteComment removes a comment from a recipe
+	DeleteComment(ctx context.Context, in *DeleteCommentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// SetVisibility changes who can see a recipe
+	SetVisibility(ctx context.Context, in *SetVisibilityRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ShareRecipe lets another user view or edit a recipe
+	ShareRecipe(ctx context.Context, in *ShareRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UnshareRecipe takes away any permission a user was given on a recipe
+	UnshareRecipe(ctx context.Context, in *UnshareRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type recipeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecipeServiceClient(cc grpc.ClientConnInterface) RecipeServiceClient {
+	return &recipeServiceClient{cc}
+}
+
+func (c *recipeServiceClient) AddRecipe(ctx context.Context, in *AddRecipeRequest, opts ...grpc.CallOption) (*AddRecipeResponse, error) {
+	out := new(AddRecipeResponse)
+	err := c.cc.Invoke(ctx, RecipeService_AddRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) DeleteRecipe(ctx context.Context, in *DeleteRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_DeleteRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) UpdateRecipe(ctx context.Context, in *UpdateRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_UpdateRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) GetAllRecipes(ctx context.Context, in *GetAllRecipesRequest, opts ...grpc.CallOption) (RecipeService_GetAllRecipesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[0], RecipeService_GetAllRecipes_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceGetAllRecipesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_GetAllRecipesClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceGetAllRecipesClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceGetAllRecipesClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) GetRecipeByID(ctx context.Context, in *GetRecipeByIDRequest, opts ...grpc.CallOption) (*Recipe, error) {
+	out := new(Recipe)
+	err := c.cc.Invoke(ctx, RecipeService_GetRecipeByID_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) GetRecipesByIDs(ctx context.Context, in *GetRecipesByIDsRequest, opts ...grpc.CallOption) (RecipeService_GetRecipesByIDsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[1], RecipeService_GetRecipesByIDs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceGetRecipesByIDsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_GetRecipesByIDsClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceGetRecipesByIDsClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceGetRecipesByIDsClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) GetRecipesByTags(ctx context.Context, in *GetRecipesByTagsRequest, opts ...grpc.CallOption) (RecipeService_GetRecipesByTagsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[2], RecipeService_GetRecipesByTags_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceGetRecipesByTagsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_GetRecipesByTagsClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceGetRecipesByTagsClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceGetRecipesByTagsClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) GetTags(ctx context.Context, in *GetTagsRequest, opts ...grpc.CallOption) (*GetTagsResponse, error) {
+	out := new(GetTagsResponse)
+	err := c.cc.Invoke(ctx, RecipeService_GetTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) SearchRecipes(ctx context.Context, in *SearchRecipesRequest, opts ...grpc.CallOption) (RecipeService_SearchRecipesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[3], RecipeService_SearchRecipes_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceSearchRecipesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_SearchRecipesClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceSearchRecipesClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceSearchRecipesClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) ListRecipes(ctx context.Context, in *ListRecipesRequest, opts ...grpc.CallOption) (RecipeService_ListRecipesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[4], RecipeService_ListRecipes_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceListRecipesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_ListRecipesClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceListRecipesClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceListRecipesClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) GetRevisions(ctx context.Context, in *GetRevisionsRequest, opts ...grpc.CallOption) (RecipeService_GetRevisionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[5], RecipeService_GetRevisions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceGetRevisionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_GetRevisionsClient interface {
+	Recv() (*Revision, error)
+	grpc.ClientStream
+}
+
+type recipeServiceGetRevisionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceGetRevisionsClient) Recv() (*Revision, error) {
+	m := new(Revision)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) GetRevision(ctx context.Context, in *GetRevisionRequest, opts ...grpc.CallOption) (*Revision, error) {
+	out := new(Revision)
+	err := c.cc.Invoke(ctx, RecipeService_GetRevision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) RestoreRevision(ctx context.Context, in *RestoreRevisionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_RestoreRevision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) GetTrash(ctx context.Context, in *GetTrashRequest, opts ...grpc.CallOption) (RecipeService_GetTrashClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RecipeService_ServiceDesc.Streams[6], RecipeService_GetTrash_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipeServiceGetTrashClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RecipeService_GetTrashClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipeServiceGetTrashClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipeServiceGetTrashClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipeServiceClient) RestoreRecipe(ctx context.Context, in *RestoreRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_RestoreRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) PurgeTrash(ctx context.Context, in *PurgeTrashRequest, opts ...grpc.CallOption) (*PurgeTrashResponse, error) {
+	out := new(PurgeTrashResponse)
+	err := c.cc.Invoke(ctx, RecipeService_PurgeTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) AddComment(ctx context.Context, in *AddCommentRequest, opts ...grpc.CallOption) (*AddCommentResponse, error) {
+	out := new(AddCommentResponse)
+	err := c.cc.Invoke(ctx, RecipeService_AddComment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) UpdateComment(ctx context.Context, in *UpdateCommentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_UpdateComment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) DeleteComment(ctx context.Context, in *DeleteCommentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_DeleteComment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) SetVisibility(ctx context.Context, in *SetVisibilityRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_SetVisibility_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) ShareRecipe(ctx context.Context, in *ShareRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_ShareRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipeServiceClient) UnshareRecipe(ctx context.Context, in *UnshareRecipeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, RecipeService_UnshareRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecipeServiceServer is the server API for RecipeService service.
+// All implementations must embed UnimplementedRecipeServiceServer
+// for forward compatibility
+type RecipeServiceServer interface {
+	// AddRecipe adds a recipe owned by the user and returns its ID
+	AddRecipe(context.Context, *AddRecipeRequest) (*AddRecipeResponse, error)
+	// DeleteRecipe moves a recipe to the trash
+	DeleteRecipe(context.Context, *DeleteRecipeRequest) (*emptypb.Empty, error)
+	// UpdateRecipe replaces a recipe, recording a new revision
+	UpdateRecipe(context.Context, *UpdateRecipeRequest) (*emptypb.Empty, error)
+	// GetAllRecipes streams every recipe the user can see
+	GetAllRecipes(*GetAllRecipesRequest, RecipeService_GetAllRecipesServer) error
+	// GetRecipeByID returns a single recipe
+	GetRecipeByID(context.Context, *GetRecipeByIDRequest) (*Recipe, error)
+	// GetRecipesByIDs streams the recipes with the given IDs, in no particular order,
+	// leaving out any the user can't see
+	GetRecipesByIDs(*GetRecipesByIDsRequest, RecipeService_GetRecipesByIDsServer) error
+	// GetRecipesByTags streams the recipes that have all of the given tags
+	GetRecipesByTags(*GetRecipesByTagsRequest, RecipeService_GetRecipesByTagsServer) error
+	// GetTags returns every tag on a recipe the user can see
+	GetTags(context.Context, *GetTagsRequest) (*GetTagsResponse, error)
+	// SearchRecipes streams the recipes that match the query and have all of the tags
+	SearchRecipes(*SearchRecipesRequest, RecipeService_SearchRecipesServer) error
+	// ListRecipes streams the recipes that match the filters, in the requested order
+	ListRecipes(*ListRecipesRequest, RecipeService_ListRecipesServer) error
+	// GetRevisions streams the revision history of a recipe, oldest first
+	GetRevisions(*GetRevisionsRequest, RecipeService_GetRevisionsServer) error
+	// GetRevision returns a single revision of a recipe
+	GetRevision(context.Context, *GetRevisionRequest) (*Revision, error)
+	// RestoreRevision makes an old revision current again
+	RestoreRevision(context.Context, *RestoreRevisionRequest) (*emptypb.Empty, error)
+	// GetTrash streams the recipes in the trash
+	GetTrash(*GetTrashRequest, RecipeService_GetTrashServer) error
+	// RestoreRecipe moves a recipe out of the trash
+	RestoreRecipe(context.Context, *RestoreRecipeRequest) (*emptypb.Empty, error)
+	// PurgeTrash permanently removes recipes deleted before the given time. Only admins
+	// can purge the trash.
+	PurgeTrash(context.Context, *PurgeTrashRequest) (*PurgeTrashResponse, error)
+	// AddComment adds a comment to a recipe and returns its ID
+	AddComment(context.Context, *AddCommentRequest) (*AddCommentResponse, error)
+	// UpdateComment changes the text of a comment
+	UpdateComment(context.Context, *UpdateCommentRequest) (*emptypb.Empty, error)
+	// DeleteComment removes a comment from a recipe
+	DeleteComment(context.Context, *DeleteCommentRequest) (*emptypb.Empty, error)
+	// SetVisibility changes who can see a recipe
+	SetVisibility(context.Context, *SetVisibilityRequest) (*emptypb.Empty, error)
+	// ShareRecipe lets another user view or edit a recipe
+	ShareRecipe(context.Context, *ShareRecipeRequest) (*emptypb.Empty, error)
+	// UnshareRecipe takes away any permission a user was given on a recipe
+	UnshareRecipe(context.Context, *UnshareRecipeRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedRecipeServiceServer()
+}
+
+// UnimplementedRecipeServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRecipeServiceServer struct {
+}
+
+func (UnimplementedRecipeServiceServer) AddRecipe(context.Context, *AddRecipeRequest) (*AddRecipeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) DeleteRecipe(context.Context, *DeleteRecipeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) UpdateRecipe(context.Context, *UpdateRecipeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetAllRecipes(*GetAllRecipesRequest, RecipeService_GetAllRecipesServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetAllRecipes not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetRecipeByID(context.Context, *GetRecipeByIDRequest) (*Recipe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecipeByID not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetRecipesByIDs(*GetRecipesByIDsRequest, RecipeService_GetRecipesByIDsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetRecipesByIDs not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetRecipesByTags(*GetRecipesByTagsRequest, RecipeService_GetRecipesByTagsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetRecipesByTags not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetTags(context.Context, *GetTagsRequest) (*GetTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTags not implemented")
+}
+func (UnimplementedRecipeServiceServer) SearchRecipes(*SearchRecipesRequest, RecipeService_SearchRecipesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SearchRecipes not implemented")
+}
+func (UnimplementedRecipeServiceServer) ListRecipes(*ListRecipesRequest, RecipeService_ListRecipesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListRecipes not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetRevisions(*GetRevisionsRequest, RecipeService_GetRevisionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetRevisions not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetRevision(context.Context, *GetRevisionRequest) (*Revision, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevision not implemented")
+}
+func (UnimplementedRecipeServiceServer) RestoreRevision(context.Context, *RestoreRevisionRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreRevision not implemented")
+}
+func (UnimplementedRecipeServiceServer) GetTrash(*GetTrashRequest, RecipeService_GetTrashServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetTrash not implemented")
+}
+func (UnimplementedRecipeServiceServer) RestoreRecipe(context.Context, *RestoreRecipeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) PurgeTrash(context.Context, *PurgeTrashRequest) (*PurgeTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeTrash not implemented")
+}
+func (UnimplementedRecipeServiceServer) AddComment(context.Context, *AddCommentRequest) (*AddCommentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddComment not implemented")
+}
+func (UnimplementedRecipeServiceServer) UpdateComment(context.Context, *UpdateCommentRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateComment not implemented")
+}
+func (UnimplementedRecipeServiceServer) DeleteComment(context.Context, *DeleteCommentRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteComment not implemented")
+}
+func (UnimplementedRecipeServiceServer) SetVisibility(context.Context, *SetVisibilityRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetVisibility not implemented")
+}
+func (UnimplementedRecipeServiceServer) ShareRecipe(context.Context, *ShareRecipeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) UnshareRecipe(context.Context, *UnshareRecipeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnshareRecipe not implemented")
+}
+func (UnimplementedRecipeServiceServer) mustEmbedUnimplementedRecipeServiceServer() {}
+
+// UnsafeRecipeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecipeServiceServer will
+// result in compilation errors.
+type UnsafeRecipeServiceServer interface {
+	mustEmbedUnimplementedRecipeServiceServer()
+}
+
+func RegisterRecipeServiceServer(s grpc.ServiceRegistrar, srv RecipeServiceServer) {
+	s.RegisterService(&RecipeService_ServiceDesc, srv)
+}
+
+func _RecipeService_AddRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).AddRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_AddRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).AddRecipe(ctx, req.(*AddRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_DeleteRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).DeleteRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_DeleteRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).DeleteRecipe(ctx, req.(*DeleteRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_UpdateRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).UpdateRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_UpdateRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).UpdateRecipe(ctx, req.(*UpdateRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_GetAllRecipes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetAllRecipesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).GetAllRecipes(m, &recipeServiceGetAllRecipesServer{stream})
+}
+
+type RecipeService_GetAllRecipesServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceGetAllRecipesServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceGetAllRecipesServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_GetRecipeByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecipeByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).GetRecipeByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_GetRecipeByID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).GetRecipeByID(ctx, req.(*GetRecipeByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_GetRecipesByIDs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRecipesByIDsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).GetRecipesByIDs(m, &recipeServiceGetRecipesByIDsServer{stream})
+}
+
+type RecipeService_GetRecipesByIDsServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceGetRecipesByIDsServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceGetRecipesByIDsServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_GetRecipesByTags_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRecipesByTagsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).GetRecipesByTags(m, &recipeServiceGetRecipesByTagsServer{stream})
+}
+
+type RecipeService_GetRecipesByTagsServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceGetRecipesByTagsServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceGetRecipesByTagsServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_GetTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).GetTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_GetTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).GetTags(ctx, req.(*GetTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_SearchRecipes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRecipesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).SearchRecipes(m, &recipeServiceSearchRecipesServer{stream})
+}
+
+type RecipeService_SearchRecipesServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceSearchRecipesServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceSearchRecipesServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_ListRecipes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRecipesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).ListRecipes(m, &recipeServiceListRecipesServer{stream})
+}
+
+type RecipeService_ListRecipesServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceListRecipesServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceListRecipesServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_GetRevisions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRevisionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).GetRevisions(m, &recipeServiceGetRevisionsServer{stream})
+}
+
+type RecipeService_GetRevisionsServer interface {
+	Send(*Revision) error
+	grpc.ServerStream
+}
+
+type recipeServiceGetRevisionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceGetRevisionsServer) Send(m *Revision) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_GetRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).GetRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_GetRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).GetRevision(ctx, req.(*GetRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_RestoreRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).RestoreRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_RestoreRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).RestoreRevision(ctx, req.(*RestoreRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_GetTrash_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetTrashRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipeServiceServer).GetTrash(m, &recipeServiceGetTrashServer{stream})
+}
+
+type RecipeService_GetTrashServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipeServiceGetTrashServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipeServiceGetTrashServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RecipeService_RestoreRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).RestoreRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_RestoreRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).RestoreRecipe(ctx, req.(*RestoreRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_PurgeTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).PurgeTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_PurgeTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).PurgeTrash(ctx, req.(*PurgeTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_AddComment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCommentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).AddComment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_AddComment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).AddComment(ctx, req.(*AddCommentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_UpdateComment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCommentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).UpdateComment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_UpdateComment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).UpdateComment(ctx, req.(*UpdateCommentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_DeleteComment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCommentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).DeleteComment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_DeleteComment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).DeleteComment(ctx, req.(*DeleteCommentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_SetVisibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetVisibilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).SetVisibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_SetVisibility_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).SetVisibility(ctx, req.(*SetVisibilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_ShareRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).ShareRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_ShareRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).ShareRecipe(ctx, req.(*ShareRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecipeService_UnshareRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnshareRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipeServiceServer).UnshareRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecipeService_UnshareRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipeServiceServer).UnshareRecipe(ctx, req.(*UnshareRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RecipeService_ServiceDesc is the grpc.ServiceDesc for RecipeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RecipeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "recipes.v1.RecipeService",
+	HandlerType: (*RecipeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddRecipe",
+			Handler:    _RecipeService_AddRecipe_Handler,
+		},
+		{
+			MethodName: "DeleteRecipe",
+			Handler:    _RecipeService_DeleteRecipe_Handler,
+		},
+		{
+			MethodName: "UpdateRecipe",
+			Handler:    _RecipeService_UpdateRecipe_Handler,
+		},
+		{
+			MethodName: "GetRecipeByID",
+			Handler:    _RecipeService_GetRecipeByID_Handler,
+		},
+		{
+			MethodName: "GetTags",
+			Handler:    _RecipeService_GetTags_Handler,
+		},
+		{
+			MethodName: "GetRevision",
+			Handler:    _RecipeService_GetRevision_Handler,
+		},
+		{
+			MethodName: "RestoreRevision",
+			Handler:    _RecipeService_RestoreRevision_Handler,
+		},
+		{
+			MethodName: "RestoreRecipe",
+			Handler:    _RecipeService_RestoreRecipe_Handler,
+		},
+		{
+			MethodName: "PurgeTrash",
+			Handler:    _RecipeService_PurgeTrash_Handler,
+		},
+		{
+			MethodName: "AddComment",
+			Handler:    _RecipeService_AddComment_Handler,
+		},
+		{
+			MethodName: "UpdateComment",
+			Handler:    _RecipeService_UpdateComment_Handler,
+		},
+		{
+			MethodName: "DeleteComment",
+			Handler:    _RecipeService_DeleteComment_Handler,
+		},
+		{
+			MethodName: "SetVisibility",
+			Handler:    _RecipeService_SetVisibility_Handler,
+		},
+		{
+			MethodName: "ShareRecipe",
+			Handler:    _RecipeService_ShareRecipe_Handler,
+		},
+		{
+			MethodName: "UnshareRecipe",
+			Handler:    _RecipeService_UnshareRecipe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetAllRecipes",
+			Handler:       _RecipeService_GetAllRecipes_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetRecipesByIDs",
+			Handler:       _RecipeService_GetRecipesByIDs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetRecipesByTags",
+			Handler:       _RecipeService_GetRecipesByTags_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SearchRecipes",
+			Handler:       _RecipeService_SearchRecipes_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListRecipes",
+			Handler:       _RecipeService_ListRecipes_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetRevisions",
+			Handler:       _RecipeService_GetRevisions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetTrash",
+			Handler:       _RecipeService_GetTrash_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "recipes.proto",
+}
@@ -0,0 +1,120 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Define personal API tokens, which let scripts act as a user without their password
+
+type APIToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id"`
+	Name       string             `bson:"name"`
+	Scope      string             `bson:"scope"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty"`
+}
+
+// APITokenPrefix starts every API token, so they can be told apart from session tokens
+// (and spotted if they are leaked)
+const APITokenPrefix = "rcp_"
+
+// Scopes limit what an API token can do. Each scope includes the ones before it.
+const (
+	ScopeRead      = "read"
+	ScopeReadWrite = "read-write"
+	ScopeAdmin     = "admin"
+)
+
+// scopes lists all of the valid scopes, from least to most powerful
+var scopes = []string{ScopeRead, ScopeReadWrite, ScopeAdmin}
+
+// ErrScopeNotAllowed is returned when creating a token with more power than its user has
+var ErrScopeNotAllowed = errors.New("only admins can create admin tokens")
+
+// ValidateScope checks that a scope is one of the known scopes
+func ValidateScope(scope string) error {
+	for _, known := range scopes {
+		if scope == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid scope %q: expected one of %v", scope, scopes)
+}
+
+// ScopeAllows returns true if a token with the given scope may do something that
+// needs the required scope
+func ScopeAllows(scope, required string) bool {
+	return scopeRank(scope) >= scopeRank(required)
+}
+
+// scopeRank returns the position of a scope in scopes, or -1 if it is unknown
+func scopeRank(scope string) int {
+	for i, known := range scopes {
+		if scope == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsAPIToken returns true if the token looks like an API token rather than a session
+// token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// CreateAPIToken creates a new API token for the user, returning the token to give to
+// them (which is not stored, so can't be shown again) along with its details
+func CreateAPIToken(ctx context.Context, store UserStore, user User, name, scope string) (string, APIToken, error) {
+	if err := ValidateScope(scope); err != nil {
+		return "", APIToken{}, err
+	}
+	if scope == ScopeAdmin && !user.Admin {
+		return "", APIToken{}, ErrScopeNotAllowed
+	}
+
+	// Generate the token
+	token, err := NewToken()
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	token = APITokenPrefix + token
+
+	// Store its hash
+	apiToken := APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		Scope:     scope,
+		TokenHash: HashToken(token),
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	id, err := store.CreateAPIToken(ctx, apiToken)
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	apiToken.ID, _ = primitive.ObjectIDFromHex(id)
+
+	return token, apiToken, nil
+}
+
+// AuthenticateAPIToken returns the user that the given API token belongs to, along with
+// the token's details
+func AuthenticateAPIToken(ctx context.Context, store UserStore, token string) (User, APIToken, error) {
+	apiToken, err := store.GetAPIToken(ctx, HashToken(token))
+	if err != nil {
+		return User{}, APIToken{}, err
+	}
+	user, err := store.GetUserByID(ctx, apiToken.UserID.Hex())
+	if err != nil {
+		return User{}, APIToken{}, err
+	}
+	return user, apiToken, nil
+}
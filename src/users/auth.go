@@ -0,0 +1,104 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SessionDuration is how long a login session lasts before the user must log in again
+const SessionDuration = 30 * 24 * time.Hour
+
+// Register creates a new user with the given credentials. The first user to register
+// becomes an admin.
+func Register(ctx context.Context, store UserStore, username, password string) (User, error) {
+	// Check the credentials before doing anything with them
+	if err := ValidateUsername(username); err != nil {
+		return User{}, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return User{}, err
+	}
+
+	// Hash the password for storage
+	hash, err := HashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	// Only registrations on an empty store can become the first admin
+	count, err := store.CountUsers(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	// Add the user
+	user := User{
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC().Truncate(time.Millisecond),
+	}
+	id, err := store.CreateUser(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+
+	// Registrations at the same time can all see an empty store, so the store decides
+	// which one of them is the first admin
+	if count == 0 {
+		if _, err := store.ClaimFirstAdmin(ctx, id); err != nil {
+			return User{}, err
+		}
+	}
+
+	return store.GetUserByID(ctx, id)
+}
+
+// Login checks a user's credentials and starts a new session, returning the session
+// token to give to the client
+func Login(ctx context.Context, store UserStore, username, password string) (string, User, error) {
+	// Look up the user, treating unknown users the same as wrong passwords so that
+	// logins don't reveal which usernames exist
+	user, err := store.GetUserByUsername(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		return "", User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", User{}, err
+	}
+	if !CheckPassword(user.PasswordHash, password) {
+		return "", User{}, ErrInvalidCredentials
+	}
+
+	// Start a session
+	token, err := NewToken()
+	if err != nil {
+		return "", User{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	session := Session{
+		TokenHash: HashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionDuration),
+	}
+	if err := store.CreateSession(ctx, session); err != nil {
+		return "", User{}, err
+	}
+
+	return token, user, nil
+}
+
+// Logout ends the session with the given token
+func Logout(ctx context.Context, store UserStore, token string) error {
+	return store.DeleteSession(ctx, HashToken(token))
+}
+
+// Authenticate returns the user that the given session token belongs to
+func Authenticate(ctx context.Context, store UserStore, token string) (User, error) {
+	session, err := store.GetSession(ctx, HashToken(token))
+	if err != nil {
+		return User{}, err
+	}
+	return store.GetUserByID(ctx, session.UserID.Hex())
+}
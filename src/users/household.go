@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+	"time"
+)
+
+// Define functions for grouping users into households, whose members share recipes
+
+// InHousehold returns true if the user belongs to a household
+func (user User) InHousehold() bool {
+	return !user.HouseholdID.IsZero()
+}
+
+// CreateHousehold creates a new household with the given name and makes the user its
+// first member. Returns ErrInHousehold if the user already belongs to one.
+func CreateHousehold(ctx context.Context, store UserStore, user User, name string) (Household, error) {
+	if user.InHousehold() {
+		return Household{}, ErrInHousehold
+	}
+
+	// Add the household
+	household := Household{
+		Name:      name,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	id, err := store.CreateHousehold(ctx, household)
+	if err != nil {
+		return Household{}, err
+	}
+
+	// Move the user into it
+	if err := store.SetUserHousehold(ctx, user.ID.Hex(), id); err != nil {
+		return Household{}, err
+	}
+
+	return store.GetHousehold(ctx, id)
+}
+
+// InviteHouseholdMember invites the user with the given username to join the household.
+// They only join once they accept (see AcceptInvitation). Returns ErrInHousehold if they
+// already belong to a household, and ErrAlreadyInvited if this one has already invited
+// them.
+func InviteHouseholdMember(ctx context.Context, store UserStore, inviter User, username string) (Invitation, error) {
+	member, err := store.GetUserByUsername(ctx, username)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if member.InHousehold() {
+		return Invitation{}, ErrInHousehold
+	}
+	household, err := store.GetHousehold(ctx, inviter.HouseholdID.Hex())
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	invitation := Invitation{
+		HouseholdID:   household.ID,
+		HouseholdName: household.Name,
+		UserID:        member.ID,
+		InvitedBy:     inviter.Username,
+		CreatedAt:     time.Now().UTC().Truncate(time.Millisecond),
+	}
+	id, err := store.CreateInvitation(ctx, invitation)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	return store.GetInvitation(ctx, id)
+}
+
+// AcceptInvitation moves the user into the household that sent them the invitation with
+// the given ID. Returns ErrNotFound if the invitation wasn't sent to them (or its
+// household no longer exists), and ErrInHousehold if they are already in a household.
+func AcceptInvitation(ctx context.Context, store UserStore, user User, invitationID string) (Household, error) {
+	invitation, err := ownInvitation(ctx, store, user, invitationID)
+	if err != nil {
+		return Household{}, err
+	}
+	if user.InHousehold() {
+		return Household{}, ErrInHousehold
+	}
+	household, err := store.GetHousehold(ctx, invitation.HouseholdID.Hex())
+	if err != nil {
+		return Household{}, err
+	}
+
+	if err := store.SetUserHousehold(ctx, user.ID.Hex(), household.ID.Hex()); err != nil {
+		return Household{}, err
+	}
+	if err := store.DeleteInvitation(ctx, invitationID); err != nil {
+		return Household{}, err
+	}
+
+	return household, nil
+}
+
+// DeclineInvitation removes an invitation sent to the user. Returns ErrNotFound if it
+// wasn't sent to them.
+func DeclineInvitation(ctx context.Context, store UserStore, user User, invitationID string) error {
+	if _, err := ownInvitation(ctx, store, user, invitationID); err != nil {
+		return err
+	}
+	return store.DeleteInvitation(ctx, invitationID)
+}
+
+// ownInvitation returns the invitation with the given ID if it was sent to the user, and
+// ErrNotFound otherwise
+func ownInvitation(ctx context.Context, store UserStore, user User, invitationID string) (Invitation, error) {
+	invitation, err := store.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if invitation.UserID != user.ID {
+		return Invitation{}, ErrNotFound
+	}
+	return invitation, nil
+}
+
+// RemoveHouseholdMember takes the user with the given ID out of the household. Returns
+// ErrNotFound if they are not a member of it.
+func RemoveHouseholdMember(ctx context.Context, store UserStore, householdID, userID string) error {
+	member, err := store.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if member.HouseholdID.Hex() != householdID {
+		return ErrNotFound
+	}
+
+	return store.SetUserHousehold(ctx, userID, "")
+}
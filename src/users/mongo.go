@@ -0,0 +1,616 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Define a MongoDB user store that implements the UserStore interface
+type MongoUserStore struct {
+	client *mongo.Client
+	dbName string
+}
+
+// CreateMongoUserStore creates a new MongoDB user store, keeping users, households,
+// household invitations, API tokens, and sessions in the "users", "households",
+// "household_invitations", "api_tokens", and "sessions" collections of the given
+// database, and who became the first admin in "bootstrap"
+func CreateMongoUserStore(uri, dbName string) (*MongoUserStore, error) {
+	// Set client options
+	clientOptions := options.Client().ApplyURI(uri)
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the connection, letting go of the client if it doesn't work
+	err = client.Ping(context.Background(), nil)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	// Create a new user store
+	userStore := &MongoUserStore{
+		client: client,
+		dbName: dbName,
+	}
+
+	// Make sure usernames are unique, and let MongoDB clean up expired sessions
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = userStore.users().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = userStore.invitations().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "household_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = userStore.apiTokens().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"token_hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = userStore.sessions().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userStore, nil
+}
+
+// Close disconnects from MongoDB, waiting for operations in progress to finish. The
+// user store can't be used afterwards.
+func (s *MongoUserStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.client.Disconnect(ctx)
+}
+
+// users returns the collection holding users
+func (s *MongoUserStore) users() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("users")
+}
+
+// households returns the collection holding households
+func (s *MongoUserStore) households() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("households")
+}
+
+// invitations returns the collection holding invitations to join households
+func (s *MongoUserStore) invitations() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("household_invitations")
+}
+
+// apiTokens returns the collection holding API tokens
+func (s *MongoUserStore) apiTokens() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("api_tokens")
+}
+
+// bootstrap returns the collection recording one-off setup, such as who became the
+// first admin
+func (s *MongoUserStore) bootstrap() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("bootstrap")
+}
+
+// sessions returns the collection holding login sessions
+func (s *MongoUserStore) sessions() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("sessions")
+}
+
+// CreateUser adds a user to the store and returns the ID of the new user
+func (s *MongoUserStore) CreateUser(ctx context.Context, user User) (string, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Add the user, relying on the unique index to catch duplicate usernames
+	user.ID = primitive.NilObjectID
+	result, err := s.users().InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrUsernameTaken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetUserByID returns the user with the given ID
+func (s *MongoUserStore) GetUserByID(ctx context.Context, id string) (User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return User{}, err
+	}
+	return s.findUser(ctx, bson.M{"_id": objID})
+}
+
+// GetUserByUsername returns the user with the given username
+func (s *MongoUserStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	return s.findUser(ctx, bson.M{"username": username})
+}
+
+// findUser returns the single user matching the filter
+func (s *MongoUserStore) findUser(ctx context.Context, filter bson.M) (User, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := s.users().FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// CountUsers returns the number of users in the store
+func (s *MongoUserStore) CountUsers(ctx context.Context) (int, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	count, err := s.users().CountDocuments(ctx, bson.M{})
+	return int(count), err
+}
+
+// ClaimFirstAdmin makes the user with the given ID an admin if no user has claimed it
+// before. The claim is a single document with a fixed ID, so only one insert can succeed.
+func (s *MongoUserStore) ClaimFirstAdmin(ctx context.Context, id string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = s.bootstrap().InsertOne(ctx, bson.M{"_id": "first_admin", "user_id": objID})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.users().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"admin": true}})
+	return err == nil, err
+}
+
+// ListUsers returns every user in the store, oldest first
+func (s *MongoUserStore) ListUsers(ctx context.Context) ([]User, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+	cursor, err := s.users().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]User, 0)
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// ImportUsers stores users exactly as they are given, replacing any user with the same ID
+func (s *MongoUserStore) ImportUsers(ctx context.Context, users []User) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Replace the users one at a time, so that a taken username stops the import
+	// where it happened
+	for _, user := range users {
+		if user.ID.IsZero() {
+			return fmt.Errorf("user %q has no ID", user.Username)
+		}
+		opts := options.Replace().SetUpsert(true)
+		_, err := s.users().ReplaceOne(ctx, bson.M{"_id": user.ID}, user, opts)
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w: %s", ErrUsernameTaken, user.Username)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateHousehold adds a household to the store and returns the ID of the new household
+func (s *MongoUserStore) CreateHousehold(ctx context.Context, household Household) (string, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	household.ID = primitive.NilObjectID
+	result, err := s.households().InsertOne(ctx, household)
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetHousehold returns the household with the given ID
+func (s *MongoUserStore) GetHousehold(ctx context.Context, id string) (Household, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Household{}, err
+	}
+
+	var household Household
+	err = s.households().FindOne(ctx, bson.M{"_id": objID}).Decode(&household)
+	if err == mongo.ErrNoDocuments {
+		return household, ErrNotFound
+	}
+	if err != nil {
+		return household, err
+	}
+
+	return household, nil
+}
+
+// SetUserHousehold moves a user into the household with the given ID, or out of their
+// household if the ID is empty
+func (s *MongoUserStore) SetUserHousehold(ctx context.Context, userID, householdID string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	// Leaving a household removes the field altogether
+	update := bson.M{"$unset": bson.M{"household_id": ""}}
+	if householdID != "" {
+		householdObjID, err := primitive.ObjectIDFromHex(householdID)
+		if err != nil {
+			return err
+		}
+		update = bson.M{"$set": bson.M{"household_id": householdObjID}}
+	}
+
+	result, err := s.users().UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetHouseholdMembers returns the users in the household with the given ID
+func (s *MongoUserStore) GetHouseholdMembers(ctx context.Context, householdID string) ([]User, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(householdID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"username": 1})
+	cursor, err := s.users().Find(ctx, bson.M{"household_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	members := make([]User, 0)
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// CreateInvitation adds an invitation to join a household and returns its ID
+func (s *MongoUserStore) CreateInvitation(ctx context.Context, invitation Invitation) (string, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Add the invitation, relying on the unique index to catch repeated invitations
+	invitation.ID = primitive.NilObjectID
+	result, err := s.invitations().InsertOne(ctx, invitation)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrAlreadyInvited
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetInvitation returns the invitation with the given ID
+func (s *MongoUserStore) GetInvitation(ctx context.Context, id string) (Invitation, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Invitation{}, ErrNotFound
+	}
+
+	var invitation Invitation
+	err = s.invitations().FindOne(ctx, bson.M{"_id": objID}).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return invitation, ErrNotFound
+	}
+	if err != nil {
+		return invitation, err
+	}
+
+	return invitation, nil
+}
+
+// ListInvitations returns the invitations sent to the user with the given ID, oldest first
+func (s *MongoUserStore) ListInvitations(ctx context.Context, userID string) ([]Invitation, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := s.invitations().Find(ctx, bson.M{"user_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := make([]Invitation, 0)
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// DeleteInvitation removes the invitation with the given ID
+func (s *MongoUserStore) DeleteInvitation(ctx context.Context, id string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	result, err := s.invitations().DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListHouseholds returns every household in the store, oldest first
+func (s *MongoUserStore) ListHouseholds(ctx context.Context) ([]Household, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+	cursor, err := s.households().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	households := make([]Household, 0)
+	if err := cursor.All(ctx, &households); err != nil {
+		return nil, err
+	}
+
+	return households, nil
+}
+
+// ImportHouseholds stores households exactly as they are given, replacing any household
+// with the same ID
+func (s *MongoUserStore) ImportHouseholds(ctx context.Context, households []Household) error {
+	if len(households) == 0 {
+		return nil
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, len(households))
+	for i, household := range households {
+		if household.ID.IsZero() {
+			return fmt.Errorf("household %q has no ID", household.Name)
+		}
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": household.ID}).
+			SetReplacement(household).
+			SetUpsert(true)
+	}
+	_, err := s.households().BulkWrite(ctx, models)
+	return err
+}
+
+// CreateAPIToken adds an API token to the store and returns the ID of the new token
+func (s *MongoUserStore) CreateAPIToken(ctx context.Context, token APIToken) (string, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	token.ID = primitive.NilObjectID
+	result, err := s.apiTokens().InsertOne(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetAPIToken returns the API token with the given hash, noting that it was used
+func (s *MongoUserStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Find the token and record when it was last used in one go
+	var token APIToken
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err := s.apiTokens().FindOneAndUpdate(ctx,
+		bson.M{"token_hash": tokenHash},
+		bson.M{"$set": bson.M{"last_used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return token, ErrNotFound
+	}
+	if err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// ListAPITokens returns the API tokens belonging to the user with the given ID
+func (s *MongoUserStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := s.apiTokens().Find(ctx, bson.M{"user_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := make([]APIToken, 0)
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes the API token with the given ID, as long as it belongs to the
+// user with the given ID
+func (s *MongoUserStore) DeleteAPIToken(ctx context.Context, userID, tokenID string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	tokenObjID, err := primitive.ObjectIDFromHex(tokenID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.apiTokens().DeleteOne(ctx, bson.M{"_id": tokenObjID, "user_id": userObjID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateSession adds a login session to the store
+func (s *MongoUserStore) CreateSession(ctx context.Context, session Session) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := s.sessions().InsertOne(ctx, session)
+	return err
+}
+
+// GetSession returns the unexpired session with the given token hash
+func (s *MongoUserStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// MongoDB only removes expired sessions periodically, so check the expiry here too
+	var session Session
+	filter := bson.M{"_id": tokenHash, "expires_at": bson.M{"$gt": time.Now()}}
+	err := s.sessions().FindOne(ctx, filter).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return session, ErrNotFound
+	}
+	if err != nil {
+		return session, err
+	}
+
+	return session, nil
+}
+
+// DeleteSession removes the session with the given token hash
+func (s *MongoUserStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := s.sessions().DeleteOne(ctx, bson.M{"_id": tokenHash})
+	return err
+}
@@ -0,0 +1,450 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dawsonc/recipes/src/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	testDBName, testURI string
+)
+
+func init() {
+	// Get the database name and uri from environment variables using os
+	testDBName = os.Getenv("TEST_DB_NAME")
+	testURI = os.Getenv("TEST_DB_URI")
+
+	// If the environment variables are not set, use the default values
+	if testDBName == "" {
+		testDBName = "recipes_test"
+	}
+	if testURI == "" {
+		testURI = "mongodb://localhost:27017"
+	}
+}
+
+// Define functions to set up and tear down the test database before and after each
+// test
+func setupTestDB() error {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		return fmt.Errorf("failed to connect to test database: %w", err)
+	}
+
+	// Drop the existing test database (if it exists)
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil && err.Error() != "mongo: database not found" {
+		return fmt.Errorf("failed to drop test database: %w", err)
+	}
+
+	return nil
+}
+
+func teardownTestDB() {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	// Drop the test database
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to drop test database: %v", err)
+	}
+}
+
+// TestRegister tests the Register function with a MongoDB user store
+func TestRegister(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+
+	// The first user should become an admin, but not the second
+	alice, err := users.Register(context.Background(), userStore, "alice", "alice's password")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	if !alice.Admin || alice.Username != "alice" || alice.ID.IsZero() {
+		t.Fatalf("First user was not registered correctly: %v", alice)
+	}
+	bob, err := users.Register(context.Background(), userStore, "bob", "bob's password")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	if bob.Admin {
+		t.Fatalf("Second user should not be an admin: %v", bob)
+	}
+
+	// The password should be stored hashed
+	if bob.PasswordHash == "bob's password" || !users.CheckPassword(bob.PasswordHash, "bob's password") {
+		t.Fatalf("Password was not hashed correctly: %v", bob.PasswordHash)
+	}
+
+	// Usernames can only be used once
+	_, err = users.Register(context.Background(), userStore, "bob", "another password")
+	if !errors.Is(err, users.ErrUsernameTaken) {
+		t.Fatalf("Expected ErrUsernameTaken, got %v", err)
+	}
+
+	// Users can be looked up by username
+	user, err := userStore.GetUserByUsername(context.Background(), "bob")
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.ID != bob.ID {
+		t.Fatalf("Got the wrong user: expected %v, got %v", bob, user)
+	}
+}
+
+// TestRegisterFirstAdmin tests that only one of several users registering at the same
+// time on an empty store becomes an admin
+func TestRegisterFirstAdmin(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+
+	// Register several users at the same time on an empty store
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := users.Register(context.Background(), userStore, fmt.Sprintf("user%d", i), "a password"); err != nil {
+				t.Errorf("Failed to register user: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	all, err := userStore.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	admins := 0
+	for _, user := range all {
+		if user.Admin {
+			admins++
+		}
+	}
+	if len(all) != 10 || admins != 1 {
+		t.Fatalf("Expected ten users and one admin, got %d and %d", len(all), admins)
+	}
+}
+
+// TestLogin tests the Login, Authenticate, and Logout functions with a MongoDB user store
+func TestLogin(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store with a user in it
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+	alice, err := users.Register(context.Background(), userStore, "alice", "alice's password")
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	// Logging in with the wrong password or an unknown user should fail the same way
+	_, _, err = users.Login(context.Background(), userStore, "alice", "wrong password")
+	if !errors.Is(err, users.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	_, _, err = users.Login(context.Background(), userStore, "mallory", "alice's password")
+	if !errors.Is(err, users.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	// Logging in with the right password should give a token for the user
+	token, user, err := users.Login(context.Background(), userStore, "alice", "alice's password")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	if user.ID != alice.ID || token == "" {
+		t.Fatalf("Logged in as the wrong user: %v", user)
+	}
+	user, err = users.Authenticate(context.Background(), userStore, token)
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	if user.ID != alice.ID {
+		t.Fatalf("Authenticated as the wrong user: %v", user)
+	}
+
+	// After logging out, the token should no longer work
+	err = users.Logout(context.Background(), userStore, token)
+	if err != nil {
+		t.Fatalf("Failed to log out: %v", err)
+	}
+	_, err = users.Authenticate(context.Background(), userStore, token)
+	if !errors.Is(err, users.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound after logging out, got %v", err)
+	}
+}
+
+// TestHouseholds tests creating households, inviting members, and removing them
+func TestHouseholds(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store with two users
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+	alice, err := users.Register(context.Background(), userStore, "alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to register alice: %v", err)
+	}
+	if _, err := users.Register(context.Background(), userStore, "bob", "battery staple"); err != nil {
+		t.Fatalf("Failed to register bob: %v", err)
+	}
+
+	// Alice creates a household and invites Bob to it
+	household, err := users.CreateHousehold(context.Background(), userStore, alice, "Home")
+	if err != nil {
+		t.Fatalf("Failed to create household: %v", err)
+	}
+	alice.HouseholdID = household.ID
+	invitation, err := users.InviteHouseholdMember(context.Background(), userStore, alice, "bob")
+	if err != nil {
+		t.Fatalf("Failed to invite bob: %v", err)
+	}
+	if invitation.HouseholdName != "Home" || invitation.InvitedBy != "alice" {
+		t.Errorf("Unexpected invitation: %+v", invitation)
+	}
+
+	// Bob isn't a member until he accepts, and can't be invited twice
+	bob, err := userStore.GetUserByUsername(context.Background(), "bob")
+	if err != nil {
+		t.Fatalf("Failed to get bob: %v", err)
+	}
+	if bob.InHousehold() {
+		t.Errorf("Expected bob not to join before accepting")
+	}
+	_, err = users.InviteHouseholdMember(context.Background(), userStore, alice, "bob")
+	if !errors.Is(err, users.ErrAlreadyInvited) {
+		t.Errorf("Expected ErrAlreadyInvited inviting bob again, got %v", err)
+	}
+	invitations, err := userStore.ListInvitations(context.Background(), bob.ID.Hex())
+	if err != nil || len(invitations) != 1 || invitations[0].ID != invitation.ID {
+		t.Fatalf("Expected bob to have one invitation, got %v, %v", invitations, err)
+	}
+
+	// Only Bob can accept it
+	_, err = users.AcceptInvitation(context.Background(), userStore, alice, invitation.ID.Hex())
+	if !errors.Is(err, users.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound accepting someone else's invitation, got %v", err)
+	}
+	if _, err := users.AcceptInvitation(context.Background(), userStore, bob, invitation.ID.Hex()); err != nil {
+		t.Fatalf("Failed to accept the invitation: %v", err)
+	}
+	bob, err = userStore.GetUserByID(context.Background(), bob.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get bob: %v", err)
+	}
+	if bob.HouseholdID != household.ID {
+		t.Errorf("Expected bob to be in household %v, got %v", household.ID, bob.HouseholdID)
+	}
+	if invitations, _ := userStore.ListInvitations(context.Background(), bob.ID.Hex()); len(invitations) != 0 {
+		t.Errorf("Expected the invitation to be used up, got %v", invitations)
+	}
+
+	// Bob can't be invited again, or create a household of his own
+	_, err = users.InviteHouseholdMember(context.Background(), userStore, alice, "bob")
+	if !errors.Is(err, users.ErrInHousehold) {
+		t.Errorf("Expected ErrInHousehold inviting bob again, got %v", err)
+	}
+	if _, err := users.CreateHousehold(context.Background(), userStore, bob, "Elsewhere"); !errors.Is(err, users.ErrInHousehold) {
+		t.Errorf("Expected ErrInHousehold creating a second household, got %v", err)
+	}
+
+	// Both are members
+	members, err := userStore.GetHouseholdMembers(context.Background(), household.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get members: %v", err)
+	}
+	if len(members) != 2 || members[0].Username != "alice" || members[1].Username != "bob" {
+		t.Errorf("Expected alice and bob to be members, got %v", members)
+	}
+
+	// Removing Bob leaves him outside any household
+	err = users.RemoveHouseholdMember(context.Background(), userStore, household.ID.Hex(), bob.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to remove bob: %v", err)
+	}
+	bob, err = userStore.GetUserByID(context.Background(), bob.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to get bob: %v", err)
+	}
+	if bob.InHousehold() {
+		t.Errorf("Expected bob to have left the household")
+	}
+}
+
+// TestImportUsers tests that users and households can be listed and imported with
+// their IDs and password hashes, for backups
+func TestImportUsers(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store with a user in a household
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+	alice, err := users.Register(context.Background(), userStore, "alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to register alice: %v", err)
+	}
+	household, err := users.CreateHousehold(context.Background(), userStore, alice, "Home")
+	if err != nil {
+		t.Fatalf("Failed to create household: %v", err)
+	}
+
+	// Both can be listed, with alice's password hash
+	listed, err := userStore.ListUsers(context.Background())
+	if err != nil || len(listed) != 1 || listed[0].PasswordHash == "" || listed[0].HouseholdID != household.ID {
+		t.Fatalf("Expected alice in her household, got %v (%v)", listed, err)
+	}
+	households, err := userStore.ListHouseholds(context.Background())
+	if err != nil || len(households) != 1 || households[0].Name != "Home" {
+		t.Fatalf("Expected alice's household, got %v (%v)", households, err)
+	}
+
+	// Importing them into an empty store keeps their IDs, so alice can still log in
+	restoredDBName := testDBName + "_restored"
+	restored, err := users.CreateMongoUserStore(testURI, restoredDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+	defer func() {
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+		if err == nil {
+			client.Database(restoredDBName).Drop(context.Background())
+		}
+	}()
+	if err := restored.ImportHouseholds(context.Background(), households); err != nil {
+		t.Fatalf("Failed to import households: %v", err)
+	}
+	if err := restored.ImportUsers(context.Background(), listed); err != nil {
+		t.Fatalf("Failed to import users: %v", err)
+	}
+	if _, user, err := users.Login(context.Background(), restored, "alice", "correct horse"); err != nil || user.ID != alice.ID {
+		t.Errorf("Expected alice to log in after the import, got %v (%v)", user, err)
+	}
+	if _, err := restored.GetHousehold(context.Background(), household.ID.Hex()); err != nil {
+		t.Errorf("Expected alice's household to be imported, got %v", err)
+	}
+
+	// Importing a different user with a taken username fails
+	impostor := users.User{ID: primitive.NewObjectID(), Username: "alice"}
+	if err := restored.ImportUsers(context.Background(), []users.User{impostor}); !errors.Is(err, users.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+}
+
+// TestAPITokens tests creating, using, and revoking API tokens with a MongoDB user store
+func TestAPITokens(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new user store with an admin and a regular user
+	userStore, err := users.CreateMongoUserStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create user store: %v", err)
+	}
+	if _, err := users.Register(context.Background(), userStore, "admin", "admin's password"); err != nil {
+		t.Fatalf("Failed to register admin: %v", err)
+	}
+	alice, err := users.Register(context.Background(), userStore, "alice", "alice's password")
+	if err != nil {
+		t.Fatalf("Failed to register alice: %v", err)
+	}
+
+	// Regular users can't create admin tokens
+	_, _, err = users.CreateAPIToken(context.Background(), userStore, alice, "backup", users.ScopeAdmin)
+	if !errors.Is(err, users.ErrScopeNotAllowed) {
+		t.Errorf("Expected ErrScopeNotAllowed, got %v", err)
+	}
+
+	// Create a token and use it
+	token, apiToken, err := users.CreateAPIToken(context.Background(), userStore, alice, "import script", users.ScopeReadWrite)
+	if err != nil {
+		t.Fatalf("Failed to create API token: %v", err)
+	}
+	if !users.IsAPIToken(token) {
+		t.Errorf("Expected token %q to have the API token prefix", token)
+	}
+	user, used, err := users.AuthenticateAPIToken(context.Background(), userStore, token)
+	if err != nil {
+		t.Fatalf("Failed to authenticate with API token: %v", err)
+	}
+	if user.ID != alice.ID || used.Scope != users.ScopeReadWrite || used.LastUsedAt == nil {
+		t.Errorf("Expected a used read-write token for alice, got %v for %v", used, user)
+	}
+
+	// The token is listed, but only its hash is stored
+	tokens, err := userStore.ListAPITokens(context.Background(), alice.ID.Hex())
+	if err != nil {
+		t.Fatalf("Failed to list API tokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != apiToken.ID || tokens[0].TokenHash == token {
+		t.Errorf("Expected one hashed token, got %v", tokens)
+	}
+
+	// Once revoked, the token no longer works
+	if err := userStore.DeleteAPIToken(context.Background(), alice.ID.Hex(), apiToken.ID.Hex()); err != nil {
+		t.Fatalf("Failed to revoke API token: %v", err)
+	}
+	_, _, err = users.AuthenticateAPIToken(context.Background(), userStore, token)
+	if !errors.Is(err, users.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after revoking, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package users_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dawsonc/recipes/src/users"
+)
+
+// TestValidateUsername tests the ValidateUsername function
+func TestValidateUsername(t *testing.T) {
+	for _, username := range []string{"bob", "alice.smith", "chef_42", "a-b"} {
+		if err := users.ValidateUsername(username); err != nil {
+			t.Errorf("Expected username %q to be valid, got %v", username, err)
+		}
+	}
+	for _, username := range []string{"", "ab", "has space", "semi;colon", strings.Repeat("a", 33)} {
+		if err := users.ValidateUsername(username); err == nil {
+			t.Errorf("Expected username %q to be invalid", username)
+		}
+	}
+}
+
+// TestValidatePassword tests the ValidatePassword function
+func TestValidatePassword(t *testing.T) {
+	if err := users.ValidatePassword("correct horse battery staple"); err != nil {
+		t.Errorf("Expected password to be valid, got %v", err)
+	}
+	for _, password := range []string{"", "short", strings.Repeat("a", 73)} {
+		if err := users.ValidatePassword(password); err == nil {
+			t.Errorf("Expected password %q to be invalid", password)
+		}
+	}
+}
+
+// TestHashPassword tests the HashPassword and CheckPassword functions
+func TestHashPassword(t *testing.T) {
+	password := "correct horse battery staple"
+	hash, err := users.HashPassword(password)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	// The hash should not contain the password, but should match it
+	if strings.Contains(hash, password) {
+		t.Errorf("Password hash contains the password: %v", hash)
+	}
+	if !users.CheckPassword(hash, password) {
+		t.Errorf("Password does not match its own hash")
+	}
+	if users.CheckPassword(hash, "wrong password") {
+		t.Errorf("Wrong password matches the hash")
+	}
+}
+
+// TestNewToken tests the NewToken and HashToken functions
+func TestNewToken(t *testing.T) {
+	token1, err := users.NewToken()
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	token2, err := users.NewToken()
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Tokens should be unique, and hash consistently to different values
+	if token1 == token2 {
+		t.Errorf("Generated the same token twice: %v", token1)
+	}
+	if users.HashToken(token1) != users.HashToken(token1) {
+		t.Errorf("Token hash is not consistent")
+	}
+	if users.HashToken(token1) == users.HashToken(token2) || users.HashToken(token1) == token1 {
+		t.Errorf("Token hashes are not distinct")
+	}
+}
+
+// TestScopes tests the ValidateScope and ScopeAllows functions
+func TestScopes(t *testing.T) {
+	for _, scope := range []string{users.ScopeRead, users.ScopeReadWrite, users.ScopeAdmin} {
+		if err := users.ValidateScope(scope); err != nil {
+			t.Errorf("Expected scope %q to be valid, got %v", scope, err)
+		}
+	}
+	if err := users.ValidateScope("write"); err == nil {
+		t.Errorf("Expected scope %q to be invalid", "write")
+	}
+
+	// Each scope includes the ones before it
+	tests := []struct {
+		scope, required string
+		allowed         bool
+	}{
+		{users.ScopeRead, users.ScopeRead, true},
+		{users.ScopeRead, users.ScopeReadWrite, false},
+		{users.ScopeReadWrite, users.ScopeRead, true},
+		{users.ScopeReadWrite, users.ScopeAdmin, false},
+		{users.ScopeAdmin, users.ScopeReadWrite, true},
+		{"unknown", users.ScopeRead, false},
+	}
+	for _, test := range tests {
+		if got := users.ScopeAllows(test.scope, test.required); got != test.allowed {
+			t.Errorf("Expected ScopeAllows(%q, %q) to be %v, got %v",
+				test.scope, test.required, test.allowed, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define structs for users and their login sessions
+
+type User struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Username     string             `bson:"username"`
+	PasswordHash string             `bson:"password_hash" json:"-"`
+	Admin        bool               `bson:"admin"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	HouseholdID  primitive.ObjectID `bson:"household_id,omitempty"`
+}
+
+type Household struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// Invitation asks a user to join a household. They only join once they accept it.
+type Invitation struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	HouseholdID   primitive.ObjectID `bson:"household_id"`
+	HouseholdName string             `bson:"household_name"`
+	UserID        primitive.ObjectID `bson:"user_id"`
+	InvitedBy     string             `bson:"invited_by"`
+	CreatedAt     time.Time          `bson:"created_at"`
+}
+
+type Session struct {
+	TokenHash string             `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+// Define errors returned by user stores and the functions in this package
+
+// ErrNotFound is returned when a user or session does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrUsernameTaken is returned when registering a username that is already in use
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// ErrInHousehold is returned when adding a user to a household while they are already
+// in one
+var ErrInHousehold = errors.New("user is already in a household")
+
+// ErrAlreadyInvited is returned when inviting a user to a household that has already
+// invited them
+var ErrAlreadyInvited = errors.New("user has already been invited to this household")
+
+// ErrInvalidCredentials is returned when logging in with the wrong username or password
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Define functions for checking and hashing credentials
+
+// Limits on usernames and passwords
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72 // bcrypt ignores anything longer than this
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// ValidateUsername checks that a username is 3-32 letters, digits, '_', '.' or '-'
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid username %q: must be 3-32 letters, digits, '_', '.' or '-'", username)
+	}
+	return nil
+}
+
+// ValidatePassword checks that a password is long enough to be safe (but not so long
+// that bcrypt would ignore part of it)
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("invalid password: must be %d-%d characters long",
+			MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword hashes a password with bcrypt for storage
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword returns true if the password matches the stored hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
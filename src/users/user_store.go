@@ -0,0 +1,71 @@
+package users
+
+import "context"
+
+// Define an interface for a generic user store
+type UserStore interface {
+	// CreateUser adds a user to the store and returns the ID of the new user. Returns
+	// ErrUsernameTaken if the username is already in use.
+	CreateUser(ctx context.Context, user User) (string, error)
+	// GetUserByID returns the user with the given ID
+	GetUserByID(ctx context.Context, id string) (User, error)
+	// GetUserByUsername returns the user with the given username
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	// CountUsers returns the number of users in the store
+	CountUsers(ctx context.Context) (int, error)
+	// ClaimFirstAdmin makes the user with the given ID an admin, as long as no user has
+	// claimed it before, and reports whether they did. Only one user can ever claim it.
+	ClaimFirstAdmin(ctx context.Context, id string) (bool, error)
+	// ListUsers returns every user in the store, oldest first
+	ListUsers(ctx context.Context) ([]User, error)
+	// ImportUsers stores users exactly as they are given, keeping their IDs and password
+	// hashes, and replacing any user with the same ID. It is for restoring backups.
+	// Returns ErrUsernameTaken if a username belongs to a different user.
+	ImportUsers(ctx context.Context, users []User) error
+
+	// CreateHousehold adds a household to the store and returns the ID of the new
+	// household
+	CreateHousehold(ctx context.Context, household Household) (string, error)
+	// GetHousehold returns the household with the given ID
+	GetHousehold(ctx context.Context, id string) (Household, error)
+	// SetUserHousehold moves a user into the household with the given ID, or out of
+	// their household if the ID is empty
+	SetUserHousehold(ctx context.Context, userID, householdID string) error
+	// GetHouseholdMembers returns the users in the household with the given ID
+	GetHouseholdMembers(ctx context.Context, householdID string) ([]User, error)
+	// CreateInvitation adds an invitation to join a household and returns its ID.
+	// Returns ErrAlreadyInvited if the household has already invited the user.
+	CreateInvitation(ctx context.Context, invitation Invitation) (string, error)
+	// GetInvitation returns the invitation with the given ID
+	GetInvitation(ctx context.Context, id string) (Invitation, error)
+	// ListInvitations returns the invitations sent to the user with the given ID, oldest
+	// first
+	ListInvitations(ctx context.Context, userID string) ([]Invitation, error)
+	// DeleteInvitation removes the invitation with the given ID
+	DeleteInvitation(ctx context.Context, id string) error
+	// ListHouseholds returns every household in the store, oldest first
+	ListHouseholds(ctx context.Context) ([]Household, error)
+	// ImportHouseholds stores households exactly as they are given, replacing any
+	// household with the same ID. It is for restoring backups.
+	ImportHouseholds(ctx context.Context, households []Household) error
+
+	// CreateAPIToken adds an API token to the store and returns the ID of the new token
+	CreateAPIToken(ctx context.Context, token APIToken) (string, error)
+	// GetAPIToken returns the API token with the given hash, noting that it was used
+	GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error)
+	// ListAPITokens returns the API tokens belonging to the user with the given ID
+	ListAPITokens(ctx context.Context, userID string) ([]APIToken, error)
+	// DeleteAPIToken revokes the API token with the given ID, as long as it belongs to
+	// the user with the given ID
+	DeleteAPIToken(ctx context.Context, userID, tokenID string) error
+
+	// CreateSession adds a login session to the store
+	CreateSession(ctx context.Context, session Session) error
+	// GetSession returns the unexpired session with the given token hash
+	GetSession(ctx context.Context, tokenHash string) (Session, error)
+	// DeleteSession removes the session with the given token hash
+	DeleteSession(ctx context.Context, tokenHash string) error
+
+	// Close releases the user store's connections once it is no longer needed
+	Close() error
+}
@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Define the HTTP client that sends deliveries, which can't be pointed at the server's
+// own network
+
+// ErrAddressNotAllowed is returned when a webhook's URL leads to an address that
+// deliveries may not be sent to
+var ErrAddressNotAllowed = errors.New("address not allowed for webhooks")
+
+// newClient returns a client that only connects to public addresses, or to ones in the
+// allowed networks. Addresses are checked as they are dialled, after any DNS lookup, so
+// a host name can't be used to reach a private address. Redirects aren't followed.
+func newClient(timeout time.Duration, allowed []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			return checkAddress(address, allowed)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkAddress returns ErrAddressNotAllowed if the IP address and port is loopback,
+// private, link-local, or unspecified, and not in one of the allowed networks
+func checkAddress(address string, allowed []netip.Prefix) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, address)
+	}
+	ip := addrPort.Addr().Unmap()
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, ip)
+	}
+	return nil
+}
+
+// ParseNetworks parses IP addresses (e.g. "10.0.0.1") and networks (e.g. "10.0.0.0/8")
+// for Options.AllowedNetworks
+func ParseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		var prefix netip.Prefix
+		var err error
+		if strings.Contains(network, "/") {
+			prefix, err = netip.ParsePrefix(network)
+		} else {
+			var ip netip.Addr
+			ip, err = netip.ParseAddr(network)
+			ip = ip.Unmap()
+			prefix = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
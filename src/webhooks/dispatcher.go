@@ -0,0 +1,281 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define a dispatcher that queues recipe events for webhooks and sends them
+
+// Options control how the dispatcher sends deliveries. Zero values use the defaults.
+type Options struct {
+	// Client sends the deliveries. By default it has Timeout, doesn't follow redirects,
+	// and refuses to connect to loopback, private, and link-local addresses outside of
+	// AllowedNetworks.
+	Client *http.Client
+	// AllowedNetworks are private networks that deliveries may be sent to anyway, such
+	// as the one other services on the same network are on
+	AllowedNetworks []netip.Prefix
+	// Timeout is how long a receiver has to answer (10 seconds by default)
+	Timeout time.Duration
+	// MaxAttempts is how many times to try each delivery before giving up (8 by default)
+	MaxAttempts int
+	// BaseDelay is how long to wait before the first retry, doubling after each one
+	// (30 seconds by default), up to MaxDelay (1 hour by default)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// PollInterval is how often to look for deliveries that are due (1 second by default)
+	PollInterval time.Duration
+}
+
+// ViewerFunc returns the viewer that a user's webhooks see recipes as, so that they
+// only hear about the recipes the user can see
+type ViewerFunc func(ctx context.Context, userID string) (recipes.Viewer, error)
+
+// maxResponseBytes is how much of a receiver's response is read before it is ignored
+const maxResponseBytes = 64 << 10
+
+// Dispatcher queues an event for every webhook that wants it, and sends the queued
+// deliveries, retrying failures with exponential backoff. Several servers can share a
+// store: each delivery is only claimed by one of them at a time.
+type Dispatcher struct {
+	store   Store
+	viewers ViewerFunc
+	opts    Options
+}
+
+// NewDispatcher makes a dispatcher for the webhooks in the store
+func NewDispatcher(store Store, viewers ViewerFunc, opts Options) *Dispatcher {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = newClient(opts.Timeout, opts.AllowedNetworks)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = 30 * time.Second
+	}
+	if opts.MaxDelay == 0 {
+		opts.MaxDelay = time.Hour
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second
+	}
+	return &Dispatcher{store: store, viewers: viewers, opts: opts}
+}
+
+// Run queues events from the source and sends deliveries until the context is done
+func (d *Dispatcher) Run(ctx context.Context, events recipes.EventSource) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.follow(ctx, events)
+	}()
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to send webhook deliveries", "error", err)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// follow queues each event from the source, subscribing again whenever the
+// subscription ends early. The source should never drop events (see recipes.Bus.Reliable),
+// since any that are missed in between are never delivered.
+func (d *Dispatcher) follow(ctx context.Context, events recipes.EventSource) {
+	for ctx.Err() == nil {
+		subscription, err := events.Subscribe(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to follow recipe events for webhooks", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(d.opts.PollInterval):
+			}
+			continue
+		}
+
+		for event := range subscription {
+			if err := d.Enqueue(ctx, event); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to queue webhook deliveries",
+					"event", event.Type, "recipe_id", event.RecipeID, "error", err)
+			}
+		}
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "recipe events for webhooks stopped early, so some may have been missed")
+		}
+	}
+}
+
+// Enqueue queues a delivery of the event to every webhook that subscribes to it and
+// whose user can see the recipe
+func (d *Dispatcher) Enqueue(ctx context.Context, event recipes.Event) error {
+	webhooks, err := d.store.WebhooksFor(ctx, event.Type)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s:%s:%d:%d", event.Type, event.RecipeID, event.Revision, event.Time.UnixNano())
+	var errs []error
+	for _, webhook := range webhooks {
+		viewer, err := d.viewers(ctx, webhook.UserID.Hex())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !event.VisibleTo(viewer) {
+			continue
+		}
+
+		delivery := Delivery{
+			WebhookID:   webhook.ID,
+			EventKey:    key,
+			EventType:   event.Type,
+			RecipeID:    event.RecipeID,
+			Payload:     payload,
+			Status:      StatusPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+			Attempts:    []Attempt{},
+		}
+		if err := d.store.Enqueue(ctx, delivery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeliverDue sends every delivery that is due, and returns how many were sent
+// successfully
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		// Claim one delivery at a time, just before sending it, and hold it for long
+		// enough to try, so that no other server sends it while this one still might
+		deliveries, err := d.store.ClaimDue(ctx, time.Now().UTC(), 2*d.opts.Timeout, 1)
+		if err != nil {
+			return delivered, err
+		}
+		if len(deliveries) == 0 {
+			return delivered, nil
+		}
+
+		// Attempts cut short by shutting down don't count; the delivery is claimed
+		// again once its lease runs out
+		delivery := d.attempt(ctx, deliveries[0])
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		if err := d.store.UpdateDelivery(ctx, delivery); err != nil {
+			return delivered, err
+		}
+		if delivery.Status == StatusDelivered {
+			delivered++
+		}
+	}
+}
+
+// attempt tries to send a delivery once, and returns it with the attempt logged and
+// its status and next attempt updated
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) Delivery {
+	now := time.Now().UTC()
+	webhook, err := d.store.GetWebhook(ctx, delivery.WebhookID.Hex())
+	if errors.Is(err, ErrNotFound) {
+		delivery.Status = StatusFailed
+		delivery.Attempts = append(delivery.Attempts, Attempt{Time: now, Error: "webhook was deleted"})
+		return delivery
+	}
+
+	var attempt Attempt
+	if err == nil {
+		attempt = d.send(ctx, webhook, delivery, now)
+	} else {
+		attempt = Attempt{Time: now, Error: err.Error()}
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = StatusDelivered
+	case len(delivery.Attempts) >= d.opts.MaxAttempts:
+		delivery.Status = StatusFailed
+	default:
+		delivery.NextAttempt = now.Add(Backoff(len(delivery.Attempts), d.opts.BaseDelay, d.opts.MaxDelay))
+	}
+	return delivery
+}
+
+// send posts a delivery to the webhook's URL, signed with its secret. Any 2xx status
+// counts as success.
+func (d *Dispatcher) send(ctx context.Context, webhook Webhook, delivery Delivery, now time.Time) Attempt {
+	attempt := Attempt{Time: now}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "recipes-webhooks/1")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, delivery.ID.Hex())
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := d.opts.Client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		attempt.Error = "unexpected status: " + response.Status
+	}
+	return attempt
+}
+
+// Backoff returns how long to wait after the given number of failed attempts: the base
+// delay, doubled for each attempt after the first, but never more than the maximum
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,255 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Define a MongoDB webhook store that implements the Store interface
+type MongoStore struct {
+	client *mongo.Client
+	dbName string
+}
+
+// CreateMongoStore creates a new MongoDB webhook store, keeping webhooks in the
+// "webhooks" collection of the given database and their deliveries in
+// "webhook_deliveries"
+func CreateMongoStore(uri, dbName string) (*MongoStore, error) {
+	// Set client options
+	clientOptions := options.Client().ApplyURI(uri)
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the connection, letting go of the client if it doesn't work
+	err = client.Ping(context.Background(), nil)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	// Create a new webhook store
+	store := &MongoStore{
+		client: client,
+		dbName: dbName,
+	}
+
+	// Webhooks are looked up by the events they want, and deliveries by when they are
+	// due. Each event is only queued once for each webhook.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = store.webhooks().Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"events": 1}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = store.deliveries().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt", Value: 1}}},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "event_key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Close disconnects from MongoDB, waiting for operations in progress to finish. The
+// webhook store can't be used afterwards.
+func (s *MongoStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.client.Disconnect(ctx)
+}
+
+// webhooks returns the collection holding webhooks
+func (s *MongoStore) webhooks() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("webhooks")
+}
+
+// deliveries returns the collection holding the delivery queue
+func (s *MongoStore) deliveries() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("webhook_deliveries")
+}
+
+// CreateWebhook adds a webhook to the store and returns its ID
+func (s *MongoStore) CreateWebhook(ctx context.Context, webhook Webhook) (string, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := s.webhooks().InsertOne(ctx, webhook)
+	if err != nil {
+		return "", err
+	}
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (s *MongoStore) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Webhook{}, ErrNotFound
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+	err = s.webhooks().FindOne(ctx, bson.M{"_id": objID}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Webhook{}, ErrNotFound
+	}
+	return webhook, err
+}
+
+// ListWebhooks returns the webhooks belonging to the user with the given ID, oldest
+// first
+func (s *MongoStore) ListWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.findWebhooks(ctx, bson.M{"user_id": objID})
+}
+
+// WebhooksFor returns every webhook subscribed to the given type of event
+func (s *MongoStore) WebhooksFor(ctx context.Context, eventType string) ([]Webhook, error) {
+	return s.findWebhooks(ctx, bson.M{"events": eventType})
+}
+
+// findWebhooks returns the webhooks matching the filter, oldest first
+func (s *MongoStore) findWebhooks(ctx context.Context, filter bson.M) ([]Webhook, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.webhooks().Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := make([]Webhook, 0)
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (s *MongoStore) DeleteWebhook(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := s.webhooks().DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = s.deliveries().DeleteMany(ctx, bson.M{"webhook_id": objID})
+	return err
+}
+
+// Enqueue adds a delivery to the queue, unless the same event is already queued for the
+// same webhook
+func (s *MongoStore) Enqueue(ctx context.Context, delivery Delivery) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := s.deliveries().InsertOne(ctx, delivery)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimDue returns up to limit pending deliveries that are due, oldest first, pushing
+// back their next attempt by the lease so that nothing else claims them meanwhile
+func (s *MongoStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Claim the deliveries one at a time, so that each is only claimed once even if
+	// several servers are looking
+	filter := bson.M{"status": StatusPending, "next_attempt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt": 1})
+	deliveries := make([]Delivery, 0)
+	for len(deliveries) < limit {
+		var delivery Delivery
+		err := s.deliveries().FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery saves a delivery's status, next attempt, and attempts
+func (s *MongoStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":       delivery.Status,
+		"next_attempt": delivery.NextAttempt,
+		"attempts":     delivery.Attempts,
+	}}
+	_, err := s.deliveries().UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	return err
+}
+
+// ListDeliveries returns the newest deliveries to the webhook with the given ID
+func (s *MongoStore) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error) {
+	objID, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	// Use a context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(int64(limit))
+	cursor, err := s.deliveries().Find(ctx, bson.M{"webhook_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := make([]Delivery, 0)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+)
+
+// Define how deliveries are signed, so that receivers can check where they came from
+
+// Headers sent with every delivery
+const (
+	// EventHeader holds the type of event, e.g. "created"
+	EventHeader = "X-Recipes-Event"
+	// DeliveryHeader holds the ID of the delivery, which stays the same between
+	// attempts so receivers can ignore repeats
+	DeliveryHeader = "X-Recipes-Delivery"
+	// TimestampHeader holds the Unix time the attempt was sent at
+	TimestampHeader = "X-Recipes-Timestamp"
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the timestamp, a ".",
+	// and the body, keyed with the webhook's secret
+	SignatureHeader = "X-Recipes-Signature"
+)
+
+// secretPrefix starts every webhook secret, so they can be spotted if they are leaked
+const secretPrefix = "whsec_"
+
+// NewSecret generates a random secret for signing a webhook's deliveries
+func NewSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Sign returns the signature of a delivery's body sent at the given Unix time
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature and timestamp headers match the body. Receivers
+// should also reject timestamps that are too old, so that deliveries can't be replayed.
+func Verify(secret, signature, timestamp string, body []byte) bool {
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, t, body)))
+}
@@ -0,0 +1,162 @@
+package webhooks_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/webhooks"
+)
+
+var (
+	testDBName, testURI string
+)
+
+func init() {
+	// Get the database name and uri from environment variables using os
+	testDBName = os.Getenv("TEST_DB_NAME")
+	testURI = os.Getenv("TEST_DB_URI")
+
+	// If the environment variables are not set, use the default values
+	if testDBName == "" {
+		testDBName = "recipes_test"
+	}
+	if testURI == "" {
+		testURI = "mongodb://localhost:27017"
+	}
+}
+
+// Define functions to set up and tear down the test database before and after each
+// test
+func setupTestDB() error {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		return fmt.Errorf("failed to connect to test database: %w", err)
+	}
+
+	// Drop the existing test database (if it exists)
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil && err.Error() != "mongo: database not found" {
+		return fmt.Errorf("failed to drop test database: %w", err)
+	}
+
+	return nil
+}
+
+func teardownTestDB() {
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(testURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	// Drop the test database
+	err = client.Database(testDBName).Drop(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to drop test database: %v", err)
+	}
+}
+
+// TestMongoWebhookStore tests managing webhooks and queueing their deliveries with a
+// MongoDB webhook store
+func TestMongoWebhookStore(t *testing.T) {
+	// Set up the test database
+	err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer teardownTestDB()
+
+	// Create a new webhook store
+	store, err := webhooks.CreateMongoStore(testURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to create webhook store: %v", err)
+	}
+	defer store.Close()
+
+	// Create a webhook, which can be found by its user and the events it wants
+	ctx := context.Background()
+	owner := primitive.NewObjectID()
+	id, err := store.CreateWebhook(ctx, webhooks.Webhook{
+		UserID:    owner,
+		URL:       "https://example.com/hook",
+		Events:    []string{recipes.EventCreated, recipes.EventDeleted},
+		Secret:    "whsec_test",
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	webhook, err := store.GetWebhook(ctx, id)
+	if err != nil || webhook.Secret != "whsec_test" || webhook.UserID != owner {
+		t.Fatalf("Failed to get webhook: %+v, %v", webhook, err)
+	}
+	if found, _ := store.ListWebhooks(ctx, owner.Hex()); len(found) != 1 {
+		t.Errorf("Expected the user to have 1 webhook, got %d", len(found))
+	}
+	if found, _ := store.WebhooksFor(ctx, recipes.EventUpdated); len(found) != 0 {
+		t.Errorf("Expected no webhooks for updates, got %d", len(found))
+	}
+
+	// Each event is only queued once
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	delivery := webhooks.Delivery{
+		WebhookID:   webhook.ID,
+		EventKey:    "created:1:1:1",
+		EventType:   recipes.EventCreated,
+		Payload:     []byte(`{"Type":"created"}`),
+		Status:      webhooks.StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+		Attempts:    []webhooks.Attempt{},
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Enqueue(ctx, delivery); err != nil {
+			t.Fatalf("Failed to queue delivery: %v", err)
+		}
+	}
+
+	// A claimed delivery isn't claimed again until its lease runs out
+	claimed, err := store.ClaimDue(ctx, now, time.Minute, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Expected to claim 1 delivery, got %d and %v", len(claimed), err)
+	}
+	if again, _ := store.ClaimDue(ctx, now, time.Minute, 10); len(again) != 0 {
+		t.Errorf("Expected a claimed delivery not to be claimed again, got %d", len(again))
+	}
+
+	// Record an attempt, which shows up in the delivery log
+	delivery = claimed[0]
+	delivery.Status = webhooks.StatusDelivered
+	delivery.Attempts = append(delivery.Attempts, webhooks.Attempt{Time: now, StatusCode: 200})
+	if err := store.UpdateDelivery(ctx, delivery); err != nil {
+		t.Fatalf("Failed to update delivery: %v", err)
+	}
+	deliveries, err := store.ListDeliveries(ctx, id, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d and %v", len(deliveries), err)
+	}
+	if deliveries[0].Status != webhooks.StatusDelivered || len(deliveries[0].Attempts) != 1 {
+		t.Errorf("Expected the delivery to be delivered, got %+v", deliveries[0])
+	}
+
+	// Deleting the webhook deletes its deliveries
+	if err := store.DeleteWebhook(ctx, id); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if _, err := store.GetWebhook(ctx, id); err != webhooks.ErrNotFound {
+		t.Errorf("Expected the webhook to be gone, got %v", err)
+	}
+	if deliveries, _ := store.ListDeliveries(ctx, id, 10); len(deliveries) != 0 {
+		t.Errorf("Expected the deliveries to be gone, got %d", len(deliveries))
+	}
+}
@@ -0,0 +1,396 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+	"github.com/dawsonc/recipes/src/webhooks"
+)
+
+// Define a fake webhook store that keeps everything in memory
+type fakeStore struct {
+	webhooks.Store
+	mu         sync.Mutex
+	webhooks   []webhooks.Webhook
+	deliveries []webhooks.Delivery
+	// leases holds when the latest claim on each delivery runs out
+	leases map[primitive.ObjectID]time.Time
+}
+
+func (s *fakeStore) CreateWebhook(ctx context.Context, webhook webhooks.Webhook) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhook.ID = primitive.NewObjectID()
+	s.webhooks = append(s.webhooks, webhook)
+	return webhook.ID.Hex(), nil
+}
+
+func (s *fakeStore) GetWebhook(ctx context.Context, id string) (webhooks.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, webhook := range s.webhooks {
+		if webhook.ID.Hex() == id {
+			return webhook, nil
+		}
+	}
+	return webhooks.Webhook{}, webhooks.ErrNotFound
+}
+
+func (s *fakeStore) WebhooksFor(ctx context.Context, eventType string) ([]webhooks.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found []webhooks.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.Subscribes(eventType) {
+			found = append(found, webhook)
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeStore) Enqueue(ctx context.Context, delivery webhooks.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queued := range s.deliveries {
+		if queued.WebhookID == delivery.WebhookID && queued.EventKey == delivery.EventKey {
+			return nil
+		}
+	}
+	delivery.ID = primitive.NewObjectID()
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+func (s *fakeStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhooks.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []webhooks.Delivery
+	for i := range s.deliveries {
+		delivery := &s.deliveries[i]
+		if len(claimed) < limit && delivery.Status == webhooks.StatusPending && !delivery.NextAttempt.After(now) {
+			delivery.NextAttempt = now.Add(lease)
+			claimed = append(claimed, *delivery)
+			if s.leases == nil {
+				s.leases = make(map[primitive.ObjectID]time.Time)
+			}
+			s.leases[delivery.ID] = delivery.NextAttempt
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) UpdateDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == delivery.ID {
+			s.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+// due makes every pending delivery due straight away, as if the backoff had passed
+func (s *fakeStore) due() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		s.deliveries[i].NextAttempt = time.Time{}
+	}
+}
+
+// loopback lets deliveries reach test receivers, which listen on the loopback address
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+// viewers lets each user see their own recipes
+func viewers(ctx context.Context, userID string) (recipes.Viewer, error) {
+	return recipes.Viewer{UserID: userID}, nil
+}
+
+// TestWebhookValidate tests that webhooks need an http or https URL and known events
+func TestWebhookValidate(t *testing.T) {
+	tests := []struct {
+		webhook webhooks.Webhook
+		valid   bool
+	}{
+		{webhooks.Webhook{URL: "https://example.com/hook", Events: []string{recipes.EventCreated}}, true},
+		{webhooks.Webhook{URL: "http://10.0.0.5:8123/api/webhook/recipes", Events: []string{recipes.EventUpdated, recipes.EventDeleted}}, true},
+		{webhooks.Webhook{URL: "example.com/hook", Events: []string{recipes.EventCreated}}, false},
+		{webhooks.Webhook{URL: "ftp://example.com/hook", Events: []string{recipes.EventCreated}}, false},
+		{webhooks.Webhook{URL: "https://example.com/hook"}, false},
+		{webhooks.Webhook{URL: "https://example.com/hook", Events: []string{"cooked"}}, false},
+	}
+	for _, test := range tests {
+		err := test.webhook.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", test.webhook, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected %+v to be invalid", test.webhook)
+		}
+	}
+}
+
+// TestSignature tests that signatures only verify with the same secret, time, and body
+func TestSignature(t *testing.T) {
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		t.Fatalf("Failed to make a secret: %v", err)
+	}
+	if !strings.HasPrefix(secret, "whsec_") {
+		t.Errorf("Expected the secret to start with whsec_, got %q", secret)
+	}
+
+	body := []byte(`{"Type":"created"}`)
+	signature := webhooks.Sign(secret, 1700000000, body)
+	if !webhooks.Verify(secret, signature, "1700000000", body) {
+		t.Errorf("Expected signature %q to verify", signature)
+	}
+	if webhooks.Verify("whsec_other", signature, "1700000000", body) {
+		t.Error("Expected a different secret not to verify")
+	}
+	if webhooks.Verify(secret, signature, "1700000001", body) {
+		t.Error("Expected a different timestamp not to verify")
+	}
+	if webhooks.Verify(secret, signature, "1700000000", []byte(`{"Type":"deleted"}`)) {
+		t.Error("Expected a different body not to verify")
+	}
+}
+
+// TestBackoff tests that the delay doubles after each attempt up to the maximum
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, delay := range expected {
+		if got := webhooks.Backoff(i+1, 30*time.Second, 5*time.Minute); got != delay {
+			t.Errorf("Expected a delay of %v after %d attempts, got %v", delay, i+1, got)
+		}
+	}
+}
+
+// TestDispatcher tests that deliveries are signed, retried until they succeed, and
+// logged
+func TestDispatcher(t *testing.T) {
+	// Make a receiver that fails the first time it is called
+	var mu sync.Mutex
+	var calls int
+	var bodies []string
+	secret := "whsec_test"
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if !webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), r.Header.Get(webhooks.TimestampHeader), body) {
+			t.Errorf("Expected a valid signature, got %q", r.Header.Get(webhooks.SignatureHeader))
+		}
+		if r.Header.Get(webhooks.EventHeader) != recipes.EventCreated {
+			t.Errorf("Expected a created event, got %q", r.Header.Get(webhooks.EventHeader))
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		bodies = append(bodies, string(body))
+	}))
+	defer receiver.Close()
+
+	// Subscribe to new recipes
+	ctx := context.Background()
+	store := &fakeStore{}
+	owner := primitive.NewObjectID()
+	store.CreateWebhook(ctx, webhooks.Webhook{UserID: owner, URL: receiver.URL, Events: []string{recipes.EventCreated}, Secret: secret})
+	dispatcher := webhooks.NewDispatcher(store, viewers, webhooks.Options{BaseDelay: time.Minute, AllowedNetworks: loopback})
+
+	// Queue the same event twice, and an event the webhook doesn't want
+	recipe := recipes.Recipe{ID: primitive.NewObjectID(), Revision: 1, Visibility: recipes.VisibilityPrivate, Owner: owner.Hex()}
+	event := recipes.NewEvent(recipes.EventCreated, recipe, "alice", time.Now())
+	for _, e := range []recipes.Event{event, event, recipes.NewEvent(recipes.EventUpdated, recipe, "alice", time.Now())} {
+		if err := dispatcher.Enqueue(ctx, e); err != nil {
+			t.Fatalf("Failed to queue event: %v", err)
+		}
+	}
+	if len(store.deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(store.deliveries))
+	}
+
+	// The first attempt fails, and is retried after the backoff
+	delivered, err := dispatcher.DeliverDue(ctx)
+	if err != nil || delivered != 0 {
+		t.Fatalf("Expected nothing to be delivered, got %d and %v", delivered, err)
+	}
+	delivery := store.deliveries[0]
+	if delivery.Status != webhooks.StatusPending || delivery.NextAttempt.Before(time.Now().Add(50*time.Second)) {
+		t.Errorf("Expected the delivery to be retried in a minute, got %s at %v", delivery.Status, delivery.NextAttempt)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the failed attempt to be logged, got %+v", delivery.Attempts)
+	}
+	if delivered, _ := dispatcher.DeliverDue(ctx); delivered != 0 {
+		t.Errorf("Expected nothing to be due yet, got %d deliveries", delivered)
+	}
+
+	// The second succeeds
+	store.due()
+	delivered, err = dispatcher.DeliverDue(ctx)
+	if err != nil || delivered != 1 {
+		t.Fatalf("Expected 1 delivery, got %d and %v", delivered, err)
+	}
+	delivery = store.deliveries[0]
+	if delivery.Status != webhooks.StatusDelivered || len(delivery.Attempts) != 2 || delivery.Attempts[1].StatusCode != http.StatusOK {
+		t.Errorf("Expected the delivery to be delivered on the second attempt, got %+v", delivery)
+	}
+
+	// The receiver got the event
+	var received recipes.Event
+	if len(bodies) != 1 || json.Unmarshal([]byte(bodies[0]), &received) != nil {
+		t.Fatalf("Expected the event as JSON, got %q", bodies)
+	}
+	if received.RecipeID != recipe.ID.Hex() || received.Actor != "alice" {
+		t.Errorf("Expected the recipe's event, got %+v", received)
+	}
+}
+
+// TestDispatcherGivesUp tests that deliveries fail after too many attempts, and that
+// webhooks only hear about the recipes their user can see
+func TestDispatcherGivesUp(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	store := &fakeStore{}
+	owner := primitive.NewObjectID()
+	store.CreateWebhook(ctx, webhooks.Webhook{UserID: owner, URL: receiver.URL, Events: []string{recipes.EventDeleted}, Secret: "whsec_test"})
+	dispatcher := webhooks.NewDispatcher(store, viewers, webhooks.Options{MaxAttempts: 3, AllowedNetworks: loopback})
+
+	// Someone else's private recipe is never queued
+	hidden := recipes.Recipe{ID: primitive.NewObjectID(), Visibility: recipes.VisibilityPrivate, Owner: primitive.NewObjectID().Hex()}
+	own := recipes.Recipe{ID: primitive.NewObjectID(), Visibility: recipes.VisibilityPrivate, Owner: owner.Hex()}
+	dispatcher.Enqueue(ctx, recipes.NewEvent(recipes.EventDeleted, hidden, "bob", time.Now()))
+	dispatcher.Enqueue(ctx, recipes.NewEvent(recipes.EventDeleted, own, "alice", time.Now()))
+	if len(store.deliveries) != 1 || store.deliveries[0].RecipeID != own.ID.Hex() {
+		t.Fatalf("Expected only the user's own recipe to be queued, got %+v", store.deliveries)
+	}
+
+	for i := 0; i < 3; i++ {
+		store.due()
+		dispatcher.DeliverDue(ctx)
+	}
+	delivery := store.deliveries[0]
+	if delivery.Status != webhooks.StatusFailed || len(delivery.Attempts) != 3 {
+		t.Errorf("Expected the delivery to fail after 3 attempts, got %s after %d", delivery.Status, len(delivery.Attempts))
+	}
+	if delivered, _ := dispatcher.DeliverDue(ctx); delivered != 0 || len(store.deliveries[0].Attempts) != 3 {
+		t.Error("Expected a failed delivery not to be tried again")
+	}
+}
+
+// TestDispatcherLeases tests that each delivery is sent before its claim runs out, even
+// when the receiver is slow and many are due at once
+func TestDispatcherLeases(t *testing.T) {
+	var mu sync.Mutex
+	answered := make(map[string]time.Time)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(40 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		answered[r.Header.Get(webhooks.DeliveryHeader)] = time.Now().UTC()
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	store := &fakeStore{}
+	owner := primitive.NewObjectID()
+	store.CreateWebhook(ctx, webhooks.Webhook{UserID: owner, URL: receiver.URL, Events: []string{recipes.EventCreated}, Secret: "whsec_test"})
+	dispatcher := webhooks.NewDispatcher(store, viewers, webhooks.Options{Timeout: 50 * time.Millisecond, AllowedNetworks: loopback})
+	for i := 0; i < 5; i++ {
+		recipe := recipes.Recipe{ID: primitive.NewObjectID(), Owner: owner.Hex()}
+		dispatcher.Enqueue(ctx, recipes.NewEvent(recipes.EventCreated, recipe, "alice", time.Now()))
+	}
+
+	if delivered, err := dispatcher.DeliverDue(ctx); err != nil || delivered != 5 {
+		t.Fatalf("Expected 5 deliveries, got %d and %v", delivered, err)
+	}
+	for id, lease := range store.leases {
+		if at, ok := answered[id.Hex()]; !ok || at.After(lease) {
+			t.Errorf("Expected delivery %s to be answered before its lease ran out at %v, got %v", id.Hex(), lease, at)
+		}
+	}
+}
+
+// TestDispatcherBlocksPrivateAddresses tests that deliveries aren't sent to the server's
+// own network unless it is allowed, and that redirects aren't followed
+func TestDispatcherBlocksPrivateAddresses(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	owner := primitive.NewObjectID()
+	recipe := recipes.Recipe{ID: primitive.NewObjectID(), Visibility: recipes.VisibilityPrivate, Owner: owner.Hex()}
+	deliver := func(url string, allowed []netip.Prefix) webhooks.Delivery {
+		store := &fakeStore{}
+		store.CreateWebhook(ctx, webhooks.Webhook{UserID: owner, URL: url, Events: []string{recipes.EventCreated}, Secret: "whsec_test"})
+		dispatcher := webhooks.NewDispatcher(store, viewers, webhooks.Options{AllowedNetworks: allowed})
+		dispatcher.Enqueue(ctx, recipes.NewEvent(recipes.EventCreated, recipe, "alice", time.Now()))
+		dispatcher.DeliverDue(ctx)
+		return store.deliveries[0]
+	}
+
+	// Loopback addresses are refused by default, whether given directly or by name
+	for _, url := range []string{receiver.URL, strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)} {
+		delivery := deliver(url, nil)
+		if delivery.Status != webhooks.StatusPending || !strings.Contains(delivery.Attempts[0].Error, "not allowed") {
+			t.Errorf("Expected %s to be refused, got %+v", url, delivery.Attempts)
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("Expected the receiver not to be called, got %v", calls)
+	}
+
+	// Redirects fail the attempt rather than being followed
+	delivery := deliver(receiver.URL+"/redirect", loopback)
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusTemporaryRedirect || delivery.Status != webhooks.StatusPending {
+		t.Errorf("Expected the redirect to fail the attempt, got %+v", delivery.Attempts)
+	}
+	if len(calls) != 1 || calls[0] != "/redirect" {
+		t.Errorf("Expected the redirect not to be followed, got %v", calls)
+	}
+}
+
+// TestParseNetworks tests that addresses and networks can be allowed for webhooks
+func TestParseNetworks(t *testing.T) {
+	networks, err := webhooks.ParseNetworks([]string{"10.0.0.5", "192.168.1.7/24", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("Failed to parse networks: %v", err)
+	}
+	expected := []string{"10.0.0.5/32", "192.168.1.0/24", "fd00::/8"}
+	for i, network := range networks {
+		if network.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], network)
+		}
+	}
+	if _, err := webhooks.ParseNetworks([]string{"intranet"}); err == nil {
+		t.Error("Expected a host name to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/dawsonc/recipes/src/recipes"
+)
+
+// Define webhooks, which tell other services about changes to recipes
+
+// Webhook sends the events it subscribes to to a URL, for the recipes its user can see
+type Webhook struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	UserID primitive.ObjectID `bson:"user_id"`
+	URL    string             `bson:"url"`
+	// Events lists the types of recipe event to send (see recipes.EventCreated etc.)
+	Events []string `bson:"events"`
+	// Secret signs each delivery, so the receiver can check it came from this server.
+	// It is only shown when the webhook is created.
+	Secret    string    `bson:"secret" json:"-"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// Subscribes returns true if the webhook wants events of the given type
+func (webhook Webhook) Subscribes(eventType string) bool {
+	return contains(webhook.Events, eventType)
+}
+
+// Statuses of a delivery
+const (
+	// StatusPending deliveries are waiting for their next attempt
+	StatusPending = "pending"
+	// StatusDelivered deliveries were accepted by the receiver
+	StatusDelivered = "delivered"
+	// StatusFailed deliveries ran out of attempts, or their webhook was deleted
+	StatusFailed = "failed"
+)
+
+// Delivery is a single event to send to a webhook, along with how sending it has gone
+type Delivery struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	WebhookID primitive.ObjectID `bson:"webhook_id"`
+	// EventKey identifies the event, so that it is only queued once for each webhook
+	// even if several servers see it
+	EventKey  string `bson:"event_key" json:"-"`
+	EventType string `bson:"event_type"`
+	RecipeID  string `bson:"recipe_id"`
+	// Payload is the body sent to the webhook: the event as JSON
+	Payload     json.RawMessage `bson:"payload"`
+	Status      string          `bson:"status"`
+	NextAttempt time.Time       `bson:"next_attempt"`
+	CreatedAt   time.Time       `bson:"created_at"`
+	// Attempts logs each try at sending the delivery, oldest first
+	Attempts []Attempt `bson:"attempts"`
+}
+
+// Attempt records a single try at sending a delivery
+type Attempt struct {
+	Time time.Time `bson:"time"`
+	// StatusCode is the HTTP status the receiver answered with, if it answered
+	StatusCode int    `bson:"status_code,omitempty"`
+	Error      string `bson:"error,omitempty"`
+}
+
+// Limits on the number of deliveries returned by a query
+const (
+	DefaultDeliveryLimit = 50
+	MaxDeliveryLimit     = 500
+)
+
+// ErrNotFound is returned when a webhook does not exist
+var ErrNotFound = errors.New("webhook not found")
+
+// eventTypes lists the types of event that webhooks can subscribe to
+var eventTypes = []string{recipes.EventCreated, recipes.EventUpdated, recipes.EventDeleted}
+
+// Validate checks that the webhook has a URL to send to and knows what it wants
+func (webhook Webhook) Validate() error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an absolute http or https URL", webhook.URL)
+	}
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("no events: expected some of %v", eventTypes)
+	}
+	for _, eventType := range webhook.Events {
+		if !contains(eventTypes, eventType) {
+			return fmt.Errorf("invalid event %q: expected one of %v", eventType, eventTypes)
+		}
+	}
+	return nil
+}
+
+// contains returns true if the given value is in the given slice
+func contains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Define an interface for a generic webhook store, which also holds the queue of
+// deliveries
+type Store interface {
+	// CreateWebhook adds a webhook to the store and returns its ID
+	CreateWebhook(ctx context.Context, webhook Webhook) (string, error)
+	// GetWebhook returns the webhook with the given ID
+	GetWebhook(ctx context.Context, id string) (Webhook, error)
+	// ListWebhooks returns the webhooks belonging to the user with the given ID
+	ListWebhooks(ctx context.Context, userID string) ([]Webhook, error)
+	// WebhooksFor returns every webhook subscribed to the given type of event
+	WebhooksFor(ctx context.Context, eventType string) ([]Webhook, error)
+	// DeleteWebhook removes a webhook along with its deliveries
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// Enqueue adds a delivery to the queue, unless the same event is already queued for
+	// the same webhook
+	Enqueue(ctx context.Context, delivery Delivery) error
+	// ClaimDue returns up to limit pending deliveries whose next attempt is due by now,
+	// holding each of them back for the lease so that nothing else sends them meanwhile.
+	// Deliveries that aren't updated before the lease runs out are claimed again.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// UpdateDelivery saves a delivery's status, next attempt, and attempts
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	// ListDeliveries returns the newest deliveries to the webhook with the given ID
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error)
+
+	// Close releases the store's connections once it is no longer needed
+	Close() error
+}